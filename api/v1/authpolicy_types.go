@@ -24,6 +24,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	authorinov1beta3 "github.com/kuadrant/authorino/api/v1beta3"
 	"github.com/kuadrant/policy-machinery/machinery"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
@@ -72,16 +73,14 @@ func (p *AuthPolicy) GetLocator() string {
 
 // Deprecated: Use GetTargetRefs instead
 func (p *AuthPolicy) GetTargetRef() gatewayapiv1alpha2.LocalPolicyTargetReference {
+	if len(p.Spec.TargetRefs) > 0 {
+		return p.Spec.TargetRefs[0].LocalPolicyTargetReference
+	}
 	return p.Spec.TargetRef.LocalPolicyTargetReference
 }
 
 func (p *AuthPolicy) GetTargetRefs() []machinery.PolicyTargetReference {
-	return []machinery.PolicyTargetReference{
-		machinery.LocalPolicyTargetReferenceWithSectionName{
-			LocalPolicyTargetReferenceWithSectionName: p.Spec.TargetRef,
-			PolicyNamespace: p.Namespace,
-		},
-	}
+	return policyTargetRefs(p.Namespace, p.Spec.TargetRef, p.Spec.TargetRefs)
 }
 
 func (p *AuthPolicy) GetMergeStrategy() machinery.MergeStrategy {
@@ -94,6 +93,28 @@ func (p *AuthPolicy) GetMergeStrategy() machinery.MergeStrategy {
 	return AtomicDefaultsMergeStrategy
 }
 
+// GetPathSelectors returns the selectors that restrict the explicit defaults or overrides of the policy to some routes
+func (p *AuthPolicy) GetPathSelectors() PathSelectors {
+	if spec := p.Spec.Defaults; spec != nil {
+		return spec.PathSelectors
+	}
+	if spec := p.Spec.Overrides; spec != nil {
+		return spec.PathSelectors
+	}
+	return PathSelectors{}
+}
+
+// GetRuleStrategies returns the merge strategies of individual rules set in the explicit defaults or overrides of the policy
+func (p *AuthPolicy) GetRuleStrategies() map[string]RuleMergeStrategy {
+	if spec := p.Spec.Defaults; spec != nil {
+		return spec.RuleStrategies
+	}
+	if spec := p.Spec.Overrides; spec != nil {
+		return spec.RuleStrategies
+	}
+	return nil
+}
+
 func (p *AuthPolicy) Merge(other machinery.Policy) machinery.Policy {
 	source, ok := other.(*AuthPolicy)
 	if !ok {
@@ -289,17 +310,40 @@ func (p *AuthPolicy) Kind() string {
 	return AuthPolicyGroupKind.Kind
 }
 
+// InShadowMode tells whether the decisions of the policy are reported without being enforced
+func (p *AuthPolicy) InShadowMode() bool {
+	return p.Spec.Mode == EnforcementModeShadow
+}
+
 // +kubebuilder:validation:XValidation:rule="!(has(self.defaults) && (has(self.patterns) || has(self.when) || has(self.rules)))",message="Implicit and explicit defaults are mutually exclusive"
 // +kubebuilder:validation:XValidation:rule="!(has(self.overrides) && (has(self.patterns) || has(self.when) || has(self.rules)))",message="Implicit defaults and explicit overrides are mutually exclusive"
 // +kubebuilder:validation:XValidation:rule="!(has(self.overrides) && has(self.defaults))",message="Explicit overrides and explicit defaults are mutually exclusive"
 // +kubebuilder:validation:XValidation:rule="!(has(self.overrides) || has(self.defaults)) ? has(self.rules) && ((has(self.rules.authentication) && size(self.rules.authentication) > 0) || (has(self.rules.metadata) && size(self.rules.metadata) > 0) || (has(self.rules.authorization) && size(self.rules.authorization) > 0) || (has(self.rules.response) && (has(self.rules.response.unauthenticated) || has(self.rules.response.unauthorized) || (has(self.rules.response.success) && (size(self.rules.response.success.headers) > 0 ||  size(self.rules.response.success.filters) > 0)))) || (has(self.rules.callbacks) && size(self.rules.callbacks) > 0)) : true",message="At least one spec.rules must be defined"
 // +kubebuilder:validation:XValidation:rule="has(self.defaults) ? has(self.defaults.rules) && ((has(self.defaults.rules.authentication) && size(self.defaults.rules.authentication) > 0) || (has(self.defaults.rules.metadata) && size(self.defaults.rules.metadata) > 0) || (has(self.defaults.rules.authorization) && size(self.defaults.rules.authorization) > 0) || (has(self.defaults.rules.response) && (has(self.defaults.rules.response.unauthenticated) || has(self.defaults.rules.response.unauthorized) || (has(self.defaults.rules.response.success) && (size(self.defaults.rules.response.success.headers) > 0 ||  size(self.defaults.rules.response.success.filters) > 0)))) || (has(self.defaults.rules.callbacks) && size(self.defaults.rules.callbacks) > 0)) : true",message="At least one spec.defaults.rules must be defined"
 // +kubebuilder:validation:XValidation:rule="has(self.overrides) ? has(self.overrides.rules) && ((has(self.overrides.rules.authentication) && size(self.overrides.rules.authentication) > 0) || (has(self.overrides.rules.metadata) && size(self.overrides.rules.metadata) > 0) || (has(self.overrides.rules.authorization) && size(self.overrides.rules.authorization) > 0) || (has(self.overrides.rules.response) && (has(self.overrides.rules.response.unauthenticated) || has(self.overrides.rules.response.unauthorized) || (has(self.overrides.rules.response.success) && (size(self.overrides.rules.response.success.headers) > 0 ||  size(self.overrides.rules.response.success.filters) > 0)))) || (has(self.overrides.rules.callbacks) && size(self.overrides.rules.callbacks) > 0)) : true",message="At least one spec.overrides.rules must be defined"
+// +kubebuilder:validation:XValidation:rule="has(self.targetRef) != has(self.targetRefs)",message="Exactly one of targetRef or targetRefs must be set"
 type AuthPolicySpec struct {
 	// Reference to the object to which this policy applies.
 	// +kubebuilder:validation:XValidation:rule="self.group == 'gateway.networking.k8s.io'",message="Invalid targetRef.group. The only supported value is 'gateway.networking.k8s.io'"
 	// +kubebuilder:validation:XValidation:rule="self.kind == 'HTTPRoute' || self.kind == 'GRPCRoute' || self.kind == 'Gateway'",message="Invalid targetRef.kind. The only supported values are 'HTTPRoute', 'GRPCRoute', and 'Gateway'"
-	TargetRef gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRef"`
+	// +optional
+	TargetRef gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRef,omitzero"`
+
+	// References to the objects to which this policy applies. Mutually exclusive with targetRef.
+	// A policy attached to more than one object of the same hierarchy (e.g. a Gateway and one of its HTTPRoutes)
+	// is merged only once, at the most specific of those objects.
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:XValidation:rule="self.all(r, r.group == 'gateway.networking.k8s.io')",message="Invalid targetRefs group. The only supported value is 'gateway.networking.k8s.io'"
+	// +kubebuilder:validation:XValidation:rule="self.all(r, r.kind == 'HTTPRoute' || r.kind == 'GRPCRoute' || r.kind == 'Gateway')",message="Invalid targetRefs kind. The only supported values are 'HTTPRoute', 'GRPCRoute', and 'Gateway'"
+	// +kubebuilder:validation:XValidation:rule="self.all(r1, self.exists_one(r2, r1.kind == r2.kind && r1.name == r2.name && (has(r1.sectionName) ? has(r2.sectionName) && r1.sectionName == r2.sectionName : !has(r2.sectionName))))",message="targetRefs must be unique"
+	TargetRefs []gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRefs,omitempty"`
+
+	// Mode defines how the data plane acts upon the decisions of the policy: `enforce` (default) or `shadow`.
+	// In shadow mode, the policy is evaluated and its decisions are reported, but requests are never denied.
+	// +optional
+	Mode EnforcementMode `json:"mode,omitempty"`
 
 	// Rules to apply as defaults. Can be overridden by more specific policiy rules lower in the hierarchy and by less specific policy overrides.
 	// Use one of: defaults, overrides, or bare set of policy rules (implicit defaults).
@@ -334,6 +378,16 @@ type MergeableAuthPolicySpec struct {
 	// +kubebuilder:default=atomic
 	Strategy string `json:"strategy,omitempty"`
 
+	// Merge strategies of individual rules of the block, keyed by rule ID (e.g. `authorization#opa-guardrail`).
+	// A `merge` rule yields to the rules with the same ID of the more specific policies, and is added to the effective policy whenever they do not define one, even if the block holds overrides or its strategy is `atomic`.
+	// A `locked` rule shadows the rules with the same ID of the more specific policies, even if the block holds defaults.
+	// +optional
+	RuleStrategies map[string]RuleMergeStrategy `json:"ruleStrategies,omitempty"`
+
+	// Restricts the block to the routes that match the selectors.
+	// If omitted, the block applies to all the routes affected by the policy.
+	PathSelectors `json:""`
+
 	AuthPolicySpecProper `json:""`
 }
 
@@ -517,6 +571,16 @@ type AuthPolicyStatus struct {
 	// +listType=map
 	// +listMapKey=type
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// Targets reports the status of the policy for each of the objects referenced in spec.targetRefs.
+	// Only set when the policy declares spec.targetRefs.
+	// +optional
+	Targets []PolicyTargetStatus `json:"targets,omitempty"`
+
+	// ShadowedRules reports the rules of the policy that are replaced in the effective policies by the rules of other policies,
+	// and the locators of the policies those rules come from.
+	// +optional
+	ShadowedRules []ShadowedRule `json:"shadowedRules,omitempty"`
 }
 
 func (s *AuthPolicyStatus) Equals(other *AuthPolicyStatus, logger logr.Logger) bool {
@@ -535,6 +599,18 @@ func (s *AuthPolicyStatus) Equals(other *AuthPolicyStatus, logger logr.Logger) b
 		return false
 	}
 
+	if !equality.Semantic.DeepEqual(s.Targets, other.Targets) {
+		diff := cmp.Diff(s.Targets, other.Targets)
+		logger.V(1).Info("Targets not equal", "difference", diff)
+		return false
+	}
+
+	if !equality.Semantic.DeepEqual(s.ShadowedRules, other.ShadowedRules) {
+		diff := cmp.Diff(s.ShadowedRules, other.ShadowedRules)
+		logger.V(1).Info("ShadowedRules not equal", "difference", diff)
+		return false
+	}
+
 	return true
 }
 
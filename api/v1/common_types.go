@@ -1,7 +1,11 @@
 package v1
 
 import (
+	"github.com/kuadrant/policy-machinery/machinery"
 	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 )
 
 func NewPredicate(predicate string) Predicate {
@@ -37,6 +41,17 @@ func (w WhenPredicates) Into() []string {
 	return lo.Map(w, func(p Predicate, _ int) string { return p.Predicate })
 }
 
+// EnforcementMode defines how the data plane acts upon the decisions of a policy
+// +kubebuilder:validation:Enum=enforce;shadow
+type EnforcementMode string
+
+const (
+	// EnforcementModeEnforce denies the requests that the policy does not allow
+	EnforcementModeEnforce EnforcementMode = "enforce"
+	// EnforcementModeShadow evaluates the policy and reports its decisions, but never denies requests
+	EnforcementModeShadow EnforcementMode = "shadow"
+)
+
 type MergeableWhenPredicates struct {
 	// Overall conditions for the policy to be enforced.
 	// If omitted, the policy will be enforced at all requests to the protected routes.
@@ -62,3 +77,85 @@ func (p *MergeableWhenPredicates) WithSource(source string) MergeableRule {
 	p.Source = source
 	return p
 }
+
+// PathSelectors restrict a defaults or overrides block to the routes that match them
+type PathSelectors struct {
+	// Selects, by their labels, the routes to which the block applies.
+	// If omitted, the block applies to the routes regardless of their labels.
+	// +optional
+	RouteSelector *metav1.LabelSelector `json:"routeSelector,omitempty"`
+
+	// Selects, by their labels, the namespaces of the routes to which the block applies.
+	// If omitted, the block applies to the routes regardless of their namespace.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// Empty tells whether neither a route selector nor a namespace selector is set
+func (s PathSelectors) Empty() bool {
+	return s.RouteSelector == nil && s.NamespaceSelector == nil
+}
+
+// Matches tells whether a route with the given labels, in a namespace with the given labels, is selected.
+// An invalid selector selects no route.
+func (s PathSelectors) Matches(routeLabels, namespaceLabels map[string]string) bool {
+	return labelSelectorMatches(s.RouteSelector, routeLabels) && labelSelectorMatches(s.NamespaceSelector, namespaceLabels)
+}
+
+func labelSelectorMatches(labelSelector *metav1.LabelSelector, set map[string]string) bool {
+	if labelSelector == nil {
+		return true
+	}
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	return err == nil && selector.Matches(labels.Set(set))
+}
+
+// RuleMergeStrategy defines how an individual rule of a defaults or overrides block is merged with the rules of other policies
+// +kubebuilder:validation:Enum=merge;locked
+type RuleMergeStrategy string
+
+const (
+	// RuleMergeStrategyMerge merges a rule as a default: it yields to the rules with the same ID of more specific policies
+	RuleMergeStrategyMerge RuleMergeStrategy = "merge"
+	// RuleMergeStrategyLocked merges a rule as an override: it shadows the rules with the same ID of more specific policies
+	RuleMergeStrategyLocked RuleMergeStrategy = "locked"
+)
+
+// ShadowedRule reports a rule of a policy that is replaced in the effective policies by the rules of other policies
+type ShadowedRule struct {
+	// ID of the rule, e.g. `authorization#opa-guardrail` or the name of a limit
+	Rule string `json:"rule"`
+
+	// Locators of the policies whose rules shadow the rule
+	ShadowedBy []string `json:"shadowedBy"`
+
+	// IDs of the route paths where the rule is shadowed, from the gateway class down to the route rule
+	// +optional
+	Paths []string `json:"paths,omitempty"`
+}
+
+// PolicyTargetStatus reports the status of a policy for one of the targets listed in its spec.targetRefs
+type PolicyTargetStatus struct {
+	// TargetRef is the reference to the target, as declared in the policy
+	TargetRef gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRef"`
+
+	// Conditions of the policy for the target.
+	// Known .status.targets[].conditions.type are: "Accepted"
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// policyTargetRefs returns the targets of a policy declared either with a list of targetRefs or with a single targetRef
+func policyTargetRefs(namespace string, targetRef gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName, targetRefs []gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName) []machinery.PolicyTargetReference {
+	if len(targetRefs) == 0 {
+		targetRefs = []gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName{targetRef}
+	}
+	return lo.Map(targetRefs, func(ref gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName, _ int) machinery.PolicyTargetReference {
+		return machinery.LocalPolicyTargetReferenceWithSectionName{
+			LocalPolicyTargetReferenceWithSectionName: ref,
+			PolicyNamespace: namespace,
+		}
+	})
+}
@@ -49,11 +49,24 @@ var (
 // +kubebuilder:validation:XValidation:rule="has(oldSelf.delegate) || !has(self.delegate) || self.delegate == false", message="delegate can't be set to true if unset"
 // +kubebuilder:validation:XValidation:rule="!has(oldSelf.delegate) || oldSelf.delegate == false || has(self.delegate)", message="delegate can't be unset if true"
 // +kubebuilder:validation:XValidation:rule="!(has(self.providerRefs) && has(self.delegate) && self.delegate == true)", message="delegate=true and providerRefs are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="has(self.targetRef) != has(self.targetRefs)",message="Exactly one of targetRef or targetRefs must be set"
 type DNSPolicySpec struct {
 	// targetRef identifies an API object to apply policy to.
 	// +kubebuilder:validation:XValidation:rule="self.group == 'gateway.networking.k8s.io'",message="Invalid targetRef.group. The only supported value is 'gateway.networking.k8s.io'"
 	// +kubebuilder:validation:XValidation:rule="self.kind == 'Gateway'",message="Invalid targetRef.kind. The only supported values are 'Gateway'"
-	TargetRef gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRef"`
+	// +optional
+	TargetRef gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRef,omitzero"`
+
+	// References to the objects to which this policy applies. Mutually exclusive with targetRef.
+	// A policy attached to more than one object of the same hierarchy (e.g. a Gateway and one of its HTTPRoutes)
+	// is merged only once, at the most specific of those objects.
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:XValidation:rule="self.all(r, r.group == 'gateway.networking.k8s.io')",message="Invalid targetRefs group. The only supported value is 'gateway.networking.k8s.io'"
+	// +kubebuilder:validation:XValidation:rule="self.all(r, r.kind == 'Gateway')",message="Invalid targetRefs kind. The only supported values are 'Gateway'"
+	// +kubebuilder:validation:XValidation:rule="self.all(r1, self.exists_one(r2, r1.kind == r2.kind && r1.name == r2.name && (has(r1.sectionName) ? has(r2.sectionName) && r1.sectionName == r2.sectionName : !has(r2.sectionName))))",message="targetRefs must be unique"
+	TargetRefs []gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRefs,omitempty"`
 
 	// +optional
 	HealthCheck *dnsv1alpha1.HealthCheckSpec `json:"healthCheck,omitempty"`
@@ -113,6 +126,40 @@ type LoadBalancingSpec struct {
 
 	// defaultGeo specifies if this is the default geo for providers that support setting a default catch all geo endpoint such as Route53.
 	DefaultGeo bool `json:"defaultGeo"`
+
+	// dynamicWeight scales the weight of the endpoints of this cluster by live health and capacity signals,
+	// so traffic drains from a degraded cluster automatically. The weight is the weight of a fully healthy cluster.
+	// +optional
+	DynamicWeight *DynamicWeightSpec `json:"dynamicWeight,omitempty"`
+}
+
+const (
+	// DNSCapacityAnnotation is the annotation of a gateway with the percentage (0-100) of its capacity available
+	// to new traffic
+	DNSCapacityAnnotation = "kuadrant.io/dns-capacity"
+)
+
+// DynamicWeightSpec selects the signals the weight of the endpoints of a cluster is scaled by.
+// The weight is the static weight multiplied by the factor of each selected signal, and never lower than minWeight.
+type DynamicWeightSpec struct {
+	// listenerReadiness sets the weight to minWeight when the gateway or the listener is not programmed.
+	// +optional
+	ListenerReadiness bool `json:"listenerReadiness,omitempty"`
+
+	// healthChecks scales the weight by the ratio of healthy probes of the health check of the DNS record.
+	// Requires spec.healthCheck.
+	// +optional
+	HealthChecks bool `json:"healthChecks,omitempty"`
+
+	// capacity scales the weight by the percentage set in the kuadrant.io/dns-capacity annotation of the gateway.
+	// +optional
+	Capacity bool `json:"capacity,omitempty"`
+
+	// minWeight is the weight of the endpoints of a cluster without capacity or health.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=0
+	// +optional
+	MinWeight int `json:"minWeight,omitempty"`
 }
 
 type GeoCode string
@@ -148,6 +195,11 @@ type DNSPolicyStatus struct {
 	// TotalRecords records the total number of individual DNSRecords managed by this DNSPolicy
 	// +optional
 	TotalRecords int32 `json:"totalRecords,omitempty"`
+
+	// Targets reports the status of the policy for each of the objects referenced in spec.targetRefs.
+	// Only set when the policy declares spec.targetRefs.
+	// +optional
+	Targets []PolicyTargetStatus `json:"targets,omitempty"`
 }
 
 func (s *DNSPolicyStatus) GetConditions() []metav1.Condition {
@@ -179,12 +231,7 @@ type DNSPolicy struct {
 var _ machinery.Policy = &DNSPolicy{}
 
 func (p *DNSPolicy) GetTargetRefs() []machinery.PolicyTargetReference {
-	return []machinery.PolicyTargetReference{
-		machinery.LocalPolicyTargetReferenceWithSectionName{
-			LocalPolicyTargetReferenceWithSectionName: p.Spec.TargetRef,
-			PolicyNamespace: p.Namespace,
-		},
-	}
+	return policyTargetRefs(p.Namespace, p.Spec.TargetRef, p.Spec.TargetRefs)
 }
 
 func (p *DNSPolicy) GetMergeStrategy() machinery.MergeStrategy {
@@ -207,6 +254,9 @@ func (p *DNSPolicy) Validate() error {
 
 // Deprecated: Use GetTargetRefs instead
 func (p *DNSPolicy) GetTargetRef() gatewayapiv1alpha2.LocalPolicyTargetReference {
+	if len(p.Spec.TargetRefs) > 0 {
+		return p.Spec.TargetRefs[0].LocalPolicyTargetReference
+	}
 	return p.Spec.TargetRef.LocalPolicyTargetReference
 }
 
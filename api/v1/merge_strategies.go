@@ -61,6 +61,22 @@ type MergeablePolicy interface {
 	DeepCopyObject() runtime.Object
 }
 
+// PathSelectingPolicy is a policy whose explicit defaults or overrides can be restricted to some of the routes it affects
+// +kubebuilder:object:generate=false
+type PathSelectingPolicy interface {
+	machinery.Policy
+
+	GetPathSelectors() PathSelectors
+}
+
+// RuleStrategiesPolicy is a policy whose explicit defaults or overrides set merge strategies for individual rules
+// +kubebuilder:object:generate=false
+type RuleStrategiesPolicy interface {
+	machinery.Policy
+
+	GetRuleStrategies() map[string]RuleMergeStrategy
+}
+
 // AtomicDefaultsMergeStrategy implements a merge strategy that returns the target Policy if it exists,
 // otherwise it returns the source Policy.
 func AtomicDefaultsMergeStrategy(source, target machinery.Policy) machinery.Policy {
@@ -72,7 +88,7 @@ func AtomicDefaultsMergeStrategy(source, target machinery.Policy) machinery.Poli
 	}
 
 	if mergeableTarget := target.(MergeablePolicy); !mergeableTarget.Empty() {
-		return copyMergeablePolicy(mergeableTarget)
+		return mergeRulesByStrategy(source, target, copyMergeablePolicy(mergeableTarget))
 	}
 
 	return copyMergeablePolicy(source.(MergeablePolicy))
@@ -82,11 +98,11 @@ var _ machinery.MergeStrategy = AtomicDefaultsMergeStrategy
 
 // AtomicOverridesMergeStrategy implements a merge strategy that overrides a target Policy with
 // a source one.
-func AtomicOverridesMergeStrategy(source, _ machinery.Policy) machinery.Policy {
+func AtomicOverridesMergeStrategy(source, target machinery.Policy) machinery.Policy {
 	if source == nil {
 		return nil
 	}
-	return copyMergeablePolicy(source.(MergeablePolicy))
+	return mergeRulesByStrategy(source, target, copyMergeablePolicy(source.(MergeablePolicy)))
 }
 
 var _ machinery.MergeStrategy = AtomicOverridesMergeStrategy
@@ -120,7 +136,7 @@ func PolicyRuleDefaultsMergeStrategy(source, target machinery.Policy) machinery.
 
 	mergedPolicy := targetMergeablePolicy.DeepCopyObject().(MergeablePolicy)
 	mergedPolicy.SetRules(rules)
-	return mergedPolicy
+	return mergeRulesByStrategy(source, target, mergedPolicy)
 }
 
 var _ machinery.MergeStrategy = PolicyRuleDefaultsMergeStrategy
@@ -148,7 +164,7 @@ func PolicyRuleOverridesMergeStrategy(source, target machinery.Policy) machinery
 
 	mergedPolicy := targetMergeablePolicy.DeepCopyObject().(MergeablePolicy)
 	mergedPolicy.SetRules(rules)
-	return mergedPolicy
+	return mergeRulesByStrategy(source, target, mergedPolicy)
 }
 
 var _ machinery.MergeStrategy = PolicyRuleOverridesMergeStrategy
@@ -195,8 +211,9 @@ func EffectivePolicyForPath[T machinery.Policy](path []machinery.Targetable, pre
 
 // PoliciesInPath gathers all policies in a path sorted from the least specific to the most specific.
 // Only policies whose predicate returns true are considered.
+// A policy that targets more than one object in the path is considered only once, at its most specific target.
 func PoliciesInPath(path []machinery.Targetable, predicate func(machinery.Policy) bool) []machinery.Policy {
-	return lo.FlatMap(path, func(targetable machinery.Targetable, _ int) []machinery.Policy {
+	policies := lo.FlatMap(path, func(targetable machinery.Targetable, _ int) []machinery.Policy {
 		policies := lo.FilterMap(targetable.Policies(), func(policy machinery.Policy, _ int) (controller.Object, bool) {
 			o, object := policy.(controller.Object)
 			return o, object && predicate(policy)
@@ -207,6 +224,31 @@ func PoliciesInPath(path []machinery.Targetable, predicate func(machinery.Policy
 			return p
 		})
 	})
+	// keep the last (most specific) occurrence of each policy
+	return lo.Reverse(lo.Uniq(lo.Reverse(policies)))
+}
+
+// PolicySelectsPath tells whether a policy applies to a path, according to the route and namespace selectors of its
+// explicit defaults or overrides. namespaceLabels returns the labels of a namespace given its name.
+// Policies without selectors apply to all their paths; policies with selectors do not apply to paths without a route.
+func PolicySelectsPath(policy machinery.Policy, path []machinery.Targetable, namespaceLabels func(string) map[string]string) bool {
+	pathSelectingPolicy, ok := policy.(PathSelectingPolicy)
+	if !ok {
+		return true
+	}
+	selectors := pathSelectingPolicy.GetPathSelectors()
+	if selectors.Empty() {
+		return true
+	}
+	for _, targetable := range path {
+		switch route := targetable.(type) {
+		case *machinery.HTTPRoute:
+			return selectors.Matches(route.GetLabels(), namespaceLabels(route.GetNamespace()))
+		case *machinery.GRPCRoute:
+			return selectors.Matches(route.GetLabels(), namespaceLabels(route.GetNamespace()))
+		}
+	}
+	return false
 }
 
 func PathID(path []machinery.Targetable) string {
@@ -226,6 +268,41 @@ func mapRuleWithSourceFunc(source machinery.Policy) func(MergeableRule, string)
 	}
 }
 
+// mergeRulesByStrategy applies the merge strategies that a source policy sets for individual rules to the policy
+// resulting from merging the source into a target, on top of the strategy of the block of the source policy.
+// Locked rules of the source replace the rules with the same ID in the merged policy, even if the block holds defaults;
+// rules of the source set to be merged yield to the rules with the same ID of the target, even if the block holds
+// overrides, and are added to the merged policy otherwise.
+func mergeRulesByStrategy(source, target machinery.Policy, mergedPolicy MergeablePolicy) MergeablePolicy {
+	strategicSource, ok := source.(RuleStrategiesPolicy)
+	if !ok || len(strategicSource.GetRuleStrategies()) == 0 {
+		return mergedPolicy
+	}
+	strategies := strategicSource.GetRuleStrategies()
+
+	var targetRules map[string]MergeableRule
+	if mergeableTarget, ok := target.(MergeablePolicy); ok {
+		targetRules = mergeableTarget.Rules()
+	}
+
+	rules := mergedPolicy.Rules()
+	for ruleID, rule := range source.(MergeablePolicy).Rules() {
+		switch strategies[ruleID] {
+		case RuleMergeStrategyLocked:
+			rules[ruleID] = mapRuleWithSourceFunc(source)(rule, ruleID)
+		case RuleMergeStrategyMerge:
+			if targetRule, ok := targetRules[ruleID]; ok {
+				rules[ruleID] = mapRuleWithSourceFunc(target)(targetRule, ruleID)
+			} else {
+				rules[ruleID] = mapRuleWithSourceFunc(source)(rule, ruleID)
+			}
+		}
+	}
+
+	mergedPolicy.SetRules(rules)
+	return mergedPolicy
+}
+
 func copyMergeablePolicy(policy MergeablePolicy) MergeablePolicy {
 	dup := policy.DeepCopyObject().(MergeablePolicy)
 	dup.SetRules(lo.MapValues(dup.Rules(), mapRuleWithSourceFunc(policy)))
@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/kuadrant/kuadrant-operator/internal/cel"
@@ -73,16 +74,14 @@ func (p *RateLimitPolicy) GetLocator() string {
 
 // Deprecated: Use GetTargetRefs instead
 func (p *RateLimitPolicy) GetTargetRef() gatewayapiv1alpha2.LocalPolicyTargetReference {
+	if len(p.Spec.TargetRefs) > 0 {
+		return p.Spec.TargetRefs[0].LocalPolicyTargetReference
+	}
 	return p.Spec.TargetRef.LocalPolicyTargetReference
 }
 
 func (p *RateLimitPolicy) GetTargetRefs() []machinery.PolicyTargetReference {
-	return []machinery.PolicyTargetReference{
-		machinery.LocalPolicyTargetReferenceWithSectionName{
-			LocalPolicyTargetReferenceWithSectionName: p.Spec.TargetRef,
-			PolicyNamespace: p.Namespace,
-		},
-	}
+	return policyTargetRefs(p.Namespace, p.Spec.TargetRef, p.Spec.TargetRefs)
 }
 
 func (p *RateLimitPolicy) GetMergeStrategy() machinery.MergeStrategy {
@@ -95,6 +94,28 @@ func (p *RateLimitPolicy) GetMergeStrategy() machinery.MergeStrategy {
 	return AtomicDefaultsMergeStrategy
 }
 
+// GetPathSelectors returns the selectors that restrict the explicit defaults or overrides of the policy to some routes
+func (p *RateLimitPolicy) GetPathSelectors() PathSelectors {
+	if spec := p.Spec.Defaults; spec != nil {
+		return spec.PathSelectors
+	}
+	if spec := p.Spec.Overrides; spec != nil {
+		return spec.PathSelectors
+	}
+	return PathSelectors{}
+}
+
+// GetRuleStrategies returns the merge strategies of individual rules set in the explicit defaults or overrides of the policy
+func (p *RateLimitPolicy) GetRuleStrategies() map[string]RuleMergeStrategy {
+	if spec := p.Spec.Defaults; spec != nil {
+		return spec.RuleStrategies
+	}
+	if spec := p.Spec.Overrides; spec != nil {
+		return spec.RuleStrategies
+	}
+	return nil
+}
+
 func (p *RateLimitPolicy) Merge(other machinery.Policy) machinery.Policy {
 	source, ok := other.(*RateLimitPolicy)
 	if !ok {
@@ -152,17 +173,52 @@ func (p *RateLimitPolicy) Kind() string {
 	return RateLimitPolicyGroupKind.Kind
 }
 
+// InShadowMode tells whether the decisions of the policy are reported without being enforced
+func (p *RateLimitPolicy) InShadowMode() bool {
+	return p.Spec.Mode == EnforcementModeShadow
+}
+
+// Validate performs validations of the policy that cannot be expressed with CRD validation rules
+func (p *RateLimitPolicy) Validate() error {
+	for name, limit := range p.Spec.Proper().Limits {
+		for _, rate := range limit.Rates {
+			if err := rate.Validate(); err != nil {
+				return fmt.Errorf("invalid limit %q: invalid rate: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
 // +kubebuilder:validation:XValidation:rule="!(has(self.defaults) && has(self.limits))",message="Implicit and explicit defaults are mutually exclusive"
 // +kubebuilder:validation:XValidation:rule="!(has(self.defaults) && has(self.overrides))",message="Overrides and explicit defaults are mutually exclusive"
 // +kubebuilder:validation:XValidation:rule="!(has(self.overrides) && has(self.limits))",message="Overrides and implicit defaults are mutually exclusive"
 // +kubebuilder:validation:XValidation:rule="!(has(self.overrides) || has(self.defaults)) ? has(self.limits) && size(self.limits) > 0 : true",message="At least one spec.limits must be defined"
 // +kubebuilder:validation:XValidation:rule="has(self.overrides) ? has(self.overrides.limits) && size(self.overrides.limits) > 0 : true",message="At least one spec.overrides.limits must be defined"
 // +kubebuilder:validation:XValidation:rule="has(self.defaults) ? has(self.defaults.limits) && size(self.defaults.limits) > 0 : true",message="At least one spec.defaults.limits must be defined"
+// +kubebuilder:validation:XValidation:rule="has(self.targetRef) != has(self.targetRefs)",message="Exactly one of targetRef or targetRefs must be set"
 type RateLimitPolicySpec struct {
 	// Reference to the object to which this policy applies.
 	// +kubebuilder:validation:XValidation:rule="self.group == 'gateway.networking.k8s.io'",message="Invalid targetRef.group. The only supported value is 'gateway.networking.k8s.io'"
 	// +kubebuilder:validation:XValidation:rule="self.kind == 'HTTPRoute' || self.kind == 'GRPCRoute' || self.kind == 'Gateway'",message="Invalid targetRef.kind. The only supported values are 'HTTPRoute', 'GRPCRoute' and 'Gateway'"
-	TargetRef gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRef"`
+	// +optional
+	TargetRef gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRef,omitzero"`
+
+	// References to the objects to which this policy applies. Mutually exclusive with targetRef.
+	// A policy attached to more than one object of the same hierarchy (e.g. a Gateway and one of its HTTPRoutes)
+	// is merged only once, at the most specific of those objects.
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:XValidation:rule="self.all(r, r.group == 'gateway.networking.k8s.io')",message="Invalid targetRefs group. The only supported value is 'gateway.networking.k8s.io'"
+	// +kubebuilder:validation:XValidation:rule="self.all(r, r.kind == 'HTTPRoute' || r.kind == 'GRPCRoute' || r.kind == 'Gateway')",message="Invalid targetRefs kind. The only supported values are 'HTTPRoute', 'GRPCRoute' and 'Gateway'"
+	// +kubebuilder:validation:XValidation:rule="self.all(r1, self.exists_one(r2, r1.kind == r2.kind && r1.name == r2.name && (has(r1.sectionName) ? has(r2.sectionName) && r1.sectionName == r2.sectionName : !has(r2.sectionName))))",message="targetRefs must be unique"
+	TargetRefs []gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRefs,omitempty"`
+
+	// Mode defines how the data plane acts upon the decisions of the policy: `enforce` (default) or `shadow`.
+	// In shadow mode, the policy is evaluated and its decisions are reported, but requests are never denied.
+	// +optional
+	Mode EnforcementMode `json:"mode,omitempty"`
 
 	// Rules to apply as defaults. Can be overridden by more specific policiy rules lower in the hierarchy and by less specific policy overrides.
 	// Use one of: defaults, overrides, or bare set of policy rules (implicit defaults).
@@ -197,6 +253,16 @@ type MergeableRateLimitPolicySpec struct {
 	// +kubebuilder:default=atomic
 	Strategy string `json:"strategy,omitempty"`
 
+	// Merge strategies of individual rules of the block, keyed by rule ID (i.e. the name of the limit).
+	// A `merge` rule yields to the rules with the same ID of the more specific policies, and is added to the effective policy whenever they do not define one, even if the block holds overrides or its strategy is `atomic`.
+	// A `locked` rule shadows the rules with the same ID of the more specific policies, even if the block holds defaults.
+	// +optional
+	RuleStrategies map[string]RuleMergeStrategy `json:"ruleStrategies,omitempty"`
+
+	// Restricts the block to the routes that match the selectors.
+	// If omitted, the block applies to all the routes affected by the policy.
+	PathSelectors `json:""`
+
 	RateLimitPolicySpecProper `json:""`
 }
 
@@ -278,19 +344,73 @@ func (d Duration) Seconds() int {
 	return int(duration.Seconds())
 }
 
+// CalendarPeriod is a calendar-aligned period that resets at the start of each day, week, month or year
+// +kubebuilder:validation:Enum=day;week;month;year
+type CalendarPeriod string
+
+const (
+	CalendarPeriodDay   CalendarPeriod = "day"
+	CalendarPeriodWeek  CalendarPeriod = "week"
+	CalendarPeriodMonth CalendarPeriod = "month"
+	CalendarPeriodYear  CalendarPeriod = "year"
+
+	// DefaultTimeZone is the time zone used to align calendar periods and hour ranges when none is specified
+	DefaultTimeZone = "UTC"
+)
+
+// HourRange defines a daily range of hours [from, to) in which a rate applies.
+// If `to` is less than or equal to `from`, the range wraps around midnight (e.g. from 22 to 6).
+type HourRange struct {
+	// From is the first hour of the day (0-23) in which the rate applies
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=23
+	From int `json:"from"`
+
+	// To is the hour of the day (0-23) at which the rate stops applying (exclusive)
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=23
+	To int `json:"to"`
+}
+
 // Rate defines the actual rate limit that will be used when there is a match
+// +kubebuilder:validation:XValidation:rule="has(self.window) != has(self.calendar)",message="exactly one of window or calendar must be specified"
+// +kubebuilder:validation:XValidation:rule="!has(self.timeZone) || has(self.calendar) || has(self.hours)",message="timeZone requires calendar or hours"
 type Rate struct {
 	// Limit defines the max value allowed for a given period of time
 	Limit int `json:"limit"`
 
 	// Window defines the time period for which the Limit specified above applies.
-	Window Duration `json:"window"`
+	// Mutually exclusive with `calendar`.
+	// +optional
+	Window Duration `json:"window,omitempty"`
+
+	// Calendar defines a calendar-aligned period for which the Limit specified above applies.
+	// Counters reset at the start of each period (e.g. at midnight, on Mondays, on the 1st of the month) in the
+	// configured time zone.
+	// Mutually exclusive with `window`.
+	// +optional
+	Calendar CalendarPeriod `json:"calendar,omitempty"`
+
+	// TimeZone is the IANA time zone name (e.g. Europe/Madrid) used to align calendar periods and hour ranges.
+	// Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Hours restricts the rate to a daily range of hours in the configured time zone.
+	// Use it to define different rates for peak and off-peak hours.
+	// +optional
+	Hours *HourRange `json:"hours,omitempty"`
 }
 
 // ToSeconds converts the rate to to Limitador's Limit format (maxValue, seconds)
+// For calendar-aligned rates, seconds is the length of the longest possible period, as the period itself is part of
+// the counter
 func (r Rate) ToSeconds() (maxValue, seconds int) {
 	maxValue = r.Limit
 	seconds = r.Window.Seconds()
+	if r.Calendar != "" {
+		seconds = r.Calendar.maxSeconds()
+	}
 
 	if r.Limit < 0 {
 		maxValue = 0
@@ -299,6 +419,106 @@ func (r Rate) ToSeconds() (maxValue, seconds int) {
 	return
 }
 
+// Validate checks the time zone and hour range of the rate
+func (r Rate) Validate() error {
+	if r.TimeZone != "" {
+		if _, err := time.LoadLocation(r.TimeZone); err != nil {
+			return fmt.Errorf("invalid time zone %q: %w", r.TimeZone, err)
+		}
+	}
+	if r.Hours != nil && (r.Hours.From < 0 || r.Hours.From > 23 || r.Hours.To < 0 || r.Hours.To > 23) {
+		return fmt.Errorf("invalid hours: from and to must be between 0 and 23")
+	}
+	return nil
+}
+
+// RateDescriptor is a descriptor entry that has to be sent to Limitador for a rate to be evaluated
+type RateDescriptor struct {
+	Key        string
+	Expression string
+}
+
+// Descriptors returns the descriptor entries required by the calendar period and hour range of the rate
+func (r Rate) Descriptors() []RateDescriptor {
+	var descriptors []RateDescriptor
+	if r.Calendar != "" {
+		descriptors = append(descriptors, RateDescriptor{Key: r.periodKey(), Expression: r.Calendar.expression(r.timeZone())})
+	}
+	if r.Hours != nil {
+		descriptors = append(descriptors, RateDescriptor{Key: r.hourKey(), Expression: fmt.Sprintf(`string(request.time.getHours(%q))`, r.timeZone())})
+	}
+	return descriptors
+}
+
+// LimitadorConditions returns the Limitador conditions restricting the rate to its hour range
+func (r Rate) LimitadorConditions() []string {
+	if r.Hours == nil {
+		return nil
+	}
+	hour := fmt.Sprintf(`int(descriptors[0][%q])`, r.hourKey())
+	operator := "&&"
+	if r.Hours.To <= r.Hours.From {
+		operator = "||"
+	}
+	return []string{fmt.Sprintf("%s >= %d %s %s < %d", hour, r.Hours.From, operator, hour, r.Hours.To)}
+}
+
+// LimitadorVariables returns the Limitador variables scoping the counters of the rate to its calendar period
+func (r Rate) LimitadorVariables() []string {
+	if r.Calendar == "" {
+		return nil
+	}
+	return []string{fmt.Sprintf(`descriptors[0][%q]`, r.periodKey())}
+}
+
+func (r Rate) timeZone() string {
+	if r.TimeZone == "" {
+		return DefaultTimeZone
+	}
+	return r.TimeZone
+}
+
+func (r Rate) periodKey() string {
+	return fmt.Sprintf("calendar.%s.%s", r.Calendar, r.timeZone())
+}
+
+func (r Rate) hourKey() string {
+	return fmt.Sprintf("calendar.hour.%s", r.timeZone())
+}
+
+// expression returns a CEL expression that evaluates to a value identifying the current period in the given time zone
+func (p CalendarPeriod) expression(tz string) string {
+	year := fmt.Sprintf(`string(request.time.getFullYear(%q))`, tz)
+	switch p {
+	case CalendarPeriodDay:
+		return fmt.Sprintf(`%s + "-" + string(request.time.getDayOfYear(%q))`, year, tz)
+	case CalendarPeriodWeek:
+		// weeks start on Monday; the timestamp is moved to noon of the Monday of the current week, so that daylight
+		// saving time transitions never move it to a different day
+		monday := fmt.Sprintf(`(request.time + duration(string((12 - request.time.getHours(%[1]q)) * 3600 - ((request.time.getDayOfWeek(%[1]q) + 6) %% 7) * 86400) + "s"))`, tz)
+		return fmt.Sprintf(`string(%[1]s.getFullYear(%[2]q)) + "-" + string(%[1]s.getDayOfYear(%[2]q))`, monday, tz)
+	case CalendarPeriodMonth:
+		return fmt.Sprintf(`%s + "-" + string(request.time.getMonth(%q))`, year, tz)
+	default:
+		return year
+	}
+}
+
+// maxSeconds returns the length of the longest possible period, including daylight saving time transitions
+func (p CalendarPeriod) maxSeconds() int {
+	const day, hour = 86400, 3600
+	switch p {
+	case CalendarPeriodDay:
+		return day + hour
+	case CalendarPeriodWeek:
+		return 7*day + hour
+	case CalendarPeriodMonth:
+		return 31*day + hour
+	default:
+		return 366*day + hour
+	}
+}
+
 // Expression defines one CEL expression
 // Expression can use well known attributes
 // Attributes: https://www.envoyproxy.io/docs/envoy/latest/intro/arch_overview/advanced/attributes
@@ -320,6 +540,16 @@ type RateLimitPolicyStatus struct {
 	// +listType=map
 	// +listMapKey=type
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// Targets reports the status of the policy for each of the objects referenced in spec.targetRefs.
+	// Only set when the policy declares spec.targetRefs.
+	// +optional
+	Targets []PolicyTargetStatus `json:"targets,omitempty"`
+
+	// ShadowedRules reports the rules of the policy that are replaced in the effective policies by the rules of other policies,
+	// and the locators of the policies those rules come from.
+	// +optional
+	ShadowedRules []ShadowedRule `json:"shadowedRules,omitempty"`
 }
 
 func (s *RateLimitPolicyStatus) GetConditions() []metav1.Condition {
@@ -3,7 +3,15 @@
 package v1
 
 import (
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 )
 
 func TestVariablesRewritten(t *testing.T) {
@@ -81,6 +89,18 @@ func TestConvertRateIntoSeconds(t *testing.T) {
 			expectedMaxValue: 5,
 			expectedSeconds:  0,
 		},
+		{
+			name:             "calendar day",
+			rate:             Rate{Limit: 5, Calendar: CalendarPeriodDay},
+			expectedMaxValue: 5,
+			expectedSeconds:  25 * 60 * 60,
+		},
+		{
+			name:             "calendar month",
+			rate:             Rate{Limit: 5, Calendar: CalendarPeriodMonth, TimeZone: "Europe/Madrid"},
+			expectedMaxValue: 5,
+			expectedSeconds:  31*24*60*60 + 60*60,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -95,3 +115,312 @@ func TestConvertRateIntoSeconds(t *testing.T) {
 		})
 	}
 }
+
+func TestRateDescriptors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rate     Rate
+		expected []RateDescriptor
+	}{
+		{
+			name:     "rolling window",
+			rate:     Rate{Limit: 5, Window: Duration("1m")},
+			expected: nil,
+		},
+		{
+			name: "calendar day defaults to UTC",
+			rate: Rate{Limit: 5, Calendar: CalendarPeriodDay},
+			expected: []RateDescriptor{
+				{Key: "calendar.day.UTC", Expression: `string(request.time.getFullYear("UTC")) + "-" + string(request.time.getDayOfYear("UTC"))`},
+			},
+		},
+		{
+			name: "calendar year with hours",
+			rate: Rate{Limit: 5, Calendar: CalendarPeriodYear, TimeZone: "Europe/Madrid", Hours: &HourRange{From: 9, To: 18}},
+			expected: []RateDescriptor{
+				{Key: "calendar.year.Europe/Madrid", Expression: `string(request.time.getFullYear("Europe/Madrid"))`},
+				{Key: "calendar.hour.Europe/Madrid", Expression: `string(request.time.getHours("Europe/Madrid"))`},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(subT *testing.T) {
+			if actual := tc.rate.Descriptors(); !reflect.DeepEqual(actual, tc.expected) {
+				subT.Errorf("descriptors do not match, expected(%v), got (%v)", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRateLimitadorConditionsAndVariables(t *testing.T) {
+	testCases := []struct {
+		name               string
+		rate               Rate
+		expectedConditions []string
+		expectedVariables  []string
+	}{
+		{
+			name: "rolling window",
+			rate: Rate{Limit: 5, Window: Duration("1m")},
+		},
+		{
+			name:               "peak hours",
+			rate:               Rate{Limit: 5, Window: Duration("1m"), Hours: &HourRange{From: 9, To: 18}},
+			expectedConditions: []string{`int(descriptors[0]["calendar.hour.UTC"]) >= 9 && int(descriptors[0]["calendar.hour.UTC"]) < 18`},
+		},
+		{
+			name:               "off-peak hours wrapping midnight",
+			rate:               Rate{Limit: 5, Calendar: CalendarPeriodWeek, TimeZone: "Asia/Tokyo", Hours: &HourRange{From: 18, To: 9}},
+			expectedConditions: []string{`int(descriptors[0]["calendar.hour.Asia/Tokyo"]) >= 18 || int(descriptors[0]["calendar.hour.Asia/Tokyo"]) < 9`},
+			expectedVariables:  []string{`descriptors[0]["calendar.week.Asia/Tokyo"]`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(subT *testing.T) {
+			if actual := tc.rate.LimitadorConditions(); !reflect.DeepEqual(actual, tc.expectedConditions) {
+				subT.Errorf("conditions do not match, expected(%v), got (%v)", tc.expectedConditions, actual)
+			}
+			if actual := tc.rate.LimitadorVariables(); !reflect.DeepEqual(actual, tc.expectedVariables) {
+				subT.Errorf("variables do not match, expected(%v), got (%v)", tc.expectedVariables, actual)
+			}
+		})
+	}
+}
+
+func TestRateValidate(t *testing.T) {
+	if err := (Rate{Limit: 5, Calendar: CalendarPeriodMonth, TimeZone: "America/New_York"}).Validate(); err != nil {
+		t.Errorf("expected valid rate, got %v", err)
+	}
+	if err := (Rate{Limit: 5, Calendar: CalendarPeriodMonth, TimeZone: "Mars/Olympus_Mons"}).Validate(); err == nil {
+		t.Error("expected invalid time zone error")
+	}
+	if err := (Rate{Limit: 5, Window: Duration("1h"), Hours: &HourRange{From: 9, To: 24}}).Validate(); err == nil {
+		t.Error("expected invalid hours error")
+	}
+}
+
+func TestRateLimitPolicyGetTargetRefs(t *testing.T) {
+	policy := &RateLimitPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-rlp", Namespace: "my-ns"},
+		Spec: RateLimitPolicySpec{
+			TargetRefs: []gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName{
+				{LocalPolicyTargetReference: gatewayapiv1alpha2.LocalPolicyTargetReference{Group: gatewayapiv1.GroupName, Kind: "Gateway", Name: "my-gateway"}},
+				{LocalPolicyTargetReference: gatewayapiv1alpha2.LocalPolicyTargetReference{Group: gatewayapiv1.GroupName, Kind: "HTTPRoute", Name: "my-route"}},
+			},
+		},
+	}
+
+	locators := lo.Map(policy.GetTargetRefs(), func(ref machinery.PolicyTargetReference, _ int) string { return ref.GetLocator() })
+	expected := []string{"gateway.gateway.networking.k8s.io:my-ns/my-gateway", "httproute.gateway.networking.k8s.io:my-ns/my-route"}
+	if !reflect.DeepEqual(locators, expected) {
+		t.Errorf("unexpected target refs, expected(%v), got (%v)", expected, locators)
+	}
+	if name := policy.GetTargetRef().Name; name != "my-gateway" {
+		t.Errorf("unexpected target ref, expected(my-gateway), got (%s)", name)
+	}
+}
+
+func TestPoliciesInPath(t *testing.T) {
+	gateway := &machinery.Gateway{Gateway: &gatewayapiv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "my-gateway", Namespace: "my-ns"}}}
+	httpRoute := &machinery.HTTPRoute{HTTPRoute: &gatewayapiv1.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "my-route", Namespace: "my-ns"}}}
+
+	multiTargetPolicy := &RateLimitPolicy{ObjectMeta: metav1.ObjectMeta{Name: "multi", Namespace: "my-ns"}}
+	gatewayPolicy := &RateLimitPolicy{ObjectMeta: metav1.ObjectMeta{Name: "gateway", Namespace: "my-ns"}}
+	gateway.SetPolicies([]machinery.Policy{multiTargetPolicy, gatewayPolicy})
+	httpRoute.SetPolicies([]machinery.Policy{multiTargetPolicy})
+
+	policies := PoliciesInPath([]machinery.Targetable{gateway, httpRoute}, func(machinery.Policy) bool { return true })
+	names := lo.Map(policies, func(p machinery.Policy, _ int) string { return p.GetName() })
+	if expected := []string{"gateway", "multi"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("unexpected policies in path, expected(%v), got (%v)", expected, names)
+	}
+}
+
+func TestPathSelectorsMatches(t *testing.T) {
+	testCases := []struct {
+		name      string
+		selectors PathSelectors
+		expected  bool
+	}{
+		{
+			name:     "no selectors",
+			expected: true,
+		},
+		{
+			name:      "matching route selector",
+			selectors: PathSelectors{RouteSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "gold"}}},
+			expected:  true,
+		},
+		{
+			name:      "non-matching route selector",
+			selectors: PathSelectors{RouteSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "silver"}}},
+			expected:  false,
+		},
+		{
+			name: "matching route selector and non-matching namespace selector",
+			selectors: PathSelectors{
+				RouteSelector:     &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "gold"}},
+				NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"staging"}}}},
+			},
+			expected: false,
+		},
+		{
+			name:      "invalid selector",
+			selectors: PathSelectors{NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: "Unknown"}}}},
+			expected:  false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(subT *testing.T) {
+			if got := tc.selectors.Matches(map[string]string{"tier": "gold"}, map[string]string{"env": "prod"}); got != tc.expected {
+				subT.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestPolicySelectsPath(t *testing.T) {
+	gateway := &machinery.Gateway{Gateway: &gatewayapiv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "my-gateway", Namespace: "my-ns"}}}
+	httpRoute := &machinery.HTTPRoute{HTTPRoute: &gatewayapiv1.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "my-route", Namespace: "my-ns", Labels: map[string]string{"tier": "gold"}}}}
+	namespaceLabels := func(string) map[string]string { return map[string]string{"env": "prod"} }
+
+	policy := &RateLimitPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-policy", Namespace: "my-ns"},
+		Spec: RateLimitPolicySpec{
+			Defaults: &MergeableRateLimitPolicySpec{
+				PathSelectors: PathSelectors{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}},
+			},
+		},
+	}
+	if !PolicySelectsPath(policy, []machinery.Targetable{gateway, httpRoute}, namespaceLabels) {
+		t.Error("expected the policy to select the path")
+	}
+	if PolicySelectsPath(policy, []machinery.Targetable{gateway}, namespaceLabels) {
+		t.Error("expected the policy with selectors not to select a path without a route")
+	}
+
+	policy.Spec.Defaults.RouteSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "silver"}}
+	if PolicySelectsPath(policy, []machinery.Targetable{gateway, httpRoute}, namespaceLabels) {
+		t.Error("expected the policy not to select the path")
+	}
+
+	policy.Spec.Defaults = nil
+	if !PolicySelectsPath(policy, []machinery.Targetable{gateway}, namespaceLabels) {
+		t.Error("expected the policy without selectors to select any path")
+	}
+}
+
+func TestRuleStrategies(t *testing.T) {
+	limit := func(limit int) Limit {
+		return Limit{Rates: []Rate{{Limit: limit, Window: Duration("1m")}}}
+	}
+	gatewayPolicy := func(overrides bool, strategy string) *RateLimitPolicy {
+		spec := &MergeableRateLimitPolicySpec{
+			Strategy: strategy,
+			RuleStrategies: map[string]RuleMergeStrategy{
+				"locked-limit": RuleMergeStrategyLocked,
+				"merged-limit": RuleMergeStrategyMerge,
+			},
+			RateLimitPolicySpecProper: RateLimitPolicySpecProper{
+				Limits: map[string]Limit{
+					"locked-limit": limit(10),
+					"merged-limit": limit(20),
+					"other-limit":  limit(30),
+				},
+			},
+		}
+		policy := &RateLimitPolicy{
+			TypeMeta:   metav1.TypeMeta{APIVersion: GroupVersion.String(), Kind: "RateLimitPolicy"},
+			ObjectMeta: metav1.ObjectMeta{Name: "gateway-policy", Namespace: "my-ns"},
+		}
+		if overrides {
+			policy.Spec.Overrides = spec
+		} else {
+			policy.Spec.Defaults = spec
+		}
+		return policy
+	}
+	routePolicy := func(limits ...string) *RateLimitPolicy {
+		policy := &RateLimitPolicy{
+			TypeMeta:   metav1.TypeMeta{APIVersion: GroupVersion.String(), Kind: "RateLimitPolicy"},
+			ObjectMeta: metav1.ObjectMeta{Name: "route-policy", Namespace: "my-ns"},
+			Spec: RateLimitPolicySpec{
+				RateLimitPolicySpecProper: RateLimitPolicySpecProper{
+					Limits: map[string]Limit{},
+				},
+			},
+		}
+		for _, name := range limits {
+			policy.Spec.Limits[name] = limit(100)
+		}
+		return policy
+	}
+
+	testCases := []struct {
+		name          string
+		overrides     bool
+		strategy      string
+		routeLimits   []string
+		expected      map[string]string // rule ID → source policy name
+		expectedRates map[string]int    // rule ID → limit
+	}{
+		{
+			name:          "atomic defaults",
+			strategy:      AtomicMergeStrategy,
+			routeLimits:   []string{"locked-limit", "route-limit"},
+			expected:      map[string]string{"locked-limit": "gateway-policy", "merged-limit": "gateway-policy", "route-limit": "route-policy"},
+			expectedRates: map[string]int{"locked-limit": 10, "merged-limit": 20},
+		},
+		{
+			name:          "merge defaults",
+			strategy:      PolicyRuleMergeStrategy,
+			routeLimits:   []string{"locked-limit", "route-limit"},
+			expected:      map[string]string{"locked-limit": "gateway-policy", "merged-limit": "gateway-policy", "other-limit": "gateway-policy", "route-limit": "route-policy"},
+			expectedRates: map[string]int{"locked-limit": 10, "merged-limit": 20},
+		},
+		{
+			name:          "atomic overrides",
+			overrides:     true,
+			strategy:      AtomicMergeStrategy,
+			routeLimits:   []string{"locked-limit", "merged-limit", "route-limit"},
+			expected:      map[string]string{"locked-limit": "gateway-policy", "merged-limit": "route-policy", "other-limit": "gateway-policy"},
+			expectedRates: map[string]int{"locked-limit": 10, "merged-limit": 100, "other-limit": 30},
+		},
+		{
+			name:          "atomic overrides without rules of the route policy to merge with",
+			overrides:     true,
+			strategy:      AtomicMergeStrategy,
+			routeLimits:   []string{"route-limit"},
+			expected:      map[string]string{"locked-limit": "gateway-policy", "merged-limit": "gateway-policy", "other-limit": "gateway-policy"},
+			expectedRates: map[string]int{"locked-limit": 10, "merged-limit": 20, "other-limit": 30},
+		},
+		{
+			name:          "merge overrides",
+			overrides:     true,
+			strategy:      PolicyRuleMergeStrategy,
+			routeLimits:   []string{"locked-limit", "merged-limit", "other-limit", "route-limit"},
+			expected:      map[string]string{"locked-limit": "gateway-policy", "merged-limit": "route-policy", "other-limit": "gateway-policy", "route-limit": "route-policy"},
+			expectedRates: map[string]int{"locked-limit": 10, "merged-limit": 100, "other-limit": 30},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(subT *testing.T) {
+			merged := routePolicy(tc.routeLimits...).Merge(gatewayPolicy(tc.overrides, tc.strategy)).(*RateLimitPolicy)
+			sources := lo.MapValues(merged.Rules(), func(rule MergeableRule, _ string) string {
+				return rule.GetSource()[strings.LastIndex(rule.GetSource(), "/")+1:]
+			})
+			if !reflect.DeepEqual(sources, tc.expected) {
+				subT.Errorf("unexpected rules in the merged policy, expected(%v), got (%v)", tc.expected, sources)
+			}
+			for ruleID, expectedRate := range tc.expectedRates {
+				if rate := merged.Spec.Proper().Limits[ruleID].Rates[0].Limit; rate != expectedRate {
+					subT.Errorf("expected a limit of %d for %s, got %d", expectedRate, ruleID, rate)
+				}
+			}
+		})
+	}
+}
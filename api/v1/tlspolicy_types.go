@@ -38,11 +38,24 @@ var (
 )
 
 // TLSPolicySpec defines the desired state of TLSPolicy
+// +kubebuilder:validation:XValidation:rule="has(self.targetRef) != has(self.targetRefs)",message="Exactly one of targetRef or targetRefs must be set"
 type TLSPolicySpec struct {
 	// TargetRef identifies an API object to apply policy to.
 	// +kubebuilder:validation:XValidation:rule="self.group == 'gateway.networking.k8s.io'",message="Invalid targetRef.group. The only supported value is 'gateway.networking.k8s.io'"
 	// +kubebuilder:validation:XValidation:rule="self.kind == 'Gateway'",message="Invalid targetRef.kind. The only supported values are 'Gateway'"
-	TargetRef gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRef"`
+	// +optional
+	TargetRef gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRef,omitzero"`
+
+	// References to the objects to which this policy applies. Mutually exclusive with targetRef.
+	// A policy attached to more than one object of the same hierarchy (e.g. a Gateway and one of its HTTPRoutes)
+	// is merged only once, at the most specific of those objects.
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:XValidation:rule="self.all(r, r.group == 'gateway.networking.k8s.io')",message="Invalid targetRefs group. The only supported value is 'gateway.networking.k8s.io'"
+	// +kubebuilder:validation:XValidation:rule="self.all(r, r.kind == 'Gateway')",message="Invalid targetRefs kind. The only supported values are 'Gateway'"
+	// +kubebuilder:validation:XValidation:rule="self.all(r1, self.exists_one(r2, r1.kind == r2.kind && r1.name == r2.name && (has(r1.sectionName) ? has(r2.sectionName) && r1.sectionName == r2.sectionName : !has(r2.sectionName))))",message="targetRefs must be unique"
+	TargetRefs []gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRefs,omitempty"`
 
 	CertificateSpec `json:",inline"`
 }
@@ -120,6 +133,11 @@ type TLSPolicyStatus struct {
 	// recorded in the status condition
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Targets reports the status of the policy for each of the objects referenced in spec.targetRefs.
+	// Only set when the policy declares spec.targetRefs.
+	// +optional
+	Targets []PolicyTargetStatus `json:"targets,omitempty"`
 }
 
 func (s *TLSPolicyStatus) GetConditions() []metav1.Condition {
@@ -150,12 +168,7 @@ type TLSPolicy struct {
 var _ machinery.Policy = &TLSPolicy{}
 
 func (p *TLSPolicy) GetTargetRefs() []machinery.PolicyTargetReference {
-	return []machinery.PolicyTargetReference{
-		machinery.LocalPolicyTargetReferenceWithSectionName{
-			LocalPolicyTargetReferenceWithSectionName: p.Spec.TargetRef,
-			PolicyNamespace: p.Namespace,
-		},
-	}
+	return policyTargetRefs(p.Namespace, p.Spec.TargetRef, p.Spec.TargetRefs)
 }
 
 func (p *TLSPolicy) GetMergeStrategy() machinery.MergeStrategy {
@@ -179,6 +192,9 @@ func (p *TLSPolicy) Kind() string {
 
 // Deprecated: Use GetTargetRefs instead
 func (p *TLSPolicy) GetTargetRef() gatewayapiv1alpha2.LocalPolicyTargetReference {
+	if len(p.Spec.TargetRefs) > 0 {
+		return p.Spec.TargetRefs[0].LocalPolicyTargetReference
+	}
 	return p.Spec.TargetRef.LocalPolicyTargetReference
 }
 
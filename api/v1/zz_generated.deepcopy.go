@@ -26,6 +26,7 @@ import (
 	"github.com/kuadrant/dns-operator/api/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/gateway-api/apis/v1alpha2"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -91,6 +92,13 @@ func (in *AuthPolicyList) DeepCopyObject() runtime.Object {
 func (in *AuthPolicySpec) DeepCopyInto(out *AuthPolicySpec) {
 	*out = *in
 	in.TargetRef.DeepCopyInto(&out.TargetRef)
+	if in.TargetRefs != nil {
+		in, out := &in.TargetRefs, &out.TargetRefs
+		*out = make([]v1alpha2.LocalPolicyTargetReferenceWithSectionName, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Defaults != nil {
 		in, out := &in.Defaults, &out.Defaults
 		*out = new(MergeableAuthPolicySpec)
@@ -152,6 +160,20 @@ func (in *AuthPolicyStatus) DeepCopyInto(out *AuthPolicyStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]PolicyTargetStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ShadowedRules != nil {
+		in, out := &in.ShadowedRules, &out.ShadowedRules
+		*out = make([]ShadowedRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthPolicyStatus.
@@ -331,6 +353,13 @@ func (in *DNSPolicyList) DeepCopyObject() runtime.Object {
 func (in *DNSPolicySpec) DeepCopyInto(out *DNSPolicySpec) {
 	*out = *in
 	in.TargetRef.DeepCopyInto(&out.TargetRef)
+	if in.TargetRefs != nil {
+		in, out := &in.TargetRefs, &out.TargetRefs
+		*out = make([]v1alpha2.LocalPolicyTargetReferenceWithSectionName, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.HealthCheck != nil {
 		in, out := &in.HealthCheck, &out.HealthCheck
 		*out = new(v1alpha1.HealthCheckSpec)
@@ -339,7 +368,7 @@ func (in *DNSPolicySpec) DeepCopyInto(out *DNSPolicySpec) {
 	if in.LoadBalancing != nil {
 		in, out := &in.LoadBalancing, &out.LoadBalancing
 		*out = new(LoadBalancingSpec)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.ProviderRefs != nil {
 		in, out := &in.ProviderRefs, &out.ProviderRefs
@@ -396,6 +425,13 @@ func (in *DNSPolicyStatus) DeepCopyInto(out *DNSPolicyStatus) {
 			(*out)[key] = outVal
 		}
 	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]PolicyTargetStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSPolicyStatus.
@@ -427,6 +463,36 @@ func (in ExcludeAddresses) DeepCopy() ExcludeAddresses {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DynamicWeightSpec) DeepCopyInto(out *DynamicWeightSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DynamicWeightSpec.
+func (in *DynamicWeightSpec) DeepCopy() *DynamicWeightSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DynamicWeightSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HourRange) DeepCopyInto(out *HourRange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HourRange.
+func (in *HourRange) DeepCopy() *HourRange {
+	if in == nil {
+		return nil
+	}
+	out := new(HourRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Limit) DeepCopyInto(out *Limit) {
 	*out = *in
@@ -443,7 +509,9 @@ func (in *Limit) DeepCopyInto(out *Limit) {
 	if in.Rates != nil {
 		in, out := &in.Rates, &out.Rates
 		*out = make([]Rate, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
@@ -460,6 +528,11 @@ func (in *Limit) DeepCopy() *Limit {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LoadBalancingSpec) DeepCopyInto(out *LoadBalancingSpec) {
 	*out = *in
+	if in.DynamicWeight != nil {
+		in, out := &in.DynamicWeight, &out.DynamicWeight
+		*out = new(DynamicWeightSpec)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancingSpec.
@@ -475,6 +548,14 @@ func (in *LoadBalancingSpec) DeepCopy() *LoadBalancingSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MergeableAuthPolicySpec) DeepCopyInto(out *MergeableAuthPolicySpec) {
 	*out = *in
+	if in.RuleStrategies != nil {
+		in, out := &in.RuleStrategies, &out.RuleStrategies
+		*out = make(map[string]RuleMergeStrategy, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.PathSelectors.DeepCopyInto(&out.PathSelectors)
 	in.AuthPolicySpecProper.DeepCopyInto(&out.AuthPolicySpecProper)
 }
 
@@ -607,6 +688,14 @@ func (in *MergeablePatternExpressions) DeepCopy() *MergeablePatternExpressions {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MergeableRateLimitPolicySpec) DeepCopyInto(out *MergeableRateLimitPolicySpec) {
 	*out = *in
+	if in.RuleStrategies != nil {
+		in, out := &in.RuleStrategies, &out.RuleStrategies
+		*out = make(map[string]RuleMergeStrategy, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.PathSelectors.DeepCopyInto(&out.PathSelectors)
 	in.RateLimitPolicySpecProper.DeepCopyInto(&out.RateLimitPolicySpecProper)
 }
 
@@ -711,6 +800,54 @@ func (in *MergeableWrappedSuccessResponseSpec) DeepCopy() *MergeableWrappedSucce
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PathSelectors) DeepCopyInto(out *PathSelectors) {
+	*out = *in
+	if in.RouteSelector != nil {
+		in, out := &in.RouteSelector, &out.RouteSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PathSelectors.
+func (in *PathSelectors) DeepCopy() *PathSelectors {
+	if in == nil {
+		return nil
+	}
+	out := new(PathSelectors)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyTargetStatus) DeepCopyInto(out *PolicyTargetStatus) {
+	*out = *in
+	in.TargetRef.DeepCopyInto(&out.TargetRef)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyTargetStatus.
+func (in *PolicyTargetStatus) DeepCopy() *PolicyTargetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyTargetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Predicate) DeepCopyInto(out *Predicate) {
 	*out = *in
@@ -729,6 +866,11 @@ func (in *Predicate) DeepCopy() *Predicate {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Rate) DeepCopyInto(out *Rate) {
 	*out = *in
+	if in.Hours != nil {
+		in, out := &in.Hours, &out.Hours
+		*out = new(HourRange)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Rate.
@@ -804,6 +946,13 @@ func (in *RateLimitPolicyList) DeepCopyObject() runtime.Object {
 func (in *RateLimitPolicySpec) DeepCopyInto(out *RateLimitPolicySpec) {
 	*out = *in
 	in.TargetRef.DeepCopyInto(&out.TargetRef)
+	if in.TargetRefs != nil {
+		in, out := &in.TargetRefs, &out.TargetRefs
+		*out = make([]v1alpha2.LocalPolicyTargetReferenceWithSectionName, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Defaults != nil {
 		in, out := &in.Defaults, &out.Defaults
 		*out = new(MergeableRateLimitPolicySpec)
@@ -860,6 +1009,20 @@ func (in *RateLimitPolicyStatus) DeepCopyInto(out *RateLimitPolicyStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]PolicyTargetStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ShadowedRules != nil {
+		in, out := &in.ShadowedRules, &out.ShadowedRules
+		*out = make([]ShadowedRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RateLimitPolicyStatus.
@@ -872,6 +1035,31 @@ func (in *RateLimitPolicyStatus) DeepCopy() *RateLimitPolicyStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ShadowedRule) DeepCopyInto(out *ShadowedRule) {
+	*out = *in
+	if in.ShadowedBy != nil {
+		in, out := &in.ShadowedBy, &out.ShadowedBy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ShadowedRule.
+func (in *ShadowedRule) DeepCopy() *ShadowedRule {
+	if in == nil {
+		return nil
+	}
+	out := new(ShadowedRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TLSPolicy) DeepCopyInto(out *TLSPolicy) {
 	*out = *in
@@ -935,6 +1123,13 @@ func (in *TLSPolicyList) DeepCopyObject() runtime.Object {
 func (in *TLSPolicySpec) DeepCopyInto(out *TLSPolicySpec) {
 	*out = *in
 	in.TargetRef.DeepCopyInto(&out.TargetRef)
+	if in.TargetRefs != nil {
+		in, out := &in.TargetRefs, &out.TargetRefs
+		*out = make([]v1alpha2.LocalPolicyTargetReferenceWithSectionName, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	in.CertificateSpec.DeepCopyInto(&out.CertificateSpec)
 }
 
@@ -958,6 +1153,13 @@ func (in *TLSPolicyStatus) DeepCopyInto(out *TLSPolicyStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]PolicyTargetStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSPolicyStatus.
@@ -0,0 +1,109 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+var (
+	EffectivePolicyGroupKind  = schema.GroupKind{Group: GroupVersion.Group, Kind: "EffectivePolicy"}
+	EffectivePoliciesResource = GroupVersion.WithResource("effectivepolicies")
+)
+
+// EffectivePolicy exposes the result of merging the policies that affect the rules of a route, for each gateway and
+// listener the route is attached to. It is managed by the Kuadrant operator and must not be edited.
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=effpol
+// +kubebuilder:printcolumn:name="TargetKind",type="string",JSONPath=".spec.targetRef.kind",description="Kind of the route the effective policies apply to"
+// +kubebuilder:printcolumn:name="TargetName",type="string",JSONPath=".spec.targetRef.name",description="Name of the route the effective policies apply to"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type EffectivePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec EffectivePolicySpec `json:"spec,omitempty"`
+}
+
+// EffectivePolicySpec holds the effective policies of a route
+type EffectivePolicySpec struct {
+	// TargetRef identifies the route the effective policies apply to
+	TargetRef gatewayapiv1alpha2.LocalPolicyTargetReference `json:"targetRef"`
+
+	// Paths lists the effective policies for each combination of gateway, listener and rule of the route
+	// +optional
+	Paths []EffectivePolicyPath `json:"paths,omitempty"`
+}
+
+// EffectivePolicyPath holds the effective policies of a rule of a route, attached to a listener of a gateway
+type EffectivePolicyPath struct {
+	// Gateway is the namespaced name of the gateway (namespace/name)
+	Gateway string `json:"gateway"`
+
+	// Listener is the name of the listener of the gateway
+	Listener string `json:"listener"`
+
+	// RouteRule is the name of the rule of the route, or its index if the rule is unnamed
+	RouteRule string `json:"routeRule"`
+
+	// Policies holds the merged result of the policies of each kind that affect the path
+	Policies []EffectivePolicyKind `json:"policies"`
+}
+
+// EffectivePolicyKind is the merged result of the policies of a kind
+type EffectivePolicyKind struct {
+	// Kind of the policies merged (e.g. AuthPolicy, RateLimitPolicy, TokenRateLimitPolicy)
+	Kind string `json:"kind"`
+
+	// Sources are the locators of the policies that contribute to the effective policy
+	Sources []string `json:"sources"`
+
+	// Rules are the rules of the effective policy, each one keeping the policy it comes from
+	// +optional
+	Rules []EffectivePolicyRule `json:"rules,omitempty"`
+}
+
+// EffectivePolicyRule is a rule of an effective policy
+type EffectivePolicyRule struct {
+	// Name of the rule (e.g. the name of a limit, or the path of an auth rule)
+	Name string `json:"name"`
+
+	// Source is the locator of the policy the rule comes from
+	Source string `json:"source"`
+
+	// Spec of the rule
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	Spec runtime.RawExtension `json:"spec"`
+}
+
+//+kubebuilder:object:root=true
+
+// EffectivePolicyList contains a list of EffectivePolicy
+type EffectivePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []EffectivePolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&EffectivePolicy{}, &EffectivePolicyList{})
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	KuadrantExtensionGroupKind = schema.GroupKind{Group: GroupVersion.Group, Kind: "KuadrantExtension"}
+	KuadrantExtensionsResource = GroupVersion.WithResource("kuadrantextensions")
+)
+
+const (
+	// KuadrantExtensionConditionAccepted is the condition of a KuadrantExtension whose extension is allowed to connect
+	KuadrantExtensionConditionAccepted = "Accepted"
+	// KuadrantExtensionConflictedReason is the reason of the Accepted condition of a KuadrantExtension whose identity is
+	// already registered by an older KuadrantExtension
+	KuadrantExtensionConflictedReason = "Conflicted"
+	// KuadrantExtensionInvalidReason is the reason of the Accepted condition of a KuadrantExtension whose capabilities
+	// are invalid
+	KuadrantExtensionInvalidReason = "Invalid"
+	// KuadrantExtensionNotAllowedReason is the reason of the Accepted condition of a KuadrantExtension outside of the
+	// namespace of the operator
+	KuadrantExtensionNotAllowedReason = "NotAllowed"
+)
+
+// KuadrantExtension registers an extension running outside of the operator, e.g. in its own Deployment. The extension
+// connects to the operator over gRPC with mutual TLS, and is identified by its client certificate.
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=kext
+// +kubebuilder:printcolumn:name="Identity",type="string",JSONPath=".spec.identity",description="Identity of the client certificate of the extension"
+// +kubebuilder:printcolumn:name="Accepted",type="string",JSONPath=`.status.conditions[?(@.type=="Accepted")].status`,description="KuadrantExtension Accepted",priority=2
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type KuadrantExtension struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KuadrantExtensionSpec   `json:"spec,omitempty"`
+	Status KuadrantExtensionStatus `json:"status,omitempty"`
+}
+
+// KuadrantExtensionSpec declares the identity of a remote extension and its capabilities. The operator rejects the
+// calls of the extension outside of them.
+type KuadrantExtensionSpec struct {
+	// Identity of the extension, matched against the URI (e.g. a SPIFFE ID) and DNS subject alternative names, and the
+	// common name, of the client certificate it connects with
+	// +kubebuilder:validation:MinLength=1
+	Identity string `json:"identity"`
+
+	// PolicyKinds are the kinds of the policies the extension manages, e.g. PlanPolicy
+	// +kubebuilder:validation:MinItems=1
+	PolicyKinds []string `json:"policyKinds"`
+
+	// Domains are the domains the extension can register mutators for, e.g. DOMAIN_AUTH
+	// +optional
+	Domains []string `json:"domains,omitempty"`
+
+	// UpstreamHosts are the hosts of the action methods the extension can register. A leading `*.` matches any
+	// subdomain.
+	// +optional
+	UpstreamHosts []string `json:"upstreamHosts,omitempty"`
+
+	// ActionTypes are the types of the pipeline actions the extension can commit, e.g. ACTION_TYPE_DENY.
+	// ACTION_TYPE_GRPC_METHOD is also required to register action methods.
+	// +optional
+	ActionTypes []string `json:"actionTypes,omitempty"`
+}
+
+type KuadrantExtensionStatus struct {
+	// ObservedGeneration reflects the generation of the most recently observed spec.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Represents the observations of the extension's current state.
+	// Known .status.conditions.type are: "Accepted"
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+//+kubebuilder:object:root=true
+
+// KuadrantExtensionList contains a list of KuadrantExtension
+type KuadrantExtensionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KuadrantExtension `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KuadrantExtension{}, &KuadrantExtensionList{})
+}
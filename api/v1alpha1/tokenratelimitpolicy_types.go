@@ -17,9 +17,14 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/kuadrant/policy-machinery/machinery"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 
 	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
@@ -28,6 +33,17 @@ import (
 	"github.com/kuadrant/kuadrant-operator/internal/utils"
 )
 
+const (
+	// DefaultTokenUsageJSONPointer is the location of the token count in OpenAI-style response bodies
+	DefaultTokenUsageJSONPointer = "/usage/total_tokens"
+	// DefaultInputTokenUsageJSONPointer is the location of the prompt token count in OpenAI-style response bodies
+	DefaultInputTokenUsageJSONPointer = "/usage/prompt_tokens"
+	// DefaultOutputTokenUsageJSONPointer is the location of the completion token count in OpenAI-style response bodies
+	DefaultOutputTokenUsageJSONPointer = "/usage/completion_tokens"
+	// DefaultPricingModelExpression resolves the model that served a request in OpenAI-style response bodies
+	DefaultPricingModelExpression = `responseBodyJSON("/model")`
+)
+
 var (
 	TokenRateLimitPolicyGroupKind  = schema.GroupKind{Group: GroupVersion.Group, Kind: "TokenRateLimitPolicy"}
 	TokenRateLimitPoliciesResource = GroupVersion.WithResource("tokenratelimitpolicies")
@@ -147,6 +163,29 @@ func (p *TokenRateLimitPolicy) Kind() string {
 	return TokenRateLimitPolicyGroupKind.Kind
 }
 
+// InShadowMode tells whether the decisions of the policy are reported without being enforced
+func (p *TokenRateLimitPolicy) InShadowMode() bool {
+	return p.Spec.Mode == kuadrantv1.EnforcementModeShadow
+}
+
+// Validate performs the checks of the policy spec that cannot be expressed as CRD validation rules
+func (p *TokenRateLimitPolicy) Validate() error {
+	for name, limit := range p.Spec.Proper().Limits {
+		if err := limit.Validate(); err != nil {
+			return fmt.Errorf("invalid limit %q: %w", name, err)
+		}
+		if limit.Unit == TokenLimitUnitCost && p.Spec.Proper().Pricing == nil {
+			return fmt.Errorf("invalid limit %q: cost limits require pricing to be defined", name)
+		}
+	}
+	if pricing := p.Spec.Proper().Pricing; pricing != nil {
+		if err := pricing.Validate(); err != nil {
+			return fmt.Errorf("invalid pricing: %w", err)
+		}
+	}
+	return nil
+}
+
 // +kubebuilder:validation:XValidation:rule="!(has(self.defaults) && has(self.limits))",message="Implicit and explicit defaults are mutually exclusive"
 // +kubebuilder:validation:XValidation:rule="!(has(self.defaults) && has(self.overrides))",message="Overrides and explicit defaults are mutually exclusive"
 // +kubebuilder:validation:XValidation:rule="!(has(self.overrides) && has(self.limits))",message="Overrides and implicit defaults are mutually exclusive"
@@ -159,6 +198,11 @@ type TokenRateLimitPolicySpec struct {
 	// +kubebuilder:validation:XValidation:rule="self.kind == 'HTTPRoute' || self.kind == 'Gateway'",message="Invalid targetRef.kind. The only supported values are 'HTTPRoute' and 'Gateway'"
 	TargetRef gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRef"`
 
+	// Mode defines how the data plane acts upon the decisions of the policy: `enforce` (default) or `shadow`.
+	// In shadow mode, the policy is evaluated and its decisions are reported, but requests are never denied.
+	// +optional
+	Mode kuadrantv1.EnforcementMode `json:"mode,omitempty"`
+
 	// Rules to apply as defaults. Can be overridden by more specific policy rules lower in the hierarchy and by less specific policy overrides.
 	// Use one of: defaults, overrides, or bare set of policy rules (implicit defaults).
 	// +optional
@@ -204,9 +248,14 @@ type TokenRateLimitPolicySpecProper struct {
 	// Limits holds the struct of token-based limits indexed by a unique name
 	// +optional
 	Limits map[string]TokenLimit `json:"limits,omitempty"`
+
+	// Pricing holds the per-model token prices used to compute the cost of the requests counted by limits of unit `cost`
+	// +optional
+	Pricing *TokenPricing `json:"pricing,omitempty"`
 }
 
 // TokenLimit represents a complete token-based rate limit configuration
+// +kubebuilder:validation:XValidation:rule="!has(self.unit) || self.unit != 'cost' || !(has(self.tokenClass) || has(self.tokenUsage) || has(self.estimate))",message="tokenClass, tokenUsage and estimate cannot be used with cost limits"
 type TokenLimit struct {
 	// When holds a list of "limit-level" `Predicate`s for token-based conditions
 	// Called also "soft" conditions as route selectors must also match
@@ -222,10 +271,215 @@ type TokenLimit struct {
 	// +optional
 	Counters []kuadrantv1.Counter `json:"counters,omitempty"`
 
+	// Unit defines what the rates of the limit count: `tokens` (default) or `cost`.
+	// Cost limits count the price of the input and output tokens of each request, according to the pricing of the policy,
+	// in the same cost units as the prices. The token counts of cost limits are read from the sources set in the pricing.
+	// +optional
+	Unit TokenLimitUnit `json:"unit,omitempty"`
+
+	// TokenClass defines which class of tokens the limit counts: `total` (default), `input` (prompt) or `output` (completion).
+	// Limits of different classes are tracked by independent counters, so input and output tokens can have distinct budgets.
+	// +optional
+	TokenClass TokenClass `json:"tokenClass,omitempty"`
+
+	// TokenUsage defines where the number of tokens consumed by a request is read from in the response.
+	// Defaults to the field of the JSON response body that matches the token class in OpenAI-style responses,
+	// i.e. `/usage/total_tokens`, `/usage/prompt_tokens` or `/usage/completion_tokens`.
+	// +optional
+	TokenUsage *TokenUsage `json:"tokenUsage,omitempty"`
+
+	// Estimate defines an estimate of the tokens a request will consume, evaluated in the request phase.
+	// Without an estimate, a request is admitted as long as the limit is not already exhausted.
+	// +optional
+	Estimate *TokenEstimate `json:"estimate,omitempty"`
+
 	// Source stores the locator of the policy where the limit is originally defined (internal use)
 	Source string `json:"-"`
 }
 
+// Validate checks the token usage settings of the limit are well-formed and consistent
+func (l *TokenLimit) Validate() error {
+	if l.TokenUsage != nil {
+		if err := l.TokenUsage.Validate(); err != nil {
+			return fmt.Errorf("invalid tokenUsage: %w", err)
+		}
+	}
+	if l.Estimate != nil {
+		if err := transformer.CheckSyntax(string(l.Estimate.Expression)); err != nil {
+			return fmt.Errorf("invalid estimate expression: %w", err)
+		}
+	}
+	for _, rate := range l.Rates {
+		if err := rate.Validate(); err != nil {
+			return fmt.Errorf("invalid rate: %w", err)
+		}
+	}
+	if l.Unit == TokenLimitUnitCost && (l.TokenClass != "" || l.TokenUsage != nil || l.Estimate != nil) {
+		return errors.New("tokenClass, tokenUsage and estimate cannot be used with cost limits")
+	}
+	return nil
+}
+
+// TokenLimitUnit is what the rates of a token limit count
+// +kubebuilder:validation:Enum=tokens;cost
+type TokenLimitUnit string
+
+const (
+	TokenLimitUnitTokens TokenLimitUnit = "tokens"
+	TokenLimitUnitCost   TokenLimitUnit = "cost"
+)
+
+// TokenPricing defines the token prices of the models served behind the targeted routes
+type TokenPricing struct {
+	// Model is a CEL expression that resolves to the name of the model that served a request,
+	// e.g. `requestBodyJSON("/model")`. Defaults to `responseBodyJSON("/model")`.
+	// +optional
+	Model kuadrantv1.Expression `json:"model,omitempty"`
+
+	// Models holds the token prices indexed by model name
+	// +kubebuilder:validation:MinProperties=1
+	Models map[string]TokenPrice `json:"models"`
+
+	// Default is the price of the tokens of models missing from the table.
+	// If omitted, requests served by such models are not charged.
+	// +optional
+	Default *TokenPrice `json:"default,omitempty"`
+
+	// InputTokenUsage defines where the number of input tokens of a request is read from in the response.
+	// Defaults to `/usage/prompt_tokens` of the JSON response body, as in OpenAI-style responses.
+	// +optional
+	InputTokenUsage *TokenUsage `json:"inputTokenUsage,omitempty"`
+
+	// OutputTokenUsage defines where the number of output tokens of a request is read from in the response.
+	// Defaults to `/usage/completion_tokens` of the JSON response body, as in OpenAI-style responses.
+	// +optional
+	OutputTokenUsage *TokenUsage `json:"outputTokenUsage,omitempty"`
+}
+
+// Validate checks the model expression and the token usage sources of the pricing are well-formed
+func (p *TokenPricing) Validate() error {
+	if p.Model != "" {
+		if err := transformer.CheckSyntax(string(p.Model)); err != nil {
+			return fmt.Errorf("invalid model expression: %w", err)
+		}
+	}
+	if p.InputTokenUsage != nil {
+		if err := p.InputTokenUsage.Validate(); err != nil {
+			return fmt.Errorf("invalid inputTokenUsage: %w", err)
+		}
+	}
+	if p.OutputTokenUsage != nil {
+		if err := p.OutputTokenUsage.Validate(); err != nil {
+			return fmt.Errorf("invalid outputTokenUsage: %w", err)
+		}
+	}
+	return nil
+}
+
+// ModelExpression returns the CEL expression that resolves the model that served a request
+func (p *TokenPricing) ModelExpression() string {
+	if p.Model == "" {
+		return DefaultPricingModelExpression
+	}
+	return string(p.Model)
+}
+
+// TokenPrice is the price of a single token, in cost units.
+// Cost units are chosen by the user and must be fine enough for prices to be integers,
+// e.g. with nano-dollars as cost unit, a price of $2.50 per million tokens is 2500.
+type TokenPrice struct {
+	// Input is the price of a prompt token
+	// +kubebuilder:validation:Minimum=0
+	Input int64 `json:"input"`
+
+	// Output is the price of a completion token
+	// +kubebuilder:validation:Minimum=0
+	Output int64 `json:"output"`
+}
+
+// TokenEstimate defines the request-phase estimate of the tokens a request will consume
+type TokenEstimate struct {
+	// Expression is a CEL expression evaluated in the request phase that resolves to the estimated token count,
+	// e.g. `requestBodyJSON("/max_tokens")`. Requests for which the expression resolves to null, e.g. without
+	// `max_tokens` in the body, are estimated at 0 tokens.
+	Expression kuadrantv1.Expression `json:"expression"`
+
+	// Reserve consumes the estimate from the limit when the request is admitted.
+	// The actual usage is then reconciled in the response phase by adding only the tokens in excess of the estimate.
+	// Estimates above the actual usage are not refunded, as limit counters can only be incremented.
+	// If false, the estimate is only checked against the remaining budget of the limit and nothing is consumed
+	// until the actual usage is reported.
+	// +optional
+	Reserve bool `json:"reserve,omitempty"`
+}
+
+// TokenClass is the class of tokens counted by a token limit
+// +kubebuilder:validation:Enum=total;input;output
+type TokenClass string
+
+const (
+	TokenClassTotal  TokenClass = "total"
+	TokenClassInput  TokenClass = "input"
+	TokenClassOutput TokenClass = "output"
+)
+
+// DefaultJSONPointer returns the location of the token count of the class in OpenAI-style response bodies
+func (c TokenClass) DefaultJSONPointer() string {
+	switch c {
+	case TokenClassInput:
+		return DefaultInputTokenUsageJSONPointer
+	case TokenClassOutput:
+		return DefaultOutputTokenUsageJSONPointer
+	default:
+		return DefaultTokenUsageJSONPointer
+	}
+}
+
+// TokenUsage defines the source of the token count of a response.
+// Exactly one of jsonPointer, expression or header must be set.
+// +kubebuilder:validation:XValidation:rule="(has(self.jsonPointer) ? 1 : 0) + (has(self.expression) ? 1 : 0) + (has(self.header) ? 1 : 0) == 1",message="Exactly one of jsonPointer, expression or header must be set"
+type TokenUsage struct {
+	// JSONPointer (RFC 6901) to the token count within the JSON response body, e.g. `/usage/output_tokens`
+	// +optional
+	JSONPointer string `json:"jsonPointer,omitempty"`
+
+	// Expression is a CEL expression evaluated in the response phase that resolves to the token count,
+	// e.g. `responseBodyJSON("/usage/input_tokens") + responseBodyJSON("/usage/output_tokens")`
+	// +optional
+	Expression kuadrantv1.Expression `json:"expression,omitempty"`
+
+	// Header is the name of a response header whose value holds the token count
+	// +optional
+	Header string `json:"header,omitempty"`
+}
+
+// Validate checks the token usage source is well-formed
+func (u *TokenUsage) Validate() error {
+	set := 0
+	if u.JSONPointer != "" {
+		set++
+		if !strings.HasPrefix(u.JSONPointer, "/") {
+			return fmt.Errorf("jsonPointer %q must start with '/'", u.JSONPointer)
+		}
+	}
+	if u.Expression != "" {
+		set++
+		if err := transformer.CheckSyntax(string(u.Expression)); err != nil {
+			return fmt.Errorf("invalid expression: %w", err)
+		}
+	}
+	if u.Header != "" {
+		set++
+		if errs := validation.IsHTTPHeaderName(u.Header); len(errs) > 0 {
+			return fmt.Errorf("invalid header %q: %s", u.Header, strings.Join(errs, ", "))
+		}
+	}
+	if set != 1 {
+		return errors.New("exactly one of jsonPointer, expression or header must be set")
+	}
+	return nil
+}
+
 func (l TokenLimit) CountersAsStringList() []string {
 	if len(l.Counters) == 0 {
 		return nil
@@ -266,6 +520,11 @@ type TokenRateLimitPolicyStatus struct {
 	// +listType=map
 	// +listMapKey=type
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// ShadowedRules reports the rules of the policy that are replaced in the effective policies by the rules of other policies,
+	// and the locators of the policies those rules come from.
+	// +optional
+	ShadowedRules []kuadrantv1.ShadowedRule `json:"shadowedRules,omitempty"`
 }
 
 func (s *TokenRateLimitPolicyStatus) GetConditions() []metav1.Condition {
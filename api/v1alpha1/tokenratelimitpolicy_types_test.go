@@ -318,3 +318,147 @@ func TestTokenLimit_CountersAsStringList(t *testing.T) {
 		})
 	}
 }
+
+func TestTokenUsage_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		usage   TokenUsage
+		wantErr bool
+	}{
+		{
+			name:  "json pointer",
+			usage: TokenUsage{JSONPointer: "/usage/output_tokens"},
+		},
+		{
+			name:    "json pointer without leading slash",
+			usage:   TokenUsage{JSONPointer: "usage.output_tokens"},
+			wantErr: true,
+		},
+		{
+			name:  "cel expression",
+			usage: TokenUsage{Expression: `responseBodyJSON("/usage/input_tokens") + responseBodyJSON("/usage/output_tokens")`},
+		},
+		{
+			name:    "invalid cel expression",
+			usage:   TokenUsage{Expression: `responseBodyJSON("/usage/input_tokens") +`},
+			wantErr: true,
+		},
+		{
+			name:  "header",
+			usage: TokenUsage{Header: "x-usage-tokens"},
+		},
+		{
+			name:    "invalid header",
+			usage:   TokenUsage{Header: "x usage tokens"},
+			wantErr: true,
+		},
+		{
+			name:    "no source",
+			usage:   TokenUsage{},
+			wantErr: true,
+		},
+		{
+			name:    "multiple sources",
+			usage:   TokenUsage{JSONPointer: "/usage/output_tokens", Header: "x-usage-tokens"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.usage.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenLimit_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   TokenLimit
+		wantErr bool
+	}{
+		{
+			name:  "default token usage",
+			limit: TokenLimit{},
+		},
+		{
+			name: "reserved estimate",
+			limit: TokenLimit{
+				Estimate: &TokenEstimate{Expression: `requestBodyJSON("/max_tokens")`, Reserve: true},
+			},
+		},
+		{
+			name:  "cost limit",
+			limit: TokenLimit{Unit: TokenLimitUnitCost},
+		},
+		{
+			name: "cost limit with token class",
+			limit: TokenLimit{
+				Unit:       TokenLimitUnitCost,
+				TokenClass: TokenClassOutput,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid estimate expression",
+			limit: TokenLimit{
+				Estimate: &TokenEstimate{Expression: `requestBodyJSON("/max_tokens"`},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.limit.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenRateLimitPolicy_Validate(t *testing.T) {
+	policy := &TokenRateLimitPolicy{
+		Spec: TokenRateLimitPolicySpec{
+			Defaults: &MergeableTokenRateLimitPolicySpec{
+				TokenRateLimitPolicySpecProper: TokenRateLimitPolicySpecProper{
+					Limits: map[string]TokenLimit{
+						"default": {},
+						"output":  {TokenUsage: &TokenUsage{JSONPointer: "usage/output_tokens"}},
+					},
+				},
+			},
+		},
+	}
+	if err := policy.Validate(); err == nil {
+		t.Error("Expected validation error for invalid tokenUsage, got nil")
+	}
+
+	policy.Spec.Defaults.Limits["output"] = TokenLimit{TokenUsage: &TokenUsage{JSONPointer: "/usage/output_tokens"}}
+	if err := policy.Validate(); err != nil {
+		t.Errorf("Expected no validation error, got %v", err)
+	}
+
+	policy.Spec.Defaults.Limits["spend"] = TokenLimit{Unit: TokenLimitUnitCost}
+	if err := policy.Validate(); err == nil {
+		t.Error("Expected validation error for cost limit without pricing, got nil")
+	}
+
+	policy.Spec.Defaults.Pricing = &TokenPricing{Models: map[string]TokenPrice{"gpt-4o": {Input: 2500, Output: 10000}}}
+	if err := policy.Validate(); err != nil {
+		t.Errorf("Expected no validation error, got %v", err)
+	}
+
+	policy.Spec.Defaults.Pricing.InputTokenUsage = &TokenUsage{JSONPointer: "/usage/input_tokens", Header: "x-input-tokens"}
+	if err := policy.Validate(); err == nil {
+		t.Error("Expected validation error for invalid pricing inputTokenUsage, got nil")
+	}
+
+	policy.Spec.Defaults.Pricing.InputTokenUsage = &TokenUsage{JSONPointer: "/usage/input_tokens"}
+	policy.Spec.Defaults.Pricing.OutputTokenUsage = &TokenUsage{Header: "x-output-tokens"}
+	if err := policy.Validate(); err != nil {
+		t.Errorf("Expected no validation error, got %v", err)
+	}
+}
@@ -26,6 +26,268 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EffectivePolicy) DeepCopyInto(out *EffectivePolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EffectivePolicy.
+func (in *EffectivePolicy) DeepCopy() *EffectivePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(EffectivePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EffectivePolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EffectivePolicyKind) DeepCopyInto(out *EffectivePolicyKind) {
+	*out = *in
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]EffectivePolicyRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EffectivePolicyKind.
+func (in *EffectivePolicyKind) DeepCopy() *EffectivePolicyKind {
+	if in == nil {
+		return nil
+	}
+	out := new(EffectivePolicyKind)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EffectivePolicyList) DeepCopyInto(out *EffectivePolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]EffectivePolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EffectivePolicyList.
+func (in *EffectivePolicyList) DeepCopy() *EffectivePolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(EffectivePolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EffectivePolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EffectivePolicyPath) DeepCopyInto(out *EffectivePolicyPath) {
+	*out = *in
+	if in.Policies != nil {
+		in, out := &in.Policies, &out.Policies
+		*out = make([]EffectivePolicyKind, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EffectivePolicyPath.
+func (in *EffectivePolicyPath) DeepCopy() *EffectivePolicyPath {
+	if in == nil {
+		return nil
+	}
+	out := new(EffectivePolicyPath)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EffectivePolicyRule) DeepCopyInto(out *EffectivePolicyRule) {
+	*out = *in
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EffectivePolicyRule.
+func (in *EffectivePolicyRule) DeepCopy() *EffectivePolicyRule {
+	if in == nil {
+		return nil
+	}
+	out := new(EffectivePolicyRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EffectivePolicySpec) DeepCopyInto(out *EffectivePolicySpec) {
+	*out = *in
+	out.TargetRef = in.TargetRef
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]EffectivePolicyPath, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EffectivePolicySpec.
+func (in *EffectivePolicySpec) DeepCopy() *EffectivePolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EffectivePolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KuadrantExtension) DeepCopyInto(out *KuadrantExtension) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KuadrantExtension.
+func (in *KuadrantExtension) DeepCopy() *KuadrantExtension {
+	if in == nil {
+		return nil
+	}
+	out := new(KuadrantExtension)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KuadrantExtension) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KuadrantExtensionList) DeepCopyInto(out *KuadrantExtensionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KuadrantExtension, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KuadrantExtensionList.
+func (in *KuadrantExtensionList) DeepCopy() *KuadrantExtensionList {
+	if in == nil {
+		return nil
+	}
+	out := new(KuadrantExtensionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KuadrantExtensionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KuadrantExtensionSpec) DeepCopyInto(out *KuadrantExtensionSpec) {
+	*out = *in
+	if in.PolicyKinds != nil {
+		in, out := &in.PolicyKinds, &out.PolicyKinds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Domains != nil {
+		in, out := &in.Domains, &out.Domains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UpstreamHosts != nil {
+		in, out := &in.UpstreamHosts, &out.UpstreamHosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ActionTypes != nil {
+		in, out := &in.ActionTypes, &out.ActionTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KuadrantExtensionSpec.
+func (in *KuadrantExtensionSpec) DeepCopy() *KuadrantExtensionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KuadrantExtensionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KuadrantExtensionStatus) DeepCopyInto(out *KuadrantExtensionStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KuadrantExtensionStatus.
+func (in *KuadrantExtensionStatus) DeepCopy() *KuadrantExtensionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KuadrantExtensionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MergeableTokenRateLimitPolicySpec) DeepCopyInto(out *MergeableTokenRateLimitPolicySpec) {
 	*out = *in
@@ -42,6 +304,21 @@ func (in *MergeableTokenRateLimitPolicySpec) DeepCopy() *MergeableTokenRateLimit
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenEstimate) DeepCopyInto(out *TokenEstimate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenEstimate.
+func (in *TokenEstimate) DeepCopy() *TokenEstimate {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenEstimate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TokenLimit) DeepCopyInto(out *TokenLimit) {
 	*out = *in
@@ -53,13 +330,25 @@ func (in *TokenLimit) DeepCopyInto(out *TokenLimit) {
 	if in.Rates != nil {
 		in, out := &in.Rates, &out.Rates
 		*out = make([]v1.Rate, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.Counters != nil {
 		in, out := &in.Counters, &out.Counters
 		*out = make([]v1.Counter, len(*in))
 		copy(*out, *in)
 	}
+	if in.TokenUsage != nil {
+		in, out := &in.TokenUsage, &out.TokenUsage
+		*out = new(TokenUsage)
+		**out = **in
+	}
+	if in.Estimate != nil {
+		in, out := &in.Estimate, &out.Estimate
+		*out = new(TokenEstimate)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenLimit.
@@ -72,6 +361,58 @@ func (in *TokenLimit) DeepCopy() *TokenLimit {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenPrice) DeepCopyInto(out *TokenPrice) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenPrice.
+func (in *TokenPrice) DeepCopy() *TokenPrice {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenPrice)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenPricing) DeepCopyInto(out *TokenPricing) {
+	*out = *in
+	if in.Models != nil {
+		in, out := &in.Models, &out.Models
+		*out = make(map[string]TokenPrice, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Default != nil {
+		in, out := &in.Default, &out.Default
+		*out = new(TokenPrice)
+		**out = **in
+	}
+	if in.InputTokenUsage != nil {
+		in, out := &in.InputTokenUsage, &out.InputTokenUsage
+		*out = new(TokenUsage)
+		**out = **in
+	}
+	if in.OutputTokenUsage != nil {
+		in, out := &in.OutputTokenUsage, &out.OutputTokenUsage
+		*out = new(TokenUsage)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenPricing.
+func (in *TokenPricing) DeepCopy() *TokenPricing {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenPricing)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TokenRateLimitPolicy) DeepCopyInto(out *TokenRateLimitPolicy) {
 	*out = *in
@@ -169,6 +510,11 @@ func (in *TokenRateLimitPolicySpecProper) DeepCopyInto(out *TokenRateLimitPolicy
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.Pricing != nil {
+		in, out := &in.Pricing, &out.Pricing
+		*out = new(TokenPricing)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenRateLimitPolicySpecProper.
@@ -191,6 +537,13 @@ func (in *TokenRateLimitPolicyStatus) DeepCopyInto(out *TokenRateLimitPolicyStat
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ShadowedRules != nil {
+		in, out := &in.ShadowedRules, &out.ShadowedRules
+		*out = make([]v1.ShadowedRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenRateLimitPolicyStatus.
@@ -202,3 +555,18 @@ func (in *TokenRateLimitPolicyStatus) DeepCopy() *TokenRateLimitPolicyStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenUsage) DeepCopyInto(out *TokenUsage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenUsage.
+func (in *TokenUsage) DeepCopy() *TokenUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenUsage)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -18,6 +18,7 @@ package v1beta1
 
 import (
 	"reflect"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/google/go-cmp/cmp"
@@ -89,6 +90,10 @@ type KuadrantSpec struct {
 	// +optional
 	// Components configures optional Kuadrant components
 	Components *Components `json:"components,omitempty"`
+	// +optional
+	// WasmConfigRollout configures the progressive rollout of the changes of the wasm configuration
+	// to the gateways, so a faulty change only reaches a subset of the gateways first.
+	WasmConfigRollout *WasmConfigRollout `json:"wasmConfigRollout,omitempty"`
 }
 
 // Observability configures telemetry and monitoring settings for Kuadrant components.
@@ -155,6 +160,55 @@ type LogLevel struct {
 	Error *string `json:"error,omitempty"`
 }
 
+// WasmConfigRollout configures the progressive rollout of the changes of the wasm configuration.
+// A change is applied to the canary gateways first, and promoted to all the gateways once the canary gateways
+// are ready and healthy for the bake time. Otherwise, the change is rolled back.
+type WasmConfigRollout struct {
+	// Enable controls whether the changes are rolled out progressively.
+	// When false, all the gateways get the changes at once.
+	Enable bool `json:"enable,omitempty"`
+
+	// CanaryGatewaySelector selects the gateways that get the changes first by their labels.
+	// When no gateway is selected, the changes are applied to all the gateways at once.
+	// +optional
+	CanaryGatewaySelector *metav1.LabelSelector `json:"canaryGatewaySelector,omitempty"`
+
+	// BakeTime is how long the canary gateways run a change before it is promoted to all the gateways.
+	// Defaults to 5m.
+	// +optional
+	BakeTime *metav1.Duration `json:"bakeTime,omitempty"`
+
+	// Analysis checks the error metrics of the canary gateways during the bake time.
+	// +optional
+	Analysis *WasmConfigRolloutAnalysis `json:"analysis,omitempty"`
+}
+
+// WasmConfigRolloutAnalysis checks the error metrics of the canary gateways with a Prometheus query.
+type WasmConfigRolloutAnalysis struct {
+	// PrometheusURL is the URL of the Prometheus API the query is sent to (e.g. http://prometheus.monitoring:9090).
+	PrometheusURL string `json:"prometheusURL"`
+
+	// Query is evaluated for each canary gateway, with the ${gateway_namespace} and ${gateway_name} placeholders
+	// replaced by the namespace and name of the gateway, e.g.
+	// sum(rate(istio_requests_total{response_code=~"5..",source_workload="${gateway_name}-istio"}[1m]))
+	Query string `json:"query"`
+
+	// MaxValue is the maximum value of the query result for a canary gateway to be healthy.
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	MaxValue string `json:"maxValue"`
+}
+
+func (r *WasmConfigRollout) IsEnabled() bool {
+	return r != nil && r.Enable
+}
+
+func (r *WasmConfigRollout) GetBakeTime() time.Duration {
+	if r == nil || r.BakeTime == nil {
+		return DefaultWasmConfigRolloutBakeTime
+	}
+	return r.BakeTime.Duration
+}
+
 type Components struct {
 	// +optional
 	// DeveloperPortal enables the developer portal integration including APIProduct and APIKeyRequest CRDs
@@ -212,6 +266,45 @@ type KuadrantStatus struct {
 	// Mtls Limitador reflects the mtls feature state regarding comms with limitador.
 	// +optional
 	MtlsLimitador *bool `json:"mtlsLimitador,omitempty"`
+
+	// WasmConfigRollout reflects the progress of the rollout of the last change of the wasm configuration.
+	// +optional
+	WasmConfigRollout *WasmConfigRolloutStatus `json:"wasmConfigRollout,omitempty"`
+}
+
+type WasmConfigRolloutPhase string
+
+const (
+	// WasmConfigRolloutProgressing means the change is applied to the canary gateways only
+	WasmConfigRolloutProgressing WasmConfigRolloutPhase = "Progressing"
+	// WasmConfigRolloutPromoted means the change is applied to all the gateways
+	WasmConfigRolloutPromoted WasmConfigRolloutPhase = "Promoted"
+	// WasmConfigRolloutRolledBack means the change was reverted on the canary gateways
+	WasmConfigRolloutRolledBack WasmConfigRolloutPhase = "RolledBack"
+	// WasmConfigRolloutFailed means the change failed on the canary gateways, but could not be reverted on them
+	WasmConfigRolloutFailed WasmConfigRolloutPhase = "Failed"
+
+	DefaultWasmConfigRolloutBakeTime = 5 * time.Minute
+)
+
+// WasmConfigRolloutStatus reflects the progress of the rollout of a change of the wasm configuration
+type WasmConfigRolloutStatus struct {
+	// Revision identifies the wasm configuration of all the gateways being rolled out.
+	Revision string `json:"revision"`
+
+	// Phase of the rollout: Progressing, Promoted, RolledBack or Failed.
+	Phase WasmConfigRolloutPhase `json:"phase"`
+
+	// CanaryGateways are the gateways that got the change first.
+	// +optional
+	CanaryGateways []string `json:"canaryGateways,omitempty"`
+
+	// StartTime is when the change was applied to the canary gateways.
+	StartTime metav1.Time `json:"startTime"`
+
+	// Message explains the phase of the rollout.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 func (r *KuadrantStatus) Equals(other *KuadrantStatus, logger logr.Logger) bool {
@@ -233,6 +326,12 @@ func (r *KuadrantStatus) Equals(other *KuadrantStatus, logger logr.Logger) bool
 		return false
 	}
 
+	if !reflect.DeepEqual(r.WasmConfigRollout, other.WasmConfigRollout) {
+		diff := cmp.Diff(r.WasmConfigRollout, other.WasmConfigRollout)
+		logger.V(1).Info("WasmConfigRollout not equal", "difference", diff)
+		return false
+	}
+
 	// Marshalling sorts by condition type
 	currentMarshaledJSON, _ := kuadrant.ConditionMarshal(r.Conditions)
 	otherMarshaledJSON, _ := kuadrant.ConditionMarshal(other.Conditions)
@@ -160,6 +160,11 @@ func (in *KuadrantSpec) DeepCopyInto(out *KuadrantSpec) {
 		*out = new(Components)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.WasmConfigRollout != nil {
+		in, out := &in.WasmConfigRollout, &out.WasmConfigRollout
+		*out = new(WasmConfigRollout)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KuadrantSpec.
@@ -192,6 +197,11 @@ func (in *KuadrantStatus) DeepCopyInto(out *KuadrantStatus) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.WasmConfigRollout != nil {
+		in, out := &in.WasmConfigRollout, &out.WasmConfigRollout
+		*out = new(WasmConfigRolloutStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KuadrantStatus.
@@ -303,3 +313,69 @@ func (in *Tracing) DeepCopy() *Tracing {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WasmConfigRollout) DeepCopyInto(out *WasmConfigRollout) {
+	*out = *in
+	if in.CanaryGatewaySelector != nil {
+		in, out := &in.CanaryGatewaySelector, &out.CanaryGatewaySelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BakeTime != nil {
+		in, out := &in.BakeTime, &out.BakeTime
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Analysis != nil {
+		in, out := &in.Analysis, &out.Analysis
+		*out = new(WasmConfigRolloutAnalysis)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WasmConfigRollout.
+func (in *WasmConfigRollout) DeepCopy() *WasmConfigRollout {
+	if in == nil {
+		return nil
+	}
+	out := new(WasmConfigRollout)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WasmConfigRolloutAnalysis) DeepCopyInto(out *WasmConfigRolloutAnalysis) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WasmConfigRolloutAnalysis.
+func (in *WasmConfigRolloutAnalysis) DeepCopy() *WasmConfigRolloutAnalysis {
+	if in == nil {
+		return nil
+	}
+	out := new(WasmConfigRolloutAnalysis)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WasmConfigRolloutStatus) DeepCopyInto(out *WasmConfigRolloutStatus) {
+	*out = *in
+	if in.CanaryGateways != nil {
+		in, out := &in.CanaryGateways, &out.CanaryGateways
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.StartTime.DeepCopyInto(&out.StartTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WasmConfigRolloutStatus.
+func (in *WasmConfigRolloutStatus) DeepCopy() *WasmConfigRolloutStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WasmConfigRolloutStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -138,22 +138,30 @@ type Limits struct {
 	// Custom defines any additional limits defined in terms of a RateLimitPolicy Rate.
 	// +optional
 	Custom []kuadrantv1.Rate `json:"custom,omitempty"`
+
+	// TimeZone is the IANA time zone name (e.g. Europe/Madrid) in which the daily, weekly, monthly and yearly limits
+	// reset. When set, the limits are aligned to calendar days, weeks (starting on Monday), months and years
+	// instead of rolling windows.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
 func (l *Limits) ToRates() []kuadrantv1.Rate {
 	rates := make([]kuadrantv1.Rate, 0)
-	addRate := func(limit *int, window kuadrantv1.Duration) {
-		if limit != nil {
-			rates = append(rates, kuadrantv1.Rate{
-				Limit:  *limit,
-				Window: window,
-			})
+	addRate := func(limit *int, window kuadrantv1.Duration, period kuadrantv1.CalendarPeriod) {
+		if limit == nil {
+			return
+		}
+		rate := kuadrantv1.Rate{Limit: *limit, Window: window}
+		if l.TimeZone != "" {
+			rate = kuadrantv1.Rate{Limit: *limit, Calendar: period, TimeZone: l.TimeZone}
 		}
+		rates = append(rates, rate)
 	}
-	addRate(l.Daily, "24h")
-	addRate(l.Weekly, "168h")
-	addRate(l.Monthly, "730h")
-	addRate(l.Yearly, "8760h")
+	addRate(l.Daily, "24h", kuadrantv1.CalendarPeriodDay)
+	addRate(l.Weekly, "168h", kuadrantv1.CalendarPeriodWeek)
+	addRate(l.Monthly, "730h", kuadrantv1.CalendarPeriodMonth)
+	addRate(l.Yearly, "8760h", kuadrantv1.CalendarPeriodYear)
 	rates = append(rates, l.Custom...)
 	return rates
 }
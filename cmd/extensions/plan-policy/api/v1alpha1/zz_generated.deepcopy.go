@@ -52,7 +52,9 @@ func (in *Limits) DeepCopyInto(out *Limits) {
 	if in.Custom != nil {
 		in, out := &in.Custom, &out.Custom
 		*out = make([]v1.Rate, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
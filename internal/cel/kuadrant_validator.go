@@ -1,7 +1,11 @@
 package cel
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/samber/lo"
 
@@ -15,6 +19,11 @@ const (
 
 	AuthPolicyName = "auth"
 	RateLimitName  = "ratelimit"
+
+	// RequestBodyJSONFunc reads a value at a JSON pointer of the request body
+	RequestBodyJSONFunc = "requestBodyJSON"
+	// ResponseBodyJSONFunc reads a value at a JSON pointer of the response body
+	ResponseBodyJSONFunc = "responseBodyJSON"
 )
 
 var StateCELValidationErrors = "CELValidationErrors"
@@ -27,7 +36,7 @@ type Issue struct {
 
 func NewIssue(action wasm.Action, pathID string, err error) *Issue {
 	return &Issue{
-		policyKind: policyKindFromWasmServiceName(action.ServiceName),
+		policyKind: policyKindFromWasmAction(action),
 		pathID:     pathID,
 		err:        err,
 	}
@@ -77,34 +86,27 @@ func NewRootValidatorBuilder() *ValidatorBuilder {
 	builder.AddBinding("destination", cel.AnyType)
 	builder.AddBinding("connection", cel.AnyType)
 
-	requestBodyJSON := cel.Overload("request_body_json_string",
-		[]*cel.Type{cel.StringType},
-		cel.AnyType,
-		cel.UnaryBinding(func(_ ref.Val) ref.Val {
-			// just for parsing and checking purposes, not evaluation
-			return nil
-		},
-		),
-	)
+	builder.AddFunction(RequestBodyJSONFunc, jsonPointerOverload("request_body_json_string"))
+	builder.AddFunction(ResponseBodyJSONFunc, jsonPointerOverload("response_body_json_string"))
 
-	builder.AddFunction("requestBodyJSON", requestBodyJSON)
+	return builder
+}
 
-	responseBodyJSON := cel.Overload("response_body_json_string",
+// jsonPointerOverload declares a function that takes a JSON pointer and reads a value from a body
+func jsonPointerOverload(overloadID string) cel.FunctionOpt {
+	return cel.Overload(overloadID,
 		[]*cel.Type{cel.StringType},
 		cel.AnyType,
 		cel.UnaryBinding(func(_ ref.Val) ref.Val {
-			// just for parsing and checking purposes, not evaluation
-			return nil
+			// just for parsing and checking purposes, bodies are only available in the data plane
+			return types.NewErr("%s cannot be evaluated outside of the data plane", overloadID)
 		},
 		),
 	)
-	builder.AddFunction("responseBodyJSON", responseBodyJSON)
-
-	return builder
 }
 
 func ValidateWasmAction(action wasm.Action, validator *Validator) error {
-	pol := policyKindFromWasmServiceName(action.ServiceName)
+	pol := policyKindFromWasmAction(action)
 	for _, predicate := range action.Predicates {
 		if _, err := validator.Validate(pol, predicate); err != nil {
 			return err
@@ -120,6 +122,19 @@ func ValidateWasmAction(action wasm.Action, validator *Validator) error {
 	return nil
 }
 
+// policyKindFromWasmAction resolves the kind of policy an action was built from.
+// Token rate limit actions that reserve an estimate use the regular rate limit service, so the source
+// policy locators take precedence over the service name.
+func policyKindFromWasmAction(action wasm.Action) string {
+	tokenRateLimitPolicyLocatorPrefix := strings.ToLower(TokenRateLimitPolicyKind) + "."
+	if lo.SomeBy(action.SourcePolicyLocators, func(locator string) bool {
+		return strings.HasPrefix(locator, tokenRateLimitPolicyLocatorPrefix)
+	}) {
+		return TokenRateLimitPolicyKind
+	}
+	return policyKindFromWasmServiceName(action.ServiceName)
+}
+
 func policyKindFromWasmServiceName(serviceName string) string {
 	switch serviceName {
 	case wasm.AuthServiceName:
@@ -134,3 +149,23 @@ func policyKindFromWasmServiceName(serviceName string) string {
 		return RateLimitPolicyKind
 	}
 }
+
+// EvaluateWasmActionPredicates tells whether all the predicates evaluate to true against the activation, in the
+// environment of the kind of policy the action was built from
+func EvaluateWasmActionPredicates(action wasm.Action, predicates []string, validator *Validator, activation map[string]any) (bool, error) {
+	return EvaluatePredicates(validator, policyKindFromWasmAction(action), predicates, activation)
+}
+
+// EvaluatePredicates tells whether all the predicates evaluate to true against the activation
+func EvaluatePredicates(validator *Validator, policy string, predicates []string, activation map[string]any) (bool, error) {
+	for _, predicate := range predicates {
+		val, err := validator.Evaluate(policy, predicate, activation)
+		if err != nil {
+			return false, fmt.Errorf("failed to evaluate predicate %q: %w", predicate, err)
+		}
+		if matched, ok := val.Value().(bool); !ok || !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
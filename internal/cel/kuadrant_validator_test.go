@@ -30,6 +30,21 @@ func TestNewRootValidatorBuilder(t *testing.T) {
 	}
 }
 
+func TestNewRootValidatorBuilderBodyFunctions(t *testing.T) {
+	builder := NewRootValidatorBuilder()
+	builder.PushPolicyBinding(TokenRateLimitPolicyKind, RateLimitName, cel.AnyType)
+	validator, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expr := range []string{`requestBodyJSON("/max_tokens") > 0`, `responseBodyJSON("/usage/total_tokens") > 0`, `(requestBodyJSON("/max_tokens")) != null ? (requestBodyJSON("/max_tokens")) : 0`} {
+		if _, err := validator.Validate(TokenRateLimitPolicyKind, expr); err != nil {
+			t.Errorf("Should not have returned an error for %s: %v", expr, err)
+		}
+	}
+}
+
 func TestValidateWasmActionInvalidNoAuth(t *testing.T) {
 	wasmAction := wasm.Action{
 		ServiceName: wasm.RateLimitServiceName,
@@ -26,6 +26,28 @@ func parseExpression(expression string) (*ast.AST, error) {
 	return p, nil
 }
 
+// CheckSyntax parses the CEL `expression` and returns an error if it is not syntactically valid
+func CheckSyntax(expression string) error {
+	_, err := parseExpression(expression)
+	return err
+}
+
+// CallsFunction parses the CEL `expression` and tells whether it calls the global function `function` anywhere in
+// its AST
+func CallsFunction(expression, function string) (bool, error) {
+	p, err := parseExpression(expression)
+	if err != nil {
+		return false, err
+	}
+	calls := false
+	ast.PreOrderVisit(p.Expr(), ast.NewExprVisitor(func(expr ast.Expr) {
+		if expr.Kind() == ast.CallKind && !expr.AsCall().IsMemberFunction() && expr.AsCall().FunctionName() == function {
+			calls = true
+		}
+	}))
+	return calls, nil
+}
+
 // TransformCounterVariable Limitador, as of v2, does expose `descriptors` explicitly. As such `Limit`'s variables
 // need to be accessed through that root binding's `Ident`: `descriptors[0]`.
 // This function parses the CEL `expression` and traverses its AST to "rename" all bindings that are from "well-known
@@ -156,3 +156,34 @@ func TestTransformErrsOutOnBadSyntax(t *testing.T) {
 		t.Errorf(`We expected to fail here! But got "%s"`, *exp)
 	}
 }
+
+func TestCallsFunction(t *testing.T) {
+	testCases := []struct {
+		expression string
+		expected   bool
+	}{
+		{expression: `requestBodyJSON("/model")`, expected: true},
+		{expression: `requestBodyJSON ( "/model" )`, expected: true},
+		{expression: `string(requestBodyJSON("/model"))`, expected: true},
+		{expression: `request.headers["x-model"] != "" ? request.headers["x-model"] : requestBodyJSON("/model")`, expected: true},
+		{expression: `request.headers["x-model"]`, expected: false},
+		{expression: `responseBodyJSON("/model")`, expected: false},
+		{expression: `"requestBodyJSON(\"/model\")"`, expected: false},
+		{expression: `request.requestBodyJSON("/model")`, expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expression, func(t *testing.T) {
+			calls, err := CallsFunction(tc.expression, "requestBodyJSON")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if calls != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, calls)
+			}
+		})
+	}
+
+	if _, err := CallsFunction(`requestBodyJSON(`, "requestBodyJSON"); err == nil {
+		t.Errorf("expected a syntax error")
+	}
+}
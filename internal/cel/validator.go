@@ -5,6 +5,7 @@ import (
 	"slices"
 
 	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/ext"
 )
 
@@ -150,3 +151,19 @@ func (v *Validator) Validate(policy string, expr string) (*cel.Ast, error) {
 
 	return ast, nil
 }
+
+// Evaluate validates the expression for the given policy and evaluates it against the activation
+func (v *Validator) Evaluate(policy string, expr string, activation map[string]any) (ref.Val, error) {
+	ast, err := v.Validate(policy, expr)
+	if err != nil {
+		return nil, err
+	}
+
+	program, err := v.envs[policy].Program(ast)
+	if err != nil {
+		return nil, err
+	}
+
+	val, _, err := program.Eval(activation)
+	return val, err
+}
@@ -8,7 +8,6 @@ import (
 	"strings"
 	"sync"
 
-	envoygatewayv1alpha1 "github.com/envoyproxy/gateway/api/v1alpha1"
 	"github.com/go-logr/logr"
 	authorinooperatorv1beta1 "github.com/kuadrant/authorino-operator/api/v1beta1"
 	authorinov1beta3 "github.com/kuadrant/authorino/api/v1beta3"
@@ -22,9 +21,6 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/utils/ptr"
-	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
-	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 
 	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
 	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
@@ -35,11 +31,11 @@ import (
 	kuadrantistio "github.com/kuadrant/kuadrant-operator/internal/istio"
 	"github.com/kuadrant/kuadrant-operator/internal/kuadrant"
 	kuadrantpolicymachinery "github.com/kuadrant/kuadrant-operator/internal/policymachinery"
-	"github.com/kuadrant/kuadrant-operator/internal/wasm"
 )
 
 type AuthPolicyStatusUpdater struct {
-	client *dynamic.DynamicClient
+	client           *dynamic.DynamicClient
+	gatewayProviders []GatewayProvider
 }
 
 // AuthPolicyStatusUpdater reconciles to events with impact to change the status of AuthPolicy resources
@@ -52,11 +48,13 @@ func (r *AuthPolicyStatusUpdater) Subscription() controller.Subscription {
 			{Kind: &machinery.GatewayGroupKind},
 			{Kind: &machinery.HTTPRouteGroupKind},
 			{Kind: &machinery.GRPCRouteGroupKind},
+			{Kind: &NamespaceGroupKind},
 			{Kind: &kuadrantv1.AuthPolicyGroupKind},
 			{Kind: &kuadrantauthorino.AuthConfigGroupKind},
 			{Kind: &kuadrantistio.EnvoyFilterGroupKind},
 			{Kind: &kuadrantenvoygateway.EnvoyPatchPolicyGroupKind},
 			{Kind: &kuadrantenvoygateway.EnvoyExtensionPolicyGroupKind},
+			{Kind: &ConfigMapGroupKind},
 		},
 	}
 }
@@ -100,13 +98,17 @@ func (r *AuthPolicyStatusUpdater) UpdateStatus(ctx context.Context, _ []controll
 
 		accepted, err := policyAcceptedFunc(policy)
 		meta.SetStatusCondition(&newStatus.Conditions, *kuadrant.AcceptedCondition(policy, err))
+		if len(policy.Spec.TargetRefs) > 0 {
+			newStatus.Targets = policyTargetStatuses(policy, topology, policy.Status.Targets)
+		}
 
 		// do not set enforced condition if Accepted condition is false
 		if !accepted {
 			meta.RemoveStatusCondition(&newStatus.Conditions, string(kuadrant.PolicyConditionEnforced))
 		} else {
-			enforcedCond := r.enforcedCondition(policy, topology, state, logger)
+			enforcedCond, shadowed := r.enforcedCondition(policy, topology, state, logger)
 			meta.SetStatusCondition(&newStatus.Conditions, *enforcedCond)
+			newStatus.ShadowedRules = shadowed
 		}
 
 		equalStatus := equality.Semantic.DeepEqual(newStatus, policy.Status)
@@ -154,16 +156,16 @@ func (r *AuthPolicyStatusUpdater) UpdateStatus(ctx context.Context, _ []controll
 	return nil
 }
 
-func (r *AuthPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.AuthPolicy, topology *machinery.Topology, state *sync.Map, logger logr.Logger) *metav1.Condition {
+func (r *AuthPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.AuthPolicy, topology *machinery.Topology, state *sync.Map, logger logr.Logger) (*metav1.Condition, []kuadrantv1.ShadowedRule) {
 	kObj := GetKuadrantFromTopology(topology, state)
 	if kObj == nil {
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrSystemResource("kuadrant"), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrSystemResource("kuadrant"), false), nil
 	}
 	policyKind := kuadrantv1.AuthPolicyGroupKind.Kind
 
 	effectivePolicies, ok := state.Load(StateEffectiveAuthPolicies)
 	if !ok {
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrUnknown(policyKind, ErrMissingStateEffectiveAuthPolicies), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrUnknown(policyKind, ErrMissingStateEffectiveAuthPolicies), false), nil
 	}
 
 	type affectedGateway struct {
@@ -174,6 +176,8 @@ func (r *AuthPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.AuthPolic
 	// check the state of the rules of the policy in the effective policies
 	policyRuleKeys := lo.Keys(policy.Rules())
 	overridingPolicies := map[string][]string{}                     // policyRuleKey → locators of policies overriding the policy rule
+	shadowedPaths := map[string][]string{}                          // policyRuleKey → IDs of the paths where the policy rule is overridden
+	var enforcingPolicies []string                                  // locators of policies not in shadow mode enforcing the rules of the policy
 	affectedGateways := map[string]affectedGateway{}                // Gateway locator → {GatewayClass, Gateway}
 	affectedHTTPRouteRules := map[string]*machinery.HTTPRouteRule{} // pathID → HTTPRouteRule
 	affectedGRPCRouteRules := map[string]*machinery.GRPCRouteRule{} // pathID → GRPCRouteRule
@@ -201,8 +205,11 @@ func (r *AuthPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.AuthPolic
 		celIssuesByPathID, celIssuesFound = celIssuesCollection.GetByPolicyKind(policyKind)
 	}
 
+	namespaceLabels := namespaceLabelsFunc(topology)
 	for pathID, effectivePolicy := range effectivePolicies.(EffectiveAuthPolicies) {
-		if len(kuadrantv1.PoliciesInPath(effectivePolicy.Path, func(p machinery.Policy) bool { return p.GetLocator() == policy.GetLocator() })) == 0 {
+		if len(kuadrantv1.PoliciesInPath(effectivePolicy.Path, func(p machinery.Policy) bool {
+			return p.GetLocator() == policy.GetLocator() && kuadrantv1.PolicySelectsPath(p, effectivePolicy.Path, namespaceLabels)
+		})) == 0 {
 			continue
 		}
 
@@ -257,9 +264,13 @@ func (r *AuthPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.AuthPolic
 						// to the effective policy are the ones that overrode this policy
 						overridingPolicies[policyRuleKey] = append(overridingPolicies[policyRuleKey], effectivePolicy.SourcePolicies...)
 					}
+					shadowedPaths[policyRuleKey] = append(shadowedPaths[policyRuleKey], kuadrantv1.PathID(effectivePolicy.Path))
 					continue
 				}
 				// policy rule is in the effective policy, track the Gateway and the route rule affected by the policy
+				if policy.InShadowMode() {
+					enforcingPolicies = append(enforcingPolicies, lo.Map(enforcingAuthSourcePolicies(effectivePolicy), func(p machinery.Policy, _ int) string { return p.GetLocator() })...)
+				}
 				if parsed.RouteType == kuadrantpolicymachinery.RouteTypeHTTP {
 					setAffectedHTTPObjects(pathID, parsed.GatewayClass, parsed.Gateway, parsed.HTTPRouteRule)
 				} else {
@@ -278,14 +289,14 @@ func (r *AuthPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.AuthPolic
 
 	if len(affectedGateways) == 0 { // no rules of the policy found in the effective policies
 		if len(overridingPolicies) == 0 { // no rules of the policy have been overridden by any other policy
-			return kuadrant.EnforcedCondition(policy, kuadrant.NewErrNoRoutes(policyKind), false)
+			return kuadrant.EnforcedCondition(policy, kuadrant.NewErrNoRoutes(policyKind), false), shadowedRules(overridingPolicies, shadowedPaths)
 		}
 		// all rules of the policy have been overridden by at least one other policy
 		overridingPoliciesKeys := lo.FilterMap(lo.Uniq(lo.Flatten(lo.Values(overridingPolicies))), func(policyLocator string, _ int) (k8stypes.NamespacedName, bool) {
 			policyKey, err := kuadrantpolicymachinery.NamespacedNameFromLocator(policyLocator)
 			return policyKey, err == nil
 		})
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrOverridden(policyKind, overridingPoliciesKeys), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrOverridden(policyKind, overridingPoliciesKeys), false), shadowedRules(overridingPolicies, shadowedPaths)
 	}
 
 	var componentsToSync []string
@@ -293,7 +304,7 @@ func (r *AuthPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.AuthPolic
 	// check the status of Authorino
 	authorino := GetAuthorinoFromTopology(topology, state)
 	if authorino == nil {
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrSystemResource("authornio"), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrSystemResource("authornio"), false), shadowedRules(overridingPolicies, shadowedPaths)
 	}
 	if !meta.IsStatusConditionTrue(lo.Map(authorino.Status.Conditions, authorinoOperatorConditionToProperConditionFunc), string(authorinooperatorv1beta1.ConditionReady)) {
 		componentsToSync = append(componentsToSync, kuadrantv1beta1.AuthorinoGroupKind.Kind)
@@ -324,47 +335,42 @@ func (r *AuthPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.AuthPolic
 
 	// check the status of the gateways' configuration resources
 	for _, g := range affectedGateways {
-		controllerName := g.gatewayClass.Spec.ControllerName
-		switch defaultGatewayControllerName(controllerName) {
-		case defaultIstioGatewayControllerName:
-			// EnvoyFilter (auth clusters)
-			istioAuthClustersModifiedGateways, _ := state.Load(StateIstioAuthClustersModified)
-			componentsToSync = append(componentsToSync, gatewayComponentsToSyncWithName(g.gateway, kuadrantistio.EnvoyFilterGroupKind, AuthClusterName(g.gateway.GetName()), istioAuthClustersModifiedGateways, topology, func(_ machinery.Object) bool {
-				// return meta.IsStatusConditionTrue(lo.Map(obj.(*controller.RuntimeObject).Object.(*istioclientgonetworkingv1alpha3.EnvoyFilter).Status.Conditions, kuadrantistio.ConditionToProperConditionFunc), "Ready")
-				return true // Istio won't ever populate the status stanza of EnvoyFilter resources, so we cannot expect to find a given a condition there
-			})...)
-			// EnvoyFilter (wasm plugin)
-			istioExtensionsModifiedGateways, _ := state.Load(StateIstioExtensionsModified)
-			componentsToSync = append(componentsToSync, gatewayComponentsToSyncWithName(g.gateway, kuadrantistio.EnvoyFilterGroupKind, wasm.ExtensionName(g.gateway.GetName()), istioExtensionsModifiedGateways, topology, func(_ machinery.Object) bool {
-				// return meta.IsStatusConditionTrue(lo.Map(obj.(*controller.RuntimeObject).Object.(*istioclientgonetworkingv1alpha3.EnvoyFilter).Status.Conditions, kuadrantistio.ConditionToProperConditionFunc), "Ready")
-				return true // Istio won't ever populate the status stanza of EnvoyFilter resources, so we cannot expect to find a given a condition there
-			})...)
-		case defaultEnvoyGatewayGatewayControllerName:
-			gatewayAncestor := gatewayapiv1.ParentReference{Name: gatewayapiv1.ObjectName(g.gateway.GetName()), Namespace: ptr.To(gatewayapiv1.Namespace(g.gateway.GetNamespace()))}
-			// EnvoyPatchPolicy
-			envoyGatewayAuthClustersModifiedGateways, _ := state.Load(StateEnvoyGatewayAuthClustersModified)
-			componentsToSync = append(componentsToSync, gatewayComponentsToSync(g.gateway, kuadrantenvoygateway.EnvoyPatchPolicyGroupKind, envoyGatewayAuthClustersModifiedGateways, topology, func(obj machinery.Object) bool {
-				return meta.IsStatusConditionTrue(kuadrantgatewayapi.PolicyStatusConditionsFromAncestor(obj.(*controller.RuntimeObject).Object.(*envoygatewayv1alpha1.EnvoyPatchPolicy).Status, controllerName, gatewayAncestor, gatewayapiv1.Namespace(obj.GetNamespace())), string(envoygatewayv1alpha1.PolicyConditionProgrammed))
-			})...)
-			// EnvoyExtensionPolicy
-			envoyGatewayExtensionsModifiedGateways, _ := state.Load(StateEnvoyGatewayExtensionsModified)
-			componentsToSync = append(componentsToSync, gatewayComponentsToSync(g.gateway, kuadrantenvoygateway.EnvoyExtensionPolicyGroupKind, envoyGatewayExtensionsModifiedGateways, topology, func(obj machinery.Object) bool {
-				return meta.IsStatusConditionTrue(kuadrantgatewayapi.PolicyStatusConditionsFromAncestor(obj.(*controller.RuntimeObject).Object.(*envoygatewayv1alpha1.EnvoyExtensionPolicy).Status, controllerName, gatewayAncestor, gatewayapiv1.Namespace(obj.GetNamespace())), string(gatewayapiv1alpha2.PolicyConditionAccepted))
-			})...)
-		default:
+		provider, found := gatewayProviderFor(r.gatewayProviders, g.gatewayClass.Spec.ControllerName)
+		if !found {
 			componentsToSync = append(componentsToSync, fmt.Sprintf("%s (%s/%s)", machinery.GatewayGroupKind.Kind, g.gateway.GetNamespace(), g.gateway.GetName()))
+			continue
 		}
+		componentsToSync = append(componentsToSync, provider.ComponentsToSync(kuadrantv1.AuthPolicyGroupKind, g.gateway, g.gatewayClass, topology, state)...)
 	}
 
 	if len(celValidationErrors) > 0 {
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrCelValidation(celValidationErrors), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrCelValidation(celValidationErrors), false), shadowedRules(overridingPolicies, shadowedPaths)
 	}
 
 	if len(componentsToSync) > 0 {
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrOutOfSync(policyKind, componentsToSync), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrOutOfSync(policyKind, componentsToSync), false), shadowedRules(overridingPolicies, shadowedPaths)
+	}
+
+	if features := nativeDataPlaneUnsupportedFeatures(state)[policy.GetLocator()]; len(features) > 0 {
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrUnsupportedFeature(policyKind, features), false), shadowedRules(overridingPolicies, shadowedPaths)
+	}
+
+	if policy.InShadowMode() {
+		if len(enforcingPolicies) > 0 {
+			// the rules of the policy are merged with the ones of policies not in shadow mode, thus enforced
+			enforcingPoliciesKeys := lo.FilterMap(lo.Uniq(enforcingPolicies), func(policyLocator string, _ int) (k8stypes.NamespacedName, bool) {
+				policyKey, err := kuadrantpolicymachinery.NamespacedNameFromLocator(policyLocator)
+				return policyKey, err == nil
+			})
+			return kuadrant.ShadowEnforcedCondition(policy, enforcingPoliciesKeys), shadowedRules(overridingPolicies, shadowedPaths)
+		}
+		if err := shadowModeUnsupportedError(policyKind, lo.MapToSlice(affectedGateways, func(_ string, g affectedGateway) *machinery.Gateway { return g.gateway })); err != nil {
+			return kuadrant.EnforcedCondition(policy, err, false), shadowedRules(overridingPolicies, shadowedPaths)
+		}
+		return kuadrant.ShadowCondition(policy), shadowedRules(overridingPolicies, shadowedPaths)
 	}
 
-	return kuadrant.EnforcedCondition(policy, nil, len(overridingPolicies) == 0)
+	return kuadrant.EnforcedCondition(policy, nil, len(overridingPolicies) == 0), shadowedRules(overridingPolicies, shadowedPaths)
 }
 
 func authorinoOperatorConditionToProperConditionFunc(condition authorinooperatorv1beta1.Condition, _ int) metav1.Condition {
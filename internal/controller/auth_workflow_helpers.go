@@ -97,11 +97,21 @@ func buildWasmActionsForAuth(pathID string, effectivePolicy EffectiveAuthPolicy)
 		Scope:                AuthConfigNameForPath(pathID),
 		Predicates:           spec.Predicates.Into(),
 		SourcePolicyLocators: effectivePolicy.SourcePolicies,
+		ReportOnly:           len(effectivePolicy.SourcePolicies) > 0 && len(enforcingAuthSourcePolicies(effectivePolicy)) == 0,
 	}
 
 	return []wasm.Action{action}
 }
 
+// enforcingAuthSourcePolicies returns the source policies of an effective auth policy that are not in shadow mode.
+// The auth config of a path merges the rules of all its source policies, so the path is only left unenforced if
+// none of them is returned.
+func enforcingAuthSourcePolicies(effectivePolicy EffectiveAuthPolicy) []machinery.Policy {
+	return kuadrantv1.PoliciesInPath(effectivePolicy.Path, func(p machinery.Policy) bool {
+		return lo.Contains(effectivePolicy.SourcePolicies, p.GetLocator()) && !kuadrant.IsInShadowMode(p)
+	})
+}
+
 func isAuthPolicyAcceptedAndNotDeletedFunc(state *sync.Map) func(machinery.Policy) bool {
 	f := isAuthPolicyAcceptedFunc(state)
 	return func(policy machinery.Policy) bool {
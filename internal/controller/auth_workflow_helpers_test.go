@@ -0,0 +1,82 @@
+//go:build unit
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
+)
+
+type targetableWithPolicies struct {
+	machinery.Targetable
+	policies []machinery.Policy
+}
+
+func (t *targetableWithPolicies) Policies() []machinery.Policy {
+	return t.policies
+}
+
+func TestBuildWasmActionsForAuthReportOnly(t *testing.T) {
+	authPolicy := func(name string, mode kuadrantv1.EnforcementMode) *kuadrantv1.AuthPolicy {
+		return &kuadrantv1.AuthPolicy{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       kuadrantv1.AuthPolicyGroupKind.Kind,
+				APIVersion: kuadrantv1.GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       kuadrantv1.AuthPolicySpec{Mode: mode},
+		}
+	}
+
+	testCases := []struct {
+		name               string
+		policies           []machinery.Policy
+		expectedReportOnly bool
+		expectedEnforcing  []string
+	}{
+		{
+			name:               "enforced policy",
+			policies:           []machinery.Policy{authPolicy("enforced", kuadrantv1.EnforcementModeEnforce)},
+			expectedReportOnly: false,
+			expectedEnforcing:  []string{"enforced"},
+		},
+		{
+			name:               "shadow policy",
+			policies:           []machinery.Policy{authPolicy("shadow", kuadrantv1.EnforcementModeShadow)},
+			expectedReportOnly: true,
+		},
+		{
+			name:               "shadow policy merged with an enforced policy",
+			policies:           []machinery.Policy{authPolicy("shadow", kuadrantv1.EnforcementModeShadow), authPolicy("enforced", kuadrantv1.EnforcementModeEnforce)},
+			expectedReportOnly: false,
+			expectedEnforcing:  []string{"enforced"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			effectivePolicy := EffectiveAuthPolicy{
+				Path:           []machinery.Targetable{&targetableWithPolicies{policies: tc.policies}},
+				SourcePolicies: lo.Map(tc.policies, func(p machinery.Policy, _ int) string { return p.GetLocator() }),
+			}
+
+			actions := buildWasmActionsForAuth("path", effectivePolicy)
+			if len(actions) != 1 {
+				t.Fatalf("expected 1 action, got %d", len(actions))
+			}
+			if actions[0].ReportOnly != tc.expectedReportOnly {
+				t.Errorf("expected reportOnly %t, got %t", tc.expectedReportOnly, actions[0].ReportOnly)
+			}
+
+			enforcing := lo.Map(enforcingAuthSourcePolicies(effectivePolicy), func(p machinery.Policy, _ int) string { return p.GetName() })
+			if len(enforcing) != len(tc.expectedEnforcing) || !lo.Every(enforcing, tc.expectedEnforcing) {
+				t.Errorf("expected enforcing source policies %v, got %v", tc.expectedEnforcing, enforcing)
+			}
+		})
+	}
+}
@@ -44,6 +44,7 @@ func (r *AuthConfigsReconciler) Subscription() controller.Subscription {
 			{Kind: &machinery.GatewayGroupKind},
 			{Kind: &machinery.HTTPRouteGroupKind},
 			{Kind: &machinery.GRPCRouteGroupKind},
+			{Kind: &NamespaceGroupKind},
 			{Kind: &kuadrantv1.AuthPolicyGroupKind},
 			{Kind: &kuadrantauthorino.AuthConfigGroupKind},
 		},
@@ -73,8 +74,10 @@ func (r *AuthConfigsReconciler) Reconcile(ctx context.Context, _ []controller.Re
 	desiredAuthConfigs := make(map[k8stypes.NamespacedName]struct{})
 	modifiedAuthConfigs := []string{}
 
+	holdsSharedChanges := wasmConfigRolloutFromState(state).HoldsSharedChanges()
+
 	for pathID, effectivePolicy := range effectivePoliciesMap {
-		authConfigName, modified := r.reconcileAuthConfigForPath(ctx, pathID, effectivePolicy, authConfigsNamespace, topology)
+		authConfigName, modified := r.reconcileAuthConfigForPath(ctx, pathID, effectivePolicy, authConfigsNamespace, topology, holdsSharedChanges)
 		if authConfigName == "" {
 			continue
 		}
@@ -88,6 +91,13 @@ func (r *AuthConfigsReconciler) Reconcile(ctx context.Context, _ []controller.Re
 		state.Store(StateModifiedAuthConfigs, modifiedAuthConfigs)
 	}
 
+	// the authconfigs still referenced by the gateways that keep the stable wasm configuration are not deleted while the
+	// rollout of the change is progressing
+	if holdsSharedChanges {
+		logger.V(1).Info("keeping stale authconfig objects while the rollout of the wasm configuration is progressing")
+		return nil
+	}
+
 	// cleanup authconfigs that are not in the effective policies
 	staleAuthConfigs := topology.Objects().Items(func(o machinery.Object) bool {
 		_, desired := desiredAuthConfigs[k8stypes.NamespacedName{Name: o.GetName(), Namespace: o.GetNamespace()}]
@@ -105,7 +115,7 @@ func (r *AuthConfigsReconciler) Reconcile(ctx context.Context, _ []controller.Re
 
 // reconcileAuthConfigForPath reconciles the AuthConfig for a single effective policy path.
 // It returns the authConfigName (empty if the path is invalid) and whether the object was modified.
-func (r *AuthConfigsReconciler) reconcileAuthConfigForPath(ctx context.Context, pathID string, effectivePolicy EffectiveAuthPolicy, authConfigsNamespace string, topology *machinery.Topology) (string, bool) {
+func (r *AuthConfigsReconciler) reconcileAuthConfigForPath(ctx context.Context, pathID string, effectivePolicy EffectiveAuthPolicy, authConfigsNamespace string, topology *machinery.Topology, holdsSharedChanges bool) (string, bool) {
 	logger := controller.LoggerFromContext(ctx).WithName("AuthConfigsReconciler")
 
 	parsed, err := kuadrantpolicymachinery.ParseTopologyPath(effectivePolicy.Path)
@@ -173,6 +183,12 @@ func (r *AuthConfigsReconciler) reconcileAuthConfigForPath(ctx context.Context,
 		return authConfigName, false
 	}
 
+	// the authconfig is shared by all the gateways, including the ones that keep the stable wasm configuration
+	if holdsSharedChanges {
+		logger.V(1).Info("authconfig object kept while the rollout of the wasm configuration is progressing", "authconfig", authConfigName)
+		return authConfigName, false
+	}
+
 	// delete
 	if utils.IsObjectTaggedToDelete(desiredAuthConfig) && !utils.IsObjectTaggedToDelete(existingAuthConfig) {
 		if err := resource.Delete(ctx, existingAuthConfig.GetName(), metav1.DeleteOptions{}); err != nil {
@@ -3,6 +3,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 
 	"github.com/kuadrant/policy-machinery/machinery"
@@ -83,3 +84,20 @@ func policyGroupKinds() []*schema.GroupKind {
 		&kuadrantv1.DNSPolicyGroupKind,
 	}
 }
+
+// shadowedRules lists, sorted by rule ID, the rules of a policy that are replaced in the effective policies by the rules
+// of other policies, given the locators of the policies overriding each rule and the IDs of the paths where each rule is overridden
+func shadowedRules(overridingPolicies, shadowedPaths map[string][]string) []kuadrantv1.ShadowedRule {
+	if len(overridingPolicies) == 0 {
+		return nil
+	}
+	ruleIDs := lo.Keys(overridingPolicies)
+	slices.Sort(ruleIDs)
+	return lo.Map(ruleIDs, func(ruleID string, _ int) kuadrantv1.ShadowedRule {
+		shadowedBy := lo.Uniq(overridingPolicies[ruleID])
+		slices.Sort(shadowedBy)
+		paths := lo.Uniq(shadowedPaths[ruleID])
+		slices.Sort(paths)
+		return kuadrantv1.ShadowedRule{Rule: ruleID, ShadowedBy: shadowedBy, Paths: paths}
+	})
+}
@@ -0,0 +1,41 @@
+//go:build unit
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
+)
+
+func TestShadowedRules(t *testing.T) {
+	if rules := shadowedRules(map[string][]string{}, map[string][]string{}); rules != nil {
+		t.Errorf("expected no shadowed rules, got %v", rules)
+	}
+
+	overridingPolicies := map[string][]string{
+		"authorization#opa-guardrail": {"kuadrant.io/v1/authpolicy:ns-b/gw-policy", "kuadrant.io/v1/authpolicy:ns-a/gw-policy", "kuadrant.io/v1/authpolicy:ns-b/gw-policy"},
+		"authentication#api-key":      {"kuadrant.io/v1/authpolicy:ns-a/gw-policy"},
+	}
+	shadowedPaths := map[string][]string{
+		"authorization#opa-guardrail": {"gw-class|ns-a/gw|ns-a/gw#http|ns-a/route-2|ns-a/route-2#rule-1", "gw-class|ns-a/gw|ns-a/gw#http|ns-a/route-1|ns-a/route-1#rule-1", "gw-class|ns-a/gw|ns-a/gw#http|ns-a/route-2|ns-a/route-2#rule-1"},
+		"authentication#api-key":      {"gw-class|ns-a/gw|ns-a/gw#http|ns-a/route-1|ns-a/route-1#rule-1"},
+	}
+
+	expected := []kuadrantv1.ShadowedRule{
+		{
+			Rule:       "authentication#api-key",
+			ShadowedBy: []string{"kuadrant.io/v1/authpolicy:ns-a/gw-policy"},
+			Paths:      []string{"gw-class|ns-a/gw|ns-a/gw#http|ns-a/route-1|ns-a/route-1#rule-1"},
+		},
+		{
+			Rule:       "authorization#opa-guardrail",
+			ShadowedBy: []string{"kuadrant.io/v1/authpolicy:ns-a/gw-policy", "kuadrant.io/v1/authpolicy:ns-b/gw-policy"},
+			Paths:      []string{"gw-class|ns-a/gw|ns-a/gw#http|ns-a/route-1|ns-a/route-1#rule-1", "gw-class|ns-a/gw|ns-a/gw#http|ns-a/route-2|ns-a/route-2#rule-1"},
+		},
+	}
+	if rules := shadowedRules(overridingPolicies, shadowedPaths); !reflect.DeepEqual(rules, expected) {
+		t.Errorf("unexpected shadowed rules, expected(%v), got (%v)", expected, rules)
+	}
+}
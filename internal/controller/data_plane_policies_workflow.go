@@ -2,10 +2,13 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"slices"
 	"strings"
+	"sync"
 
+	"github.com/google/cel-go/cel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	controllerruntime "sigs.k8s.io/controller-runtime"
@@ -22,8 +25,12 @@ import (
 	kuadrantv1alpha1 "github.com/kuadrant/kuadrant-operator/api/v1alpha1"
 	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
 	kuadrantauthorino "github.com/kuadrant/kuadrant-operator/internal/authorino"
+	celvalidator "github.com/kuadrant/kuadrant-operator/internal/cel"
 	kuadrantenvoygateway "github.com/kuadrant/kuadrant-operator/internal/envoygateway"
+	kuadrantgatewayapi "github.com/kuadrant/kuadrant-operator/internal/gatewayapi"
 	kuadrantistio "github.com/kuadrant/kuadrant-operator/internal/istio"
+	"github.com/kuadrant/kuadrant-operator/internal/kuadrant"
+	kuadrantpolicymachinery "github.com/kuadrant/kuadrant-operator/internal/policymachinery"
 	"github.com/kuadrant/kuadrant-operator/internal/wasm"
 )
 
@@ -44,6 +51,9 @@ var (
 	StateIstioExtensionsModified        = "IstioExtensionsModified"
 	StateEnvoyGatewayExtensionsModified = "EnvoyGatewayExtensionsModified"
 
+	StateIstioNativeFiltersModified        = "IstioNativeFiltersModified"
+	StateEnvoyGatewayNativeFiltersModified = "EnvoyGatewayNativeFiltersModified"
+
 	// Event matchers to match events with potential impact on effective data plane policies (auth or rate limit)
 	dataPlaneEffectivePoliciesEventMatchers = []controller.ResourceEventMatcher{
 		{Kind: &kuadrantv1beta1.KuadrantGroupKind},
@@ -51,6 +61,7 @@ var (
 		{Kind: &machinery.GatewayGroupKind},
 		{Kind: &machinery.HTTPRouteGroupKind},
 		{Kind: &machinery.GRPCRouteGroupKind},
+		{Kind: &NamespaceGroupKind},
 		{Kind: &kuadrantv1.RateLimitPolicyGroupKind},
 		{Kind: &kuadrantv1alpha1.TokenRateLimitPolicyGroupKind},
 		{Kind: &kuadrantv1beta1.LimitadorGroupKind},
@@ -77,8 +88,8 @@ var (
 //+kubebuilder:rbac:groups=kuadrant.io,resources=tokenratelimitpolicies/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=kuadrant.io,resources=tokenratelimitpolicies/finalizers,verbs=update
 
-func NewDataPlanePoliciesWorkflow(mgr controllerruntime.Manager, client *dynamic.DynamicClient, isGatewayAPInstalled, isIstioInstalled, isEnvoyGatewayInstalled, isLimitadorOperatorInstalled, isAuthorinoOperatorInstalled bool) *controller.Workflow {
-	isGatewayProviderInstalled := isIstioInstalled || isEnvoyGatewayInstalled
+func NewDataPlanePoliciesWorkflow(mgr controllerruntime.Manager, client *dynamic.DynamicClient, isGatewayAPInstalled bool, gatewayProviders []GatewayProvider, isLimitadorOperatorInstalled, isAuthorinoOperatorInstalled bool, wasmConfigRollout *wasmConfigRolloutTracker) *controller.Workflow {
+	isGatewayProviderInstalled := len(gatewayProviders) > 0
 	dataPlanePoliciesValidation := &controller.Workflow{
 		Tasks: []controller.ReconcileFunc{
 			traceReconcileFunc("validator.auth_policy", (&AuthPolicyValidator{isGatewayAPIInstalled: isGatewayAPInstalled, isAuthorinoOperatorInstalled: isAuthorinoOperatorInstalled, isGatewayProviderInstalled: isGatewayProviderInstalled}).Subscription().Reconcile),
@@ -94,6 +105,7 @@ func NewDataPlanePoliciesWorkflow(mgr controllerruntime.Manager, client *dynamic
 				traceReconcileFunc("effective_policies.ratelimit", (&EffectiveRateLimitPolicyReconciler{client: client}).Subscription().Reconcile),
 				traceReconcileFunc("effective_policies.token_ratelimit", (&EffectiveTokenRateLimitPolicyReconciler{client: client}).Subscription().Reconcile),
 			},
+			Postcondition: traceReconcileFunc("effective_policies.wasm_config_rollout", NewWasmConfigRolloutReconciler(gatewayProviders, wasmConfigRollout).Run),
 		}).Run),
 		Tasks: []controller.ReconcileFunc{
 			traceReconcileFunc("reconciler.auth_configs", (&AuthConfigsReconciler{client: client}).Subscription().Reconcile),
@@ -101,41 +113,21 @@ func NewDataPlanePoliciesWorkflow(mgr controllerruntime.Manager, client *dynamic
 		},
 	}
 
-	if isIstioInstalled {
-		effectiveDataPlanePoliciesWorkflow.Tasks = append(effectiveDataPlanePoliciesWorkflow.Tasks,
-			traceReconcileFunc("reconciler.istio_auth_cluster", (&IstioAuthClusterReconciler{client: client}).Subscription().Reconcile))
-		effectiveDataPlanePoliciesWorkflow.Tasks = append(effectiveDataPlanePoliciesWorkflow.Tasks,
-			traceReconcileFunc("reconciler.istio_ratelimit_cluster", (&IstioRateLimitClusterReconciler{client: client}).Subscription().Reconcile))
-		effectiveDataPlanePoliciesWorkflow.Tasks = append(effectiveDataPlanePoliciesWorkflow.Tasks,
-			traceReconcileFunc("reconciler.istio_tracing_cluster", (&IstioTracingClusterReconciler{client: client}).Subscription().Reconcile))
-		effectiveDataPlanePoliciesWorkflow.Tasks = append(effectiveDataPlanePoliciesWorkflow.Tasks,
-			traceReconcileFunc("reconciler.istio_extension", (&IstioExtensionReconciler{client: client}).Subscription().Reconcile))
-	}
-
-	if isEnvoyGatewayInstalled {
-		effectiveDataPlanePoliciesWorkflow.Tasks = append(effectiveDataPlanePoliciesWorkflow.Tasks,
-			traceReconcileFunc("reconciler.envoy_gateway_auth_cluster", (&EnvoyGatewayAuthClusterReconciler{client: client}).Subscription().Reconcile))
-		effectiveDataPlanePoliciesWorkflow.Tasks = append(effectiveDataPlanePoliciesWorkflow.Tasks,
-			traceReconcileFunc("reconciler.envoy_gateway_ratelimit_cluster", (&EnvoyGatewayRateLimitClusterReconciler{client: client}).Subscription().Reconcile))
-		effectiveDataPlanePoliciesWorkflow.Tasks = append(effectiveDataPlanePoliciesWorkflow.Tasks,
-			traceReconcileFunc("reconciler.envoy_gateway_tracing_cluster", (&EnvoyGatewayTracingClusterReconciler{client: client}).Subscription().Reconcile))
-		effectiveDataPlanePoliciesWorkflow.Tasks = append(effectiveDataPlanePoliciesWorkflow.Tasks,
-			traceReconcileFunc("reconciler.envoy_gateway_extension", (&EnvoyGatewayExtensionReconciler{client: client}).Subscription().Reconcile))
+	gatewayProviderOptions := GatewayProviderOptions{
+		Manager:                      mgr,
+		Client:                       client,
+		IsLimitadorOperatorInstalled: isLimitadorOperatorInstalled,
+		IsAuthorinoOperatorInstalled: isAuthorinoOperatorInstalled,
 	}
-
-	if isIstioInstalled && isAuthorinoOperatorInstalled && isLimitadorOperatorInstalled {
-		effectiveDataPlanePoliciesWorkflow.Tasks = append(effectiveDataPlanePoliciesWorkflow.Tasks,
-			traceReconcileFunc("reconciler.peer_authentication", NewPeerAuthenticationReconciler(mgr, client).Subscription().Reconcile),
-			traceReconcileFunc("reconciler.limitador_istio_integration", NewLimitadorIstioIntegrationReconciler(mgr, client).Subscription().Reconcile),
-			traceReconcileFunc("reconciler.authorino_istio_integration", NewAuthorinoIstioIntegrationReconciler(mgr, client).Subscription().Reconcile),
-		)
+	for _, provider := range gatewayProviders {
+		effectiveDataPlanePoliciesWorkflow.Tasks = append(effectiveDataPlanePoliciesWorkflow.Tasks, provider.Reconcilers(gatewayProviderOptions)...)
 	}
 
 	dataPlanePoliciesStatus := &controller.Workflow{
 		Tasks: []controller.ReconcileFunc{
-			traceReconcileFunc("status.auth_policy", (&AuthPolicyStatusUpdater{client: client}).Subscription().Reconcile),
-			traceReconcileFunc("status.ratelimit_policy", (&RateLimitPolicyStatusUpdater{client: client}).Subscription().Reconcile),
-			traceReconcileFunc("status.token_ratelimit_policy", (&TokenRateLimitPolicyStatusUpdater{client: client}).Subscription().Reconcile),
+			traceReconcileFunc("status.auth_policy", (&AuthPolicyStatusUpdater{client: client, gatewayProviders: gatewayProviders}).Subscription().Reconcile),
+			traceReconcileFunc("status.ratelimit_policy", (&RateLimitPolicyStatusUpdater{client: client, gatewayProviders: gatewayProviders}).Subscription().Reconcile),
+			traceReconcileFunc("status.token_ratelimit_policy", (&TokenRateLimitPolicyStatusUpdater{client: client, gatewayProviders: gatewayProviders}).Subscription().Reconcile),
 		},
 	}
 
@@ -185,13 +177,242 @@ func getGatewayControllerNames(envName string, defaultGatewayControllerName stri
 	return append(gatewayControllers, gatewayapiv1.GatewayController(defaultGatewayControllerName))
 }
 
-func defaultGatewayControllerName(controllerName gatewayapiv1.GatewayController) gatewayapiv1.GatewayController {
-	if lo.Contains(istioGatewayControllerNames, controllerName) {
-		return defaultIstioGatewayControllerName
-	} else if lo.Contains(envoyGatewayGatewayControllerNames, controllerName) {
-		return defaultEnvoyGatewayGatewayControllerName
+// buildWasmConfigs returns a map of gateway locators to an ordered list of corresponding wasm policies, for the
+// gateways whose gateway class is managed by any of the given gateway controllers
+func buildWasmConfigs(ctx context.Context, topology *machinery.Topology, state *sync.Map, gatewayControllerNames []gatewayapiv1.GatewayController) (map[string]wasm.Config, error) {
+	logger := controller.LoggerFromContext(ctx).WithName("buildWasmConfigs").WithValues("context", ctx)
+	logger.Info("build Wasm configuration", "status", "started")
+	logger.Info("build Wasm configuration", "status", "completed")
+
+	serviceBuilder := wasm.NewServiceBuilder(&logger)
+	// Get Kuadrant CR to access observability settings
+	kObj := GetKuadrantFromTopology(topology, state)
+	var observability *wasm.Observability
+	if kObj != nil {
+		observability = wasm.BuildObservabilityConfig(serviceBuilder, &kObj.Spec.Observability)
+	}
+
+	effectiveAuthPolicies, ok := state.Load(StateEffectiveAuthPolicies)
+	if !ok {
+		return nil, ErrMissingStateEffectiveAuthPolicies
+	}
+	effectiveAuthPoliciesMap := effectiveAuthPolicies.(EffectiveAuthPolicies)
+
+	var effectiveRateLimitPoliciesMap EffectiveRateLimitPolicies
+	if effectiveRateLimitPolicies, ok := state.Load(StateEffectiveRateLimitPolicies); ok {
+		effectiveRateLimitPoliciesMap = effectiveRateLimitPolicies.(EffectiveRateLimitPolicies)
+	} else {
+		logger.V(1).Info("no effective rate limit policies found in state, continuing with empty map")
+	}
+
+	var effectiveTokenRateLimitPoliciesMap EffectiveTokenRateLimitPolicies
+	if effectiveTokenRateLimitPolicies, ok := state.Load(StateEffectiveTokenRateLimitPolicies); ok {
+		effectiveTokenRateLimitPoliciesMap = effectiveTokenRateLimitPolicies.(EffectiveTokenRateLimitPolicies)
+	} else {
+		logger.V(1).Info("no effective token rate limit policies found in state, continuing with empty map")
+	}
+
+	logger.V(1).Info("building wasm configs for gateway extension", "effectiveAuthPolicies", len(effectiveAuthPoliciesMap), "effectiveRateLimitPolicies", len(effectiveRateLimitPoliciesMap), "effectiveTokenRateLimitPolicies", len(effectiveTokenRateLimitPoliciesMap))
+
+	// unique paths from different policy types
+	var allPaths []lo.Entry[string, []machinery.Targetable]
+
+	// paths from auth ratelimit and tokenratelimit policies
+	authPaths := lo.Entries(lo.MapValues(effectiveAuthPoliciesMap, func(p EffectiveAuthPolicy, _ string) []machinery.Targetable { return p.Path }))
+	allPaths = append(allPaths, authPaths...)
+	rateLimitPaths := lo.Entries(lo.MapValues(effectiveRateLimitPoliciesMap, func(p EffectiveRateLimitPolicy, _ string) []machinery.Targetable { return p.Path }))
+	allPaths = append(allPaths, rateLimitPaths...)
+	tokenRateLimitPaths := lo.Entries(lo.MapValues(effectiveTokenRateLimitPoliciesMap, func(p EffectiveTokenRateLimitPolicy, _ string) []machinery.Targetable { return p.Path }))
+	allPaths = append(allPaths, tokenRateLimitPaths...)
+
+	// unique paths by key
+	paths := lo.UniqBy(allPaths, func(e lo.Entry[string, []machinery.Targetable]) string { return e.Key })
+
+	logger.V(1).Info("processing paths for wasm config", "totalPaths", len(paths))
+
+	authPathIDs := lo.Keys(effectiveAuthPoliciesMap)
+	logger.V(1).Info("effective auth policy pathIDs", "count", len(authPathIDs), "pathIDs", authPathIDs)
+
+	wasmActionSets := kuadrantgatewayapi.GrouppedHTTPRouteMatchConfigs{}
+	celValidationIssues := celvalidator.NewIssueCollection()
+
+	tracer := controller.TracerFromContext(ctx)
+
+	// build the wasm policies for each topological path that contains an effective rate limit policy affecting a gateway of the given gateway controllers
+	for i := range paths {
+		pathID := paths[i].Key
+		path := paths[i].Value
+
+		logger.V(1).Info("processing path", "pathID", pathID, "pathLength", len(path))
+
+		parsed, pathErr := kuadrantpolicymachinery.ParseTopologyPath(path)
+		if pathErr != nil {
+			logger.V(1).Info("skipping path - failed to parse", "pathID", pathID, "error", pathErr)
+			continue
+		}
+
+		// ignore if not a gateway of the given gateway controllers
+		if !lo.Contains(gatewayControllerNames, parsed.GatewayClass.Spec.ControllerName) {
+			continue
+		}
+
+		// ignore if the policies of the gateway are enforced with the native Envoy filters instead of the wasm shim
+		if isNativeDataPlaneGateway(parsed.Gateway) {
+			continue
+		}
+
+		// Create a parent span for this entire path processing
+		pathCtx, pathSpan := tracer.Start(ctx, "wasm.BuildConfigForPath")
+		pathSpan.SetAttributes(
+			attribute.String("path_id", pathID),
+			attribute.String("route_type", parsed.RouteType.String()),
+			attribute.String("gateway.name", parsed.Gateway.GetName()),
+			attribute.String("gateway.namespace", parsed.Gateway.GetNamespace()),
+			attribute.String("listener.name", string(parsed.Listener.Name)),
+			attribute.String("route.name", parsed.GetRouteName()),
+			attribute.String("route.namespace", parsed.GetRouteNamespace()),
+		)
+
+		validatorBuilder := celvalidator.NewRootValidatorBuilder()
+
+		var actions []wasm.Action
+
+		// auth
+		if effectivePolicy, ok := effectiveAuthPoliciesMap[pathID]; ok {
+			actions = append(actions, buildWasmActionsForAuth(pathID, effectivePolicy)...)
+			validatorBuilder.PushPolicyBinding(celvalidator.AuthPolicyKind, celvalidator.AuthPolicyName, cel.AnyType)
+		}
+
+		// rate limit
+		if effectivePolicy, ok := effectiveRateLimitPoliciesMap[pathID]; ok {
+			rlAction := buildWasmActionsForRateLimit(effectivePolicy, isRateLimitPolicyAcceptedAndNotDeletedFunc(state))
+			if hasAuthAccess(rlAction) {
+				actions = append(actions, rlAction...)
+			} else {
+				// pre auth rate limiting
+				actions = append(rlAction, actions...)
+			}
+			validatorBuilder.PushPolicyBinding(celvalidator.RateLimitPolicyKind, celvalidator.RateLimitName, cel.AnyType)
+		}
+
+		if effectivePolicy, ok := effectiveTokenRateLimitPoliciesMap[pathID]; ok {
+			trlAction := buildWasmActionsForTokenRateLimit(effectivePolicy, isTokenRateLimitPolicyAcceptedAndNotDeletedFunc(state))
+			if hasAuthAccess(trlAction) {
+				actions = append(actions, trlAction...)
+			} else {
+				// pre auth rate limiting
+				actions = append(trlAction, actions...)
+			}
+			validatorBuilder.PushPolicyBinding(celvalidator.TokenRateLimitPolicyKind, celvalidator.RateLimitName, cel.AnyType)
+		}
+
+		// the wasm-shim would enforce the actions of the policies in shadow mode if it does not implement reportOnly
+		if !wasm.ShimSupports(wasm.ShimFeatureReportOnly) {
+			actions = lo.Reject(actions, func(action wasm.Action, _ int) bool { return action.ReportOnly })
+		}
+
+		pathSpan.SetAttributes(attribute.Int("actions.before_merge", len(actions)))
+
+		// Extract and track source policies before merging
+		sourcePolicies := lo.Uniq(lo.FlatMap(actions, func(a wasm.Action, _ int) []string {
+			return a.SourcePolicyLocators
+		}))
+		if len(sourcePolicies) > 0 {
+			pathSpan.SetAttributes(attribute.StringSlice("source_policies", sourcePolicies))
+		}
+
+		actions, err := mergeAndVerify(pathCtx, actions)
+		if err != nil {
+			pathSpan.RecordError(err)
+			pathSpan.SetStatus(codes.Error, "failed to merge/verify actions")
+			pathSpan.End()
+			return nil, fmt.Errorf("failed to merge/verify actions for path %s: %w", pathID, err)
+		}
+
+		if len(actions) == 0 {
+			pathSpan.SetStatus(codes.Ok, "no actions after merge")
+			pathSpan.End()
+			continue
+		}
+
+		validator, err := validatorBuilder.Build()
+		if err != nil {
+			pathSpan.RecordError(err)
+			pathSpan.SetStatus(codes.Error, "failed to build validator")
+			pathSpan.End()
+			return nil, fmt.Errorf("failed to build validator for path %s: %w", pathID, err)
+		}
+		var validatedActions []wasm.Action
+
+		for _, action := range actions {
+			if err := celvalidator.ValidateWasmAction(action, validator); err != nil {
+				logger.V(1).Info("WASM action is invalid", "action", action, "path", pathID, "error", err)
+				celValidationIssues.Add(celvalidator.NewIssue(action, pathID, err))
+			} else {
+				validatedActions = append(validatedActions, action)
+			}
+		}
+
+		pathSpan.SetAttributes(
+			attribute.Int("actions.after_merge", len(actions)),
+			attribute.Int("actions.validated", len(validatedActions)),
+			attribute.Int("actions.invalid", len(actions)-len(validatedActions)),
+		)
+
+		if len(validatedActions) == 0 {
+			pathSpan.SetStatus(codes.Ok, "no validated actions")
+			pathSpan.End()
+			continue
+		}
+
+		wasmActionSetsForPath, err := wasm.BuildActionSetsForPath(pathCtx, pathID, path, validatedActions)
+		if err != nil {
+			if errors.As(err, &kuadrantpolicymachinery.ErrInvalidPath{}) {
+				logger.V(1).Info("ingoring invalid paths", "error", err.Error(), "status", "skipping", "pathID", pathID)
+				pathSpan.SetStatus(codes.Ok, "invalid path - skipped")
+				pathSpan.End()
+				continue
+			}
+			logger.Error(err, "failed to build wasm policies for path", "pathID", pathID, "status", "error")
+			pathSpan.RecordError(err)
+			pathSpan.SetStatus(codes.Error, "failed to build action sets")
+			pathSpan.End()
+			continue
+		}
+
+		pathSpan.SetAttributes(attribute.Int("actionsets.created", len(wasmActionSetsForPath)))
+		pathSpan.SetStatus(codes.Ok, "")
+		pathSpan.End()
+
+		wasmActionSets.Add(parsed.Gateway.GetLocator(), wasmActionSetsForPath...)
+	}
+
+	if !celValidationIssues.IsEmpty() {
+		state.Store(celvalidator.StateCELValidationErrors, celValidationIssues)
+	}
+
+	wasmConfigs := lo.MapValues(wasmActionSets.Sorted(), func(configs kuadrantgatewayapi.SortableHTTPRouteMatchConfigs, _ string) wasm.Config {
+		return wasm.BuildConfigForActionSet(lo.Map(configs, func(c kuadrantgatewayapi.HTTPRouteMatchConfig, _ int) wasm.ActionSet {
+			return c.Config.(wasm.ActionSet)
+		}), &logger, observability, serviceBuilder)
+	})
+
+	return wasmConfigs, nil
+}
+
+// shadowModeUnsupportedError returns the error of a policy in shadow mode that affects gateways whose wasm-shim does
+// not implement it, if any
+func shadowModeUnsupportedError(policyKind string, gateways []*machinery.Gateway) kuadrant.PolicyError {
+	return shimFeatureUnsupportedError(policyKind, "shadow mode", wasm.ShimFeatureReportOnly, gateways)
+}
+
+// shimFeatureUnsupportedError returns the error of a policy whose usage requires a feature of the wasm-shim, if the
+// policy affects gateways in wasm data plane mode and the feature is not declared
+func shimFeatureUnsupportedError(policyKind, usage string, feature wasm.ShimFeature, gateways []*machinery.Gateway) kuadrant.PolicyError {
+	if wasm.ShimSupports(feature) || lo.EveryBy(gateways, isNativeDataPlaneGateway) {
+		return nil
 	}
-	return "Unknown"
+	return kuadrant.NewErrUnsupportedShimFeature(policyKind, usage, string(feature))
 }
 
 func mergeAndVerify(ctx context.Context, actions []wasm.Action) ([]wasm.Action, error) {
@@ -213,9 +434,12 @@ func mergeAndVerify(ctx context.Context, actions []wasm.Action) ([]wasm.Action,
 	for _, currentAction := range actions[1:] {
 		lastAction := &result[len(result)-1]
 
-		if lastAction.Scope == currentAction.Scope &&
+		if lastAction.Scope == currentAction.Scope && lastAction.ReportOnly == currentAction.ReportOnly &&
 			lastAction.ServiceName == currentAction.ServiceName && lastAction.ServiceName != wasm.AuthServiceName {
 			lastAction.ConditionalData = append(lastAction.ConditionalData, currentAction.ConditionalData...)
+			if len(currentAction.RequestData) > 0 {
+				lastAction.RequestData = lo.Assign(lastAction.RequestData, currentAction.RequestData)
+			}
 			// Merge source policy locators - deduplicate them
 			lastAction.SourcePolicyLocators = lo.Uniq(append(lastAction.SourcePolicyLocators, currentAction.SourcePolicyLocators...))
 			slices.Sort(lastAction.SourcePolicyLocators)
@@ -3,8 +3,13 @@ package controllers
 import (
 	"testing"
 
+	"github.com/kuadrant/policy-machinery/machinery"
 	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	"github.com/kuadrant/kuadrant-operator/internal/kuadrant"
+	"github.com/kuadrant/kuadrant-operator/internal/wasm"
 )
 
 func TestGetGatewayControllerNames(t *testing.T) {
@@ -22,11 +27,55 @@ func TestGetGatewayControllerNames(t *testing.T) {
 	assert.Equal(t, envoyGwGwCtrlNames[0], gatewayapiv1.GatewayController("default-envoy"))
 }
 
-func TestDefaultGatewayControllerNames(t *testing.T) {
+func TestGatewayProviderFor(t *testing.T) {
+	defer func(istio, envoyGateway, envoyXDSFile []gatewayapiv1.GatewayController) {
+		istioGatewayControllerNames, envoyGatewayGatewayControllerNames, envoyXDSFileGatewayControllerNames = istio, envoyGateway, envoyXDSFile
+	}(istioGatewayControllerNames, envoyGatewayGatewayControllerNames, envoyXDSFileGatewayControllerNames)
+
 	istioGatewayControllerNames = []gatewayapiv1.GatewayController{"istio-alpha1"}
 	envoyGatewayGatewayControllerNames = []gatewayapiv1.GatewayController{"envoy-alpha1"}
+	envoyXDSFileGatewayControllerNames = []gatewayapiv1.GatewayController{"envoy-xds-alpha1"}
+
+	providers := DefaultGatewayProviders()
+
+	provider, found := gatewayProviderFor(providers, "istio-alpha1")
+	assert.Assert(t, found)
+	assert.Equal(t, provider.Name(), "istio")
+
+	provider, found = gatewayProviderFor(providers, "envoy-alpha1")
+	assert.Assert(t, found)
+	assert.Equal(t, provider.Name(), "envoygateway")
+
+	provider, found = gatewayProviderFor(providers, "envoy-xds-alpha1")
+	assert.Assert(t, found)
+	assert.Equal(t, provider.Name(), "envoy_xds_file")
+
+	_, found = gatewayProviderFor(providers, "envoy-alpha2")
+	assert.Assert(t, !found)
+
+	// only the installed providers are taken into account
+	_, found = gatewayProviderFor(providers[:1], "envoy-alpha1")
+	assert.Assert(t, !found)
+}
+
+func TestShadowModeUnsupportedError(t *testing.T) {
+	wasmGateway := &machinery.Gateway{Gateway: &gatewayapiv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "wasm"}}}
+	nativeGateway := &machinery.Gateway{Gateway: &gatewayapiv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "native", Annotations: map[string]string{DataPlaneModeAnnotation: NativeDataPlaneMode}}}}
+
+	t.Setenv("WASM_SHIM_FEATURES", "reportOnly")
+	assert.Assert(t, shadowModeUnsupportedError("RateLimitPolicy", []*machinery.Gateway{wasmGateway}) == nil)
+
+	t.Setenv("WASM_SHIM_FEATURES", "")
+	assert.Assert(t, shadowModeUnsupportedError("RateLimitPolicy", []*machinery.Gateway{nativeGateway}) == nil)
+	err := shadowModeUnsupportedError("RateLimitPolicy", []*machinery.Gateway{nativeGateway, wasmGateway})
+	assert.Assert(t, err != nil)
+	assert.Equal(t, err.Reason(), kuadrant.PolicyReasonUnsupportedFeature)
+	assert.Equal(t, err.Error(), "RateLimitPolicy uses shadow mode, which requires the reportOnly feature of the wasm-shim, not declared in WASM_SHIM_FEATURES")
 
-	assert.Equal(t, defaultGatewayControllerName("istio-alpha1"), gatewayapiv1.GatewayController("istio.io/gateway-controller"))
-	assert.Equal(t, defaultGatewayControllerName("envoy-alpha1"), gatewayapiv1.GatewayController("gateway.envoyproxy.io/gatewayclass-controller"))
-	assert.Equal(t, defaultGatewayControllerName("envoy-alpha2"), gatewayapiv1.GatewayController("Unknown"))
+	t.Setenv("WASM_SHIM_FEATURES", "reportOnly,requestData")
+	assert.Assert(t, shimFeatureUnsupportedError("TokenRateLimitPolicy", "reserved token estimates", wasm.ShimFeatureRequestData, []*machinery.Gateway{wasmGateway}) == nil)
+	t.Setenv("WASM_SHIM_FEATURES", "reportOnly")
+	err = shimFeatureUnsupportedError("TokenRateLimitPolicy", "reserved token estimates", wasm.ShimFeatureRequestData, []*machinery.Gateway{wasmGateway})
+	assert.Assert(t, err != nil)
+	assert.Equal(t, err.Error(), "TokenRateLimitPolicy uses reserved token estimates, which requires the requestData feature of the wasm-shim, not declared in WASM_SHIM_FEATURES")
 }
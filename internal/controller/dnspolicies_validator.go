@@ -3,7 +3,6 @@ package controllers
 import (
 	"context"
 	"errors"
-	"reflect"
 	"sync"
 
 	"github.com/samber/lo"
@@ -120,22 +119,24 @@ func (r *DNSPoliciesValidator) isMissingDependency() error {
 }
 
 // isTargetRefsFound Policies are already linked to their targets.
-// If the target ref length and length of targetables by this policy is not the same, then the policy could not find the target.
+// If none of the targets of the policy is linked to it, then the policy could not find any target.
+// Targets that could not be found when others were are reported in the status of the policy.
 func isTargetRefsFound(topology *machinery.Topology, p *kuadrantv1.DNSPolicy) error {
-	if len(p.GetTargetRefs()) != len(topology.Targetables().Children(p)) {
-		return kuadrant.NewErrTargetNotFound(kuadrantv1.DNSPolicyGroupKind.Kind, p.Spec.TargetRef.LocalPolicyTargetReference, apierrors.NewNotFound(controller.GatewaysResource.GroupResource(), p.GetName()))
+	if isPolicyTargetNotFound(p, topology) {
+		targetRef := p.GetTargetRefs()[0].(machinery.LocalPolicyTargetReferenceWithSectionName)
+		return kuadrant.NewErrTargetNotFound(kuadrantv1.DNSPolicyGroupKind.Kind, targetRef.LocalPolicyTargetReference, apierrors.NewNotFound(controller.GatewaysResource.GroupResource(), p.GetName()))
 	}
 
 	return nil
 }
 
-// isConflict Validates if there's already an older policy with the same target ref
+// isConflict Validates if there's already an older policy sharing a target ref
 func isConflict(policies []machinery.Policy, p *kuadrantv1.DNSPolicy) error {
 	conflictingP, ok := lo.Find(policies, func(item machinery.Policy) bool {
 		policy := item.(*kuadrantv1.DNSPolicy)
 		return p != policy && policy.DeletionTimestamp == nil &&
 			policy.CreationTimestamp.Before(&p.CreationTimestamp) &&
-			reflect.DeepEqual(policy.GetTargetRefs(), p.GetTargetRefs())
+			targetRefsOverlap(policy, p)
 	})
 
 	if ok {
@@ -2,9 +2,14 @@ package controllers
 
 import (
 	"fmt"
+	"math"
 	"net"
+	"strconv"
 	"strings"
 
+	"github.com/go-logr/logr"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/env"
 	externaldns "sigs.k8s.io/external-dns/endpoint"
@@ -18,6 +23,9 @@ import (
 
 const (
 	LabelListenerReference = "kuadrant.io/listener-name"
+
+	// dnsRecordProbesCreatingMessage is the message of the Healthy condition of a DNS record whose probes are not created yet
+	dnsRecordProbesCreatingMessage = "Probes are creating"
 )
 
 func dnsPolicyDefaultTTL() (int, error) {
@@ -40,7 +48,7 @@ func dnsRecordName(gatewayName, listenerName string) string {
 	return fmt.Sprintf("%s-%s", gatewayName, listenerName)
 }
 
-func desiredDNSRecord(gateway *gatewayapiv1.Gateway, clusterID string, dnsPolicy *kuadrantv1.DNSPolicy, targetListener gatewayapiv1.Listener, defaultTTL int, defaultLoadBalancedTTL int) (*kuadrantdnsv1alpha1.DNSRecord, error) {
+func desiredDNSRecord(gateway *gatewayapiv1.Gateway, clusterID string, dnsPolicy *kuadrantv1.DNSPolicy, targetListener gatewayapiv1.Listener, loadBalancingWeight int, defaultTTL int, defaultLoadBalancedTTL int) (*kuadrantdnsv1alpha1.DNSRecord, error) {
 	rootHost := string(*targetListener.Hostname)
 	var healthCheckSpec *kuadrantdnsv1alpha1.HealthCheckSpec
 
@@ -82,7 +90,7 @@ func desiredDNSRecord(gateway *gatewayapiv1.Gateway, clusterID string, dnsPolicy
 
 	dnsRecord.Labels[LabelListenerReference] = string(targetListener.Name)
 
-	endpoints, err := buildEndpoints(clusterID, string(*targetListener.Hostname), gateway, dnsPolicy, defaultTTL, defaultLoadBalancedTTL)
+	endpoints, err := buildEndpoints(clusterID, string(*targetListener.Hostname), gateway, dnsPolicy, loadBalancingWeight, defaultTTL, defaultLoadBalancedTTL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate dns record for a gateway %s in %s ns: %w", gateway.Name, gateway.Namespace, err)
 	}
@@ -144,7 +152,7 @@ func (g *GatewayWrapper) RemoveExcludedStatusAddresses(p *kuadrantv1.DNSPolicy)
 	return nil
 }
 
-func buildEndpoints(clusterID, hostname string, gateway *gatewayapiv1.Gateway, policy *kuadrantv1.DNSPolicy, defaultTTL int, defaultLoadBalancedTTL int) ([]*externaldns.Endpoint, error) {
+func buildEndpoints(clusterID, hostname string, gateway *gatewayapiv1.Gateway, policy *kuadrantv1.DNSPolicy, loadBalancingWeight int, defaultTTL int, defaultLoadBalancedTTL int) ([]*externaldns.Endpoint, error) {
 	gw := gateway.DeepCopy()
 	gatewayWrapper := NewGatewayWrapper(gw)
 	// modify the status addresses based on any that need to be excluded
@@ -158,10 +166,85 @@ func buildEndpoints(clusterID, hostname string, gateway *gatewayapiv1.Gateway, p
 	if policy.Spec.LoadBalancing != nil {
 		endpointBuilder.WithLoadBalancingFor(
 			clusterID,
-			policy.Spec.LoadBalancing.Weight,
+			loadBalancingWeight,
 			policy.Spec.LoadBalancing.Geo,
 			policy.Spec.LoadBalancing.DefaultGeo)
 	}
 
 	return endpointBuilder.Build()
 }
+
+// loadBalancingWeight returns the weight of the endpoints of a listener, i.e. the weight of the load balancing spec of
+// the policy scaled by the signals selected in its dynamic weight:
+//   - listenerReadiness: 0 if the gateway or the listener is not programmed, 1 otherwise
+//   - healthChecks: the ratio of healthy probes of the existing DNS record
+//   - capacity: the percentage in the kuadrant.io/dns-capacity annotation of the gateway
+func loadBalancingWeight(logger logr.Logger, gateway *gatewayapiv1.Gateway, policy *kuadrantv1.DNSPolicy, targetListener gatewayapiv1.Listener, existingRecord *kuadrantdnsv1alpha1.DNSRecord) int {
+	if policy.Spec.LoadBalancing == nil {
+		return 0
+	}
+	weight := policy.Spec.LoadBalancing.Weight
+	dynamicWeight := policy.Spec.LoadBalancing.DynamicWeight
+	if dynamicWeight == nil {
+		return weight
+	}
+
+	factor := 1.0
+
+	if dynamicWeight.ListenerReadiness && !isListenerProgrammed(gateway, targetListener.Name) {
+		factor = 0
+	}
+
+	if dynamicWeight.HealthChecks && policy.Spec.HealthCheck != nil && existingRecord != nil {
+		factor *= dnsRecordHealth(existingRecord)
+	}
+
+	if dynamicWeight.Capacity {
+		if value, ok := gateway.GetAnnotations()[kuadrantv1.DNSCapacityAnnotation]; ok {
+			capacity, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+			if err != nil || capacity < 0 || capacity > 100 {
+				logger.Info("invalid capacity annotation on gateway, expected a percentage between 0 and 100, ignoring", "annotation", kuadrantv1.DNSCapacityAnnotation, "value", value)
+			} else {
+				factor *= capacity / 100
+			}
+		}
+	}
+
+	return max(int(math.Round(float64(weight)*factor)), dynamicWeight.MinWeight)
+}
+
+// dnsRecordHealth returns the ratio of healthy probes of a DNS record, or, when the probes are not reported, 1 if all
+// the health checks passed, 0.5 if some did and 0 if none did. Records whose probes are still being created are
+// considered healthy, so new clusters are not drained.
+func dnsRecordHealth(record *kuadrantdnsv1alpha1.DNSRecord) float64 {
+	if record.Status.HealthCheck != nil && len(record.Status.HealthCheck.Probes) > 0 {
+		probes := record.Status.HealthCheck.Probes
+		healthy := lo.CountBy(probes, func(probe kuadrantdnsv1alpha1.HealthCheckStatusProbe) bool {
+			return meta.IsStatusConditionTrue(probe.Conditions, string(kuadrantdnsv1alpha1.ConditionTypeHealthy))
+		})
+		return float64(healthy) / float64(len(probes))
+	}
+
+	condition := meta.FindStatusCondition(record.Status.Conditions, string(kuadrantdnsv1alpha1.ConditionTypeHealthy))
+	switch {
+	case condition == nil || condition.Status == metav1.ConditionTrue:
+		return 1
+	case condition.Reason == string(kuadrantdnsv1alpha1.ConditionReasonPartiallyHealthy):
+		return 0.5
+	case condition.Message == dnsRecordProbesCreatingMessage:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// isListenerProgrammed tells whether both the gateway and one of its listeners are programmed
+func isListenerProgrammed(gateway *gatewayapiv1.Gateway, listenerName gatewayapiv1.SectionName) bool {
+	if !meta.IsStatusConditionTrue(gateway.Status.Conditions, string(gatewayapiv1.GatewayConditionProgrammed)) {
+		return false
+	}
+	listenerStatus, found := lo.Find(gateway.Status.Listeners, func(l gatewayapiv1.ListenerStatus) bool {
+		return l.Name == listenerName
+	})
+	return found && meta.IsStatusConditionTrue(listenerStatus.Conditions, string(gatewayapiv1.ListenerConditionProgrammed))
+}
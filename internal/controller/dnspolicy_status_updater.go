@@ -87,6 +87,9 @@ func (r *DNSPolicyStatusUpdater) updateStatus(ctx context.Context, _ []controlle
 
 		accepted, err := policyAcceptedFunc(policy)
 		meta.SetStatusCondition(&newStatus.Conditions, *kuadrant.AcceptedCondition(policy, err))
+		if len(policy.Spec.TargetRefs) > 0 {
+			newStatus.Targets = policyTargetStatuses(policy, topology, policy.Status.Targets)
+		}
 
 		// do not set enforced condition if Accepted condition is false
 		if !accepted {
@@ -73,11 +73,14 @@ func CalculateEffectiveAuthPolicies(ctx context.Context, topology *machinery.Top
 	allRouteRules = append(allRouteRules, httpRouteRules...)
 	allRouteRules = append(allRouteRules, grpcRouteRules...)
 
+	isPolicyAcceptedAndNotDeleted := isAuthPolicyAcceptedAndNotDeletedFunc(state)
+	namespaceLabels := namespaceLabelsFunc(topology)
+
 	for _, gatewayClass := range gatewayClasses {
 		for _, routeRule := range allRouteRules {
 			paths := targetables.Paths(gatewayClass, routeRule) // this may be expensive in clusters with many gateway classes - an alternative is to deep search the topology for httprouterules from each gatewayclass, keeping record of the paths
 			for i := range paths {
-				if effectivePolicy := kuadrantv1.EffectivePolicyForPath[*kuadrantv1.AuthPolicy](paths[i], isAuthPolicyAcceptedAndNotDeletedFunc(state)); effectivePolicy != nil {
+				if effectivePolicy := kuadrantv1.EffectivePolicyForPath[*kuadrantv1.AuthPolicy](paths[i], policySelectsPathFunc(isPolicyAcceptedAndNotDeleted, paths[i], namespaceLabels)); effectivePolicy != nil {
 					pathID := kuadrantv1.PathID(paths[i])
 
 					// Extract source policy locators from the effective policy rules
@@ -142,7 +142,22 @@ func (r *EffectiveDNSPoliciesReconciler) reconcile(ctx context.Context, _ []cont
 				gatewayHasAttachedRoutes = true
 			}
 
-			desiredRecord, err := desiredDNSRecord(gateway.Gateway, clusterID, policy, *listener.Listener, defaultTTL, defaultLoadBalancedTTL)
+			existingRecordObj, recordExists := lo.Find(topology.Objects().Children(listener), func(o machinery.Object) bool {
+				_, ok := o.(*controller.RuntimeObject).Object.(*kuadrantdnsv1alpha1.DNSRecord)
+				return ok && o.GetNamespace() == listener.GetNamespace() && o.GetName() == dnsRecordName(listener.Gateway.Name, string(listener.Name))
+			})
+
+			var existingRecord *kuadrantdnsv1alpha1.DNSRecord
+			if recordExists {
+				existingRecord = existingRecordObj.(*controller.RuntimeObject).Object.(*kuadrantdnsv1alpha1.DNSRecord)
+			}
+
+			weight := loadBalancingWeight(lLogger, gateway.Gateway, policy, *listener.Listener, existingRecord)
+			if policy.Spec.LoadBalancing != nil && weight != policy.Spec.LoadBalancing.Weight {
+				lLogger.V(1).Info("dynamic load balancing weight", "weight", weight, "staticWeight", policy.Spec.LoadBalancing.Weight)
+			}
+
+			desiredRecord, err := desiredDNSRecord(gateway.Gateway, clusterID, policy, *listener.Listener, weight, defaultTTL, defaultLoadBalancedTTL)
 			if err != nil {
 				lLogger.Error(err, "failed to build desired dns record")
 				continue
@@ -154,11 +169,6 @@ func (r *EffectiveDNSPoliciesReconciler) reconcile(ctx context.Context, _ []cont
 
 			resource := r.client.Resource(DNSRecordResource).Namespace(desiredRecord.GetNamespace())
 
-			existingRecordObj, recordExists := lo.Find(topology.Objects().Children(listener), func(o machinery.Object) bool {
-				_, ok := o.(*controller.RuntimeObject).Object.(*kuadrantdnsv1alpha1.DNSRecord)
-				return ok && o.GetNamespace() == listener.GetNamespace() && o.GetName() == dnsRecordName(listener.Gateway.Name, string(listener.Name))
-			})
-
 			if len(desiredRecord.Spec.Endpoints) == 0 {
 				policyErrors[policy.GetLocator()] = ErrNoAddresses
 			}
@@ -167,8 +177,6 @@ func (r *EffectiveDNSPoliciesReconciler) reconcile(ctx context.Context, _ []cont
 			if recordExists {
 				rLogger := lLogger.WithValues("record", existingRecordObj.GetLocator())
 
-				existingRecord := existingRecordObj.(*controller.RuntimeObject).Object.(*kuadrantdnsv1alpha1.DNSRecord)
-
 				// Deal with the potential deletion of a record first
 				if !hasAttachedRoute || len(desiredRecord.Spec.Endpoints) == 0 {
 					if !hasAttachedRoute {
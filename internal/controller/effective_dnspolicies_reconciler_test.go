@@ -6,9 +6,14 @@ import (
 	"context"
 	"testing"
 
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	externaldns "sigs.k8s.io/external-dns/endpoint"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
 
 	kuadrantdnsv1alpha1 "github.com/kuadrant/dns-operator/api/v1alpha1"
+
+	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
 )
 
 func Test_canUpdateDNSRecord(t *testing.T) {
@@ -135,3 +140,110 @@ func Test_canUpdateDNSRecord(t *testing.T) {
 		})
 	}
 }
+
+func Test_loadBalancingWeight(t *testing.T) {
+	programmed := []metav1.Condition{{Type: string(gatewayapiv1.GatewayConditionProgrammed), Status: metav1.ConditionTrue}}
+	gateway := func(listenerConditions []metav1.Condition, annotations map[string]string) *gatewayapiv1.Gateway {
+		return &gatewayapiv1.Gateway{
+			ObjectMeta: metav1.ObjectMeta{Name: "gw", Annotations: annotations},
+			Status: gatewayapiv1.GatewayStatus{
+				Conditions: programmed,
+				Listeners:  []gatewayapiv1.ListenerStatus{{Name: "api", Conditions: listenerConditions}},
+			},
+		}
+	}
+	policy := func(dynamicWeight *kuadrantv1.DynamicWeightSpec) *kuadrantv1.DNSPolicy {
+		return &kuadrantv1.DNSPolicy{Spec: kuadrantv1.DNSPolicySpec{
+			HealthCheck:   &kuadrantdnsv1alpha1.HealthCheckSpec{Path: "/health"},
+			LoadBalancing: &kuadrantv1.LoadBalancingSpec{Weight: 120, Geo: "GEO-EU", DynamicWeight: dynamicWeight},
+		}}
+	}
+	record := func(status metav1.ConditionStatus, reason, message string) *kuadrantdnsv1alpha1.DNSRecord {
+		return &kuadrantdnsv1alpha1.DNSRecord{Status: kuadrantdnsv1alpha1.DNSRecordStatus{Conditions: []metav1.Condition{
+			{Type: string(kuadrantdnsv1alpha1.ConditionTypeHealthy), Status: status, Reason: reason, Message: message},
+		}}}
+	}
+	healthyProbe := kuadrantdnsv1alpha1.HealthCheckStatusProbe{Conditions: []metav1.Condition{{Type: string(kuadrantdnsv1alpha1.ConditionTypeHealthy), Status: metav1.ConditionTrue}}}
+	unhealthyProbe := kuadrantdnsv1alpha1.HealthCheckStatusProbe{Conditions: []metav1.Condition{{Type: string(kuadrantdnsv1alpha1.ConditionTypeHealthy), Status: metav1.ConditionFalse}}}
+
+	tests := []struct {
+		name     string
+		gateway  *gatewayapiv1.Gateway
+		policy   *kuadrantv1.DNSPolicy
+		record   *kuadrantdnsv1alpha1.DNSRecord
+		expected int
+	}{
+		{
+			name:     "static weight",
+			gateway:  gateway(nil, nil),
+			policy:   policy(nil),
+			expected: 120,
+		},
+		{
+			name:     "listener not programmed",
+			gateway:  gateway(nil, nil),
+			policy:   policy(&kuadrantv1.DynamicWeightSpec{ListenerReadiness: true, MinWeight: 1}),
+			expected: 1,
+		},
+		{
+			name:     "listener programmed",
+			gateway:  gateway(programmed, nil),
+			policy:   policy(&kuadrantv1.DynamicWeightSpec{ListenerReadiness: true}),
+			expected: 120,
+		},
+		{
+			name:     "some probes healthy",
+			gateway:  gateway(programmed, nil),
+			policy:   policy(&kuadrantv1.DynamicWeightSpec{HealthChecks: true}),
+			record:   &kuadrantdnsv1alpha1.DNSRecord{Status: kuadrantdnsv1alpha1.DNSRecordStatus{HealthCheck: &kuadrantdnsv1alpha1.HealthCheckStatus{Probes: []kuadrantdnsv1alpha1.HealthCheckStatusProbe{healthyProbe, healthyProbe, unhealthyProbe}}}},
+			expected: 80,
+		},
+		{
+			name:     "partially healthy record",
+			gateway:  gateway(programmed, nil),
+			policy:   policy(&kuadrantv1.DynamicWeightSpec{HealthChecks: true}),
+			record:   record(metav1.ConditionFalse, string(kuadrantdnsv1alpha1.ConditionReasonPartiallyHealthy), "Not healthy addresses: [1.1.1.1]"),
+			expected: 60,
+		},
+		{
+			name:     "unhealthy record",
+			gateway:  gateway(programmed, nil),
+			policy:   policy(&kuadrantv1.DynamicWeightSpec{HealthChecks: true}),
+			record:   record(metav1.ConditionFalse, "HealthChecksFailed", "Not healthy addresses: [1.1.1.1]"),
+			expected: 0,
+		},
+		{
+			name:     "probes being created",
+			gateway:  gateway(programmed, nil),
+			policy:   policy(&kuadrantv1.DynamicWeightSpec{HealthChecks: true}),
+			record:   record(metav1.ConditionFalse, "HealthChecksFailed", dnsRecordProbesCreatingMessage),
+			expected: 120,
+		},
+		{
+			name:     "capacity",
+			gateway:  gateway(programmed, map[string]string{kuadrantv1.DNSCapacityAnnotation: "25%"}),
+			policy:   policy(&kuadrantv1.DynamicWeightSpec{Capacity: true}),
+			expected: 30,
+		},
+		{
+			name:     "invalid capacity",
+			gateway:  gateway(programmed, map[string]string{kuadrantv1.DNSCapacityAnnotation: "150"}),
+			policy:   policy(&kuadrantv1.DynamicWeightSpec{Capacity: true}),
+			expected: 120,
+		},
+		{
+			name:     "all signals",
+			gateway:  gateway(programmed, map[string]string{kuadrantv1.DNSCapacityAnnotation: "50"}),
+			policy:   policy(&kuadrantv1.DynamicWeightSpec{ListenerReadiness: true, HealthChecks: true, Capacity: true, MinWeight: 10}),
+			record:   record(metav1.ConditionFalse, string(kuadrantdnsv1alpha1.ConditionReasonPartiallyHealthy), ""),
+			expected: 30,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := loadBalancingWeight(logr.Discard(), tt.gateway, tt.policy, gatewayapiv1.Listener{Name: "api"}, tt.record); got != tt.expected {
+				t.Errorf("loadBalancingWeight() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
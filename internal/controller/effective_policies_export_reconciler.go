@@ -0,0 +1,342 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
+	kuadrantv1alpha1 "github.com/kuadrant/kuadrant-operator/api/v1alpha1"
+	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
+	kuadrantpolicymachinery "github.com/kuadrant/kuadrant-operator/internal/policymachinery"
+)
+
+//+kubebuilder:rbac:groups=kuadrant.io,resources=effectivepolicies,verbs=get;list;watch;create;update;patch;delete
+
+const (
+	effectivePolicyObjectLabelKey = "kuadrant.io/effective-policy"
+
+	// effectivePolicyTopLevelPredicatesRuleName is the name under which the top-level predicates of a policy are exported
+	effectivePolicyTopLevelPredicatesRuleName = "#when"
+)
+
+func EffectivePolicyObjectLabels() labels.Set {
+	m := KuadrantManagedObjectLabels()
+	m[effectivePolicyObjectLabelKey] = "true"
+	return m
+}
+
+// EffectivePolicyNameForRoute returns the name of the EffectivePolicy object that exports the effective policies of a route.
+// Names exceeding the maximum length of an object name are truncated and suffixed with a hash of the full name
+func EffectivePolicyNameForRoute(routeKind, routeName string) string {
+	name := fmt.Sprintf("%s-%s", strings.ToLower(routeKind), routeName)
+	if len(name) <= validation.DNS1123SubdomainMaxLength {
+		return name
+	}
+	hash := sha256.Sum256([]byte(name))
+	suffix := hex.EncodeToString(hash[:4])
+	prefix := strings.TrimRight(name[:validation.DNS1123SubdomainMaxLength-len(suffix)-1], "-.")
+	return prefix + "-" + suffix
+}
+
+type EffectivePoliciesExportReconciler struct {
+	client *dynamic.DynamicClient
+}
+
+func NewEffectivePoliciesExportReconciler(client *dynamic.DynamicClient) *EffectivePoliciesExportReconciler {
+	return &EffectivePoliciesExportReconciler{client: client}
+}
+
+// Subscription subscribes to events with potential to change the effective policies exported as EffectivePolicy custom resources
+func (r *EffectivePoliciesExportReconciler) Subscription() *controller.Subscription {
+	return &controller.Subscription{
+		ReconcileFunc: r.Reconcile,
+		Events: []controller.ResourceEventMatcher{
+			{Kind: &kuadrantv1beta1.KuadrantGroupKind},
+			{Kind: &machinery.GatewayClassGroupKind},
+			{Kind: &machinery.GatewayGroupKind},
+			{Kind: &machinery.HTTPRouteGroupKind},
+			{Kind: &machinery.GRPCRouteGroupKind},
+			{Kind: &NamespaceGroupKind},
+			{Kind: &kuadrantv1.AuthPolicyGroupKind},
+			{Kind: &kuadrantv1.RateLimitPolicyGroupKind},
+			{Kind: &kuadrantv1alpha1.TokenRateLimitPolicyGroupKind},
+			{Kind: &kuadrantv1alpha1.EffectivePolicyGroupKind},
+		},
+	}
+}
+
+func (r *EffectivePoliciesExportReconciler) Reconcile(ctx context.Context, _ []controller.ResourceEvent, topology *machinery.Topology, _ error, state *sync.Map) error {
+	logger := controller.LoggerFromContext(ctx).WithName("EffectivePoliciesExportReconciler").WithValues("context", ctx)
+
+	effectiveAuthPolicies, authOK := state.Load(StateEffectiveAuthPolicies)
+	effectiveRateLimitPolicies, rateLimitOK := state.Load(StateEffectiveRateLimitPolicies)
+	effectiveTokenRateLimitPolicies, tokenRateLimitOK := state.Load(StateEffectiveTokenRateLimitPolicies)
+	if !authOK || !rateLimitOK || !tokenRateLimitOK {
+		// the effective policies were not computed in this reconciliation, exporting now would wrongly delete existing objects
+		logger.V(1).Info("effective policies not found in the reconciliation state, skipping export")
+		return nil
+	}
+
+	desiredEffectivePolicies := buildEffectivePolicyObjects(
+		effectiveAuthPolicies.(EffectiveAuthPolicies),
+		effectiveRateLimitPolicies.(EffectiveRateLimitPolicies),
+		effectiveTokenRateLimitPolicies.(EffectiveTokenRateLimitPolicies),
+		func(err error, path []machinery.Targetable) {
+			if errors.As(err, &kuadrantpolicymachinery.ErrInvalidPath{}) {
+				logger.V(1).Info("skipping effective policy export for invalid path", "path", path)
+				return
+			}
+			logger.Error(err, "failed to export effective policy", "path", path)
+		},
+	)
+
+	logger.V(1).Info("exporting effective policies", "effectivePolicies", len(desiredEffectivePolicies))
+	defer logger.V(1).Info("finished exporting effective policies")
+
+	existingEffectivePolicies := topology.Objects().Items(func(o machinery.Object) bool {
+		return o.GroupVersionKind().GroupKind() == kuadrantv1alpha1.EffectivePolicyGroupKind && labels.Set(o.(*controller.RuntimeObject).GetLabels()).AsSelector().Matches(EffectivePolicyObjectLabels())
+	})
+
+	desiredKeys := make(map[k8stypes.NamespacedName]struct{}, len(desiredEffectivePolicies))
+
+	for _, desiredEffectivePolicy := range desiredEffectivePolicies {
+		key := k8stypes.NamespacedName{Namespace: desiredEffectivePolicy.GetNamespace(), Name: desiredEffectivePolicy.GetName()}
+		desiredKeys[key] = struct{}{}
+
+		resource := r.client.Resource(kuadrantv1alpha1.EffectivePoliciesResource).Namespace(key.Namespace)
+
+		existingEffectivePolicyObj, found := lo.Find(existingEffectivePolicies, func(o machinery.Object) bool {
+			return o.GetNamespace() == key.Namespace && o.GetName() == key.Name
+		})
+
+		// create
+		if !found {
+			desiredEffectivePolicyUnstructured, err := controller.Destruct(desiredEffectivePolicy)
+			if err != nil {
+				logger.Error(err, "failed to destruct effective policy object", "effectivepolicy", key.String())
+				continue
+			}
+			if _, err = resource.Create(ctx, desiredEffectivePolicyUnstructured, metav1.CreateOptions{}); err != nil {
+				logger.Error(err, "failed to create effective policy object", "effectivepolicy", key.String())
+				// TODO: handle error
+			}
+			continue
+		}
+
+		existingEffectivePolicy := existingEffectivePolicyObj.(*controller.RuntimeObject).Object.(*kuadrantv1alpha1.EffectivePolicy)
+
+		if equalEffectivePolicies(existingEffectivePolicy, desiredEffectivePolicy) {
+			logger.V(1).Info("effective policy object is up to date, nothing to do", "effectivepolicy", key.String())
+			continue
+		}
+
+		// update
+		existingEffectivePolicy.Spec = desiredEffectivePolicy.Spec
+		existingEffectivePolicy.OwnerReferences = desiredEffectivePolicy.OwnerReferences
+		existingEffectivePolicyUnstructured, err := controller.Destruct(existingEffectivePolicy)
+		if err != nil {
+			logger.Error(err, "failed to destruct effective policy object", "effectivepolicy", key.String())
+			continue
+		}
+		if _, err = resource.Update(ctx, existingEffectivePolicyUnstructured, metav1.UpdateOptions{}); err != nil {
+			logger.Error(err, "failed to update effective policy object", "effectivepolicy", key.String())
+			// TODO: handle error
+		}
+	}
+
+	// cleanup effective policies of routes that are no longer affected by any policy
+	for _, effectivePolicy := range existingEffectivePolicies {
+		if _, desired := desiredKeys[k8stypes.NamespacedName{Namespace: effectivePolicy.GetNamespace(), Name: effectivePolicy.GetName()}]; desired {
+			continue
+		}
+		if err := r.client.Resource(kuadrantv1alpha1.EffectivePoliciesResource).Namespace(effectivePolicy.GetNamespace()).Delete(ctx, effectivePolicy.GetName(), metav1.DeleteOptions{}); err != nil {
+			logger.Error(err, "failed to delete effective policy object", "effectivepolicy", fmt.Sprintf("%s/%s", effectivePolicy.GetNamespace(), effectivePolicy.GetName()))
+			// TODO: handle error
+		}
+	}
+
+	return nil
+}
+
+type exportedEffectivePolicy struct {
+	path           []machinery.Targetable
+	kind           string
+	sourcePolicies []string
+	rules          map[string]kuadrantv1.MergeableRule
+}
+
+// buildEffectivePolicyObjects groups the effective policies by route and builds one EffectivePolicy object per route,
+// in the namespace of the route. Paths that cannot be parsed are reported to onError and skipped.
+func buildEffectivePolicyObjects(authPolicies EffectiveAuthPolicies, rateLimitPolicies EffectiveRateLimitPolicies, tokenRateLimitPolicies EffectiveTokenRateLimitPolicies, onError func(error, []machinery.Targetable)) []*kuadrantv1alpha1.EffectivePolicy {
+	exportedByPath := make(map[string][]exportedEffectivePolicy)
+	for pathID, effectivePolicy := range authPolicies {
+		exportedByPath[pathID] = append(exportedByPath[pathID], exportedEffectivePolicy{path: effectivePolicy.Path, kind: kuadrantv1.AuthPolicyGroupKind.Kind, sourcePolicies: effectivePolicy.SourcePolicies, rules: effectivePolicy.Spec.Rules()})
+	}
+	for pathID, effectivePolicy := range rateLimitPolicies {
+		exportedByPath[pathID] = append(exportedByPath[pathID], exportedEffectivePolicy{path: effectivePolicy.Path, kind: kuadrantv1.RateLimitPolicyGroupKind.Kind, sourcePolicies: effectivePolicy.SourcePolicies, rules: effectivePolicy.Spec.Rules()})
+	}
+	for pathID, effectivePolicy := range tokenRateLimitPolicies {
+		exportedByPath[pathID] = append(exportedByPath[pathID], exportedEffectivePolicy{path: effectivePolicy.Path, kind: kuadrantv1alpha1.TokenRateLimitPolicyGroupKind.Kind, sourcePolicies: effectivePolicy.SourcePolicies, rules: effectivePolicy.Spec.Rules()})
+	}
+
+	effectivePolicies := make(map[k8stypes.NamespacedName]*kuadrantv1alpha1.EffectivePolicy)
+
+	for _, exported := range exportedByPath {
+		path := exported[0].path
+		parsed, err := kuadrantpolicymachinery.ParseTopologyPath(path)
+		if err != nil {
+			onError(err, path)
+			continue
+		}
+
+		route := routeFromParsedTopologyPath(parsed)
+		routeKind := route.GroupVersionKind().Kind
+		key := k8stypes.NamespacedName{Namespace: route.GetNamespace(), Name: EffectivePolicyNameForRoute(routeKind, route.GetName())}
+
+		effectivePolicy, ok := effectivePolicies[key]
+		if !ok {
+			effectivePolicy = &kuadrantv1alpha1.EffectivePolicy{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       kuadrantv1alpha1.EffectivePolicyGroupKind.Kind,
+					APIVersion: kuadrantv1alpha1.GroupVersion.String(),
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name,
+					Namespace: key.Namespace,
+					Labels:    EffectivePolicyObjectLabels(),
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: route.GroupVersionKind().GroupVersion().String(),
+							Kind:       routeKind,
+							Name:       route.GetName(),
+							UID:        parsed.GetRoute().GetUID(),
+						},
+					},
+				},
+				Spec: kuadrantv1alpha1.EffectivePolicySpec{
+					TargetRef: gatewayapiv1alpha2.LocalPolicyTargetReference{
+						Group: gatewayapiv1alpha2.Group(route.GroupVersionKind().Group),
+						Kind:  gatewayapiv1alpha2.Kind(routeKind),
+						Name:  gatewayapiv1alpha2.ObjectName(route.GetName()),
+					},
+				},
+			}
+			effectivePolicies[key] = effectivePolicy
+		}
+
+		// keep a stable order of kinds within a path
+		sort.SliceStable(exported, func(i, j int) bool { return exported[i].kind < exported[j].kind })
+
+		effectivePolicy.Spec.Paths = append(effectivePolicy.Spec.Paths, kuadrantv1alpha1.EffectivePolicyPath{
+			Gateway:   k8stypes.NamespacedName{Namespace: parsed.Gateway.GetNamespace(), Name: parsed.Gateway.GetName()}.String(),
+			Listener:  string(parsed.Listener.Name),
+			RouteRule: parsed.GetRouteRuleName(),
+			Policies: lo.Map(exported, func(e exportedEffectivePolicy, _ int) kuadrantv1alpha1.EffectivePolicyKind {
+				return kuadrantv1alpha1.EffectivePolicyKind{
+					Kind:    e.kind,
+					Sources: e.sourcePolicies,
+					Rules:   effectivePolicyRules(e.rules),
+				}
+			}),
+		})
+	}
+
+	result := lo.Values(effectivePolicies)
+	for _, effectivePolicy := range result {
+		sort.Slice(effectivePolicy.Spec.Paths, func(i, j int) bool {
+			a, b := effectivePolicy.Spec.Paths[i], effectivePolicy.Spec.Paths[j]
+			if a.Gateway != b.Gateway {
+				return a.Gateway < b.Gateway
+			}
+			if a.Listener != b.Listener {
+				return a.Listener < b.Listener
+			}
+			return a.RouteRule < b.RouteRule
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+func effectivePolicyRules(rules map[string]kuadrantv1.MergeableRule) []kuadrantv1alpha1.EffectivePolicyRule {
+	ruleIDs := lo.Keys(rules)
+	sort.Strings(ruleIDs)
+
+	return lo.FilterMap(ruleIDs, func(ruleID string, _ int) (kuadrantv1alpha1.EffectivePolicyRule, bool) {
+		rule := rules[ruleID]
+		spec, err := json.Marshal(rule.GetSpec())
+		if err != nil {
+			return kuadrantv1alpha1.EffectivePolicyRule{}, false
+		}
+		name := ruleID
+		if ruleID == kuadrantv1.RulesKeyTopLevelPredicates {
+			name = effectivePolicyTopLevelPredicatesRuleName
+		}
+		return kuadrantv1alpha1.EffectivePolicyRule{
+			Name:   name,
+			Source: rule.GetSource(),
+			Spec:   runtime.RawExtension{Raw: spec},
+		}, true
+	})
+}
+
+func routeFromParsedTopologyPath(parsed *kuadrantpolicymachinery.ParsedTopologyPath) machinery.Targetable {
+	if parsed.HTTPRoute != nil {
+		return parsed.HTTPRoute
+	}
+	return parsed.GRPCRoute
+}
+
+// equalEffectivePolicies compares the owner references and the specs of two EffectivePolicy objects. The rule specs are
+// compared semantically, as the raw JSON read from the cluster does not necessarily preserve the order of the fields.
+func equalEffectivePolicies(existing, desired *kuadrantv1alpha1.EffectivePolicy) bool {
+	if !reflect.DeepEqual(existing.OwnerReferences, desired.OwnerReferences) {
+		return false
+	}
+	existingSpec, err := normalizedJSON(existing.Spec)
+	if err != nil {
+		return false
+	}
+	desiredSpec, err := normalizedJSON(desired.Spec)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(existingSpec, desiredSpec)
+}
+
+func normalizedJSON(v any) (any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	if err := json.Unmarshal(raw, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
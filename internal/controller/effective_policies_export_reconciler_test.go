@@ -0,0 +1,227 @@
+//go:build unit
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/ptr"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
+	kuadrantv1alpha1 "github.com/kuadrant/kuadrant-operator/api/v1alpha1"
+)
+
+func TestBuildEffectivePolicyObjects(t *testing.T) {
+	gatewayClass := &machinery.GatewayClass{GatewayClass: &gatewayapiv1.GatewayClass{
+		TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.SchemeGroupVersion.String(), Kind: machinery.GatewayClassGroupKind.Kind},
+		ObjectMeta: metav1.ObjectMeta{Name: "my-gateway-class"},
+	}}
+	gateway := &machinery.Gateway{Gateway: &gatewayapiv1.Gateway{
+		TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.SchemeGroupVersion.String(), Kind: machinery.GatewayGroupKind.Kind},
+		ObjectMeta: metav1.ObjectMeta{Name: "my-gateway", Namespace: "gateway-system"},
+		Spec: gatewayapiv1.GatewaySpec{
+			GatewayClassName: "my-gateway-class",
+			Listeners:        []gatewayapiv1.Listener{{Name: "http"}},
+		},
+	}}
+	listener := &machinery.Listener{Listener: &gateway.Spec.Listeners[0], Gateway: gateway}
+	httpRoute := &machinery.HTTPRoute{HTTPRoute: &gatewayapiv1.HTTPRoute{
+		TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.SchemeGroupVersion.String(), Kind: machinery.HTTPRouteGroupKind.Kind},
+		ObjectMeta: metav1.ObjectMeta{Name: "toystore", Namespace: "my-ns", UID: types.UID("route-uid")},
+		Spec: gatewayapiv1.HTTPRouteSpec{
+			CommonRouteSpec: gatewayapiv1.CommonRouteSpec{
+				ParentRefs: []gatewayapiv1.ParentReference{{Name: "my-gateway", Namespace: ptr.To(gatewayapiv1.Namespace("gateway-system"))}},
+			},
+			Rules: []gatewayapiv1.HTTPRouteRule{{}, {}},
+		},
+	}}
+	rule1 := &machinery.HTTPRouteRule{Name: "rule-1", HTTPRoute: httpRoute, HTTPRouteRule: &httpRoute.Spec.Rules[0]}
+	rule2 := &machinery.HTTPRouteRule{Name: "rule-2", HTTPRoute: httpRoute, HTTPRouteRule: &httpRoute.Spec.Rules[1]}
+
+	path1 := []machinery.Targetable{gatewayClass, gateway, listener, httpRoute, rule1}
+	path2 := []machinery.Targetable{gatewayClass, gateway, listener, httpRoute, rule2}
+
+	const (
+		gatewayPolicy = "kuadrant.io/v1/ratelimitpolicy:gateway-system/gw-rlp"
+		routePolicy   = "kuadrant.io/v1/ratelimitpolicy:my-ns/toystore-rlp"
+		authPolicy    = "kuadrant.io/v1/authpolicy:my-ns/toystore-auth"
+	)
+
+	rateLimitPolicy := func(limits map[string]kuadrantv1.Limit, predicates ...string) kuadrantv1.RateLimitPolicy {
+		return kuadrantv1.RateLimitPolicy{
+			Spec: kuadrantv1.RateLimitPolicySpec{
+				RateLimitPolicySpecProper: kuadrantv1.RateLimitPolicySpecProper{
+					MergeableWhenPredicates: kuadrantv1.MergeableWhenPredicates{
+						Predicates: lo.Map(predicates, func(p string, _ int) kuadrantv1.Predicate { return kuadrantv1.Predicate{Predicate: p} }),
+					},
+					Limits: limits,
+				},
+			},
+		}
+	}
+
+	rateLimitPolicies := EffectiveRateLimitPolicies{
+		"path1": {
+			Path: path1,
+			Spec: rateLimitPolicy(map[string]kuadrantv1.Limit{
+				"global": {Rates: []kuadrantv1.Rate{{Limit: 100, Window: "1m"}}, Source: gatewayPolicy},
+			}),
+			SourcePolicies: []string{gatewayPolicy},
+		},
+		"path2": {
+			Path: path2,
+			Spec: func() kuadrantv1.RateLimitPolicy {
+				p := rateLimitPolicy(map[string]kuadrantv1.Limit{}, "request.method == 'POST'")
+				p.Spec.MergeableWhenPredicates.Source = routePolicy
+				p.Spec.Limits["writes"] = kuadrantv1.Limit{Rates: []kuadrantv1.Rate{{Limit: 5, Window: "10s"}}, Source: routePolicy}
+				p.Spec.Limits["global"] = kuadrantv1.Limit{Rates: []kuadrantv1.Rate{{Limit: 100, Window: "1m"}}, Source: gatewayPolicy}
+				return p
+			}(),
+			SourcePolicies: []string{gatewayPolicy, routePolicy},
+		},
+	}
+
+	authPolicies := EffectiveAuthPolicies{
+		"path2": {
+			Path: path2,
+			Spec: kuadrantv1.AuthPolicy{
+				Spec: kuadrantv1.AuthPolicySpec{
+					AuthPolicySpecProper: kuadrantv1.AuthPolicySpecProper{
+						AuthScheme: &kuadrantv1.AuthSchemeSpec{
+							Authentication: map[string]kuadrantv1.MergeableAuthenticationSpec{
+								"api-key": {Source: authPolicy},
+							},
+						},
+					},
+				},
+			},
+			SourcePolicies: []string{authPolicy},
+		},
+	}
+
+	var errs []error
+	effectivePolicies := buildEffectivePolicyObjects(authPolicies, rateLimitPolicies, EffectiveTokenRateLimitPolicies{}, func(err error, _ []machinery.Targetable) {
+		errs = append(errs, err)
+	})
+
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if len(effectivePolicies) != 1 {
+		t.Fatalf("expected 1 effective policy object, got %d", len(effectivePolicies))
+	}
+
+	effectivePolicy := effectivePolicies[0]
+	if effectivePolicy.Namespace != "my-ns" || effectivePolicy.Name != "httproute-toystore" {
+		t.Errorf("unexpected effective policy object key: %s/%s", effectivePolicy.Namespace, effectivePolicy.Name)
+	}
+	if len(effectivePolicy.OwnerReferences) != 1 || effectivePolicy.OwnerReferences[0].UID != "route-uid" || effectivePolicy.OwnerReferences[0].Kind != "HTTPRoute" {
+		t.Errorf("unexpected owner references: %+v", effectivePolicy.OwnerReferences)
+	}
+	if effectivePolicy.Spec.TargetRef.Kind != "HTTPRoute" || effectivePolicy.Spec.TargetRef.Name != "toystore" || effectivePolicy.Spec.TargetRef.Group != gatewayapiv1.GroupName {
+		t.Errorf("unexpected target ref: %+v", effectivePolicy.Spec.TargetRef)
+	}
+
+	paths := effectivePolicy.Spec.Paths
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 paths, got %d", len(paths))
+	}
+	if paths[0].Gateway != "gateway-system/my-gateway" || paths[0].Listener != "http" || paths[0].RouteRule != "rule-1" {
+		t.Errorf("unexpected first path: %+v", paths[0])
+	}
+	if len(paths[0].Policies) != 1 || paths[0].Policies[0].Kind != "RateLimitPolicy" {
+		t.Fatalf("unexpected policies in the first path: %+v", paths[0].Policies)
+	}
+	if paths[1].RouteRule != "rule-2" {
+		t.Errorf("unexpected second path: %+v", paths[1])
+	}
+
+	kinds := lo.Map(paths[1].Policies, func(p kuadrantv1alpha1.EffectivePolicyKind, _ int) string { return p.Kind })
+	if diff := cmp.Diff([]string{"AuthPolicy", "RateLimitPolicy"}, kinds); diff != "" {
+		t.Fatalf("unexpected policy kinds in the second path (-want +got):\n%s", diff)
+	}
+
+	authRules := paths[1].Policies[0].Rules
+	if len(authRules) != 1 || authRules[0].Name != "authentication#api-key" || authRules[0].Source != authPolicy {
+		t.Errorf("unexpected auth rules: %+v", authRules)
+	}
+
+	rateLimitRules := paths[1].Policies[1].Rules
+	names := lo.Map(rateLimitRules, func(r kuadrantv1alpha1.EffectivePolicyRule, _ int) string { return r.Name })
+	if diff := cmp.Diff([]string{effectivePolicyTopLevelPredicatesRuleName, "global", "writes"}, names); diff != "" {
+		t.Errorf("unexpected rate limit rule names (-want +got):\n%s", diff)
+	}
+	sources := lo.Map(rateLimitRules, func(r kuadrantv1alpha1.EffectivePolicyRule, _ int) string { return r.Source })
+	if diff := cmp.Diff([]string{routePolicy, gatewayPolicy, routePolicy}, sources); diff != "" {
+		t.Errorf("unexpected rate limit rule sources (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{gatewayPolicy, routePolicy}, paths[1].Policies[1].Sources); diff != "" {
+		t.Errorf("unexpected rate limit sources (-want +got):\n%s", diff)
+	}
+}
+
+func TestEffectivePolicyNameForRoute(t *testing.T) {
+	if name := EffectivePolicyNameForRoute("HTTPRoute", "my-route"); name != "httproute-my-route" {
+		t.Errorf("unexpected name, expected(httproute-my-route), got (%s)", name)
+	}
+
+	longRouteName := strings.Repeat("a", validation.DNS1123SubdomainMaxLength)
+	name := EffectivePolicyNameForRoute("HTTPRoute", longRouteName)
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		t.Errorf("invalid name %s: %v", name, errs)
+	}
+	if !strings.HasPrefix(name, "httproute-aaa") {
+		t.Errorf("expected the name to keep the beginning of the full name, got (%s)", name)
+	}
+	if other := EffectivePolicyNameForRoute("GRPCRoute", longRouteName); other == name {
+		t.Errorf("expected distinct names for routes of distinct kinds, got (%s)", other)
+	}
+	if other := EffectivePolicyNameForRoute("HTTPRoute", longRouteName[:validation.DNS1123SubdomainMaxLength-1]+"b"); other == name {
+		t.Errorf("expected distinct names for routes differing past the truncation, got (%s)", other)
+	}
+
+	// the truncated name must not end with a separator before the hash suffix
+	name = EffectivePolicyNameForRoute("HTTPRoute", strings.Repeat("a", 233)+strings.Repeat(".", 20))
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		t.Errorf("invalid name %s: %v", name, errs)
+	}
+}
+
+func TestEqualEffectivePolicies(t *testing.T) {
+	effectivePolicy := func(rawSpec string) *kuadrantv1alpha1.EffectivePolicy {
+		return &kuadrantv1alpha1.EffectivePolicy{
+			Spec: kuadrantv1alpha1.EffectivePolicySpec{
+				Paths: []kuadrantv1alpha1.EffectivePolicyPath{
+					{
+						Gateway:   "gateway-system/my-gateway",
+						Listener:  "http",
+						RouteRule: "rule-1",
+						Policies: []kuadrantv1alpha1.EffectivePolicyKind{
+							{
+								Kind:    "RateLimitPolicy",
+								Sources: []string{"kuadrant.io/v1/ratelimitpolicy:my-ns/my-rlp"},
+								Rules:   []kuadrantv1alpha1.EffectivePolicyRule{{Name: "global", Spec: runtime.RawExtension{Raw: []byte(rawSpec)}}},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	if !equalEffectivePolicies(effectivePolicy(`{"rates":[{"limit":10,"window":"1m"}]}`), effectivePolicy(`{ "rates": [ { "window": "1m", "limit": 10 } ] }`)) {
+		t.Error("expected effective policies with semantically equal rule specs to be equal")
+	}
+	if equalEffectivePolicies(effectivePolicy(`{"rates":[{"limit":10,"window":"1m"}]}`), effectivePolicy(`{"rates":[{"limit":20,"window":"1m"}]}`)) {
+		t.Error("expected effective policies with different rule specs to differ")
+	}
+}
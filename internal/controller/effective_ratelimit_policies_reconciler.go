@@ -73,11 +73,14 @@ func (r *EffectiveRateLimitPolicyReconciler) calculateEffectivePolicies(ctx cont
 	allRouteRules = append(allRouteRules, httpRouteRules...)
 	allRouteRules = append(allRouteRules, grpcRouteRules...)
 
+	isPolicyAcceptedAndNotDeleted := isRateLimitPolicyAcceptedAndNotDeletedFunc(state)
+	namespaceLabels := namespaceLabelsFunc(topology)
+
 	for _, gatewayClass := range gatewayClasses {
 		for _, routeRule := range allRouteRules {
 			paths := targetables.Paths(gatewayClass, routeRule) // this may be expensive in clusters with many gateway classes - an alternative is to deep search the topology for httprouterules and grpcrouterules from each gatewayclass, keeping record of the paths
 			for i := range paths {
-				if effectivePolicy := kuadrantv1.EffectivePolicyForPath[*kuadrantv1.RateLimitPolicy](paths[i], isRateLimitPolicyAcceptedAndNotDeletedFunc(state)); effectivePolicy != nil {
+				if effectivePolicy := kuadrantv1.EffectivePolicyForPath[*kuadrantv1.RateLimitPolicy](paths[i], policySelectsPathFunc(isPolicyAcceptedAndNotDeleted, paths[i], namespaceLabels)); effectivePolicy != nil {
 					pathID := kuadrantv1.PathID(paths[i])
 
 					// Extract source policy locators from the effective policy rules
@@ -41,6 +41,7 @@ func (r *EnvoyGatewayAuthClusterReconciler) Subscription() controller.Subscripti
 			{Kind: &machinery.GatewayGroupKind},
 			{Kind: &machinery.HTTPRouteGroupKind},
 			{Kind: &machinery.GRPCRouteGroupKind},
+			{Kind: &NamespaceGroupKind},
 			{Kind: &kuadrantv1.AuthPolicyGroupKind},
 			{Kind: &kuadrantenvoygateway.EnvoyPatchPolicyGroupKind},
 		},
@@ -9,12 +9,9 @@ import (
 
 	envoygatewayv1alpha1 "github.com/envoyproxy/gateway/api/v1alpha1"
 	"github.com/go-logr/logr"
-	"github.com/google/cel-go/cel"
 	"github.com/kuadrant/policy-machinery/controller"
 	"github.com/kuadrant/policy-machinery/machinery"
 	"github.com/samber/lo"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/codes"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	k8stypes "k8s.io/apimachinery/pkg/types"
@@ -28,11 +25,9 @@ import (
 	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
 	kuadrantv1alpha1 "github.com/kuadrant/kuadrant-operator/api/v1alpha1"
 	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
-	celvalidator "github.com/kuadrant/kuadrant-operator/internal/cel"
 	kuadrantenvoygateway "github.com/kuadrant/kuadrant-operator/internal/envoygateway"
 	"github.com/kuadrant/kuadrant-operator/internal/extension"
 	kuadrantgatewayapi "github.com/kuadrant/kuadrant-operator/internal/gatewayapi"
-	kuadrantpolicymachinery "github.com/kuadrant/kuadrant-operator/internal/policymachinery"
 	"github.com/kuadrant/kuadrant-operator/internal/utils"
 	"github.com/kuadrant/kuadrant-operator/internal/wasm"
 )
@@ -54,6 +49,7 @@ func (r *EnvoyGatewayExtensionReconciler) Subscription() controller.Subscription
 			{Kind: &machinery.GatewayGroupKind},
 			{Kind: &machinery.HTTPRouteGroupKind},
 			{Kind: &machinery.GRPCRouteGroupKind},
+			{Kind: &NamespaceGroupKind},
 			{Kind: &kuadrantv1.AuthPolicyGroupKind},
 			{Kind: &kuadrantv1.RateLimitPolicyGroupKind},
 			{Kind: &kuadrantv1alpha1.TokenRateLimitPolicyGroupKind},
@@ -79,7 +75,7 @@ func (r *EnvoyGatewayExtensionReconciler) Reconcile(ctx context.Context, _ []con
 	r.reconcileUpstreamClusters(ctx, topology, gateways)
 
 	// build wasm plugin configs for each gateway
-	wasmConfigs, err := r.buildWasmConfigs(ctx, topology, state)
+	wasmConfigs, err := buildWasmConfigs(ctx, topology, state, envoyGatewayGatewayControllerNames)
 	if err != nil {
 		if errors.Is(err, ErrMissingStateEffectiveAuthPolicies) || errors.Is(err, ErrMissingStateEffectiveRateLimitPolicies) {
 			logger.V(1).Info(err.Error())
@@ -94,7 +90,11 @@ func (r *EnvoyGatewayExtensionReconciler) Reconcile(ctx context.Context, _ []con
 		gatewayKey := k8stypes.NamespacedName{Name: gateway.GetName(), Namespace: gateway.GetNamespace()}
 
 		// Get the wasm config for this gateway and apply mutators
-		wasmConfig := wasmConfigs[gateway.GetLocator()]
+		wasmConfig, admitted := wasmConfigRolloutFromState(state).ConfigForGateway(gateway.GetLocator(), wasmConfigs[gateway.GetLocator()])
+		if !admitted {
+			logger.V(1).Info("gateway keeps its current wasm configuration until the rollout of the change is promoted", "gateway", gatewayKey.String())
+			continue
+		}
 		if err := extension.ApplyWasmConfigMutators(&wasmConfig, gateway, topology); err != nil {
 			logger.Error(err, "failed to apply wasm config mutators", "gateway", gatewayKey.String())
 		}
@@ -327,201 +327,6 @@ func buildUpstreamEnvoyPatchPolicy(logger logr.Logger, gateway *machinery.Gatewa
 	return policy, nil
 }
 
-// buildWasmConfigs returns a map of envoy gateway gateway locators to an ordered list of corresponding wasm policies
-func (r *EnvoyGatewayExtensionReconciler) buildWasmConfigs(ctx context.Context, topology *machinery.Topology, state *sync.Map) (map[string]wasm.Config, error) {
-	logger := controller.LoggerFromContext(ctx).WithName("EnvoyGatewayExtensionReconciler").WithName("buildWasmConfigs").WithValues("context", ctx)
-
-	serviceBuilder := wasm.NewServiceBuilder(&logger)
-	// Get Kuadrant CR to access observability settings
-	kObj := GetKuadrantFromTopology(topology, state)
-	var observability *wasm.Observability
-	if kObj != nil {
-		observability = wasm.BuildObservabilityConfig(serviceBuilder, &kObj.Spec.Observability)
-	}
-
-	effectiveAuthPolicies, ok := state.Load(StateEffectiveAuthPolicies)
-	if !ok {
-		return nil, ErrMissingStateEffectiveAuthPolicies
-	}
-	effectiveAuthPoliciesMap := effectiveAuthPolicies.(EffectiveAuthPolicies)
-
-	var effectiveRateLimitPoliciesMap EffectiveRateLimitPolicies
-	if effectiveRateLimitPolicies, ok := state.Load(StateEffectiveRateLimitPolicies); ok {
-		effectiveRateLimitPoliciesMap = effectiveRateLimitPolicies.(EffectiveRateLimitPolicies)
-	} else {
-		logger.V(1).Info("no effective rate limit policies found in state, continuing with empty map")
-	}
-
-	var effectiveTokenRateLimitPoliciesMap EffectiveTokenRateLimitPolicies
-	if effectiveTokenRateLimitPolicies, ok := state.Load(StateEffectiveTokenRateLimitPolicies); ok {
-		effectiveTokenRateLimitPoliciesMap = effectiveTokenRateLimitPolicies.(EffectiveTokenRateLimitPolicies)
-	} else {
-		logger.V(1).Info("no effective token rate limit policies found in state, continuing with empty map")
-	}
-
-	logger.V(1).Info("building wasm configs for envoy gateway extension", "effectiveAuthPolicies", len(effectiveAuthPoliciesMap), "effectiveRateLimitPolicies", len(effectiveRateLimitPoliciesMap), "effectiveTokenRateLimitPolicies", len(effectiveTokenRateLimitPoliciesMap))
-
-	// unique paths from different policy types
-	var allPaths []lo.Entry[string, []machinery.Targetable]
-
-	// paths from auth ratelimit and tokenratelimit policies
-	authPaths := lo.Entries(lo.MapValues(effectiveAuthPoliciesMap, func(p EffectiveAuthPolicy, _ string) []machinery.Targetable { return p.Path }))
-	allPaths = append(allPaths, authPaths...)
-	rateLimitPaths := lo.Entries(lo.MapValues(effectiveRateLimitPoliciesMap, func(p EffectiveRateLimitPolicy, _ string) []machinery.Targetable { return p.Path }))
-	allPaths = append(allPaths, rateLimitPaths...)
-	tokenRateLimitPaths := lo.Entries(lo.MapValues(effectiveTokenRateLimitPoliciesMap, func(p EffectiveTokenRateLimitPolicy, _ string) []machinery.Targetable { return p.Path }))
-	allPaths = append(allPaths, tokenRateLimitPaths...)
-
-	// unique paths by key
-	paths := lo.UniqBy(allPaths, func(e lo.Entry[string, []machinery.Targetable]) string { return e.Key })
-
-	wasmActionSets := kuadrantgatewayapi.GrouppedHTTPRouteMatchConfigs{}
-	celValidationIssues := celvalidator.NewIssueCollection()
-
-	tracer := controller.TracerFromContext(ctx)
-
-	// build the wasm policies for each topological path that contains an effective rate limit policy affecting an envoy gateway gateway
-	for i := range paths {
-		pathID := paths[i].Key
-		path := paths[i].Value
-
-		parsed, pathErr := kuadrantpolicymachinery.ParseTopologyPath(path)
-		if pathErr != nil {
-			continue
-		}
-
-		// ignore if not an envoy gateway gateway
-		if !lo.Contains(envoyGatewayGatewayControllerNames, parsed.GatewayClass.Spec.ControllerName) {
-			continue
-		}
-
-		// Create a parent span for this entire path processing
-		pathCtx, pathSpan := tracer.Start(ctx, "wasm.BuildConfigForPath")
-		pathSpan.SetAttributes(
-			attribute.String("path_id", pathID),
-			attribute.String("route_type", parsed.RouteType.String()),
-			attribute.String("gateway.name", parsed.Gateway.GetName()),
-			attribute.String("gateway.namespace", parsed.Gateway.GetNamespace()),
-			attribute.String("listener.name", string(parsed.Listener.Name)),
-			attribute.String("route.name", parsed.GetRouteName()),
-			attribute.String("route.namespace", parsed.GetRouteNamespace()),
-		)
-		validatorBuilder := celvalidator.NewRootValidatorBuilder()
-
-		var actions []wasm.Action
-
-		// auth
-		if effectivePolicy, ok := effectiveAuthPoliciesMap[pathID]; ok {
-			actions = append(actions, buildWasmActionsForAuth(pathID, effectivePolicy)...)
-			validatorBuilder.PushPolicyBinding(celvalidator.AuthPolicyKind, celvalidator.AuthPolicyName, cel.AnyType)
-		}
-
-		// rate limit
-		if effectivePolicy, ok := effectiveRateLimitPoliciesMap[pathID]; ok {
-			rlAction := buildWasmActionsForRateLimit(effectivePolicy, isRateLimitPolicyAcceptedAndNotDeletedFunc(state))
-			if hasAuthAccess(rlAction) {
-				actions = append(actions, rlAction...)
-			} else {
-				// pre auth rate limiting
-				actions = append(rlAction, actions...)
-			}
-			validatorBuilder.PushPolicyBinding(celvalidator.RateLimitPolicyKind, celvalidator.RateLimitName, cel.AnyType)
-		}
-
-		if effectivePolicy, ok := effectiveTokenRateLimitPoliciesMap[pathID]; ok {
-			trlAction := buildWasmActionsForTokenRateLimit(effectivePolicy, isTokenRateLimitPolicyAcceptedAndNotDeletedFunc(state))
-			if hasAuthAccess(trlAction) {
-				actions = append(actions, trlAction...)
-			} else {
-				// pre auth rate limiting
-				actions = append(trlAction, actions...)
-			}
-			validatorBuilder.PushPolicyBinding(celvalidator.TokenRateLimitPolicyKind, celvalidator.RateLimitName, cel.AnyType)
-		}
-
-		pathSpan.SetAttributes(attribute.Int("actions.before_merge", len(actions)))
-
-		// Extract and track source policies before merging
-		sourcePolicies := lo.Uniq(lo.FlatMap(actions, func(a wasm.Action, _ int) []string {
-			return a.SourcePolicyLocators
-		}))
-		if len(sourcePolicies) > 0 {
-			pathSpan.SetAttributes(attribute.StringSlice("source_policies", sourcePolicies))
-		}
-
-		actions, err := mergeAndVerify(pathCtx, actions)
-		if err != nil {
-			pathSpan.RecordError(err)
-			pathSpan.SetStatus(codes.Error, "failed to merge/verify actions")
-			pathSpan.End()
-			return nil, fmt.Errorf("failed to merge/verify actions for path %s: %w", pathID, err)
-		}
-
-		if len(actions) == 0 {
-			pathSpan.SetStatus(codes.Ok, "no actions after merge")
-			pathSpan.End()
-			continue
-		}
-
-		validator, err := validatorBuilder.Build()
-		if err != nil {
-			pathSpan.RecordError(err)
-			pathSpan.SetStatus(codes.Error, "failed to build validator")
-			pathSpan.End()
-			return nil, fmt.Errorf("failed to build validator for path %s: %w", pathID, err)
-		}
-		var validatedActions []wasm.Action
-
-		for _, action := range actions {
-			if err := celvalidator.ValidateWasmAction(action, validator); err != nil {
-				logger.V(1).Info("WASM action is invalid", "action", action, "path", pathID, "error", err)
-				celValidationIssues.Add(celvalidator.NewIssue(action, pathID, err))
-			} else {
-				validatedActions = append(validatedActions, action)
-			}
-		}
-
-		pathSpan.SetAttributes(
-			attribute.Int("actions.after_merge", len(actions)),
-			attribute.Int("actions.validated", len(validatedActions)),
-			attribute.Int("actions.invalid", len(actions)-len(validatedActions)),
-		)
-
-		if len(validatedActions) == 0 {
-			pathSpan.SetStatus(codes.Ok, "no validated actions")
-			pathSpan.End()
-			continue
-		}
-
-		wasmActionSetsForPath, err := wasm.BuildActionSetsForPath(pathCtx, pathID, path, validatedActions)
-		if err != nil {
-			logger.Error(err, "failed to build wasm policies for path", "pathID", pathID)
-			pathSpan.RecordError(err)
-			pathSpan.SetStatus(codes.Error, "failed to build action sets")
-			pathSpan.End()
-			continue
-		}
-
-		pathSpan.SetAttributes(attribute.Int("actionsets.created", len(wasmActionSetsForPath)))
-		pathSpan.SetStatus(codes.Ok, "")
-		pathSpan.End()
-
-		wasmActionSets.Add(parsed.Gateway.GetLocator(), wasmActionSetsForPath...)
-	}
-
-	if !celValidationIssues.IsEmpty() {
-		state.Store(celvalidator.StateCELValidationErrors, celValidationIssues)
-	}
-
-	wasmConfigs := lo.MapValues(wasmActionSets.Sorted(), func(configs kuadrantgatewayapi.SortableHTTPRouteMatchConfigs, _ string) wasm.Config {
-		return wasm.BuildConfigForActionSet(lo.Map(configs, func(c kuadrantgatewayapi.HTTPRouteMatchConfig, _ int) wasm.ActionSet {
-			return c.Config.(wasm.ActionSet)
-		}), &logger, observability, serviceBuilder)
-	})
-
-	return wasmConfigs, nil
-}
-
 // buildEnvoyExtensionPolicyForGateway builds a desired EnvoyExtensionPolicy custom resource for a given gateway and corresponding wasm config
 func buildEnvoyExtensionPolicyForGateway(gateway *machinery.Gateway, wasmConfig wasm.Config, protectedRegistry, imageURL string) *envoygatewayv1alpha1.EnvoyExtensionPolicy {
 	envoyPolicy := &envoygatewayv1alpha1.EnvoyExtensionPolicy{
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"fmt"
+	"sync"
+
+	egv1alpha1 "github.com/envoyproxy/gateway/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
+	"github.com/kuadrant/kuadrant-operator/internal/envoygateway"
+	kuadrantgatewayapi "github.com/kuadrant/kuadrant-operator/internal/gatewayapi"
+)
+
+// envoyGatewayGatewayProvider delivers the Kuadrant configuration to Envoy Gateway gateways via EnvoyPatchPolicy and
+// EnvoyExtensionPolicy custom resources
+type envoyGatewayGatewayProvider struct{}
+
+var _ GatewayProvider = &envoyGatewayGatewayProvider{}
+
+func (p *envoyGatewayGatewayProvider) Name() string {
+	return "envoygateway"
+}
+
+func (p *envoyGatewayGatewayProvider) IsInstalled(restMapper meta.RESTMapper) (bool, error) {
+	return envoygateway.IsEnvoyGatewayInstalled(restMapper)
+}
+
+func (p *envoyGatewayGatewayProvider) GatewayControllerNames() []gatewayapiv1.GatewayController {
+	return envoyGatewayGatewayControllerNames
+}
+
+func (p *envoyGatewayGatewayProvider) ControllerOptions(_ logr.Logger) []controller.ControllerOption {
+	return []controller.ControllerOption{
+		controller.WithRunnable("envoypatchpolicy watcher", controller.Watch(
+			&egv1alpha1.EnvoyPatchPolicy{},
+			envoygateway.EnvoyPatchPoliciesResource,
+			metav1.NamespaceAll,
+			controller.FilterResourcesByLabel[*egv1alpha1.EnvoyPatchPolicy](fmt.Sprintf("%s=true", kuadrantManagedLabelKey)),
+		)),
+		controller.WithRunnable("envoyextensionpolicy watcher", controller.Watch(
+			&egv1alpha1.EnvoyExtensionPolicy{},
+			envoygateway.EnvoyExtensionPoliciesResource,
+			metav1.NamespaceAll,
+			controller.FilterResourcesByLabel[*egv1alpha1.EnvoyExtensionPolicy](fmt.Sprintf("%s=true", kuadrantManagedLabelKey)),
+		)),
+		controller.WithObjectKinds(
+			envoygateway.EnvoyPatchPolicyGroupKind,
+			envoygateway.EnvoyExtensionPolicyGroupKind,
+		),
+		controller.WithObjectLinks(
+			envoygateway.LinkGatewayToEnvoyPatchPolicy,
+			envoygateway.LinkGatewayToEnvoyExtensionPolicy,
+		),
+	}
+}
+
+func (p *envoyGatewayGatewayProvider) Reconcilers(opts GatewayProviderOptions) []controller.ReconcileFunc {
+	return []controller.ReconcileFunc{
+		traceReconcileFunc("reconciler.envoy_gateway_auth_cluster", (&EnvoyGatewayAuthClusterReconciler{client: opts.Client}).Subscription().Reconcile),
+		traceReconcileFunc("reconciler.envoy_gateway_ratelimit_cluster", (&EnvoyGatewayRateLimitClusterReconciler{client: opts.Client}).Subscription().Reconcile),
+		traceReconcileFunc("reconciler.envoy_gateway_tracing_cluster", (&EnvoyGatewayTracingClusterReconciler{client: opts.Client}).Subscription().Reconcile),
+		traceReconcileFunc("reconciler.envoy_gateway_extension", (&EnvoyGatewayExtensionReconciler{client: opts.Client}).Subscription().Reconcile),
+		traceReconcileFunc("reconciler.envoy_gateway_native_filters", (&EnvoyGatewayNativeFiltersReconciler{client: opts.Client}).Subscription().Reconcile),
+	}
+}
+
+func (p *envoyGatewayGatewayProvider) ComponentsToSync(policyKind schema.GroupKind, gateway *machinery.Gateway, gatewayClass *machinery.GatewayClass, topology *machinery.Topology, state *sync.Map) []string {
+	var componentsToSync []string
+
+	controllerName := gatewayClass.Spec.ControllerName
+	gatewayAncestor := gatewayapiv1.ParentReference{Name: gatewayapiv1.ObjectName(gateway.GetName()), Namespace: ptr.To(gatewayapiv1.Namespace(gateway.GetNamespace()))}
+
+	// EnvoyPatchPolicy
+	clustersModifiedStateKey := StateEnvoyGatewayRateLimitClustersModified
+	if policyKind == kuadrantv1.AuthPolicyGroupKind {
+		clustersModifiedStateKey = StateEnvoyGatewayAuthClustersModified
+	}
+	envoyGatewayClustersModifiedGateways, _ := state.Load(clustersModifiedStateKey)
+	componentsToSync = append(componentsToSync, gatewayComponentsToSync(gateway, envoygateway.EnvoyPatchPolicyGroupKind, envoyGatewayClustersModifiedGateways, topology, func(obj machinery.Object) bool {
+		return meta.IsStatusConditionTrue(kuadrantgatewayapi.PolicyStatusConditionsFromAncestor(obj.(*controller.RuntimeObject).Object.(*egv1alpha1.EnvoyPatchPolicy).Status, controllerName, gatewayAncestor, gatewayapiv1.Namespace(obj.GetNamespace())), string(egv1alpha1.PolicyConditionProgrammed))
+	})...)
+
+	if isNativeDataPlaneGateway(gateway) {
+		// EnvoyPatchPolicy (native filters)
+		envoyGatewayNativeFiltersModifiedGateways, _ := state.Load(StateEnvoyGatewayNativeFiltersModified)
+		componentsToSync = append(componentsToSync, gatewayComponentsToSyncWithName(gateway, envoygateway.EnvoyPatchPolicyGroupKind, NativeFiltersName(gateway.GetName()), envoyGatewayNativeFiltersModifiedGateways, topology, func(obj machinery.Object) bool {
+			return meta.IsStatusConditionTrue(kuadrantgatewayapi.PolicyStatusConditionsFromAncestor(obj.(*controller.RuntimeObject).Object.(*egv1alpha1.EnvoyPatchPolicy).Status, controllerName, gatewayAncestor, gatewayapiv1.Namespace(obj.GetNamespace())), string(egv1alpha1.PolicyConditionProgrammed))
+		})...)
+	} else {
+		// EnvoyExtensionPolicy
+		envoyGatewayExtensionsModifiedGateways, _ := state.Load(StateEnvoyGatewayExtensionsModified)
+		componentsToSync = append(componentsToSync, gatewayComponentsToSync(gateway, envoygateway.EnvoyExtensionPolicyGroupKind, envoyGatewayExtensionsModifiedGateways, topology, func(obj machinery.Object) bool {
+			return meta.IsStatusConditionTrue(kuadrantgatewayapi.PolicyStatusConditionsFromAncestor(obj.(*controller.RuntimeObject).Object.(*egv1alpha1.EnvoyExtensionPolicy).Status, controllerName, gatewayAncestor, gatewayapiv1.Namespace(obj.GetNamespace())), string(gatewayapiv1alpha2.PolicyConditionAccepted))
+		})...)
+	}
+
+	return componentsToSync
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	envoygatewayv1alpha1 "github.com/envoyproxy/gateway/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/utils/ptr"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
+	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
+	kuadrantenvoygateway "github.com/kuadrant/kuadrant-operator/internal/envoygateway"
+	"github.com/kuadrant/kuadrant-operator/internal/utils"
+)
+
+//+kubebuilder:rbac:groups=gateway.envoyproxy.io,resources=envoypatchpolicies,verbs=get;list;watch;create;update;patch;delete
+
+// EnvoyGatewayNativeFiltersReconciler reconciles Envoy Gateway EnvoyPatchPolicy custom resources that configure the
+// native ext_authz and ratelimit filters of the gateways in native data plane mode
+type EnvoyGatewayNativeFiltersReconciler struct {
+	client *dynamic.DynamicClient
+}
+
+// EnvoyGatewayNativeFiltersReconciler subscribes to events with potential impact on the native filters of the gateways
+func (r *EnvoyGatewayNativeFiltersReconciler) Subscription() controller.Subscription {
+	return controller.Subscription{
+		ReconcileFunc: r.Reconcile,
+		Events: []controller.ResourceEventMatcher{
+			{Kind: &kuadrantv1beta1.KuadrantGroupKind},
+			{Kind: &machinery.GatewayClassGroupKind},
+			{Kind: &machinery.GatewayGroupKind},
+			{Kind: &machinery.HTTPRouteGroupKind},
+			{Kind: &NamespaceGroupKind},
+			{Kind: &kuadrantv1.AuthPolicyGroupKind},
+			{Kind: &kuadrantv1.RateLimitPolicyGroupKind},
+			{Kind: &kuadrantenvoygateway.EnvoyPatchPolicyGroupKind},
+		},
+	}
+}
+
+func (r *EnvoyGatewayNativeFiltersReconciler) Reconcile(ctx context.Context, _ []controller.ResourceEvent, topology *machinery.Topology, _ error, state *sync.Map) error {
+	logger := controller.LoggerFromContext(ctx).WithName("EnvoyGatewayNativeFiltersReconciler").WithValues("context", ctx)
+
+	logger.V(1).Info("building envoy gateway native filters")
+	defer logger.V(1).Info("finished building envoy gateway native filters")
+
+	nativeRouteConfigs, err := buildNativeRouteConfigs(ctx, topology, state, envoyGatewayGatewayControllerNames)
+	if err != nil {
+		if errors.Is(err, ErrMissingStateEffectiveAuthPolicies) {
+			logger.V(1).Info(err.Error())
+		} else {
+			return err
+		}
+	}
+
+	gateways := lo.Map(topology.Targetables().Items(func(o machinery.Object) bool {
+		return o.GroupVersionKind().GroupKind() == machinery.GatewayGroupKind
+	}), func(g machinery.Targetable, _ int) *machinery.Gateway {
+		return g.(*machinery.Gateway)
+	})
+
+	var modifiedGateways []string
+
+	for _, gateway := range gateways {
+		gatewayKey := k8stypes.NamespacedName{Name: gateway.GetName(), Namespace: gateway.GetNamespace()}
+
+		desiredEnvoyPatchPolicy, err := buildEnvoyGatewayNativeFiltersPatchPolicy(logger, gateway, nativeRouteConfigs[gateway.GetLocator()])
+		if err != nil {
+			logger.Error(err, "failed to build envoypatchpolicy object for native filters", "gateway", gatewayKey.String())
+			continue
+		}
+
+		resource := r.client.Resource(kuadrantenvoygateway.EnvoyPatchPoliciesResource).Namespace(desiredEnvoyPatchPolicy.GetNamespace())
+
+		existingEnvoyPatchPolicyObj, found := lo.Find(topology.Objects().Children(gateway), func(child machinery.Object) bool {
+			return child.GroupVersionKind().GroupKind() == kuadrantenvoygateway.EnvoyPatchPolicyGroupKind && child.GetName() == desiredEnvoyPatchPolicy.GetName() && child.GetNamespace() == desiredEnvoyPatchPolicy.GetNamespace() && labels.Set(child.(*controller.RuntimeObject).GetLabels()).AsSelector().Matches(labels.Set(desiredEnvoyPatchPolicy.GetLabels()))
+		})
+
+		// create
+		if !found {
+			if utils.IsObjectTaggedToDelete(desiredEnvoyPatchPolicy) {
+				continue
+			}
+			modifiedGateways = append(modifiedGateways, gateway.GetLocator()) // we only signal the gateway as modified when an envoypatchpolicy is created, because updates won't change the status
+			desiredEnvoyPatchPolicyUnstructured, err := controller.Destruct(desiredEnvoyPatchPolicy)
+			if err != nil {
+				logger.Error(err, "failed to destruct envoypatchpolicy object", "gateway", gatewayKey.String(), "envoypatchpolicy", desiredEnvoyPatchPolicy)
+				continue
+			}
+			if _, err = resource.Create(ctx, desiredEnvoyPatchPolicyUnstructured, metav1.CreateOptions{}); err != nil {
+				logger.Error(err, "failed to create envoypatchpolicy object", "gateway", gatewayKey.String(), "envoypatchpolicy", desiredEnvoyPatchPolicyUnstructured.Object)
+				// TODO: handle error
+			}
+			continue
+		}
+
+		existingEnvoyPatchPolicy := existingEnvoyPatchPolicyObj.(*controller.RuntimeObject).Object.(*envoygatewayv1alpha1.EnvoyPatchPolicy)
+
+		// delete
+		if utils.IsObjectTaggedToDelete(desiredEnvoyPatchPolicy) && !utils.IsObjectTaggedToDelete(existingEnvoyPatchPolicy) {
+			if err := resource.Delete(ctx, existingEnvoyPatchPolicy.GetName(), metav1.DeleteOptions{}); err != nil {
+				logger.Error(err, "failed to delete envoypatchpolicy object", "gateway", gatewayKey.String(), "envoypatchpolicy", fmt.Sprintf("%s/%s", existingEnvoyPatchPolicy.GetNamespace(), existingEnvoyPatchPolicy.GetName()))
+				// TODO: handle error
+			}
+			continue
+		}
+
+		if kuadrantenvoygateway.EqualEnvoyPatchPolicies(existingEnvoyPatchPolicy, desiredEnvoyPatchPolicy) {
+			logger.V(1).Info("envoypatchpolicy object is up to date, nothing to do")
+			continue
+		}
+
+		// update
+		existingEnvoyPatchPolicy.Spec = envoygatewayv1alpha1.EnvoyPatchPolicySpec{
+			TargetRef:   desiredEnvoyPatchPolicy.Spec.TargetRef,
+			Type:        desiredEnvoyPatchPolicy.Spec.Type,
+			JSONPatches: desiredEnvoyPatchPolicy.Spec.JSONPatches,
+		}
+
+		existingEnvoyPatchPolicyUnstructured, err := controller.Destruct(existingEnvoyPatchPolicy)
+		if err != nil {
+			logger.Error(err, "failed to destruct envoypatchpolicy object", "gateway", gatewayKey.String(), "envoypatchpolicy", existingEnvoyPatchPolicy)
+			continue
+		}
+		if _, err = resource.Update(ctx, existingEnvoyPatchPolicyUnstructured, metav1.UpdateOptions{}); err != nil {
+			logger.Error(err, "failed to update envoypatchpolicy object", "gateway", gatewayKey.String(), "envoypatchpolicy", existingEnvoyPatchPolicyUnstructured.Object)
+			// TODO: handle error
+		}
+	}
+
+	state.Store(StateEnvoyGatewayNativeFiltersModified, modifiedGateways)
+
+	return nil
+}
+
+// buildEnvoyGatewayNativeFiltersPatchPolicy builds a desired EnvoyPatchPolicy custom resource that adds the native
+// filters to the listeners of a gateway and enables them in the routes of the given route configs.
+//
+// Envoy Gateway names the listeners and route configurations after the Gateway listeners (<namespace>/<gateway>/<listener>)
+// and the routes after the HTTPRoute rules (httproute/<namespace>/<route>/rule/<index>/match/<index>/<hostname>).
+func buildEnvoyGatewayNativeFiltersPatchPolicy(logger logr.Logger, gateway *machinery.Gateway, configs []nativeRouteConfig) (*envoygatewayv1alpha1.EnvoyPatchPolicy, error) {
+	envoyPatchPolicy := &envoygatewayv1alpha1.EnvoyPatchPolicy{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       kuadrantenvoygateway.EnvoyPatchPolicyGroupKind.Kind,
+			APIVersion: envoygatewayv1alpha1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      NativeFiltersName(gateway.GetName()),
+			Namespace: gateway.GetNamespace(),
+			Labels:    KuadrantManagedObjectLabels(),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         gateway.GroupVersionKind().GroupVersion().String(),
+					Kind:               gateway.GroupVersionKind().Kind,
+					Name:               gateway.Name,
+					UID:                gateway.UID,
+					BlockOwnerDeletion: ptr.To(true),
+					Controller:         ptr.To(true),
+				},
+			},
+		},
+		Spec: envoygatewayv1alpha1.EnvoyPatchPolicySpec{
+			TargetRef: gatewayapiv1alpha2.LocalPolicyTargetReference{
+				Group: gatewayapiv1alpha2.Group(machinery.GatewayGroupKind.Group),
+				Kind:  gatewayapiv1alpha2.Kind(machinery.GatewayGroupKind.Kind),
+				Name:  gatewayapiv1alpha2.ObjectName(gateway.GetName()),
+			},
+			Type: envoygatewayv1alpha1.JSONPatchEnvoyPatchType,
+		},
+	}
+
+	if len(configs) == 0 {
+		utils.TagObjectToDelete(envoyPatchPolicy)
+		return envoyPatchPolicy, nil
+	}
+
+	filters := buildNativeFilters(logger, configs)
+
+	listenerNames := lo.Uniq(lo.Map(configs, func(c nativeRouteConfig, _ int) string {
+		return fmt.Sprintf("%s/%s/%s", gateway.GetNamespace(), gateway.GetName(), c.Listener.Name)
+	}))
+
+	var jsonPatches []envoygatewayv1alpha1.EnvoyJSONPatchConfig
+	for _, listenerName := range listenerNames {
+		patches, err := kuadrantenvoygateway.BuildEnvoyPatchPolicyHTTPFiltersPatch(listenerName, filters)
+		if err != nil {
+			return nil, err
+		}
+		jsonPatches = append(jsonPatches, patches...)
+	}
+
+	for _, config := range configs {
+		route := config.Route
+		routeConfigurationName := fmt.Sprintf("%s/%s/%s", gateway.GetNamespace(), gateway.GetName(), config.Listener.Name)
+		// dots are escaped in the regular expression, and the escaping backslash in the JSONPath string literal
+		routeNamePattern := strings.ReplaceAll(fmt.Sprintf("%s/%s/%s/rule/%d/match/", nativeRouteKind(config), route.GetNamespace(), route.GetName(), config.RuleIndex), ".", `\\.`) + ".*"
+		patch, err := kuadrantenvoygateway.BuildEnvoyPatchPolicyRoutePatch(routeConfigurationName, routeNamePattern, config.PerFilterConfig())
+		if err != nil {
+			return nil, err
+		}
+		jsonPatches = append(jsonPatches, patch)
+	}
+
+	envoyPatchPolicy.Spec.JSONPatches = jsonPatches
+
+	return envoyPatchPolicy, nil
+}
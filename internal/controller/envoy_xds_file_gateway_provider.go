@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/env"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+const (
+	envoyXDSFileObjectLabelKey = "kuadrant.io/envoy-xds"
+
+	// Keys of the xDS DiscoveryResponse files in the ConfigMaps read by the gateways
+	EnvoyXDSClustersFileName         = "cds.json"
+	EnvoyXDSExtensionConfigsFileName = "ecds.json"
+
+	StateEnvoyXDSFilesModified = "EnvoyXDSFilesModified"
+)
+
+// envoyXDSFileGatewayControllerNames are the gateway controllers of the gateways configured via xDS files.
+// Unlike the other providers, there is no default gateway controller name, the provider is only enabled when the
+// environment variable is set.
+var envoyXDSFileGatewayControllerNames = lo.FilterMap(strings.Split(env.GetString("ENVOY_XDS_FILE_GATEWAY_CONTROLLER_NAMES", ""), ","), func(c string, _ int) (gatewayapiv1.GatewayController, bool) {
+	c = strings.TrimSpace(c)
+	return gatewayapiv1.GatewayController(c), c != ""
+})
+
+// envoyXDSFileGatewayProvider delivers the Kuadrant configuration to plain Envoy based gateways as xDS DiscoveryResponse
+// files stored in a ConfigMap per gateway. The ConfigMap is meant to be mounted in the Envoy pods of the gateway and
+// the files watched via path_config_source, the clusters as CDS and the wasm filter as ECDS.
+type envoyXDSFileGatewayProvider struct{}
+
+var _ GatewayProvider = &envoyXDSFileGatewayProvider{}
+
+func (p *envoyXDSFileGatewayProvider) Name() string {
+	return "envoy_xds_file"
+}
+
+func (p *envoyXDSFileGatewayProvider) IsInstalled(_ meta.RESTMapper) (bool, error) {
+	return len(envoyXDSFileGatewayControllerNames) > 0, nil
+}
+
+func (p *envoyXDSFileGatewayProvider) GatewayControllerNames() []gatewayapiv1.GatewayController {
+	return envoyXDSFileGatewayControllerNames
+}
+
+func (p *envoyXDSFileGatewayProvider) ControllerOptions(logger logr.Logger) []controller.ControllerOption {
+	return []controller.ControllerOption{
+		controller.WithRunnable("envoy xds configmap watcher", controller.Watch(
+			&corev1.ConfigMap{},
+			controller.ConfigMapsResource,
+			metav1.NamespaceAll,
+			controller.FilterResourcesByLabel[*corev1.ConfigMap](fmt.Sprintf("%s=true", envoyXDSFileObjectLabelKey)),
+		)),
+		controller.WithObjectLinks(
+			linkGatewayToEnvoyXDSConfigMap,
+		),
+		controller.WithRunnable("wasm server", wasmServerRunnable(logger)),
+	}
+}
+
+func (p *envoyXDSFileGatewayProvider) Reconcilers(opts GatewayProviderOptions) []controller.ReconcileFunc {
+	return []controller.ReconcileFunc{
+		traceReconcileFunc("reconciler.envoy_xds_file", (&EnvoyXDSFileReconciler{client: opts.Client}).Subscription().Reconcile),
+	}
+}
+
+func (p *envoyXDSFileGatewayProvider) ComponentsToSync(_ schema.GroupKind, gateway *machinery.Gateway, _ *machinery.GatewayClass, topology *machinery.Topology, state *sync.Map) []string {
+	envoyXDSFilesModifiedGateways, _ := state.Load(StateEnvoyXDSFilesModified)
+	componentsToSync := gatewayComponentsToSyncWithName(gateway, ConfigMapGroupKind, EnvoyXDSConfigMapName(gateway.GetName()), envoyXDSFilesModifiedGateways, topology, func(_ machinery.Object) bool {
+		return true // ConfigMaps have no status, the files are picked up by Envoy from the filesystem
+	})
+	return append(componentsToSync, wasmModuleComponentsToSync(gateway)...)
+}
+
+func EnvoyXDSConfigMapName(gatewayName string) string {
+	return fmt.Sprintf("kuadrant-xds-%s", gatewayName)
+}
+
+func EnvoyXDSFileObjectLabels() labels.Set {
+	m := KuadrantManagedObjectLabels()
+	m[envoyXDSFileObjectLabelKey] = "true"
+	return m
+}
+
+func linkGatewayToEnvoyXDSConfigMap(objs controller.Store) machinery.LinkFunc {
+	gateways := lo.Map(objs.FilterByGroupKind(machinery.GatewayGroupKind), func(obj controller.Object, _ int) machinery.Object {
+		return &machinery.Gateway{Gateway: obj.(*gatewayapiv1.Gateway)}
+	})
+
+	return machinery.LinkFunc{
+		From: machinery.GatewayGroupKind,
+		To:   ConfigMapGroupKind,
+		Func: func(child machinery.Object) []machinery.Object {
+			configMap := child.(*controller.RuntimeObject).Object.(*corev1.ConfigMap)
+			if configMap.GetLabels()[envoyXDSFileObjectLabelKey] != "true" {
+				return nil
+			}
+			return lo.Filter(gateways, func(gateway machinery.Object, _ int) bool {
+				return EnvoyXDSConfigMapName(gateway.GetName()) == configMap.GetName() && gateway.GetNamespace() == configMap.GetNamespace()
+			})
+		},
+	}
+}
@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+
+	authorinooperatorv1beta1 "github.com/kuadrant/authorino-operator/api/v1beta1"
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/utils/env"
+	"k8s.io/utils/ptr"
+
+	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
+	kuadrantv1alpha1 "github.com/kuadrant/kuadrant-operator/api/v1alpha1"
+	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
+	kuadrantenvoy "github.com/kuadrant/kuadrant-operator/internal/envoy"
+	"github.com/kuadrant/kuadrant-operator/internal/extension"
+	"github.com/kuadrant/kuadrant-operator/internal/utils"
+	"github.com/kuadrant/kuadrant-operator/internal/wasm"
+)
+
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+
+// EnvoyXDSFileReconciler reconciles the ConfigMaps with the xDS DiscoveryResponse files of the gateways configured
+// by the Envoy xDS file gateway provider
+type EnvoyXDSFileReconciler struct {
+	client *dynamic.DynamicClient
+}
+
+// EnvoyXDSFileReconciler subscribes to events with potential impact on the xDS files of the gateways
+func (r *EnvoyXDSFileReconciler) Subscription() controller.Subscription {
+	return controller.Subscription{
+		ReconcileFunc: r.Reconcile,
+		Events: []controller.ResourceEventMatcher{
+			{Kind: &kuadrantv1beta1.KuadrantGroupKind},
+			{Kind: &machinery.GatewayClassGroupKind},
+			{Kind: &machinery.GatewayGroupKind},
+			{Kind: &machinery.HTTPRouteGroupKind},
+			{Kind: &machinery.GRPCRouteGroupKind},
+			{Kind: &NamespaceGroupKind},
+			{Kind: &kuadrantv1.AuthPolicyGroupKind},
+			{Kind: &kuadrantv1.RateLimitPolicyGroupKind},
+			{Kind: &kuadrantv1alpha1.TokenRateLimitPolicyGroupKind},
+			{Kind: &kuadrantv1beta1.AuthorinoGroupKind},
+			{Kind: &kuadrantv1beta1.LimitadorGroupKind},
+			{Kind: &ConfigMapGroupKind},
+		},
+	}
+}
+
+func (r *EnvoyXDSFileReconciler) Reconcile(ctx context.Context, _ []controller.ResourceEvent, topology *machinery.Topology, _ error, state *sync.Map) error {
+	logger := controller.LoggerFromContext(ctx).WithName("EnvoyXDSFileReconciler").WithValues("context", ctx)
+
+	logger.V(1).Info("building envoy xds files")
+	defer logger.V(1).Info("finished building envoy xds files")
+
+	kuadrant := GetKuadrantFromTopology(topology, state)
+	if kuadrant == nil {
+		return nil
+	}
+
+	operatorNamespace := env.GetString("OPERATOR_NAMESPACE", "kuadrant-system")
+	wasmServerHost := fmt.Sprintf("kuadrant-operator-wasm.%s.svc.cluster.local", operatorNamespace)
+	wasmServerPort, portErr := env.GetInt("WASM_SERVER_PORT", defaultWasmServerPort)
+	if portErr != nil {
+		wasmServerPort = defaultWasmServerPort
+	}
+
+	gateways := lo.FilterMap(topology.Targetables().Items(func(o machinery.Object) bool {
+		return o.GroupVersionKind().GroupKind() == machinery.GatewayGroupKind
+	}), func(t machinery.Targetable, _ int) (*machinery.Gateway, bool) {
+		gatewayClass, found := lo.Find(topology.Targetables().Parents(t), func(p machinery.Targetable) bool {
+			return p.GroupVersionKind().GroupKind() == machinery.GatewayClassGroupKind
+		})
+		return t.(*machinery.Gateway), found && lo.Contains(envoyXDSFileGatewayControllerNames, gatewayClass.(*machinery.GatewayClass).Spec.ControllerName)
+	})
+
+	// build wasm plugin configs for each gateway
+	wasmConfigs, err := buildWasmConfigs(ctx, topology, state, envoyXDSFileGatewayControllerNames)
+	if err != nil {
+		if errors.Is(err, ErrMissingStateEffectiveAuthPolicies) || errors.Is(err, ErrMissingStateEffectiveRateLimitPolicies) {
+			logger.V(1).Info(err.Error())
+		} else {
+			return err
+		}
+	}
+
+	clusters := buildEnvoyXDSClusters(kuadrant, topology, state, wasmServerHost, wasmServerPort)
+
+	modifiedGateways := make([]string, 0, len(gateways))
+
+	for _, gateway := range gateways {
+		gatewayKey := k8stypes.NamespacedName{Name: gateway.GetName(), Namespace: gateway.GetNamespace()}
+
+		// Get the wasm config for this gateway and apply mutators
+		wasmConfig, admitted := wasmConfigRolloutFromState(state).ConfigForGateway(gateway.GetLocator(), wasmConfigs[gateway.GetLocator()])
+		if !admitted {
+			logger.V(1).Info("gateway keeps its current wasm configuration until the rollout of the change is promoted", "gateway", gatewayKey.String())
+			continue
+		}
+		if err := extension.ApplyWasmConfigMutators(&wasmConfig, gateway, topology); err != nil {
+			logger.Error(err, "failed to apply wasm config mutators", "gateway", gatewayKey.String())
+		}
+
+		wasmURL, wasmSHA, err := wasmModuleForGateway(gateway, wasmServerHost, wasmServerPort)
+		if err != nil {
+			// the gateway keeps its current wasm module, the unresolvable version is reported in the status of the gateway
+			logger.Error(err, "failed to resolve the wasm module pinned to the gateway", "gateway", gatewayKey.String())
+			continue
+		}
+		desiredConfigMap, err := buildEnvoyXDSConfigMapForGateway(gateway, wasmConfig, clusters, wasmURL, wasmSHA)
+		if err != nil {
+			logger.Error(err, "failed to build desired configmap", "gateway", gatewayKey.String())
+			continue
+		}
+
+		resource := r.client.Resource(controller.ConfigMapsResource).Namespace(desiredConfigMap.GetNamespace())
+
+		existingConfigMapObj, found := lo.Find(topology.Objects().Children(gateway), func(child machinery.Object) bool {
+			return child.GroupVersionKind().GroupKind() == ConfigMapGroupKind && child.GetName() == desiredConfigMap.GetName() && child.GetNamespace() == desiredConfigMap.GetNamespace() && labels.Set(child.(*controller.RuntimeObject).GetLabels()).AsSelector().Matches(labels.Set(desiredConfigMap.GetLabels()))
+		})
+
+		// create
+		if !found {
+			if utils.IsObjectTaggedToDelete(desiredConfigMap) {
+				continue
+			}
+			modifiedGateways = append(modifiedGateways, gateway.GetLocator()) // we only signal the gateway as modified when a configmap is created, because updates won't change the status
+			desiredConfigMapUnstructured, err := controller.Destruct(desiredConfigMap)
+			if err != nil {
+				logger.Error(err, "failed to destruct configmap object", "gateway", gatewayKey.String(), "configmap", desiredConfigMap)
+				continue
+			}
+			if _, err = resource.Create(ctx, desiredConfigMapUnstructured, metav1.CreateOptions{}); err != nil {
+				logger.Error(err, "failed to create configmap object", "gateway", gatewayKey.String(), "configmap", desiredConfigMapUnstructured.Object)
+				// TODO: handle error
+			}
+			continue
+		}
+
+		existingConfigMap := existingConfigMapObj.(*controller.RuntimeObject).Object.(*corev1.ConfigMap)
+
+		// delete
+		if utils.IsObjectTaggedToDelete(desiredConfigMap) && !utils.IsObjectTaggedToDelete(existingConfigMap) {
+			if err := resource.Delete(ctx, existingConfigMap.GetName(), metav1.DeleteOptions{}); err != nil {
+				logger.Error(err, "failed to delete configmap object", "gateway", gatewayKey.String(), "configmap", fmt.Sprintf("%s/%s", existingConfigMap.GetNamespace(), existingConfigMap.GetName()))
+				// TODO: handle error
+			}
+			continue
+		}
+
+		if maps.Equal(existingConfigMap.Data, desiredConfigMap.Data) {
+			logger.V(1).Info("configmap object is up to date, nothing to do")
+			continue
+		}
+
+		// update
+		existingConfigMap.Data = desiredConfigMap.Data
+
+		existingConfigMapUnstructured, err := controller.Destruct(existingConfigMap)
+		if err != nil {
+			logger.Error(err, "failed to destruct configmap object", "gateway", gatewayKey.String(), "configmap", existingConfigMap)
+			continue
+		}
+		if _, err = resource.Update(ctx, existingConfigMapUnstructured, metav1.UpdateOptions{}); err != nil {
+			logger.Error(err, "failed to update configmap object", "gateway", gatewayKey.String(), "configmap", existingConfigMapUnstructured.Object)
+			// TODO: handle error
+		}
+	}
+
+	state.Store(StateEnvoyXDSFilesModified, modifiedGateways)
+
+	return nil
+}
+
+// buildEnvoyXDSClusters returns the Envoy clusters of the services referred in the wasm configuration.
+// Clusters are configured without mTLS, as the SDS configuration of the transport socket is specific to Istio.
+func buildEnvoyXDSClusters(kuadrant *kuadrantv1beta1.Kuadrant, topology *machinery.Topology, state *sync.Map, wasmServerHost string, wasmServerPort int) []map[string]any {
+	clusters := []map[string]any{
+		buildClusterPatch(WasmServerClusterName, wasmServerHost, wasmServerPort, false),
+	}
+
+	if authorinoObj, found := lo.Find(topology.Objects().Children(kuadrant), func(child machinery.Object) bool {
+		return child.GroupVersionKind().GroupKind() == kuadrantv1beta1.AuthorinoGroupKind
+	}); found {
+		authorinoServiceInfo := authorinoServiceInfoFromAuthorino(authorinoObj.(*controller.RuntimeObject).Object.(*authorinooperatorv1beta1.Authorino))
+		clusters = append(clusters, authClusterPatch(authorinoServiceInfo.Host, int(authorinoServiceInfo.Port), false))
+	}
+
+	if limitador := GetLimitadorFromTopology(topology, state); limitador != nil && limitador.Status.Service != nil {
+		clusters = append(clusters, rateLimitClusterPatch(limitador.Status.Service.Host, int(limitador.Status.Service.Ports.GRPC), false))
+	}
+
+	if tracing := kuadrant.Spec.Observability.Tracing; tracing != nil && tracing.DefaultEndpoint != "" {
+		if host, port, err := parseTracingEndpoint(tracing.DefaultEndpoint); err == nil {
+			clusters = append(clusters, tracingClusterPatch(host, port, false))
+		}
+	}
+
+	return clusters
+}
+
+// buildEnvoyXDSConfigMapForGateway builds a desired ConfigMap with the xDS files for a given gateway and corresponding wasm config
+func buildEnvoyXDSConfigMapForGateway(gateway *machinery.Gateway, wasmConfig wasm.Config, clusters []map[string]any, wasmURL, imageSHA string) (*corev1.ConfigMap, error) {
+	configMap := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       ConfigMapGroupKind.Kind,
+			APIVersion: corev1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      EnvoyXDSConfigMapName(gateway.GetName()),
+			Namespace: gateway.GetNamespace(),
+			Labels:    EnvoyXDSFileObjectLabels(),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         gateway.GroupVersionKind().GroupVersion().String(),
+					Kind:               gateway.GroupVersionKind().Kind,
+					Name:               gateway.Name,
+					UID:                gateway.UID,
+					BlockOwnerDeletion: ptr.To(true),
+					Controller:         ptr.To(true),
+				},
+			},
+		},
+	}
+
+	if len(wasmConfig.ActionSets) == 0 {
+		utils.TagObjectToDelete(configMap)
+		return configMap, nil
+	}
+
+	pluginConfigStruct, err := wasmConfig.ToStruct()
+	if err != nil {
+		return nil, err
+	}
+	wasmFilterConfig, err := kuadrantenvoy.BuildWasmFilterConfig(wasmURL, "", imageSHA, WasmServerClusterName, pluginConfigStruct)
+	if err != nil {
+		return nil, err
+	}
+
+	cds, err := kuadrantenvoy.BuildDiscoveryResponse(kuadrantenvoy.ClusterTypeURL, clusters)
+	if err != nil {
+		return nil, err
+	}
+	ecds, err := kuadrantenvoy.BuildDiscoveryResponse(kuadrantenvoy.TypedExtensionConfigTypeURL, []map[string]any{
+		kuadrantenvoy.BuildWasmExtensionConfig(wasm.ExtensionName(gateway.GetName()), wasmFilterConfig),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	configMap.Data = map[string]string{
+		EnvoyXDSClustersFileName:         string(cds),
+		EnvoyXDSExtensionConfigsFileName: string(ecds),
+	}
+
+	return configMap, nil
+}
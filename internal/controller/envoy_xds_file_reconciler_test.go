@@ -0,0 +1,88 @@
+//go:build unit
+
+package controllers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kuadrant/policy-machinery/machinery"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	kuadrantenvoy "github.com/kuadrant/kuadrant-operator/internal/envoy"
+	"github.com/kuadrant/kuadrant-operator/internal/kuadrant"
+	"github.com/kuadrant/kuadrant-operator/internal/utils"
+	"github.com/kuadrant/kuadrant-operator/internal/wasm"
+)
+
+func TestBuildEnvoyXDSConfigMapForGateway(t *testing.T) {
+	gateway := &machinery.Gateway{
+		Gateway: &gatewayapiv1.Gateway{
+			TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.GroupVersion.String(), Kind: "Gateway"},
+			ObjectMeta: metav1.ObjectMeta{Name: "my-gateway", Namespace: "my-ns", UID: "gw-uid"},
+		},
+	}
+	clusters := []map[string]any{
+		buildClusterPatch(WasmServerClusterName, "kuadrant-operator-wasm.kuadrant-system.svc.cluster.local", 8082, false),
+		rateLimitClusterPatch("limitador-limitador.kuadrant-system.svc.cluster.local", 8081, false),
+	}
+
+	t.Run("no action sets", func(t *testing.T) {
+		configMap, err := buildEnvoyXDSConfigMapForGateway(gateway, wasm.Config{}, clusters, "http://wasm/plugin.wasm", "sha")
+		assert.NilError(t, err)
+		assert.Equal(t, configMap.GetName(), "kuadrant-xds-my-gateway")
+		assert.Equal(t, configMap.GetNamespace(), "my-ns")
+		assert.Assert(t, utils.IsObjectTaggedToDelete(configMap))
+	})
+
+	t.Run("with action sets", func(t *testing.T) {
+		wasmConfig := wasm.Config{
+			Services: map[string]wasm.Service{
+				wasm.RateLimitServiceName: {Type: wasm.RateLimitServiceType, Endpoint: kuadrant.KuadrantRateLimitClusterName, FailureMode: wasm.FailureModeAllow},
+			},
+			ActionSets: []wasm.ActionSet{
+				{
+					Name:                "some-action-set",
+					RouteRuleConditions: wasm.RouteRuleConditions{Hostnames: []string{"*.example.com"}},
+					Actions:             []wasm.Action{{ServiceName: wasm.RateLimitServiceName, Scope: "my-ns/my-route"}},
+				},
+			},
+		}
+
+		configMap, err := buildEnvoyXDSConfigMapForGateway(gateway, wasmConfig, clusters, "http://wasm/plugin.wasm", "sha")
+		assert.NilError(t, err)
+		assert.Assert(t, !utils.IsObjectTaggedToDelete(configMap))
+		assert.Equal(t, configMap.GetLabels()[envoyXDSFileObjectLabelKey], "true")
+		assert.Equal(t, configMap.GetOwnerReferences()[0].UID, gateway.GetUID())
+
+		var cds map[string]any
+		assert.NilError(t, json.Unmarshal([]byte(configMap.Data[EnvoyXDSClustersFileName]), &cds))
+		assert.Equal(t, cds["type_url"], kuadrantenvoy.ClusterTypeURL)
+		assert.Assert(t, cds["version_info"] != "")
+		resources := cds["resources"].([]any)
+		assert.Equal(t, len(resources), 2)
+		assert.Equal(t, resources[0].(map[string]any)["@type"], kuadrantenvoy.ClusterTypeURL)
+		assert.Equal(t, resources[0].(map[string]any)["name"], WasmServerClusterName)
+		assert.Equal(t, resources[1].(map[string]any)["name"], kuadrant.KuadrantRateLimitClusterName)
+
+		var ecds map[string]any
+		assert.NilError(t, json.Unmarshal([]byte(configMap.Data[EnvoyXDSExtensionConfigsFileName]), &ecds))
+		assert.Equal(t, ecds["type_url"], kuadrantenvoy.TypedExtensionConfigTypeURL)
+		resources = ecds["resources"].([]any)
+		assert.Equal(t, len(resources), 1)
+		extensionConfig := resources[0].(map[string]any)
+		assert.Equal(t, extensionConfig["name"], wasm.ExtensionName("my-gateway"))
+		assert.Equal(t, extensionConfig["typed_config"].(map[string]any)["@type"], kuadrantenvoy.WasmTypeURL)
+
+		// the version of the files only changes with their content
+		sameConfigMap, err := buildEnvoyXDSConfigMapForGateway(gateway, wasmConfig, clusters, "http://wasm/plugin.wasm", "sha")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, sameConfigMap.Data, configMap.Data)
+		otherConfigMap, err := buildEnvoyXDSConfigMapForGateway(gateway, wasmConfig, clusters[:1], "http://wasm/plugin.wasm", "sha")
+		assert.NilError(t, err)
+		assert.Assert(t, otherConfigMap.Data[EnvoyXDSClustersFileName] != configMap.Data[EnvoyXDSClustersFileName])
+		assert.Equal(t, otherConfigMap.Data[EnvoyXDSExtensionConfigsFileName], configMap.Data[EnvoyXDSExtensionConfigsFileName])
+	})
+}
@@ -62,23 +62,26 @@ func (e *ExamplePolicy) DeepCopyObject() runtime.Object {
 }
 
 type ExamplePolicySpec struct {
-	TargetRef gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRef"`
+	TargetRefs []gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName `json:"targetRefs"`
 }
 
 func (e *ExamplePolicy) GetTargetRefs() []gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName {
-	return []gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName{
-		e.Spec.TargetRef,
-	}
+	return e.Spec.TargetRefs
 }
 
 func newExamplePolicy(authPolicy *kuadrantv1.AuthPolicy) *ExamplePolicy {
+	// the policy targets either the list of targetRefs or the single targetRef of the AuthPolicy
+	targetRefs := authPolicy.Spec.TargetRefs
+	if len(targetRefs) == 0 {
+		targetRefs = []gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName{authPolicy.Spec.TargetRef}
+	}
 	return &ExamplePolicy{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      authPolicy.Name,
 			Namespace: authPolicy.Namespace,
 		},
 		Spec: ExamplePolicySpec{
-			TargetRef: authPolicy.Spec.TargetRef,
+			TargetRefs: targetRefs,
 		},
 	}
 }
@@ -18,6 +18,7 @@ import (
 
 	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
 	kuadrantv1alpha1 "github.com/kuadrant/kuadrant-operator/api/v1alpha1"
+	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
 )
 
 type GatewayPolicyDiscoverabilityReconciler struct {
@@ -37,6 +38,7 @@ func (r *GatewayPolicyDiscoverabilityReconciler) Subscription() *controller.Subs
 			{Kind: &kuadrantv1alpha1.TokenRateLimitPolicyGroupKind},
 			{Kind: &kuadrantv1.TLSPolicyGroupKind},
 			{Kind: &kuadrantv1.DNSPolicyGroupKind},
+			{Kind: &kuadrantv1beta1.KuadrantGroupKind}, // the wasm modules changed
 		},
 		ReconcileFunc: r.reconcile,
 	}
@@ -94,9 +96,25 @@ func buildGatewayStatus(ctx context.Context, syncMap *sync.Map, gw *machinery.Ga
 		updatePolicyConditions(ctx, syncMap, gw, policyKind, status, logger)
 	}
 
+	updateWasmModuleCondition(gw, topology, status, logger)
+
 	return status
 }
 
+func updateWasmModuleCondition(gw *machinery.Gateway, topology *machinery.Topology, status *gatewayapiv1.GatewayStatus, logger logr.Logger) {
+	gatewayClass, found := lo.Find(topology.Targetables().Parents(gw), func(parent machinery.Targetable) bool {
+		_, ok := parent.(*machinery.GatewayClass)
+		return ok
+	})
+	if found && gatewayUsesWasmServer(gw, gatewayClass.(*machinery.GatewayClass)) {
+		if condition := wasmModuleResolvedCondition(gw); condition != nil {
+			addOrUpdateCondition(&status.Conditions, *condition, gw.GetGeneration(), logger)
+			return
+		}
+	}
+	removeConditionIfExists(&status.Conditions, WasmModuleResolvedConditionType, logger, gw.GetName())
+}
+
 func updateListenerStatus(ctx context.Context, syncMap *sync.Map, gw *machinery.Gateway, listener *machinery.Listener, logger logr.Logger, policyKinds []*schema.GroupKind) gatewayapiv1.ListenerStatus {
 	status, _, exists := findListenerStatus(gw.Status.Listeners, listener.Name)
 	if !exists {
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	ctrlruntime "sigs.k8s.io/controller-runtime"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// GatewayProvider integrates Kuadrant with an Envoy-based implementation of the Gateway API.
+// A provider delivers the auth, rate limit and tracing clusters and the wasm configuration to the gateways whose
+// gateway class is managed by one of its gateway controllers, and tells which of its resources are still to be
+// synced when reporting the status of the policies.
+type GatewayProvider interface {
+	// Name identifies the provider in logs and metrics
+	Name() string
+	// IsInstalled tells whether the provider can be enabled in the cluster at boot time
+	IsInstalled(restMapper meta.RESTMapper) (bool, error)
+	// GatewayControllerNames returns the names of the gateway controllers whose gateways are handled by the provider
+	GatewayControllerNames() []gatewayapiv1.GatewayController
+	// ControllerOptions returns the watchers, kinds of objects and links required by the provider
+	ControllerOptions(logger logr.Logger) []controller.ControllerOption
+	// Reconcilers returns the tasks of the provider to run within the data plane policies workflow
+	Reconcilers(opts GatewayProviderOptions) []controller.ReconcileFunc
+	// ComponentsToSync returns the resources of the provider that are not yet in sync for a given gateway and kind of policy
+	ComponentsToSync(policyKind schema.GroupKind, gateway *machinery.Gateway, gatewayClass *machinery.GatewayClass, topology *machinery.Topology, state *sync.Map) []string
+}
+
+// GatewayProviderOptions are the dependencies and boot time configurations available to the reconcilers of the gateway providers
+type GatewayProviderOptions struct {
+	Manager                      ctrlruntime.Manager
+	Client                       *dynamic.DynamicClient
+	IsLimitadorOperatorInstalled bool
+	IsAuthorinoOperatorInstalled bool
+}
+
+// DefaultGatewayProviders returns the gateway providers registered by default in the BootOptionsBuilder
+func DefaultGatewayProviders() []GatewayProvider {
+	return []GatewayProvider{
+		&istioGatewayProvider{},
+		&envoyGatewayGatewayProvider{},
+		&envoyXDSFileGatewayProvider{},
+	}
+}
+
+// gatewayProviderFor returns the gateway provider that handles the gateways of a given gateway controller
+func gatewayProviderFor(providers []GatewayProvider, controllerName gatewayapiv1.GatewayController) (GatewayProvider, bool) {
+	return lo.Find(providers, func(provider GatewayProvider) bool {
+		return lo.Contains(provider.GatewayControllerNames(), controllerName)
+	})
+}
@@ -7,12 +7,9 @@ import (
 	"sync"
 
 	"github.com/go-logr/logr"
-	"github.com/google/cel-go/cel"
 	"github.com/kuadrant/policy-machinery/controller"
 	"github.com/kuadrant/policy-machinery/machinery"
 	"github.com/samber/lo"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/codes"
 	istioapinetworkingv1alpha3 "istio.io/api/networking/v1alpha3"
 	istiov1beta1 "istio.io/api/type/v1beta1"
 	istioclientgonetworkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
@@ -26,11 +23,8 @@ import (
 	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
 	kuadrantv1alpha1 "github.com/kuadrant/kuadrant-operator/api/v1alpha1"
 	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
-	celvalidator "github.com/kuadrant/kuadrant-operator/internal/cel"
 	"github.com/kuadrant/kuadrant-operator/internal/extension"
-	kuadrantgatewayapi "github.com/kuadrant/kuadrant-operator/internal/gatewayapi"
 	kuadrantistio "github.com/kuadrant/kuadrant-operator/internal/istio"
-	kuadrantpolicymachinery "github.com/kuadrant/kuadrant-operator/internal/policymachinery"
 	"github.com/kuadrant/kuadrant-operator/internal/utils"
 	"github.com/kuadrant/kuadrant-operator/internal/wasm"
 )
@@ -52,6 +46,7 @@ func (r *IstioExtensionReconciler) Subscription() controller.Subscription {
 			{Kind: &machinery.GatewayGroupKind},
 			{Kind: &machinery.HTTPRouteGroupKind},
 			{Kind: &machinery.GRPCRouteGroupKind},
+			{Kind: &NamespaceGroupKind},
 			{Kind: &kuadrantv1.RateLimitPolicyGroupKind},
 			{Kind: &kuadrantv1alpha1.TokenRateLimitPolicyGroupKind},
 			{Kind: &kuadrantv1.AuthPolicyGroupKind},
@@ -69,9 +64,8 @@ func (r *IstioExtensionReconciler) Reconcile(ctx context.Context, _ []controller
 	if portErr != nil {
 		wasmServerPort = defaultWasmServerPort
 	}
-	wasmURL := fmt.Sprintf("http://%s:%d/plugin.wasm", wasmServerHost, wasmServerPort)
 
-	logger.V(1).Info("building istio extension", "wasm url", wasmURL)
+	logger.V(1).Info("building istio extension", "wasm modules", wasmModules.Versions())
 	defer logger.V(1).Info("finished building istio extension")
 
 	// reconcile for each gateway based on the desired wasm plugin policies calculated before
@@ -85,7 +79,7 @@ func (r *IstioExtensionReconciler) Reconcile(ctx context.Context, _ []controller
 	r.reconcileUpstreamClusters(ctx, topology, gateways)
 
 	// build wasm plugin configs for each gateway
-	wasmConfigs, err := r.buildWasmConfigs(ctx, topology, state)
+	wasmConfigs, err := buildWasmConfigs(ctx, topology, state, istioGatewayControllerNames)
 	if err != nil {
 		if errors.Is(err, ErrMissingStateEffectiveAuthPolicies) || errors.Is(err, ErrMissingStateEffectiveRateLimitPolicies) {
 			logger.V(1).Info(err.Error())
@@ -100,12 +94,22 @@ func (r *IstioExtensionReconciler) Reconcile(ctx context.Context, _ []controller
 		gatewayKey := k8stypes.NamespacedName{Name: gateway.GetName(), Namespace: gateway.GetNamespace()}
 
 		// Get the wasm config for this gateway and apply mutators
-		wasmConfig := wasmConfigs[gateway.GetLocator()]
+		wasmConfig, admitted := wasmConfigRolloutFromState(state).ConfigForGateway(gateway.GetLocator(), wasmConfigs[gateway.GetLocator()])
+		if !admitted {
+			logger.V(1).Info("gateway keeps its current wasm configuration until the rollout of the change is promoted", "gateway", gatewayKey.String())
+			continue
+		}
 		if err := extension.ApplyWasmConfigMutators(&wasmConfig, gateway, topology); err != nil {
 			logger.Error(err, "failed to apply wasm config mutators", "gateway", gatewayKey.String())
 		}
 
-		desiredEnvoyFilter := buildIstioEnvoyFilterForGateway(gateway, wasmConfig, wasmURL, wasmServerHost, wasmServerPort, WasmFileSHA256)
+		wasmURL, wasmSHA, err := wasmModuleForGateway(gateway, wasmServerHost, wasmServerPort)
+		if err != nil {
+			// the gateway keeps its current wasm module, the unresolvable version is reported in the status of the gateway
+			logger.Error(err, "failed to resolve the wasm module pinned to the gateway", "gateway", gatewayKey.String())
+			continue
+		}
+		desiredEnvoyFilter := buildIstioEnvoyFilterForGateway(gateway, wasmConfig, wasmURL, wasmServerHost, wasmServerPort, wasmSHA)
 
 		resource := r.client.Resource(kuadrantistio.EnvoyFiltersResource).Namespace(desiredEnvoyFilter.GetNamespace())
 
@@ -334,218 +338,6 @@ func buildUpstreamEnvoyFilter(logger logr.Logger, gateway *machinery.Gateway, up
 	return envoyFilter, nil
 }
 
-// buildWasmConfigs returns a map of istio gateway locators to an ordered list of corresponding wasm policies
-func (r *IstioExtensionReconciler) buildWasmConfigs(ctx context.Context, topology *machinery.Topology, state *sync.Map) (map[string]wasm.Config, error) {
-	logger := controller.LoggerFromContext(ctx).WithName("IstioExtensionReconciler").WithName("buildWasmConfigs").WithValues("context", ctx)
-	logger.Info("build Wasm configuration", "status", "started")
-	logger.Info("build Wasm configuration", "status", "completed")
-
-	serviceBuilder := wasm.NewServiceBuilder(&logger)
-	// Get Kuadrant CR to access observability settings
-	kObj := GetKuadrantFromTopology(topology, state)
-	var observability *wasm.Observability
-	if kObj != nil {
-		observability = wasm.BuildObservabilityConfig(serviceBuilder, &kObj.Spec.Observability)
-	}
-
-	effectiveAuthPolicies, ok := state.Load(StateEffectiveAuthPolicies)
-	if !ok {
-		return nil, ErrMissingStateEffectiveAuthPolicies
-	}
-	effectiveAuthPoliciesMap := effectiveAuthPolicies.(EffectiveAuthPolicies)
-
-	var effectiveRateLimitPoliciesMap EffectiveRateLimitPolicies
-	if effectiveRateLimitPolicies, ok := state.Load(StateEffectiveRateLimitPolicies); ok {
-		effectiveRateLimitPoliciesMap = effectiveRateLimitPolicies.(EffectiveRateLimitPolicies)
-	} else {
-		logger.V(1).Info("no effective rate limit policies found in state, continuing with empty map")
-	}
-
-	var effectiveTokenRateLimitPoliciesMap EffectiveTokenRateLimitPolicies
-	if effectiveTokenRateLimitPolicies, ok := state.Load(StateEffectiveTokenRateLimitPolicies); ok {
-		effectiveTokenRateLimitPoliciesMap = effectiveTokenRateLimitPolicies.(EffectiveTokenRateLimitPolicies)
-	} else {
-		logger.V(1).Info("no effective token rate limit policies found in state, continuing with empty map")
-	}
-
-	logger.V(1).Info("building wasm configs for istio extension", "effectiveAuthPolicies", len(effectiveAuthPoliciesMap), "effectiveRateLimitPolicies", len(effectiveRateLimitPoliciesMap), "effectiveTokenRateLimitPolicies", len(effectiveTokenRateLimitPoliciesMap))
-
-	// unique paths from different policy types
-	var allPaths []lo.Entry[string, []machinery.Targetable]
-
-	// paths from auth ratelimit and tokenratelimit policies
-	authPaths := lo.Entries(lo.MapValues(effectiveAuthPoliciesMap, func(p EffectiveAuthPolicy, _ string) []machinery.Targetable { return p.Path }))
-	allPaths = append(allPaths, authPaths...)
-	rateLimitPaths := lo.Entries(lo.MapValues(effectiveRateLimitPoliciesMap, func(p EffectiveRateLimitPolicy, _ string) []machinery.Targetable { return p.Path }))
-	allPaths = append(allPaths, rateLimitPaths...)
-	tokenRateLimitPaths := lo.Entries(lo.MapValues(effectiveTokenRateLimitPoliciesMap, func(p EffectiveTokenRateLimitPolicy, _ string) []machinery.Targetable { return p.Path }))
-	allPaths = append(allPaths, tokenRateLimitPaths...)
-
-	// unique paths by key
-	paths := lo.UniqBy(allPaths, func(e lo.Entry[string, []machinery.Targetable]) string { return e.Key })
-
-	logger.V(1).Info("processing paths for wasm config", "totalPaths", len(paths))
-
-	authPathIDs := lo.Keys(effectiveAuthPoliciesMap)
-	logger.V(1).Info("effective auth policy pathIDs", "count", len(authPathIDs), "pathIDs", authPathIDs)
-
-	wasmActionSets := kuadrantgatewayapi.GrouppedHTTPRouteMatchConfigs{}
-	celValidationIssues := celvalidator.NewIssueCollection()
-
-	tracer := controller.TracerFromContext(ctx)
-
-	// build the wasm policies for each topological path that contains an effective rate limit policy affecting an istio gateway
-	for i := range paths {
-		pathID := paths[i].Key
-		path := paths[i].Value
-
-		logger.V(1).Info("processing path", "pathID", pathID, "pathLength", len(path))
-
-		parsed, pathErr := kuadrantpolicymachinery.ParseTopologyPath(path)
-		if pathErr != nil {
-			logger.V(1).Info("skipping path - failed to parse", "pathID", pathID, "error", pathErr)
-			continue
-		}
-
-		// ignore if not an istio gateway
-		if !lo.Contains(istioGatewayControllerNames, parsed.GatewayClass.Spec.ControllerName) {
-			continue
-		}
-
-		// Create a parent span for this entire path processing
-		pathCtx, pathSpan := tracer.Start(ctx, "wasm.BuildConfigForPath")
-		pathSpan.SetAttributes(
-			attribute.String("path_id", pathID),
-			attribute.String("route_type", parsed.RouteType.String()),
-			attribute.String("gateway.name", parsed.Gateway.GetName()),
-			attribute.String("gateway.namespace", parsed.Gateway.GetNamespace()),
-			attribute.String("listener.name", string(parsed.Listener.Name)),
-			attribute.String("route.name", parsed.GetRouteName()),
-			attribute.String("route.namespace", parsed.GetRouteNamespace()),
-		)
-
-		validatorBuilder := celvalidator.NewRootValidatorBuilder()
-
-		var actions []wasm.Action
-
-		// auth
-		if effectivePolicy, ok := effectiveAuthPoliciesMap[pathID]; ok {
-			actions = append(actions, buildWasmActionsForAuth(pathID, effectivePolicy)...)
-			validatorBuilder.PushPolicyBinding(celvalidator.AuthPolicyKind, celvalidator.AuthPolicyName, cel.AnyType)
-		}
-
-		// rate limit
-		if effectivePolicy, ok := effectiveRateLimitPoliciesMap[pathID]; ok {
-			rlAction := buildWasmActionsForRateLimit(effectivePolicy, isRateLimitPolicyAcceptedAndNotDeletedFunc(state))
-			if hasAuthAccess(rlAction) {
-				actions = append(actions, rlAction...)
-			} else {
-				// pre auth rate limiting
-				actions = append(rlAction, actions...)
-			}
-			validatorBuilder.PushPolicyBinding(celvalidator.RateLimitPolicyKind, celvalidator.RateLimitName, cel.AnyType)
-		}
-
-		if effectivePolicy, ok := effectiveTokenRateLimitPoliciesMap[pathID]; ok {
-			trlAction := buildWasmActionsForTokenRateLimit(effectivePolicy, isTokenRateLimitPolicyAcceptedAndNotDeletedFunc(state))
-			if hasAuthAccess(trlAction) {
-				actions = append(actions, trlAction...)
-			} else {
-				// pre auth rate limiting
-				actions = append(trlAction, actions...)
-			}
-			validatorBuilder.PushPolicyBinding(celvalidator.TokenRateLimitPolicyKind, celvalidator.RateLimitName, cel.AnyType)
-		}
-
-		pathSpan.SetAttributes(attribute.Int("actions.before_merge", len(actions)))
-
-		// Extract and track source policies before merging
-		sourcePolicies := lo.Uniq(lo.FlatMap(actions, func(a wasm.Action, _ int) []string {
-			return a.SourcePolicyLocators
-		}))
-		if len(sourcePolicies) > 0 {
-			pathSpan.SetAttributes(attribute.StringSlice("source_policies", sourcePolicies))
-		}
-
-		actions, err := mergeAndVerify(pathCtx, actions)
-		if err != nil {
-			pathSpan.RecordError(err)
-			pathSpan.SetStatus(codes.Error, "failed to merge/verify actions")
-			pathSpan.End()
-			return nil, fmt.Errorf("failed to merge/verify actions for path %s: %w", pathID, err)
-		}
-
-		if len(actions) == 0 {
-			pathSpan.SetStatus(codes.Ok, "no actions after merge")
-			pathSpan.End()
-			continue
-		}
-
-		validator, err := validatorBuilder.Build()
-		if err != nil {
-			pathSpan.RecordError(err)
-			pathSpan.SetStatus(codes.Error, "failed to build validator")
-			pathSpan.End()
-			return nil, fmt.Errorf("failed to build validator for path %s: %w", pathID, err)
-		}
-		var validatedActions []wasm.Action
-
-		for _, action := range actions {
-			if err := celvalidator.ValidateWasmAction(action, validator); err != nil {
-				logger.V(1).Info("WASM action is invalid", "action", action, "path", pathID, "error", err)
-				celValidationIssues.Add(celvalidator.NewIssue(action, pathID, err))
-			} else {
-				validatedActions = append(validatedActions, action)
-			}
-		}
-
-		pathSpan.SetAttributes(
-			attribute.Int("actions.after_merge", len(actions)),
-			attribute.Int("actions.validated", len(validatedActions)),
-			attribute.Int("actions.invalid", len(actions)-len(validatedActions)),
-		)
-
-		if len(validatedActions) == 0 {
-			pathSpan.SetStatus(codes.Ok, "no validated actions")
-			pathSpan.End()
-			continue
-		}
-
-		wasmActionSetsForPath, err := wasm.BuildActionSetsForPath(pathCtx, pathID, path, validatedActions)
-		if err != nil {
-			if errors.As(err, &kuadrantpolicymachinery.ErrInvalidPath{}) {
-				logger.V(1).Info("ingoring invalid paths", "error", err.Error(), "status", "skipping", "pathID", pathID)
-				pathSpan.SetStatus(codes.Ok, "invalid path - skipped")
-				pathSpan.End()
-				continue
-			}
-			logger.Error(err, "failed to build wasm policies for path", "pathID", pathID, "status", "error")
-			pathSpan.RecordError(err)
-			pathSpan.SetStatus(codes.Error, "failed to build action sets")
-			pathSpan.End()
-			continue
-		}
-
-		pathSpan.SetAttributes(attribute.Int("actionsets.created", len(wasmActionSetsForPath)))
-		pathSpan.SetStatus(codes.Ok, "")
-		pathSpan.End()
-
-		wasmActionSets.Add(parsed.Gateway.GetLocator(), wasmActionSetsForPath...)
-	}
-
-	if !celValidationIssues.IsEmpty() {
-		state.Store(celvalidator.StateCELValidationErrors, celValidationIssues)
-	}
-
-	wasmConfigs := lo.MapValues(wasmActionSets.Sorted(), func(configs kuadrantgatewayapi.SortableHTTPRouteMatchConfigs, _ string) wasm.Config {
-		return wasm.BuildConfigForActionSet(lo.Map(configs, func(c kuadrantgatewayapi.HTTPRouteMatchConfig, _ int) wasm.ActionSet {
-			return c.Config.(wasm.ActionSet)
-		}), &logger, observability, serviceBuilder)
-	})
-
-	return wasmConfigs, nil
-}
-
 func hasAuthAccess(actionSet []wasm.Action) bool {
 	for _, action := range actionSet {
 		if action.HasAuthAccess() {
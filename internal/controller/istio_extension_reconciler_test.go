@@ -575,6 +575,26 @@ func TestMergeAndVerifyEdgeCases(t *testing.T) {
 		assert.Equal(t, len(result), 1)
 	})
 
+	t.Run("report only and enforced actions do not merge", func(t *testing.T) {
+		actions := []wasm.Action{
+			{
+				ServiceName:     wasm.RateLimitServiceName,
+				Scope:           "global",
+				ConditionalData: []wasm.ConditionalData{},
+			},
+			{
+				ServiceName:     wasm.RateLimitServiceName,
+				Scope:           "global",
+				ConditionalData: []wasm.ConditionalData{},
+				ReportOnly:      true,
+			},
+		}
+
+		result, err := mergeAndVerify(context.TODO(), actions)
+		assert.NilError(t, err)
+		assert.Equal(t, len(result), 2)
+	})
+
 	t.Run("empty data in conditional data", func(t *testing.T) {
 		actions := []wasm.Action{
 			{
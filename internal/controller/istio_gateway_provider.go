@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	istioclientnetworkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istiosecurity "istio.io/client-go/pkg/apis/security/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
+	"github.com/kuadrant/kuadrant-operator/internal/istio"
+	"github.com/kuadrant/kuadrant-operator/internal/wasm"
+)
+
+// istioGatewayProvider delivers the Kuadrant configuration to Istio gateways via EnvoyFilter custom resources
+type istioGatewayProvider struct{}
+
+var _ GatewayProvider = &istioGatewayProvider{}
+
+func (p *istioGatewayProvider) Name() string {
+	return "istio"
+}
+
+func (p *istioGatewayProvider) IsInstalled(restMapper meta.RESTMapper) (bool, error) {
+	return istio.IsIstioInstalled(restMapper)
+}
+
+func (p *istioGatewayProvider) GatewayControllerNames() []gatewayapiv1.GatewayController {
+	return istioGatewayControllerNames
+}
+
+func (p *istioGatewayProvider) ControllerOptions(logger logr.Logger) []controller.ControllerOption {
+	return []controller.ControllerOption{
+		controller.WithRunnable("envoyfilter watcher", controller.Watch(
+			&istioclientnetworkingv1alpha3.EnvoyFilter{},
+			istio.EnvoyFiltersResource,
+			metav1.NamespaceAll,
+			controller.FilterResourcesByLabel[*istioclientnetworkingv1alpha3.EnvoyFilter](fmt.Sprintf("%s=true", kuadrantManagedLabelKey)),
+		)),
+		controller.WithRunnable("peerauthentication watcher", controller.Watch(
+			&istiosecurity.PeerAuthentication{},
+			istio.PeerAuthenticationResource,
+			metav1.NamespaceAll,
+			controller.FilterResourcesByLabel[*istiosecurity.PeerAuthentication](fmt.Sprintf("%s=true", kuadrantManagedLabelKey)),
+		)),
+		controller.WithObjectKinds(
+			istio.EnvoyFilterGroupKind,
+			istio.PeerAuthenticationGroupKind,
+		),
+		controller.WithObjectLinks(
+			istio.LinkGatewayToEnvoyFilter,
+			istio.LinkKuadrantToPeerAuthentication,
+		),
+		controller.WithRunnable("wasm server", wasmServerRunnable(logger)),
+	}
+}
+
+func (p *istioGatewayProvider) Reconcilers(opts GatewayProviderOptions) []controller.ReconcileFunc {
+	reconcilers := []controller.ReconcileFunc{
+		traceReconcileFunc("reconciler.istio_auth_cluster", (&IstioAuthClusterReconciler{client: opts.Client}).Subscription().Reconcile),
+		traceReconcileFunc("reconciler.istio_ratelimit_cluster", (&IstioRateLimitClusterReconciler{client: opts.Client}).Subscription().Reconcile),
+		traceReconcileFunc("reconciler.istio_tracing_cluster", (&IstioTracingClusterReconciler{client: opts.Client}).Subscription().Reconcile),
+		traceReconcileFunc("reconciler.istio_extension", (&IstioExtensionReconciler{client: opts.Client}).Subscription().Reconcile),
+		traceReconcileFunc("reconciler.istio_native_filters", (&IstioNativeFiltersReconciler{client: opts.Client}).Subscription().Reconcile),
+	}
+
+	if opts.IsAuthorinoOperatorInstalled && opts.IsLimitadorOperatorInstalled {
+		reconcilers = append(reconcilers,
+			traceReconcileFunc("reconciler.peer_authentication", NewPeerAuthenticationReconciler(opts.Manager, opts.Client).Subscription().Reconcile),
+			traceReconcileFunc("reconciler.limitador_istio_integration", NewLimitadorIstioIntegrationReconciler(opts.Manager, opts.Client).Subscription().Reconcile),
+			traceReconcileFunc("reconciler.authorino_istio_integration", NewAuthorinoIstioIntegrationReconciler(opts.Manager, opts.Client).Subscription().Reconcile),
+		)
+	}
+
+	return reconcilers
+}
+
+func (p *istioGatewayProvider) ComponentsToSync(policyKind schema.GroupKind, gateway *machinery.Gateway, _ *machinery.GatewayClass, topology *machinery.Topology, state *sync.Map) []string {
+	var componentsToSync []string
+
+	// Istio won't ever populate the status stanza of EnvoyFilter resources, so we cannot expect to find a given a condition there
+	envoyFilterCondition := func(_ machinery.Object) bool { return true }
+
+	if policyKind == kuadrantv1.AuthPolicyGroupKind {
+		// EnvoyFilter (auth clusters)
+		istioAuthClustersModifiedGateways, _ := state.Load(StateIstioAuthClustersModified)
+		componentsToSync = append(componentsToSync, gatewayComponentsToSyncWithName(gateway, istio.EnvoyFilterGroupKind, AuthClusterName(gateway.GetName()), istioAuthClustersModifiedGateways, topology, envoyFilterCondition)...)
+	} else {
+		// EnvoyFilter (rate limit clusters)
+		istioRateLimitClustersModifiedGateways, _ := state.Load(StateIstioRateLimitClustersModified)
+		componentsToSync = append(componentsToSync, gatewayComponentsToSyncWithName(gateway, istio.EnvoyFilterGroupKind, RateLimitClusterName(gateway.GetName()), istioRateLimitClustersModifiedGateways, topology, envoyFilterCondition)...)
+	}
+
+	if isNativeDataPlaneGateway(gateway) {
+		// EnvoyFilter (native filters)
+		istioNativeFiltersModifiedGateways, _ := state.Load(StateIstioNativeFiltersModified)
+		componentsToSync = append(componentsToSync, gatewayComponentsToSyncWithName(gateway, istio.EnvoyFilterGroupKind, NativeFiltersName(gateway.GetName()), istioNativeFiltersModifiedGateways, topology, envoyFilterCondition)...)
+	} else {
+		// EnvoyFilter (wasm plugin)
+		istioExtensionsModifiedGateways, _ := state.Load(StateIstioExtensionsModified)
+		componentsToSync = append(componentsToSync, gatewayComponentsToSyncWithName(gateway, istio.EnvoyFilterGroupKind, wasm.ExtensionName(gateway.GetName()), istioExtensionsModifiedGateways, topology, envoyFilterCondition)...)
+		componentsToSync = append(componentsToSync, wasmModuleComponentsToSync(gateway)...)
+	}
+
+	return componentsToSync
+}
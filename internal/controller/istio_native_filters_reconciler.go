@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	istioapinetworkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	istiov1beta1 "istio.io/api/type/v1beta1"
+	istioclientgonetworkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/utils/ptr"
+
+	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
+	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
+	kuadrantistio "github.com/kuadrant/kuadrant-operator/internal/istio"
+	"github.com/kuadrant/kuadrant-operator/internal/utils"
+)
+
+//+kubebuilder:rbac:groups=networking.istio.io,resources=envoyfilters,verbs=get;list;watch;create;update;patch;delete
+
+// IstioNativeFiltersReconciler reconciles Istio EnvoyFilter custom resources that configure the native ext_authz and
+// ratelimit filters of the gateways in native data plane mode
+type IstioNativeFiltersReconciler struct {
+	client *dynamic.DynamicClient
+}
+
+// IstioNativeFiltersReconciler subscribes to events with potential impact on the native filters of the gateways
+func (r *IstioNativeFiltersReconciler) Subscription() controller.Subscription {
+	return controller.Subscription{
+		ReconcileFunc: r.Reconcile,
+		Events: []controller.ResourceEventMatcher{
+			{Kind: &kuadrantv1beta1.KuadrantGroupKind},
+			{Kind: &machinery.GatewayClassGroupKind},
+			{Kind: &machinery.GatewayGroupKind},
+			{Kind: &machinery.HTTPRouteGroupKind},
+			{Kind: &NamespaceGroupKind},
+			{Kind: &kuadrantv1.AuthPolicyGroupKind},
+			{Kind: &kuadrantv1.RateLimitPolicyGroupKind},
+			{Kind: &kuadrantistio.EnvoyFilterGroupKind},
+		},
+	}
+}
+
+func (r *IstioNativeFiltersReconciler) Reconcile(ctx context.Context, _ []controller.ResourceEvent, topology *machinery.Topology, _ error, state *sync.Map) error {
+	logger := controller.LoggerFromContext(ctx).WithName("IstioNativeFiltersReconciler").WithValues("context", ctx)
+
+	logger.V(1).Info("building istio native filters")
+	defer logger.V(1).Info("finished building istio native filters")
+
+	nativeRouteConfigs, err := buildNativeRouteConfigs(ctx, topology, state, istioGatewayControllerNames)
+	if err != nil {
+		if errors.Is(err, ErrMissingStateEffectiveAuthPolicies) {
+			logger.V(1).Info(err.Error())
+		} else {
+			return err
+		}
+	}
+
+	gateways := lo.Map(topology.Targetables().Items(func(o machinery.Object) bool {
+		return o.GroupVersionKind().GroupKind() == machinery.GatewayGroupKind
+	}), func(g machinery.Targetable, _ int) *machinery.Gateway {
+		return g.(*machinery.Gateway)
+	})
+
+	var modifiedGateways []string
+
+	for _, gateway := range gateways {
+		gatewayKey := k8stypes.NamespacedName{Name: gateway.GetName(), Namespace: gateway.GetNamespace()}
+
+		desiredEnvoyFilter, err := buildIstioNativeFiltersEnvoyFilter(logger, gateway, nativeRouteConfigs[gateway.GetLocator()])
+		if err != nil {
+			logger.Error(err, "failed to build envoyfilter object for native filters", "gateway", gatewayKey.String())
+			continue
+		}
+
+		resource := r.client.Resource(kuadrantistio.EnvoyFiltersResource).Namespace(desiredEnvoyFilter.GetNamespace())
+
+		existingEnvoyFilterObj, found := lo.Find(topology.Objects().Children(gateway), func(child machinery.Object) bool {
+			return child.GroupVersionKind().GroupKind() == kuadrantistio.EnvoyFilterGroupKind && child.GetName() == desiredEnvoyFilter.GetName() && child.GetNamespace() == desiredEnvoyFilter.GetNamespace() && labels.Set(child.(*controller.RuntimeObject).GetLabels()).AsSelector().Matches(labels.Set(desiredEnvoyFilter.GetLabels()))
+		})
+
+		// create
+		if !found {
+			if utils.IsObjectTaggedToDelete(desiredEnvoyFilter) {
+				continue
+			}
+			modifiedGateways = append(modifiedGateways, gateway.GetLocator()) // we only signal the gateway as modified when an envoyfilter is created, because updates won't change the status
+			desiredEnvoyFilterUnstructured, err := controller.Destruct(desiredEnvoyFilter)
+			if err != nil {
+				logger.Error(err, "failed to destruct envoyfilter object", "gateway", gatewayKey.String(), "envoyfilter", desiredEnvoyFilter)
+				continue
+			}
+			if _, err = resource.Create(ctx, desiredEnvoyFilterUnstructured, metav1.CreateOptions{}); err != nil {
+				logger.Error(err, "failed to create envoyfilter object", "gateway", gatewayKey.String(), "envoyfilter", desiredEnvoyFilterUnstructured.Object)
+				// TODO: handle error
+			}
+			continue
+		}
+
+		existingEnvoyFilter := existingEnvoyFilterObj.(*controller.RuntimeObject).Object.(*istioclientgonetworkingv1alpha3.EnvoyFilter)
+
+		// delete
+		if utils.IsObjectTaggedToDelete(desiredEnvoyFilter) && !utils.IsObjectTaggedToDelete(existingEnvoyFilter) {
+			if err := resource.Delete(ctx, existingEnvoyFilter.GetName(), metav1.DeleteOptions{}); err != nil {
+				logger.Error(err, "failed to delete envoyfilter object", "gateway", gatewayKey.String(), "envoyfilter", fmt.Sprintf("%s/%s", existingEnvoyFilter.GetNamespace(), existingEnvoyFilter.GetName()))
+				// TODO: handle error
+			}
+			continue
+		}
+
+		if kuadrantistio.EqualEnvoyFilters(existingEnvoyFilter, desiredEnvoyFilter) {
+			logger.V(1).Info("envoyfilter object is up to date, nothing to do")
+			continue
+		}
+
+		// update
+		existingEnvoyFilter.Spec.ConfigPatches = desiredEnvoyFilter.Spec.ConfigPatches
+		existingEnvoyFilter.Spec.Priority = desiredEnvoyFilter.Spec.Priority
+		existingEnvoyFilter.Spec.TargetRefs = desiredEnvoyFilter.Spec.TargetRefs
+
+		existingEnvoyFilterUnstructured, err := controller.Destruct(existingEnvoyFilter)
+		if err != nil {
+			logger.Error(err, "failed to destruct envoyfilter object", "gateway", gatewayKey.String(), "envoyfilter", existingEnvoyFilter)
+			continue
+		}
+		if _, err = resource.Update(ctx, existingEnvoyFilterUnstructured, metav1.UpdateOptions{}); err != nil {
+			logger.Error(err, "failed to update envoyfilter object", "gateway", gatewayKey.String(), "envoyfilter", existingEnvoyFilterUnstructured.Object)
+			// TODO: handle error
+		}
+	}
+
+	state.Store(StateIstioNativeFiltersModified, modifiedGateways)
+
+	return nil
+}
+
+// buildIstioNativeFiltersEnvoyFilter builds a desired EnvoyFilter custom resource that adds the native filters to a
+// gateway and enables them in the routes of the given route configs.
+//
+// Istio names the routes after the HTTPRoute rules (<namespace>.<route>.<index>) and the route configurations after
+// the ports of the listeners.
+func buildIstioNativeFiltersEnvoyFilter(logger logr.Logger, gateway *machinery.Gateway, configs []nativeRouteConfig) (*istioclientgonetworkingv1alpha3.EnvoyFilter, error) {
+	envoyFilter := &istioclientgonetworkingv1alpha3.EnvoyFilter{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       kuadrantistio.EnvoyFilterGroupKind.Kind,
+			APIVersion: istioclientgonetworkingv1alpha3.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      NativeFiltersName(gateway.GetName()),
+			Namespace: gateway.GetNamespace(),
+			Labels:    KuadrantManagedObjectLabels(),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         gateway.GroupVersionKind().GroupVersion().String(),
+					Kind:               gateway.GroupVersionKind().Kind,
+					Name:               gateway.Name,
+					UID:                gateway.UID,
+					BlockOwnerDeletion: ptr.To(true),
+					Controller:         ptr.To(true),
+				},
+			},
+		},
+		Spec: istioapinetworkingv1alpha3.EnvoyFilter{
+			TargetRefs: []*istiov1beta1.PolicyTargetReference{
+				{
+					Group: machinery.GatewayGroupKind.Group,
+					Kind:  machinery.GatewayGroupKind.Kind,
+					Name:  gateway.GetName(),
+				},
+			},
+		},
+	}
+
+	if len(configs) == 0 {
+		utils.TagObjectToDelete(envoyFilter)
+		return envoyFilter, nil
+	}
+
+	configPatches, err := kuadrantistio.BuildEnvoyFilterHTTPFiltersPatch(buildNativeFilters(logger, configs))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, config := range configs {
+		route := config.Route
+		routeName := fmt.Sprintf("%s.%s.%d", route.GetNamespace(), route.GetName(), config.RuleIndex)
+		patch, err := kuadrantistio.BuildEnvoyFilterRoutePatch(uint32(config.Listener.Port), routeName, config.PerFilterConfig()) // #nosec G115
+		if err != nil {
+			return nil, err
+		}
+		configPatches = append(configPatches, patch)
+	}
+
+	envoyFilter.Spec.ConfigPatches = configPatches
+
+	return envoyFilter, nil
+}
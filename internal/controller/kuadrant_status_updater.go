@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"slices"
+	"strings"
 	"sync"
 
 	"github.com/go-logr/logr"
 	limitadorv1alpha1 "github.com/kuadrant/limitador-operator/api/v1alpha1"
 	"github.com/kuadrant/policy-machinery/controller"
 	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -19,12 +21,17 @@ import (
 
 	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
 	"github.com/kuadrant/kuadrant-operator/internal/authorino"
+	"github.com/kuadrant/kuadrant-operator/internal/extension"
 	"github.com/kuadrant/kuadrant-operator/internal/kuadrant"
 	operatormetrics "github.com/kuadrant/kuadrant-operator/internal/metrics"
 )
 
 const (
 	ReadyConditionType string = "Ready"
+
+	// ExtensionsHealthyConditionType reports whether the out-of-process extensions are running. It is only set when
+	// extensions are found.
+	ExtensionsHealthyConditionType string = "ExtensionsHealthy"
 )
 
 type KuadrantStatusUpdater struct {
@@ -131,15 +138,58 @@ func (r *KuadrantStatusUpdater) calculateStatus(topology *machinery.Topology, lo
 		ObservedGeneration: kObj.Status.ObservedGeneration,
 		MtlsAuthorino:      mtlsAuthorino(kObj, state),
 		MtlsLimitador:      mtlsLimitador(kObj, state),
+		WasmConfigRollout:  wasmConfigRolloutStatus(kObj, state),
 	}
 
 	availableCond := r.readyCondition(topology, logger, state)
 
 	meta.SetStatusCondition(&newStatus.Conditions, *availableCond)
 
+	if extensionsCond := extensionsHealthyCondition(extension.Health()); extensionsCond != nil {
+		meta.SetStatusCondition(&newStatus.Conditions, *extensionsCond)
+	} else {
+		meta.RemoveStatusCondition(&newStatus.Conditions, ExtensionsHealthyConditionType)
+	}
+
 	return newStatus
 }
 
+func extensionsHealthyCondition(extensions []extension.ExtensionHealth) *metav1.Condition {
+	if len(extensions) == 0 {
+		return nil
+	}
+
+	cond := &metav1.Condition{
+		Type:    ExtensionsHealthyConditionType,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ExtensionsRunning",
+		Message: "All extensions are running",
+	}
+
+	unhealthy := lo.FilterMap(extensions, func(e extension.ExtensionHealth, _ int) (string, bool) {
+		return fmt.Sprintf("%s is %s after %d restarts (%s)", e.Name, e.State, e.Restarts, e.LastError), !e.Healthy()
+	})
+	if len(unhealthy) > 0 {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "ExtensionsUnhealthy"
+		cond.Message = strings.Join(unhealthy, "; ")
+	}
+
+	return cond
+}
+
+// wasmConfigRolloutStatus reports the rollout of the last change of the wasm configuration, keeping the reported one
+// until the rollout is planned after a restart
+func wasmConfigRolloutStatus(kObj *kuadrantv1beta1.Kuadrant, state *sync.Map) *kuadrantv1beta1.WasmConfigRolloutStatus {
+	if !kObj.Spec.WasmConfigRollout.IsEnabled() {
+		return nil
+	}
+	if status, initialized := wasmConfigRolloutFromState(state).Status(); initialized {
+		return status
+	}
+	return kObj.Status.WasmConfigRollout
+}
+
 func mtlsAuthorino(kObj *kuadrantv1beta1.Kuadrant, state *sync.Map) *bool {
 	effectiveAuthPolicies, ok := state.Load(StateEffectiveAuthPolicies)
 	if !ok {
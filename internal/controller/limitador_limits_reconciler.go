@@ -3,6 +3,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 
 	limitadorv1alpha1 "github.com/kuadrant/limitador-operator/api/v1alpha1"
@@ -39,6 +40,7 @@ func (r *LimitadorLimitsReconciler) Subscription() controller.Subscription {
 			{Kind: &machinery.GatewayGroupKind},
 			{Kind: &machinery.HTTPRouteGroupKind},
 			{Kind: &machinery.GRPCRouteGroupKind},
+			{Kind: &NamespaceGroupKind},
 			{Kind: &kuadrantv1.RateLimitPolicyGroupKind},
 			{Kind: &kuadrantv1alpha1.TokenRateLimitPolicyGroupKind},
 			{Kind: &kuadrantv1beta1.LimitadorGroupKind},
@@ -59,6 +61,12 @@ func (r *LimitadorLimitsReconciler) Reconcile(ctx context.Context, _ []controlle
 
 	desiredLimits, sources := r.buildLimitadorLimits(ctx, state)
 
+	// the limits are shared by all the gateways, including the ones that keep the stable wasm configuration
+	if wasmConfigRolloutFromState(state).HoldsSharedChanges() {
+		logger.V(1).Info("only adding new limits while the rollout of the wasm configuration is progressing")
+		desiredLimits = addedLimitadorLimits(limitador.Spec.Limits, desiredLimits)
+	}
+
 	if ratelimit.LimitadorRateLimits(limitador.Spec.Limits).EqualTo(desiredLimits) {
 		logger.Info("limitador object is up to date, nothing to do", "status", "skipping")
 		return nil
@@ -181,8 +189,8 @@ func (r *LimitadorLimitsReconciler) processPolicyRules(ctx context.Context, path
 					Namespace:  limitsNamespace,
 					MaxValue:   maxValue,
 					Seconds:    seconds,
-					Conditions: []string{fmt.Sprintf("descriptors[0][\"%s\"] == \"1\"", limitIdentifier)},
-					Variables:  utils.GetEmptySliceIfNil(limit.CountersAsStringList()),
+					Conditions: append([]string{fmt.Sprintf("descriptors[0][\"%s\"] == \"1\"", limitIdentifier)}, rate.LimitadorConditions()...),
+					Variables:  utils.GetEmptySliceIfNil(append(limit.CountersAsStringList(), rate.LimitadorVariables()...)),
 				}
 			})
 			rateLimitIndex.Set(fmt.Sprintf("%s/%s", limitsNamespace, limitIdentifier), rateLimits)
@@ -196,8 +204,8 @@ func (r *LimitadorLimitsReconciler) processPolicyRules(ctx context.Context, path
 					Namespace:  limitsNamespace,
 					MaxValue:   maxValue,
 					Seconds:    seconds,
-					Conditions: []string{fmt.Sprintf("descriptors[0][\"%s\"] == \"1\"", limitIdentifier)},
-					Variables:  utils.GetEmptySliceIfNil(limit.CountersAsStringList()),
+					Conditions: append([]string{fmt.Sprintf("descriptors[0][\"%s\"] == \"1\"", limitIdentifier)}, rate.LimitadorConditions()...),
+					Variables:  utils.GetEmptySliceIfNil(append(limit.CountersAsStringList(), rate.LimitadorVariables()...)),
 				}
 			})
 			rateLimitIndex.Set(fmt.Sprintf("%s/%s", limitsNamespace, limitIdentifier), rateLimits)
@@ -220,3 +228,14 @@ func isRateLimitingPolicyAcceptedAndNotDeletedFunc(state *sync.Map) func(machine
 		return rlpPredicate(policy) || trlpPredicate(policy)
 	}
 }
+
+// addedLimitadorLimits returns the existing limits plus the desired limits of the namespaces and names not defined yet,
+// leaving the existing limits untouched
+func addedLimitadorLimits(existing, desired []limitadorv1alpha1.RateLimit) []limitadorv1alpha1.RateLimit {
+	limitKey := func(limit limitadorv1alpha1.RateLimit) string { return limit.Namespace + "#" + limit.Name }
+	existingKeys := lo.SliceToMap(existing, func(limit limitadorv1alpha1.RateLimit) (string, struct{}) { return limitKey(limit), struct{}{} })
+	return append(slices.Clone(existing), lo.Filter(desired, func(limit limitadorv1alpha1.RateLimit, _ int) bool {
+		_, exists := existingKeys[limitKey(limit)]
+		return !exists
+	})...)
+}
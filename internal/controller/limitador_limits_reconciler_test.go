@@ -0,0 +1,27 @@
+//go:build unit
+
+package controllers
+
+import (
+	"testing"
+
+	limitadorv1alpha1 "github.com/kuadrant/limitador-operator/api/v1alpha1"
+	"gotest.tools/assert"
+)
+
+func TestAddedLimitadorLimits(t *testing.T) {
+	existing := []limitadorv1alpha1.RateLimit{
+		{Namespace: "ns/route", Name: "limit.a", MaxValue: 10, Seconds: 60},
+		{Namespace: "ns/route", Name: "limit.removed", MaxValue: 5, Seconds: 60},
+	}
+	desired := []limitadorv1alpha1.RateLimit{
+		{Namespace: "ns/route", Name: "limit.a", MaxValue: 20, Seconds: 60},
+		{Namespace: "ns/route", Name: "limit.new", MaxValue: 1, Seconds: 1},
+	}
+
+	assert.DeepEqual(t, addedLimitadorLimits(existing, desired), []limitadorv1alpha1.RateLimit{
+		{Namespace: "ns/route", Name: "limit.a", MaxValue: 10, Seconds: 60},
+		{Namespace: "ns/route", Name: "limit.removed", MaxValue: 5, Seconds: 60},
+		{Namespace: "ns/route", Name: "limit.new", MaxValue: 1, Seconds: 1},
+	})
+}
@@ -0,0 +1,352 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	kuadrantenvoy "github.com/kuadrant/kuadrant-operator/internal/envoy"
+	"github.com/kuadrant/kuadrant-operator/internal/kuadrant"
+	kuadrantpolicymachinery "github.com/kuadrant/kuadrant-operator/internal/policymachinery"
+	"github.com/kuadrant/kuadrant-operator/internal/wasm"
+)
+
+const (
+	// DataPlaneModeAnnotation selects how the policies are enforced in the data plane of a gateway
+	DataPlaneModeAnnotation = "kuadrant.io/data-plane-mode"
+
+	// WasmDataPlaneMode enforces the policies with the wasm shim (default)
+	WasmDataPlaneMode = "wasm"
+	// NativeDataPlaneMode enforces the policies with the native Envoy ext_authz and ratelimit filters
+	NativeDataPlaneMode = "native"
+
+	// features of the policies that the native Envoy filters cannot enforce
+	nativeUnsupportedPredicates      = "'when' predicates"
+	nativeSkippedLimitPredicates     = "'when' predicates of limits (skipped, the requests they match are not rate limited)"
+	nativeUnsupportedShadowMode      = "shadow mode"
+	nativeUnsupportedDescriptors     = "counters or calendar rates"
+	nativeUnsupportedTokenRateLimits = "token rate limits"
+	nativeUnsupportedNonHTTPRoutes   = "routes other than HTTPRoutes"
+)
+
+var StateNativeDataPlaneUnsupportedFeatures = "NativeDataPlaneUnsupportedFeatures"
+
+// isNativeDataPlaneGateway tells whether the policies of a gateway are enforced with the native Envoy filters
+// instead of the wasm shim
+func isNativeDataPlaneGateway(gateway *machinery.Gateway) bool {
+	return strings.EqualFold(gateway.GetAnnotations()[DataPlaneModeAnnotation], NativeDataPlaneMode)
+}
+
+func NativeFiltersName(gatewayName string) string {
+	return fmt.Sprintf("kuadrant-native-%s", gatewayName)
+}
+
+// nativeRouteConfig is the configuration of the native Envoy filters for the routes of a route rule in a listener
+type nativeRouteConfig struct {
+	Listener *machinery.Listener
+	// RouteType is the type of the route of the rule, i.e. HTTP or gRPC
+	RouteType kuadrantpolicymachinery.RouteType
+	// Route is the HTTPRoute or GRPCRoute of the rule
+	Route machinery.Object
+	// RouteRule is the HTTPRouteRule or GRPCRouteRule
+	RouteRule      machinery.Object
+	RuleIndex      int
+	AuthConfigName string
+	// DenyAll denies every request of the route, for its auth cannot be enforced by the native filters
+	DenyAll bool
+	// RateLimitDomain is the limits namespace of the route in Limitador
+	RateLimitDomain string
+	// RateLimitDescriptorKeys are the identifiers of the limits that apply to every request of the route
+	RateLimitDescriptorKeys []string
+}
+
+// PerFilterConfig returns the typed_per_filter_config of the Envoy routes, enabling the filters disabled by default
+func (c nativeRouteConfig) PerFilterConfig() map[string]any {
+	perFilterConfig := map[string]any{}
+	if c.DenyAll {
+		perFilterConfig[kuadrantenvoy.RBACFilterName] = kuadrantenvoy.BuildDenyAllPerRouteConfig()
+	}
+	if c.AuthConfigName != "" {
+		perFilterConfig[kuadrantenvoy.ExtAuthzFilterName] = kuadrantenvoy.BuildExtAuthzPerRouteConfig(c.AuthConfigName)
+	}
+	if len(c.RateLimitDescriptorKeys) > 0 {
+		perFilterConfig[kuadrantenvoy.RateLimitFilterName] = kuadrantenvoy.BuildRateLimitPerRouteConfig(c.RateLimitDomain, c.RateLimitDescriptorKeys)
+	}
+	return perFilterConfig
+}
+
+// buildNativeFilters returns the native HTTP filters required by the route configs of a gateway, in the order
+// they must run
+func buildNativeFilters(logger logr.Logger, configs []nativeRouteConfig) []map[string]any {
+	var filters []map[string]any
+	if lo.SomeBy(configs, func(c nativeRouteConfig) bool { return c.DenyAll }) {
+		filters = append(filters, kuadrantenvoy.BuildRBACFilter())
+	}
+	if lo.SomeBy(configs, func(c nativeRouteConfig) bool { return c.AuthConfigName != "" }) {
+		filters = append(filters, kuadrantenvoy.BuildExtAuthzFilter(kuadrant.KuadrantAuthClusterName, wasm.AuthServiceTimeout(), wasm.AuthServiceFailureMode(&logger) == wasm.FailureModeAllow))
+	}
+	if lo.SomeBy(configs, func(c nativeRouteConfig) bool { return len(c.RateLimitDescriptorKeys) > 0 }) {
+		filters = append(filters, kuadrantenvoy.BuildRateLimitFilter(kuadrant.KuadrantRateLimitClusterName, wasm.RatelimitServiceTimeout(), wasm.RatelimitServiceFailureMode(&logger) == wasm.FailureModeDeny))
+	}
+	return filters
+}
+
+// buildNativeRouteConfigs translates the effective auth and rate limit policies of the topological paths of the
+// native data plane gateways of the given gateway controllers into configs of the native Envoy filters, per gateway.
+//
+// Only what the native filters can enforce on their own is translated: auth without 'when' predicates and limits
+// without 'when' predicates, counters or calendar rates. Anything else, shadow mode and token rate limits
+// require the wasm shim. Routes whose auth cannot be translated, including any route other than an HTTPRoute,
+// deny every request instead of being left open;
+// unsupported limits, including the ones with 'when' predicates, are skipped. Either way, the policies report the unsupported features in their status
+// (see nativeDataPlaneUnsupportedFeatures).
+func buildNativeRouteConfigs(ctx context.Context, topology *machinery.Topology, state *sync.Map, gatewayControllerNames []gatewayapiv1.GatewayController) (map[string][]nativeRouteConfig, error) {
+	logger := controller.LoggerFromContext(ctx).WithName("buildNativeRouteConfigs")
+
+	effectiveAuthPolicies, ok := state.Load(StateEffectiveAuthPolicies)
+	if !ok {
+		return nil, ErrMissingStateEffectiveAuthPolicies
+	}
+	effectiveAuthPoliciesMap := effectiveAuthPolicies.(EffectiveAuthPolicies)
+
+	var effectiveRateLimitPoliciesMap EffectiveRateLimitPolicies
+	if effectiveRateLimitPolicies, ok := state.Load(StateEffectiveRateLimitPolicies); ok {
+		effectiveRateLimitPoliciesMap = effectiveRateLimitPolicies.(EffectiveRateLimitPolicies)
+	}
+
+	var allPaths []lo.Entry[string, []machinery.Targetable]
+	allPaths = append(allPaths, lo.Entries(lo.MapValues(effectiveAuthPoliciesMap, func(p EffectiveAuthPolicy, _ string) []machinery.Targetable { return p.Path }))...)
+	allPaths = append(allPaths, lo.Entries(lo.MapValues(effectiveRateLimitPoliciesMap, func(p EffectiveRateLimitPolicy, _ string) []machinery.Targetable { return p.Path }))...)
+	paths := lo.UniqBy(allPaths, func(e lo.Entry[string, []machinery.Targetable]) string { return e.Key })
+
+	configs := map[string][]nativeRouteConfig{}
+
+	for _, entry := range paths {
+		pathID := entry.Key
+
+		parsed, err := kuadrantpolicymachinery.ParseTopologyPath(entry.Value)
+		if err != nil {
+			continue
+		}
+
+		// ignore if not a native data plane gateway of the given gateway controllers
+		if !lo.Contains(gatewayControllerNames, parsed.GatewayClass.Spec.ControllerName) || !isNativeDataPlaneGateway(parsed.Gateway) {
+			continue
+		}
+
+		config := nativeRouteConfig{
+			Listener:  parsed.Listener,
+			RouteType: parsed.RouteType,
+			Route:     parsed.GetRoute().(machinery.Object),
+			RouteRule: parsed.GetRouteRule(),
+		}
+
+		// only the auth and limits of HTTPRoutes are translated; the routes of other types deny every request
+		// if any auth applies to them
+		if parsed.RouteType != kuadrantpolicymachinery.RouteTypeHTTP {
+			config.RuleIndex = grpcRouteRuleIndex(parsed.GRPCRouteRule)
+			if effectivePolicy, ok := effectiveAuthPoliciesMap[pathID]; ok && len(buildWasmActionsForAuth(pathID, effectivePolicy)) > 0 {
+				logger.V(1).Info("only HTTPRoutes are supported in native data plane mode, denying all requests", "pathID", pathID, "route", parsed.GetRouteNamespacedName().String())
+				config.DenyAll = true
+				configs[parsed.Gateway.GetLocator()] = append(configs[parsed.Gateway.GetLocator()], config)
+				continue
+			}
+			logger.V(1).Info("only HTTPRoutes are supported in native data plane mode, skipping", "pathID", pathID, "route", parsed.GetRouteNamespacedName().String())
+			continue
+		}
+
+		config.RuleIndex = httpRouteRuleIndex(parsed.HTTPRouteRule)
+
+		// auth
+		if effectivePolicy, ok := effectiveAuthPoliciesMap[pathID]; ok {
+			for _, action := range buildWasmActionsForAuth(pathID, effectivePolicy) {
+				if features := nativeAuthUnsupportedFeatures(action); len(features) > 0 {
+					logger.V(1).Info("auth not supported in native data plane mode, denying all requests", "pathID", pathID, "policies", action.SourcePolicyLocators, "features", features)
+					config.AuthConfigName = ""
+					config.DenyAll = true
+					break
+				}
+				config.AuthConfigName = action.Scope
+			}
+		}
+
+		// rate limit
+		if effectivePolicy, ok := effectiveRateLimitPoliciesMap[pathID]; ok {
+			for _, action := range buildWasmActionsForRateLimit(effectivePolicy, isRateLimitPolicyAcceptedAndNotDeletedFunc(state)) {
+				descriptorKey, features := nativeRateLimitDescriptorKey(action)
+				if len(features) > 0 {
+					logger.V(1).Info("limit not supported in native data plane mode, skipping", "pathID", pathID, "policies", action.SourcePolicyLocators, "features", features)
+					continue
+				}
+				config.RateLimitDomain = action.Scope
+				config.RateLimitDescriptorKeys = append(config.RateLimitDescriptorKeys, descriptorKey)
+			}
+			slices.Sort(config.RateLimitDescriptorKeys)
+		}
+
+		if !config.DenyAll && config.AuthConfigName == "" && len(config.RateLimitDescriptorKeys) == 0 {
+			continue
+		}
+
+		configs[parsed.Gateway.GetLocator()] = append(configs[parsed.Gateway.GetLocator()], config)
+	}
+
+	for gateway := range configs {
+		slices.SortFunc(configs[gateway], func(a, b nativeRouteConfig) int {
+			return strings.Compare(fmt.Sprintf("%s#%s", a.Listener.GetLocator(), a.RouteRule.GetLocator()), fmt.Sprintf("%s#%s", b.Listener.GetLocator(), b.RouteRule.GetLocator()))
+		})
+	}
+
+	return configs, nil
+}
+
+// nativeAuthUnsupportedFeatures returns the features of an auth action that the ext_authz filter cannot enforce
+func nativeAuthUnsupportedFeatures(action wasm.Action) []string {
+	var features []string
+	if len(action.Predicates) > 0 {
+		features = append(features, nativeUnsupportedPredicates)
+	}
+	if action.ReportOnly {
+		features = append(features, nativeUnsupportedShadowMode)
+	}
+	return features
+}
+
+// nativeRateLimitDescriptorKey returns the descriptor key of a rate limit action that only activates a limit,
+// i.e. with no predicates and no other descriptor entries than the limit identifier.
+// Otherwise, it returns the features of the action that the ratelimit filter cannot enforce.
+func nativeRateLimitDescriptorKey(action wasm.Action) (string, []string) {
+	var features []string
+	if action.ReportOnly {
+		features = append(features, nativeUnsupportedShadowMode)
+	}
+	if len(action.Predicates) > 0 || lo.SomeBy(action.ConditionalData, func(c wasm.ConditionalData) bool { return len(c.Predicates) > 0 }) {
+		features = append(features, nativeSkippedLimitPredicates)
+	}
+	if len(action.ConditionalData) != 1 || len(action.ConditionalData[0].Data) != 1 {
+		features = append(features, nativeUnsupportedDescriptors)
+	}
+	if len(features) > 0 {
+		return "", features
+	}
+	expression, ok := action.ConditionalData[0].Data[0].Value.(*wasm.Expression)
+	if !ok || expression.ExpressionItem.Value != "1" {
+		return "", []string{nativeUnsupportedDescriptors}
+	}
+	return expression.ExpressionItem.Key, nil
+}
+
+// nativeDataPlaneUnsupportedFeatures returns the features that the native Envoy filters cannot enforce, by locator
+// of the policies using them in the paths of native data plane gateways.
+// The features are computed once per reconciliation and stored in the state.
+func nativeDataPlaneUnsupportedFeatures(state *sync.Map) map[string][]string {
+	if features, ok := state.Load(StateNativeDataPlaneUnsupportedFeatures); ok {
+		return features.(map[string][]string)
+	}
+
+	features := map[string][]string{}
+	addFeatures := func(policyLocators []string, policyFeatures ...string) {
+		for _, locator := range policyLocators {
+			features[locator] = lo.Uniq(append(features[locator], policyFeatures...))
+		}
+	}
+	nativePath := func(path []machinery.Targetable) (bool, bool) {
+		parsed, err := kuadrantpolicymachinery.ParseTopologyPath(path)
+		if err != nil || !isNativeDataPlaneGateway(parsed.Gateway) {
+			return false, false
+		}
+		return true, parsed.RouteType == kuadrantpolicymachinery.RouteTypeHTTP
+	}
+
+	if effectivePolicies, ok := state.Load(StateEffectiveAuthPolicies); ok {
+		for pathID, effectivePolicy := range effectivePolicies.(EffectiveAuthPolicies) {
+			native, httpRoute := nativePath(effectivePolicy.Path)
+			if !native {
+				continue
+			}
+			if !httpRoute {
+				addFeatures(effectivePolicy.SourcePolicies, nativeUnsupportedNonHTTPRoutes)
+				continue
+			}
+			for _, action := range buildWasmActionsForAuth(pathID, effectivePolicy) {
+				addFeatures(action.SourcePolicyLocators, nativeAuthUnsupportedFeatures(action)...)
+			}
+		}
+	}
+
+	if effectivePolicies, ok := state.Load(StateEffectiveRateLimitPolicies); ok {
+		for _, effectivePolicy := range effectivePolicies.(EffectiveRateLimitPolicies) {
+			native, httpRoute := nativePath(effectivePolicy.Path)
+			if !native {
+				continue
+			}
+			if !httpRoute {
+				addFeatures(effectivePolicy.SourcePolicies, nativeUnsupportedNonHTTPRoutes)
+				continue
+			}
+			for _, action := range buildWasmActionsForRateLimit(effectivePolicy, isRateLimitPolicyAcceptedAndNotDeletedFunc(state)) {
+				_, actionFeatures := nativeRateLimitDescriptorKey(action)
+				addFeatures(action.SourcePolicyLocators, actionFeatures...)
+			}
+		}
+	}
+
+	if effectivePolicies, ok := state.Load(StateEffectiveTokenRateLimitPolicies); ok {
+		for _, effectivePolicy := range effectivePolicies.(EffectiveTokenRateLimitPolicies) {
+			if native, _ := nativePath(effectivePolicy.Path); native {
+				addFeatures(effectivePolicy.SourcePolicies, nativeUnsupportedTokenRateLimits)
+			}
+		}
+	}
+
+	features = lo.PickBy(features, func(_ string, policyFeatures []string) bool { return len(policyFeatures) > 0 })
+	for locator := range features {
+		slices.Sort(features[locator])
+	}
+	state.Store(StateNativeDataPlaneUnsupportedFeatures, features)
+	return features
+}
+
+// httpRouteRuleIndex returns the position of a rule within the rules of its HTTPRoute
+func httpRouteRuleIndex(rule *machinery.HTTPRouteRule) int {
+	for i, r := range rule.HTTPRoute.Spec.Rules {
+		name := gatewayapiv1.SectionName(fmt.Sprintf("rule-%d", i+1))
+		if r.Name != nil {
+			name = *r.Name
+		}
+		if name == rule.Name {
+			return i
+		}
+	}
+	return 0
+}
+
+// grpcRouteRuleIndex returns the position of a rule within the rules of its GRPCRoute
+func grpcRouteRuleIndex(rule *machinery.GRPCRouteRule) int {
+	for i, r := range rule.GRPCRoute.Spec.Rules {
+		name := gatewayapiv1.SectionName(fmt.Sprintf("rule-%d", i+1))
+		if r.Name != nil {
+			name = *r.Name
+		}
+		if name == rule.Name {
+			return i
+		}
+	}
+	return 0
+}
+
+// nativeRouteKind returns the kind of the route of a route config in lowercase, as in the names of the Envoy routes
+func nativeRouteKind(config nativeRouteConfig) string {
+	if config.RouteType == kuadrantpolicymachinery.RouteTypeGRPC {
+		return "grpcroute"
+	}
+	return "httproute"
+}
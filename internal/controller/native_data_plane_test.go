@@ -0,0 +1,299 @@
+//go:build unit
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	envoygatewayv1alpha1 "github.com/envoyproxy/gateway/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
+	kuadrantenvoy "github.com/kuadrant/kuadrant-operator/internal/envoy"
+	"github.com/kuadrant/kuadrant-operator/internal/kuadrant"
+	kuadrantpolicymachinery "github.com/kuadrant/kuadrant-operator/internal/policymachinery"
+	"github.com/kuadrant/kuadrant-operator/internal/utils"
+	"github.com/kuadrant/kuadrant-operator/internal/wasm"
+)
+
+func TestIsNativeDataPlaneGateway(t *testing.T) {
+	gateway := func(annotations map[string]string) *machinery.Gateway {
+		return &machinery.Gateway{Gateway: &gatewayapiv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "my-gateway", Annotations: annotations}}}
+	}
+	assert.Assert(t, !isNativeDataPlaneGateway(gateway(nil)))
+	assert.Assert(t, !isNativeDataPlaneGateway(gateway(map[string]string{DataPlaneModeAnnotation: WasmDataPlaneMode})))
+	assert.Assert(t, isNativeDataPlaneGateway(gateway(map[string]string{DataPlaneModeAnnotation: NativeDataPlaneMode})))
+	assert.Assert(t, isNativeDataPlaneGateway(gateway(map[string]string{DataPlaneModeAnnotation: "Native"})))
+}
+
+func TestNativeRateLimitDescriptorKey(t *testing.T) {
+	limitData := func(key, value string) wasm.DataType {
+		return wasm.DataType{Value: &wasm.Expression{ExpressionItem: wasm.ExpressionItem{Key: key, Value: value}}}
+	}
+
+	testCases := []struct {
+		name             string
+		action           wasm.Action
+		expectedKey      string
+		expectedFeatures []string
+	}{
+		{
+			name:        "limit only",
+			action:      wasm.Action{ConditionalData: []wasm.ConditionalData{{Data: []wasm.DataType{limitData("my-ns/my-rlp/limit", "1")}}}},
+			expectedKey: "my-ns/my-rlp/limit",
+		},
+		{
+			name:             "with predicates",
+			action:           wasm.Action{ConditionalData: []wasm.ConditionalData{{Predicates: []string{"request.method == 'GET'"}, Data: []wasm.DataType{limitData("my-ns/my-rlp/limit", "1")}}}},
+			expectedFeatures: []string{nativeSkippedLimitPredicates},
+		},
+		{
+			name:             "with counters",
+			action:           wasm.Action{ConditionalData: []wasm.ConditionalData{{Data: []wasm.DataType{limitData("my-ns/my-rlp/limit", "1"), limitData("auth.identity.username", "auth.identity.username")}}}},
+			expectedFeatures: []string{nativeUnsupportedDescriptors},
+		},
+		{
+			name:             "in shadow mode",
+			action:           wasm.Action{ReportOnly: true, ConditionalData: []wasm.ConditionalData{{Data: []wasm.DataType{limitData("my-ns/my-rlp/limit", "1")}}}},
+			expectedFeatures: []string{nativeUnsupportedShadowMode},
+		},
+		{
+			name:             "in shadow mode with predicates",
+			action:           wasm.Action{ReportOnly: true, Predicates: []string{"request.method == 'GET'"}, ConditionalData: []wasm.ConditionalData{{Data: []wasm.DataType{limitData("my-ns/my-rlp/limit", "1")}}}},
+			expectedFeatures: []string{nativeUnsupportedShadowMode, nativeSkippedLimitPredicates},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, features := nativeRateLimitDescriptorKey(tc.action)
+			assert.DeepEqual(t, features, tc.expectedFeatures)
+			assert.Equal(t, key, tc.expectedKey)
+		})
+	}
+
+	// the policy tells the limits with predicates are skipped rather than enforced
+	assert.Equal(t, kuadrant.NewErrUnsupportedFeature("RateLimitPolicy", []string{nativeSkippedLimitPredicates}).Error(),
+		"RateLimitPolicy uses features not supported by the native data plane: 'when' predicates of limits (skipped, the requests they match are not rate limited)")
+}
+
+func TestNativeAuthUnsupportedFeatures(t *testing.T) {
+	assert.Equal(t, len(nativeAuthUnsupportedFeatures(wasm.Action{Scope: "some-auth-config"})), 0)
+	assert.DeepEqual(t, nativeAuthUnsupportedFeatures(wasm.Action{Predicates: []string{"request.method == 'GET'"}}), []string{nativeUnsupportedPredicates})
+	assert.DeepEqual(t, nativeAuthUnsupportedFeatures(wasm.Action{ReportOnly: true}), []string{nativeUnsupportedShadowMode})
+}
+
+func TestNativeRouteConfigDenyAll(t *testing.T) {
+	config := nativeRouteConfig{DenyAll: true}
+	perFilterConfig := config.PerFilterConfig()
+	assert.Equal(t, len(perFilterConfig), 1)
+	rbacConfig := perFilterConfig[kuadrantenvoy.RBACFilterName].(map[string]any)
+	assert.Equal(t, rbacConfig["@type"], kuadrantenvoy.FilterConfigTypeURL)
+	rules := rbacConfig["config"].(map[string]any)["rbac"].(map[string]any)["rules"].(map[string]any)
+	assert.Equal(t, rules["action"], "DENY")
+
+	filters := buildNativeFilters(logr.Discard(), []nativeRouteConfig{config, {AuthConfigName: "some-auth-config"}})
+	assert.Equal(t, len(filters), 2)
+	assert.Equal(t, filters[0]["name"], kuadrantenvoy.RBACFilterName)
+	assert.Equal(t, filters[0]["disabled"], true)
+	assert.Equal(t, filters[1]["name"], kuadrantenvoy.ExtAuthzFilterName)
+}
+
+func TestHTTPRouteRuleIndex(t *testing.T) {
+	route := &machinery.HTTPRoute{HTTPRoute: &gatewayapiv1.HTTPRoute{Spec: gatewayapiv1.HTTPRouteSpec{Rules: []gatewayapiv1.HTTPRouteRule{{}, {Name: ptr.To[gatewayapiv1.SectionName]("named")}, {}}}}}
+	assert.Equal(t, httpRouteRuleIndex(&machinery.HTTPRouteRule{HTTPRoute: route, Name: "rule-1"}), 0)
+	assert.Equal(t, httpRouteRuleIndex(&machinery.HTTPRouteRule{HTTPRoute: route, Name: "named"}), 1)
+	assert.Equal(t, httpRouteRuleIndex(&machinery.HTTPRouteRule{HTTPRoute: route, Name: "rule-3"}), 2)
+}
+
+func TestBuildNativeFiltersResources(t *testing.T) {
+	gateway := &machinery.Gateway{
+		Gateway: &gatewayapiv1.Gateway{
+			TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.GroupVersion.String(), Kind: "Gateway"},
+			ObjectMeta: metav1.ObjectMeta{Name: "my-gateway", Namespace: "gateway-ns", UID: "gw-uid"},
+		},
+	}
+	listener := &machinery.Listener{Listener: &gatewayapiv1.Listener{Name: "http", Port: 80}, Gateway: gateway}
+	route := &machinery.HTTPRoute{HTTPRoute: &gatewayapiv1.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "my.route", Namespace: "route-ns"}}}
+	configs := []nativeRouteConfig{
+		{
+			Listener:                listener,
+			RouteType:               kuadrantpolicymachinery.RouteTypeHTTP,
+			Route:                   route,
+			RouteRule:               &machinery.HTTPRouteRule{HTTPRoute: route, Name: "rule-2"},
+			RuleIndex:               1,
+			AuthConfigName:          "some-auth-config",
+			RateLimitDomain:         "route-ns/my.route",
+			RateLimitDescriptorKeys: []string{"limit.a", "limit.b"},
+		},
+	}
+
+	t.Run("envoy gateway", func(t *testing.T) {
+		envoyPatchPolicy, err := buildEnvoyGatewayNativeFiltersPatchPolicy(logr.Discard(), gateway, nil)
+		assert.NilError(t, err)
+		assert.Assert(t, utils.IsObjectTaggedToDelete(envoyPatchPolicy))
+
+		envoyPatchPolicy, err = buildEnvoyGatewayNativeFiltersPatchPolicy(logr.Discard(), gateway, configs)
+		assert.NilError(t, err)
+		assert.Assert(t, !utils.IsObjectTaggedToDelete(envoyPatchPolicy))
+		assert.Equal(t, envoyPatchPolicy.GetName(), "kuadrant-native-my-gateway")
+		assert.Equal(t, envoyPatchPolicy.GetNamespace(), "gateway-ns")
+		assert.Equal(t, len(envoyPatchPolicy.Spec.JSONPatches), 3)
+
+		// the filters are inserted first in reverse order, so ext_authz runs before ratelimit
+		var filter map[string]any
+		assert.Equal(t, envoyPatchPolicy.Spec.JSONPatches[0].Type, envoygatewayv1alpha1.ListenerEnvoyResourceType)
+		assert.Equal(t, envoyPatchPolicy.Spec.JSONPatches[0].Name, "gateway-ns/my-gateway/http")
+		assert.NilError(t, json.Unmarshal(envoyPatchPolicy.Spec.JSONPatches[0].Operation.Value.Raw, &filter))
+		assert.Equal(t, filter["name"], kuadrantenvoy.RateLimitFilterName)
+		assert.NilError(t, json.Unmarshal(envoyPatchPolicy.Spec.JSONPatches[1].Operation.Value.Raw, &filter))
+		assert.Equal(t, filter["name"], kuadrantenvoy.ExtAuthzFilterName)
+		assert.Equal(t, filter["disabled"], true)
+
+		routePatch := envoyPatchPolicy.Spec.JSONPatches[2]
+		assert.Equal(t, routePatch.Type, envoygatewayv1alpha1.RouteConfigurationEnvoyResourceType)
+		assert.Equal(t, routePatch.Name, "gateway-ns/my-gateway/http")
+		assert.Equal(t, *routePatch.Operation.JSONPath, `..routes[?match(@.name, 'httproute/route-ns/my\\.route/rule/1/match/.*')]`)
+		assert.Equal(t, *routePatch.Operation.Path, "/typed_per_filter_config")
+		var perFilterConfig map[string]map[string]any
+		assert.NilError(t, json.Unmarshal(routePatch.Operation.Value.Raw, &perFilterConfig))
+		assert.Equal(t, perFilterConfig[kuadrantenvoy.ExtAuthzFilterName]["@type"], kuadrantenvoy.FilterConfigTypeURL)
+		rateLimitConfig := perFilterConfig[kuadrantenvoy.RateLimitFilterName]["config"].(map[string]any)
+		assert.Equal(t, rateLimitConfig["domain"], "route-ns/my.route")
+		actions := rateLimitConfig["rate_limits"].([]any)[0].(map[string]any)["actions"].([]any)
+		assert.Equal(t, len(actions), 2)
+		assert.Equal(t, actions[0].(map[string]any)["generic_key"].(map[string]any)["descriptor_key"], "limit.a")
+	})
+
+	t.Run("istio", func(t *testing.T) {
+		envoyFilter, err := buildIstioNativeFiltersEnvoyFilter(logr.Discard(), gateway, nil)
+		assert.NilError(t, err)
+		assert.Assert(t, utils.IsObjectTaggedToDelete(envoyFilter))
+
+		envoyFilter, err = buildIstioNativeFiltersEnvoyFilter(logr.Discard(), gateway, configs)
+		assert.NilError(t, err)
+		assert.Assert(t, !utils.IsObjectTaggedToDelete(envoyFilter))
+		assert.Equal(t, envoyFilter.GetName(), "kuadrant-native-my-gateway")
+		assert.Equal(t, len(envoyFilter.Spec.ConfigPatches), 3)
+		assert.Equal(t, envoyFilter.Spec.ConfigPatches[0].Patch.Value.Fields["name"].GetStringValue(), kuadrantenvoy.ExtAuthzFilterName)
+		assert.Equal(t, envoyFilter.Spec.ConfigPatches[1].Patch.Value.Fields["name"].GetStringValue(), kuadrantenvoy.RateLimitFilterName)
+		routeMatch := envoyFilter.Spec.ConfigPatches[2].Match.GetRouteConfiguration()
+		assert.Equal(t, routeMatch.GetPortNumber(), uint32(80))
+		assert.Equal(t, routeMatch.GetVhost().GetRoute().GetName(), "route-ns.my.route.1")
+	})
+}
+
+func TestBuildNativeRouteConfigsGRPCRoute(t *testing.T) {
+	gatewayClass := &machinery.GatewayClass{GatewayClass: &gatewayapiv1.GatewayClass{
+		TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.SchemeGroupVersion.String(), Kind: machinery.GatewayClassGroupKind.Kind},
+		ObjectMeta: metav1.ObjectMeta{Name: "my-gateway-class"},
+		Spec:       gatewayapiv1.GatewayClassSpec{ControllerName: "my-controller"},
+	}}
+	gateway := &machinery.Gateway{Gateway: &gatewayapiv1.Gateway{
+		TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.SchemeGroupVersion.String(), Kind: machinery.GatewayGroupKind.Kind},
+		ObjectMeta: metav1.ObjectMeta{Name: "native-gateway", Namespace: "gateway-system", Annotations: map[string]string{DataPlaneModeAnnotation: NativeDataPlaneMode}},
+		Spec: gatewayapiv1.GatewaySpec{
+			GatewayClassName: "my-gateway-class",
+			Listeners:        []gatewayapiv1.Listener{{Name: "http", Port: 80}},
+		},
+	}}
+	listener := &machinery.Listener{Listener: &gateway.Spec.Listeners[0], Gateway: gateway}
+	grpcRoute := &machinery.GRPCRoute{GRPCRoute: &gatewayapiv1.GRPCRoute{
+		TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.SchemeGroupVersion.String(), Kind: machinery.GRPCRouteGroupKind.Kind},
+		ObjectMeta: metav1.ObjectMeta{Name: "toystore", Namespace: "my-ns"},
+		Spec: gatewayapiv1.GRPCRouteSpec{
+			CommonRouteSpec: gatewayapiv1.CommonRouteSpec{
+				ParentRefs: []gatewayapiv1.ParentReference{{Name: "native-gateway", Namespace: ptr.To(gatewayapiv1.Namespace("gateway-system"))}},
+			},
+			Rules: []gatewayapiv1.GRPCRouteRule{{}, {}},
+		},
+	}}
+	rule := &machinery.GRPCRouteRule{Name: "rule-2", GRPCRoute: grpcRoute, GRPCRouteRule: &grpcRoute.Spec.Rules[1]}
+	path := []machinery.Targetable{gatewayClass, gateway, listener, grpcRoute, rule}
+
+	authPolicy := &kuadrantv1.AuthPolicy{
+		TypeMeta:   metav1.TypeMeta{Kind: kuadrantv1.AuthPolicyGroupKind.Kind, APIVersion: kuadrantv1.GroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{Name: "my-auth", Namespace: "my-ns"},
+	}
+
+	state := &sync.Map{}
+	state.Store(StateEffectiveAuthPolicies, EffectiveAuthPolicies{
+		"grpc-path": {Path: path, Spec: *authPolicy, SourcePolicies: []string{authPolicy.GetLocator()}},
+	})
+
+	configs, err := buildNativeRouteConfigs(controller.LoggerIntoContext(context.Background(), logr.Discard()), nil, state, []gatewayapiv1.GatewayController{"my-controller"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(configs[gateway.GetLocator()]), 1)
+	config := configs[gateway.GetLocator()][0]
+	assert.Assert(t, config.DenyAll)
+	assert.Equal(t, config.AuthConfigName, "")
+	assert.Equal(t, config.RouteType, kuadrantpolicymachinery.RouteTypeGRPC)
+	assert.Equal(t, config.RuleIndex, 1)
+
+	// the route of the rule is denied in the data plane
+	envoyPatchPolicy, err := buildEnvoyGatewayNativeFiltersPatchPolicy(logr.Discard(), gateway, configs[gateway.GetLocator()])
+	assert.NilError(t, err)
+	routePatch := envoyPatchPolicy.Spec.JSONPatches[len(envoyPatchPolicy.Spec.JSONPatches)-1]
+	assert.Equal(t, *routePatch.Operation.JSONPath, `..routes[?match(@.name, 'grpcroute/my-ns/toystore/rule/1/match/.*')]`)
+	var perFilterConfig map[string]map[string]any
+	assert.NilError(t, json.Unmarshal(routePatch.Operation.Value.Raw, &perFilterConfig))
+	assert.Equal(t, len(perFilterConfig), 1)
+	assert.Equal(t, perFilterConfig[kuadrantenvoy.RBACFilterName]["@type"], kuadrantenvoy.FilterConfigTypeURL)
+}
+
+func TestNativeDataPlaneUnsupportedFeatures(t *testing.T) {
+	gatewayClass := &machinery.GatewayClass{GatewayClass: &gatewayapiv1.GatewayClass{
+		TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.SchemeGroupVersion.String(), Kind: machinery.GatewayClassGroupKind.Kind},
+		ObjectMeta: metav1.ObjectMeta{Name: "my-gateway-class"},
+	}}
+	path := func(gatewayName string, annotations map[string]string) []machinery.Targetable {
+		gateway := &machinery.Gateway{Gateway: &gatewayapiv1.Gateway{
+			TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.SchemeGroupVersion.String(), Kind: machinery.GatewayGroupKind.Kind},
+			ObjectMeta: metav1.ObjectMeta{Name: gatewayName, Namespace: "gateway-system", Annotations: annotations},
+			Spec: gatewayapiv1.GatewaySpec{
+				GatewayClassName: "my-gateway-class",
+				Listeners:        []gatewayapiv1.Listener{{Name: "http"}},
+			},
+		}}
+		listener := &machinery.Listener{Listener: &gateway.Spec.Listeners[0], Gateway: gateway}
+		httpRoute := &machinery.HTTPRoute{HTTPRoute: &gatewayapiv1.HTTPRoute{
+			TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.SchemeGroupVersion.String(), Kind: machinery.HTTPRouteGroupKind.Kind},
+			ObjectMeta: metav1.ObjectMeta{Name: "toystore", Namespace: "my-ns"},
+			Spec: gatewayapiv1.HTTPRouteSpec{
+				CommonRouteSpec: gatewayapiv1.CommonRouteSpec{
+					ParentRefs: []gatewayapiv1.ParentReference{{Name: gatewayapiv1.ObjectName(gatewayName), Namespace: ptr.To(gatewayapiv1.Namespace("gateway-system"))}},
+				},
+				Rules: []gatewayapiv1.HTTPRouteRule{{}},
+			},
+		}}
+		rule := &machinery.HTTPRouteRule{Name: "rule-1", HTTPRoute: httpRoute, HTTPRouteRule: &httpRoute.Spec.Rules[0]}
+		return []machinery.Targetable{gatewayClass, gateway, listener, httpRoute, rule}
+	}
+
+	const (
+		nativePolicy = "kuadrant.io/v1alpha1/tokenratelimitpolicy:my-ns/native-trlp"
+		wasmPolicy   = "kuadrant.io/v1alpha1/tokenratelimitpolicy:my-ns/wasm-trlp"
+	)
+
+	state := &sync.Map{}
+	state.Store(StateEffectiveTokenRateLimitPolicies, EffectiveTokenRateLimitPolicies{
+		"native-path": {Path: path("native-gateway", map[string]string{DataPlaneModeAnnotation: NativeDataPlaneMode}), SourcePolicies: []string{nativePolicy}},
+		"wasm-path":   {Path: path("wasm-gateway", nil), SourcePolicies: []string{wasmPolicy}},
+	})
+
+	features := nativeDataPlaneUnsupportedFeatures(state)
+	assert.DeepEqual(t, features, map[string][]string{nativePolicy: {nativeUnsupportedTokenRateLimits}})
+
+	// the features are computed once per reconciliation
+	state.Store(StateEffectiveTokenRateLimitPolicies, EffectiveTokenRateLimitPolicies{})
+	assert.DeepEqual(t, nativeDataPlaneUnsupportedFeatures(state), features)
+}
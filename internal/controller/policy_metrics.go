@@ -18,11 +18,13 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	"github.com/kuadrant/policy-machinery/controller"
@@ -59,8 +61,9 @@ var (
 type PolicyStatus string
 
 const (
-	PolicyStatusTrue  PolicyStatus = "true"
-	PolicyStatusFalse PolicyStatus = "false"
+	PolicyStatusTrue   PolicyStatus = "true"
+	PolicyStatusFalse  PolicyStatus = "false"
+	PolicyStatusShadow PolicyStatus = "shadow"
 )
 
 // PolicyMetricsReconciler emits Prometheus metrics for all Kuadrant policies
@@ -75,7 +78,7 @@ func NewPolicyMetricsReconciler() *PolicyMetricsReconciler {
 // This reconciler automatically discovers and tracks all policy types by grouping policies by their Kind.
 // Currently includes core policies: AuthPolicy, RateLimitPolicy, DNSPolicy, TLSPolicy, and TokenRateLimitPolicy.
 // Note: Extension policies (OIDCPolicy, PlanPolicy, TelemetryPolicy) are not part of the topology and are not tracked.
-func (r *PolicyMetricsReconciler) Reconcile(ctx context.Context, _ []controller.ResourceEvent, topology *machinery.Topology, _ error, _ *sync.Map) error {
+func (r *PolicyMetricsReconciler) Reconcile(ctx context.Context, _ []controller.ResourceEvent, topology *machinery.Topology, _ error, state *sync.Map) error {
 	logger := controller.LoggerFromContext(ctx).WithName("policy_metrics").WithValues("context", ctx)
 
 	// Reset all metrics to zero before recalculating
@@ -95,6 +98,8 @@ func (r *PolicyMetricsReconciler) Reconcile(ctx context.Context, _ []controller.
 		r.emitMetricsForPolicies(kind, policies)
 	}
 
+	r.updateShadowDenials(topology, state)
+
 	logger.V(1).Info("policy metrics updated", "policyKinds", len(policiesByKind))
 	return nil
 }
@@ -106,8 +111,9 @@ func (r *PolicyMetricsReconciler) emitMetricsForPolicies(kind string, policies [
 
 	// Track enforcement status counts
 	enforcedCounts := map[PolicyStatus]int{
-		PolicyStatusTrue:  0,
-		PolicyStatusFalse: 0,
+		PolicyStatusTrue:   0,
+		PolicyStatusFalse:  0,
+		PolicyStatusShadow: 0,
 	}
 
 	for _, policy := range policies {
@@ -121,8 +127,44 @@ func (r *PolicyMetricsReconciler) emitMetricsForPolicies(kind string, policies [
 	}
 }
 
+// updateShadowDenials points the shadow denials counter to the limits of the rate limiting policies and to the
+// AuthConfigs of the auth policies in shadow mode, and to the metrics endpoints of Limitador and Authorino
+func (r *PolicyMetricsReconciler) updateShadowDenials(topology *machinery.Topology, state *sync.Map) {
+	limits := shadowLimitsBuilder{}
+	if effectivePolicies, ok := state.Load(StateEffectiveRateLimitPolicies); ok {
+		for _, effectivePolicy := range effectivePolicies.(EffectiveRateLimitPolicies) {
+			limits.add(effectivePolicy.Path, effectivePolicy.Spec.Rules())
+		}
+	}
+	if effectivePolicies, ok := state.Load(StateEffectiveTokenRateLimitPolicies); ok {
+		for _, effectivePolicy := range effectivePolicies.(EffectiveTokenRateLimitPolicies) {
+			limits.add(effectivePolicy.Path, effectivePolicy.Spec.Rules())
+		}
+	}
+
+	targets := shadowDenialsTargets{limits: limits.build()}
+	if limitador := GetLimitadorFromTopology(topology, state); limitador != nil && limitador.Status.Service != nil {
+		targets.limitadorMetricsURL = fmt.Sprintf("http://%s:%d/metrics", limitador.Status.Service.Host, limitador.Status.Service.Ports.HTTP)
+	}
+
+	if authorino := GetAuthorinoFromTopology(topology, state); authorino != nil {
+		authConfigs := shadowAuthConfigsBuilder{}
+		if effectivePolicies, ok := state.Load(StateEffectiveAuthPolicies); ok {
+			for pathID, effectivePolicy := range effectivePolicies.(EffectiveAuthPolicies) {
+				authConfigs.add(authorino.GetNamespace(), pathID, effectivePolicy)
+			}
+		}
+		metricsPort := ptr.Deref(authorino.Spec.Metrics.Port, authorinoDefaultMetricsPort)
+		targets.authorinoMetricsURL = fmt.Sprintf("http://%s-controller-metrics.%s.svc:%d/server-metrics", authorino.GetName(), authorino.GetNamespace(), metricsPort)
+		targets.authConfigs = authConfigs
+	}
+
+	shadowDenials.update(targets)
+}
+
 // getEnforcementStatus returns the enforcement status of a policy based on its Enforced condition.
 // A policy is considered enforced (true) only when it has an Enforced condition with status True.
+// A policy whose Enforced condition has reason Shadow is reported as shadow.
 // All other cases (no condition, condition False, condition Unknown, or unable to read status) are
 // treated as not enforced (false).
 func (r *PolicyMetricsReconciler) getEnforcementStatus(policy machinery.Policy) PolicyStatus {
@@ -134,6 +176,10 @@ func (r *PolicyMetricsReconciler) getEnforcementStatus(policy machinery.Policy)
 	conditions := policyWithStatusObj.GetStatus().GetConditions()
 	enforcedCondition := meta.FindStatusCondition(conditions, string(kuadrant.PolicyConditionEnforced))
 
+	if enforcedCondition != nil && enforcedCondition.Reason == string(kuadrant.PolicyReasonShadow) {
+		return PolicyStatusShadow
+	}
+
 	if enforcedCondition == nil || enforcedCondition.Status != metav1.ConditionTrue {
 		return PolicyStatusFalse
 	}
@@ -143,5 +189,5 @@ func (r *PolicyMetricsReconciler) getEnforcementStatus(policy machinery.Policy)
 
 func init() {
 	// Register metrics with controller-runtime's Prometheus registry
-	metrics.Registry.MustRegister(policiesTotal, policiesEnforced)
+	metrics.Registry.MustRegister(policiesTotal, policiesEnforced, shadowDenials)
 }
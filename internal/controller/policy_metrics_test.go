@@ -79,6 +79,21 @@ func TestGetEnforcementStatus(t *testing.T) {
 			},
 			expectedStatus: PolicyStatusFalse,
 		},
+		{
+			name: "enforced condition in shadow mode",
+			policy: &kuadrantv1.AuthPolicy{
+				Status: kuadrantv1.AuthPolicyStatus{
+					Conditions: []metav1.Condition{
+						{
+							Type:   string(kuadrant.PolicyConditionEnforced),
+							Status: metav1.ConditionFalse,
+							Reason: string(kuadrant.PolicyReasonShadow),
+						},
+					},
+				},
+			},
+			expectedStatus: PolicyStatusShadow,
+		},
 		{
 			name: "no enforced condition",
 			policy: &kuadrantv1.AuthPolicy{
@@ -119,6 +134,9 @@ func TestPolicyStatusConstants(t *testing.T) {
 	if PolicyStatusFalse != "false" {
 		t.Errorf("expected PolicyStatusFalse to be 'false', got %s", PolicyStatusFalse)
 	}
+	if PolicyStatusShadow != "shadow" {
+		t.Errorf("expected PolicyStatusShadow to be 'shadow', got %s", PolicyStatusShadow)
+	}
 }
 
 func TestMetricLabels(t *testing.T) {
@@ -0,0 +1,349 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/samber/lo"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
+	"github.com/kuadrant/kuadrant-operator/internal/kuadrant"
+	kuadrantpolicymachinery "github.com/kuadrant/kuadrant-operator/internal/policymachinery"
+)
+
+const (
+	policyNamespaceLabel = "namespace"
+	policyNameLabel      = "name"
+
+	limitadorLimitedCallsMetric   = "limited_calls"
+	limitadorNamespaceLabel       = "limitador_namespace"
+	limitadorLimitNameLabel       = "limit_name"
+	limitadorMetricsScrapeTimeout = 5 * time.Second
+
+	authorinoAuthConfigResponseStatusMetric = "auth_server_authconfig_response_status"
+	authorinoNamespaceLabel                 = "namespace"
+	authorinoAuthConfigLabel                = "authconfig"
+	authorinoStatusLabel                    = "status"
+	authorinoStatusOK                       = "OK"
+	authorinoDefaultMetricsPort             = int32(8080)
+
+	// shadowDenialsScrapeInterval is how often the metrics of Limitador and Authorino are scraped for the requests
+	// that the policies in shadow mode would have denied
+	shadowDenialsScrapeInterval = 30 * time.Second
+)
+
+// shadowDenials counts the requests that policies in shadow mode would have denied
+var shadowDenials = newShadowDenialsCollector(&http.Client{Timeout: limitadorMetricsScrapeTimeout})
+
+// shadowLimit identifies a limit in Limitador the same way its metrics do
+type shadowLimit struct {
+	limitsNamespace string
+	limitName       string
+}
+
+// shadowAuthConfig identifies an AuthConfig in Authorino the same way its metrics do
+type shadowAuthConfig struct {
+	namespace string
+	name      string
+}
+
+// shadowPolicySource is the policy in shadow mode that a limit in Limitador, or an AuthConfig in Authorino,
+// originates from
+type shadowPolicySource struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// shadowDenialsTargets are the metrics endpoints of Limitador and Authorino, and the limits and AuthConfigs of the
+// policies in shadow mode to report
+type shadowDenialsTargets struct {
+	limitadorMetricsURL string
+	limits              map[shadowLimit]shadowPolicySource
+	authorinoMetricsURL string
+	authConfigs         map[shadowAuthConfig]shadowPolicySource
+}
+
+// shadowDenialsCollector reports the kuadrant_shadow_denials_total counter.
+// A limit of a policy in shadow mode is still checked by Limitador, which counts the requests over the limit in its
+// limited_calls metric, while the data plane lets them through. Likewise, the AuthConfig of a policy in shadow mode is
+// still evaluated by Authorino, which counts its responses by status in its auth_server_authconfig_response_status
+// metric. The counter is computed out of these metrics, for the limits and AuthConfigs of the policies in shadow mode,
+// which are scraped in the background, so that the scrapes of the metrics of the operator never wait for Limitador or
+// Authorino. The limited_calls metric requires the telemetry of Limitador to be set to exhaustive, so it carries the
+// name of the limit.
+type shadowDenialsCollector struct {
+	desc    *prometheus.Desc
+	client  *http.Client
+	refresh chan struct{}
+
+	mu      sync.RWMutex
+	targets shadowDenialsTargets
+	// the denials last scraped from Limitador and Authorino, nil when they could not be scraped
+	limitadorDenials map[shadowPolicySource]float64
+	authorinoDenials map[shadowPolicySource]float64
+}
+
+func newShadowDenialsCollector(client *http.Client) *shadowDenialsCollector {
+	return &shadowDenialsCollector{
+		desc: prometheus.NewDesc(
+			"kuadrant_shadow_denials_total",
+			"Number of requests that Kuadrant policies in shadow mode would have denied",
+			[]string{policyKindLabel, policyNamespaceLabel, policyNameLabel},
+			nil,
+		),
+		client:  client,
+		refresh: make(chan struct{}, 1),
+	}
+}
+
+// update sets the metrics endpoints of Limitador and Authorino, and the limits and AuthConfigs of the policies in
+// shadow mode to report, and triggers a scrape
+func (c *shadowDenialsCollector) update(targets shadowDenialsTargets) {
+	c.mu.Lock()
+	c.targets = targets
+	c.mu.Unlock()
+
+	select {
+	case c.refresh <- struct{}{}:
+	default: // a scrape is already pending
+	}
+}
+
+// Start scrapes the metrics of Limitador and Authorino periodically, and whenever the targets change, until the
+// context is done
+func (c *shadowDenialsCollector) Start(ctx context.Context) error {
+	ticker := time.NewTicker(shadowDenialsScrapeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-c.refresh:
+		}
+		c.scrape(ctx)
+	}
+}
+
+// scrape reads the requests that the policies in shadow mode would have denied out of the metrics of Limitador and
+// Authorino, and caches them until the next scrape
+func (c *shadowDenialsCollector) scrape(ctx context.Context) {
+	logger := ctrl.LoggerFrom(ctx).WithName("shadow_denials")
+
+	c.mu.RLock()
+	targets := c.targets
+	c.mu.RUnlock()
+
+	var limitadorDenials, authorinoDenials map[shadowPolicySource]float64
+	if targets.limitadorMetricsURL != "" && len(targets.limits) > 0 {
+		limitedCalls, err := c.scrapeLimitedCalls(ctx, targets.limitadorMetricsURL)
+		if err != nil {
+			logger.V(1).Info("failed to scrape the metrics of Limitador", "url", targets.limitadorMetricsURL, "error", err.Error())
+		} else {
+			limitadorDenials = make(map[shadowPolicySource]float64)
+			for limit, source := range targets.limits {
+				limitadorDenials[source] += limitedCalls[limit]
+			}
+		}
+	}
+	if targets.authorinoMetricsURL != "" && len(targets.authConfigs) > 0 {
+		deniedCalls, err := c.scrapeAuthConfigDenials(ctx, targets.authorinoMetricsURL)
+		if err != nil {
+			logger.V(1).Info("failed to scrape the metrics of Authorino", "url", targets.authorinoMetricsURL, "error", err.Error())
+		} else {
+			authorinoDenials = make(map[shadowPolicySource]float64)
+			for authConfig, source := range targets.authConfigs {
+				authorinoDenials[source] += deniedCalls[authConfig]
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limitadorDenials = limitadorDenials
+	c.authorinoDenials = authorinoDenials
+}
+
+func (c *shadowDenialsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect reports the denials last scraped. The denials of the policies whose limits or AuthConfigs could not be
+// scraped are not reported.
+func (c *shadowDenialsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	denials := make(map[shadowPolicySource]float64, len(c.limitadorDenials)+len(c.authorinoDenials))
+	for _, scraped := range []map[shadowPolicySource]float64{c.limitadorDenials, c.authorinoDenials} {
+		for source, count := range scraped {
+			denials[source] += count
+		}
+	}
+	c.mu.RUnlock()
+
+	for source, count := range denials {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, count, source.kind, source.namespace, source.name)
+	}
+}
+
+// scrapeLimitedCalls returns the value of the limited_calls metric of Limitador by limit
+func (c *shadowDenialsCollector) scrapeLimitedCalls(ctx context.Context, metricsURL string) (map[shadowLimit]float64, error) {
+	metrics, err := c.scrapeMetric(ctx, metricsURL, limitadorLimitedCallsMetric)
+	if err != nil {
+		return nil, err
+	}
+	limitedCalls := make(map[shadowLimit]float64)
+	for _, metric := range metrics {
+		var limit shadowLimit
+		for _, label := range metric.GetLabel() {
+			switch label.GetName() {
+			case limitadorNamespaceLabel:
+				limit.limitsNamespace = label.GetValue()
+			case limitadorLimitNameLabel:
+				limit.limitName = label.GetValue()
+			}
+		}
+		if counter := metric.GetCounter(); counter != nil {
+			limitedCalls[limit] += counter.GetValue()
+		}
+	}
+	return limitedCalls, nil
+}
+
+// scrapeAuthConfigDenials returns the number of responses of Authorino other than OK by AuthConfig, out of its
+// auth_server_authconfig_response_status metric
+func (c *shadowDenialsCollector) scrapeAuthConfigDenials(ctx context.Context, metricsURL string) (map[shadowAuthConfig]float64, error) {
+	metrics, err := c.scrapeMetric(ctx, metricsURL, authorinoAuthConfigResponseStatusMetric)
+	if err != nil {
+		return nil, err
+	}
+	deniedCalls := make(map[shadowAuthConfig]float64)
+	for _, metric := range metrics {
+		var authConfig shadowAuthConfig
+		var status string
+		for _, label := range metric.GetLabel() {
+			switch label.GetName() {
+			case authorinoNamespaceLabel:
+				authConfig.namespace = label.GetValue()
+			case authorinoAuthConfigLabel:
+				authConfig.name = label.GetValue()
+			case authorinoStatusLabel:
+				status = label.GetValue()
+			}
+		}
+		if counter := metric.GetCounter(); counter != nil && status != authorinoStatusOK {
+			deniedCalls[authConfig] += counter.GetValue()
+		}
+	}
+	return deniedCalls, nil
+}
+
+// scrapeMetric returns the series of a metric exposed in the Prometheus text format by an endpoint
+func (c *shadowDenialsCollector) scrapeMetric(ctx context.Context, metricsURL, name string) ([]*dto.Metric, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metricsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d scraping %s", resp.StatusCode, metricsURL)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return families[name].GetMetric(), nil
+}
+
+// shadowLimitsBuilder collects the limits in Limitador that originate from policies in shadow mode, out of the
+// effective rate limiting policies of the paths.
+// Limits whose name is shared with limits of other policies for the same route are left out, for their counts in
+// Limitador cannot be told apart.
+type shadowLimitsBuilder map[shadowLimit]shadowPolicySource
+
+func (b shadowLimitsBuilder) add(path []machinery.Targetable, rules map[string]kuadrantv1.MergeableRule) {
+	parsed, err := kuadrantpolicymachinery.ParseTopologyPath(path)
+	if err != nil {
+		return
+	}
+	limitsNamespace := LimitsNamespaceFromRoute(parsed.GetRoute())
+	policies := kuadrantv1.PoliciesInPath(path, func(machinery.Policy) bool { return true })
+	for limitKey, rule := range rules {
+		if limitKey == kuadrantv1.RulesKeyTopLevelPredicates {
+			continue
+		}
+		var source shadowPolicySource // zero value for limits not in shadow mode or shared by multiple policies
+		policy, found := lo.Find(policies, func(p machinery.Policy) bool {
+			return p.GetLocator() == rule.GetSource()
+		})
+		if found && kuadrant.IsInShadowMode(policy) {
+			source = shadowPolicySource{
+				kind:      policy.GroupVersionKind().Kind,
+				namespace: policy.GetNamespace(),
+				name:      policy.GetName(),
+			}
+		}
+		limit := shadowLimit{limitsNamespace: limitsNamespace, limitName: limitKey}
+		if existing, ok := b[limit]; ok && existing != source {
+			source = shadowPolicySource{}
+		}
+		b[limit] = source
+	}
+}
+
+func (b shadowLimitsBuilder) build() map[shadowLimit]shadowPolicySource {
+	return lo.PickBy(b, func(_ shadowLimit, source shadowPolicySource) bool {
+		return source != shadowPolicySource{}
+	})
+}
+
+// shadowAuthConfigsBuilder collects the AuthConfigs in Authorino that originate from policies in shadow mode, out of
+// the effective auth policies of the paths.
+// AuthConfigs that merge the rules of several policies are left out, for their responses cannot be told apart, as are
+// the ones that merge the rules of policies not in shadow mode, for they are enforced.
+type shadowAuthConfigsBuilder map[shadowAuthConfig]shadowPolicySource
+
+func (b shadowAuthConfigsBuilder) add(namespace, pathID string, effectivePolicy EffectiveAuthPolicy) {
+	policies := kuadrantv1.PoliciesInPath(effectivePolicy.Path, func(p machinery.Policy) bool {
+		return lo.Contains(effectivePolicy.SourcePolicies, p.GetLocator())
+	})
+	if len(policies) != 1 || !kuadrant.IsInShadowMode(policies[0]) {
+		return
+	}
+	b[shadowAuthConfig{namespace: namespace, name: AuthConfigNameForPath(pathID)}] = shadowPolicySource{
+		kind:      policies[0].GroupVersionKind().Kind,
+		namespace: policies[0].GetNamespace(),
+		name:      policies[0].GetName(),
+	}
+}
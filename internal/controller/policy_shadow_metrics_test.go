@@ -0,0 +1,143 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const limitadorMetrics = `# HELP limited_calls Limited calls
+# TYPE limited_calls counter
+limited_calls{limitador_namespace="default/toystore",limit_name="shadow-limit"} 7
+limited_calls{limitador_namespace="default/toystore",limit_name="other-shadow-limit"} 3
+limited_calls{limitador_namespace="default/toystore",limit_name="enforced-limit"} 11
+limited_calls{limitador_namespace="default/other",limit_name="shadow-limit"} 5
+`
+
+const authorinoMetrics = `# HELP auth_server_authconfig_response_status Response status of authconfigs sent by the auth server, partitioned by authconfig.
+# TYPE auth_server_authconfig_response_status counter
+auth_server_authconfig_response_status{authconfig="shadow-authconfig",namespace="kuadrant-system",status="OK"} 13
+auth_server_authconfig_response_status{authconfig="shadow-authconfig",namespace="kuadrant-system",status="PERMISSION_DENIED"} 2
+auth_server_authconfig_response_status{authconfig="shadow-authconfig",namespace="kuadrant-system",status="UNAUTHENTICATED"} 4
+auth_server_authconfig_response_status{authconfig="enforced-authconfig",namespace="kuadrant-system",status="UNAUTHENTICATED"} 17
+`
+
+func newShadowDenialsTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, limitadorMetrics)
+	})
+	mux.HandleFunc("/server-metrics", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, authorinoMetrics)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestShadowDenialsCollector(t *testing.T) {
+	server := newShadowDenialsTestServer()
+	defer server.Close()
+
+	collector := newShadowDenialsCollector(server.Client())
+
+	// nothing is reported before the collector knows about Limitador, Authorino and the policies in shadow mode
+	collector.scrape(context.Background())
+	if count := testutil.CollectAndCount(collector); count != 0 {
+		t.Errorf("expected no metrics, got %d", count)
+	}
+
+	shadowPolicy := shadowPolicySource{kind: "RateLimitPolicy", namespace: "default", name: "shadow-policy"}
+	collector.update(shadowDenialsTargets{
+		limitadorMetricsURL: server.URL + "/metrics",
+		limits: map[shadowLimit]shadowPolicySource{
+			{limitsNamespace: "default/toystore", limitName: "shadow-limit"}:       shadowPolicy,
+			{limitsNamespace: "default/toystore", limitName: "other-shadow-limit"}: shadowPolicy,
+			{limitsNamespace: "default/toystore", limitName: "unused-limit"}:       {kind: "TokenRateLimitPolicy", namespace: "default", name: "unused-policy"},
+		},
+		authorinoMetricsURL: server.URL + "/server-metrics",
+		authConfigs: map[shadowAuthConfig]shadowPolicySource{
+			{namespace: "kuadrant-system", name: "shadow-authconfig"}: {kind: "AuthPolicy", namespace: "default", name: "shadow-auth-policy"},
+		},
+	})
+
+	// the denials are only reported once scraped
+	if count := testutil.CollectAndCount(collector); count != 0 {
+		t.Errorf("expected no metrics, got %d", count)
+	}
+	collector.scrape(context.Background())
+
+	expected := `# HELP kuadrant_shadow_denials_total Number of requests that Kuadrant policies in shadow mode would have denied
+# TYPE kuadrant_shadow_denials_total counter
+kuadrant_shadow_denials_total{kind="AuthPolicy",name="shadow-auth-policy",namespace="default"} 6
+kuadrant_shadow_denials_total{kind="RateLimitPolicy",name="shadow-policy",namespace="default"} 10
+kuadrant_shadow_denials_total{kind="TokenRateLimitPolicy",name="unused-policy",namespace="default"} 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	// the denials last scraped are reported without reaching Limitador and Authorino
+	server.Close()
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestShadowDenialsCollectorUnavailable(t *testing.T) {
+	server := newShadowDenialsTestServer()
+	defer server.Close()
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	collector := newShadowDenialsCollector(server.Client())
+	targets := shadowDenialsTargets{
+		limitadorMetricsURL: server.URL + "/metrics",
+		limits: map[shadowLimit]shadowPolicySource{
+			{limitsNamespace: "default/toystore", limitName: "shadow-limit"}: {kind: "RateLimitPolicy", namespace: "default", name: "shadow-policy"},
+		},
+		authorinoMetricsURL: unavailable.URL + "/server-metrics",
+		authConfigs: map[shadowAuthConfig]shadowPolicySource{
+			{namespace: "kuadrant-system", name: "shadow-authconfig"}: {kind: "AuthPolicy", namespace: "default", name: "shadow-auth-policy"},
+		},
+	}
+	collector.update(targets)
+	collector.scrape(context.Background())
+
+	// the denials of the policies whose metrics cannot be scraped are not reported
+	expected := `# HELP kuadrant_shadow_denials_total Number of requests that Kuadrant policies in shadow mode would have denied
+# TYPE kuadrant_shadow_denials_total counter
+kuadrant_shadow_denials_total{kind="RateLimitPolicy",name="shadow-policy",namespace="default"} 7
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	targets.limitadorMetricsURL = unavailable.URL + "/metrics"
+	collector.update(targets)
+	collector.scrape(context.Background())
+	if count := testutil.CollectAndCount(collector); count != 0 {
+		t.Errorf("expected no metrics, got %d", count)
+	}
+}
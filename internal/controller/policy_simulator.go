@@ -0,0 +1,430 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/utils/env"
+	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	celvalidator "github.com/kuadrant/kuadrant-operator/internal/cel"
+	kuadrantgatewayapi "github.com/kuadrant/kuadrant-operator/internal/gatewayapi"
+	kuadrantpolicymachinery "github.com/kuadrant/kuadrant-operator/internal/policymachinery"
+	"github.com/kuadrant/kuadrant-operator/internal/wasm"
+)
+
+const (
+	// PolicySimulationPath is the path where the policy simulation endpoint is served.
+	// Requests to the endpoint require a bearer token of a user allowed to post to the path (see NewPolicySimulationServer).
+	PolicySimulationPath = "/simulate"
+
+	defaultPolicySimulationPort = 8443
+)
+
+// SimulationRequest is a synthetic request to be evaluated against the effective policies
+type SimulationRequest struct {
+	// Gateway restricts the simulation to a gateway (namespace/name). All gateways are simulated if empty.
+	Gateway string `json:"gateway,omitempty"`
+
+	Host    string            `json:"host"`
+	Path    string            `json:"path"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Auth is the auth identity and metadata as exposed to the rate limit predicates after authentication
+	Auth map[string]any `json:"auth,omitempty"`
+}
+
+// SimulationResult reports what would happen to a simulated request in each gateway
+type SimulationResult struct {
+	Gateways []GatewaySimulationResult `json:"gateways"`
+}
+
+// GatewaySimulationResult reports the action set that would match a simulated request in a gateway, and the
+// AuthConfigs and Limitador limits that would be hit
+type GatewaySimulationResult struct {
+	Gateway     string                `json:"gateway"`
+	ActionSet   string                `json:"actionSet"`
+	Route       string                `json:"route"`
+	AuthConfigs []SimulatedAuthConfig `json:"authConfigs,omitempty"`
+	Limits      []SimulatedLimit      `json:"limits,omitempty"`
+}
+
+// SimulatedAuthConfig is an AuthConfig that would be called for a simulated request
+type SimulatedAuthConfig struct {
+	Name           string   `json:"name"`
+	SourcePolicies []string `json:"sourcePolicies"`
+	Shadow         bool     `json:"shadow,omitempty"`
+	// Error tells why the predicates could not be evaluated, in which case the AuthConfig may or may not be called
+	Error string `json:"error,omitempty"`
+}
+
+// SimulatedLimit is a Limitador limit that would be hit by a simulated request
+type SimulatedLimit struct {
+	Namespace      string   `json:"namespace"`
+	Identifier     string   `json:"identifier"`
+	Service        string   `json:"service"`
+	SourcePolicies []string `json:"sourcePolicies"`
+	Shadow         bool     `json:"shadow,omitempty"`
+	// Error tells why the predicates could not be evaluated, in which case the limit may or may not be hit
+	Error string `json:"error,omitempty"`
+}
+
+type simulatedActionSet struct {
+	gateway   k8stypes.NamespacedName
+	actionSet wasm.ActionSet
+}
+
+// PolicySimulator keeps the action sets built from the effective policies of the last reconciliation and evaluates
+// synthetic requests against them
+type PolicySimulator struct {
+	mutex      sync.RWMutex
+	actionSets []simulatedActionSet
+	synced     bool
+}
+
+func NewPolicySimulator() *PolicySimulator {
+	return &PolicySimulator{}
+}
+
+// Reconcile stores the action sets of all gateways, built from the effective policies in the state.
+// Unlike the ones sent to the data plane, the actions are not merged, so each of them keeps its source policy.
+func (s *PolicySimulator) Reconcile(ctx context.Context, _ []controller.ResourceEvent, _ *machinery.Topology, _ error, state *sync.Map) error {
+	logger := controller.LoggerFromContext(ctx).WithName("PolicySimulator").WithValues("context", ctx)
+
+	var effectiveAuthPolicies EffectiveAuthPolicies
+	if policies, ok := state.Load(StateEffectiveAuthPolicies); ok {
+		effectiveAuthPolicies = policies.(EffectiveAuthPolicies)
+	}
+	var effectiveRateLimitPolicies EffectiveRateLimitPolicies
+	if policies, ok := state.Load(StateEffectiveRateLimitPolicies); ok {
+		effectiveRateLimitPolicies = policies.(EffectiveRateLimitPolicies)
+	}
+	var effectiveTokenRateLimitPolicies EffectiveTokenRateLimitPolicies
+	if policies, ok := state.Load(StateEffectiveTokenRateLimitPolicies); ok {
+		effectiveTokenRateLimitPolicies = policies.(EffectiveTokenRateLimitPolicies)
+	}
+
+	paths := lo.Assign(
+		lo.MapValues(effectiveAuthPolicies, func(p EffectiveAuthPolicy, _ string) []machinery.Targetable { return p.Path }),
+		lo.MapValues(effectiveRateLimitPolicies, func(p EffectiveRateLimitPolicy, _ string) []machinery.Targetable { return p.Path }),
+		lo.MapValues(effectiveTokenRateLimitPolicies, func(p EffectiveTokenRateLimitPolicy, _ string) []machinery.Targetable { return p.Path }),
+	)
+
+	grouppedActionSets := kuadrantgatewayapi.GrouppedHTTPRouteMatchConfigs{}
+	gateways := make(map[string]k8stypes.NamespacedName)
+
+	for pathID, path := range paths {
+		parsed, err := kuadrantpolicymachinery.ParseTopologyPath(path)
+		if err != nil {
+			continue
+		}
+
+		var actions []wasm.Action
+		if effectivePolicy, ok := effectiveAuthPolicies[pathID]; ok {
+			actions = append(actions, buildWasmActionsForAuth(pathID, effectivePolicy)...)
+		}
+		if effectivePolicy, ok := effectiveRateLimitPolicies[pathID]; ok {
+			rlActions := buildWasmActionsForRateLimit(effectivePolicy, isRateLimitPolicyAcceptedAndNotDeletedFunc(state))
+			if hasAuthAccess(rlActions) {
+				actions = append(actions, rlActions...)
+			} else {
+				actions = append(rlActions, actions...)
+			}
+		}
+		if effectivePolicy, ok := effectiveTokenRateLimitPolicies[pathID]; ok {
+			trlActions := buildWasmActionsForTokenRateLimit(effectivePolicy, isTokenRateLimitPolicyAcceptedAndNotDeletedFunc(state))
+			if hasAuthAccess(trlActions) {
+				actions = append(actions, trlActions...)
+			} else {
+				actions = append(trlActions, actions...)
+			}
+		}
+		if len(actions) == 0 {
+			continue
+		}
+
+		actionSets, err := wasm.BuildActionSetsForPath(ctx, pathID, path, actions)
+		if err != nil {
+			logger.V(1).Info("skipping path", "pathID", pathID, "error", err.Error())
+			continue
+		}
+		gatewayLocator := parsed.Gateway.GetLocator()
+		gateways[gatewayLocator] = k8stypes.NamespacedName{Namespace: parsed.Gateway.GetNamespace(), Name: parsed.Gateway.GetName()}
+		grouppedActionSets.Add(gatewayLocator, actionSets...)
+	}
+
+	var simulatedActionSets []simulatedActionSet
+	sorted := grouppedActionSets.Sorted()
+	gatewayLocators := lo.Keys(sorted)
+	sort.Strings(gatewayLocators)
+	for _, gatewayLocator := range gatewayLocators {
+		for _, config := range sorted[gatewayLocator] {
+			simulatedActionSets = append(simulatedActionSets, simulatedActionSet{
+				gateway:   gateways[gatewayLocator],
+				actionSet: config.Config.(wasm.ActionSet),
+			})
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.actionSets = simulatedActionSets
+	s.synced = true
+
+	return nil
+}
+
+// Simulate evaluates a synthetic request against the action sets of the gateways.
+// In each gateway, the first action set whose hostname and route rule predicates match the request is selected, as
+// the data plane does, and its actions are evaluated to find out the AuthConfigs and Limitador limits that would be hit.
+func (s *PolicySimulator) Simulate(request SimulationRequest) (*SimulationResult, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	validator, err := simulationValidator()
+	if err != nil {
+		return nil, err
+	}
+	activation := request.activation()
+
+	result := &SimulationResult{Gateways: make([]GatewaySimulationResult, 0)}
+	for _, simulated := range s.actionSets {
+		gateway := simulated.gateway.String()
+		if request.Gateway != "" && request.Gateway != gateway {
+			continue
+		}
+		if lo.ContainsBy(result.Gateways, func(g GatewaySimulationResult) bool { return g.Gateway == gateway }) {
+			continue // an action set has already been selected for this gateway
+		}
+
+		actionSet := simulated.actionSet
+		if !lo.SomeBy(actionSet.RouteRuleConditions.Hostnames, func(hostname string) bool { return hostnameMatches(hostname, request.Host) }) {
+			continue
+		}
+		matches, err := celvalidator.EvaluatePredicates(validator, celvalidator.AuthPolicyKind, actionSet.RouteRuleConditions.Predicates, activation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate route rule conditions of action set %s: %w", actionSet.Name, err)
+		}
+		if !matches {
+			continue
+		}
+
+		result.Gateways = append(result.Gateways, simulateActionSet(gateway, actionSet, validator, activation))
+	}
+
+	return result, nil
+}
+
+func simulateActionSet(gateway string, actionSet wasm.ActionSet, validator *celvalidator.Validator, activation map[string]any) GatewaySimulationResult {
+	result := GatewaySimulationResult{
+		Gateway:   gateway,
+		ActionSet: actionSet.Name,
+		Route:     actionSet.SourceRoute,
+	}
+
+	for _, action := range actionSet.Actions {
+		applies, err := celvalidator.EvaluateWasmActionPredicates(action, action.Predicates, validator, activation)
+		if err == nil && !applies {
+			continue
+		}
+
+		if action.ServiceName == wasm.AuthServiceName {
+			result.AuthConfigs = append(result.AuthConfigs, SimulatedAuthConfig{
+				Name:           action.Scope,
+				SourcePolicies: action.SourcePolicyLocators,
+				Shadow:         action.ReportOnly,
+				Error:          errorString(err),
+			})
+			continue
+		}
+
+		for _, conditionalData := range action.ConditionalData {
+			dataErr := err
+			if dataErr == nil {
+				var hits bool
+				if hits, dataErr = celvalidator.EvaluateWasmActionPredicates(action, conditionalData.Predicates, validator, activation); dataErr == nil && !hits {
+					continue
+				}
+			}
+			for _, identifier := range limitIdentifiersFromData(conditionalData.Data) {
+				if lo.ContainsBy(result.Limits, func(l SimulatedLimit) bool {
+					return l.Namespace == action.Scope && l.Identifier == identifier && l.Service == action.ServiceName
+				}) {
+					continue
+				}
+				result.Limits = append(result.Limits, SimulatedLimit{
+					Namespace:      action.Scope,
+					Identifier:     identifier,
+					Service:        action.ServiceName,
+					SourcePolicies: action.SourcePolicyLocators,
+					Shadow:         action.ReportOnly,
+					Error:          errorString(dataErr),
+				})
+			}
+		}
+	}
+
+	return result
+}
+
+// limitIdentifiersFromData returns the identifiers of the limits activated by the data of a rate limit action
+func limitIdentifiersFromData(data []wasm.DataType) []string {
+	return lo.FilterMap(data, func(d wasm.DataType, _ int) (string, bool) {
+		expression, ok := d.Value.(*wasm.Expression)
+		if !ok || expression.ExpressionItem.Value != "1" {
+			return "", false
+		}
+		key := expression.ExpressionItem.Key
+		return key, strings.HasPrefix(key, "limit.") || strings.HasPrefix(key, "tokenlimit.")
+	})
+}
+
+// activation builds the CEL attributes of the simulated request
+func (r SimulationRequest) activation() map[string]any {
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	urlPath, query, _ := strings.Cut(r.Path, "?")
+	headers := map[string]any{}
+	for name, value := range r.Headers {
+		headers[strings.ToLower(name)] = value
+	}
+	headers[":authority"] = r.Host
+	headers[":path"] = r.Path
+	headers[":method"] = method
+
+	activation := map[string]any{
+		"request": map[string]any{
+			"host":     r.Host,
+			"path":     r.Path,
+			"url_path": urlPath,
+			"query":    query,
+			"method":   method,
+			"headers":  headers,
+			"time":     time.Now(),
+		},
+		"source":      map[string]any{},
+		"destination": map[string]any{},
+		"connection":  map[string]any{},
+	}
+	if r.Auth != nil {
+		activation[celvalidator.AuthPolicyName] = r.Auth
+	}
+	return activation
+}
+
+func simulationValidator() (*celvalidator.Validator, error) {
+	builder := celvalidator.NewRootValidatorBuilder()
+	builder.AddFunction("queryMap", cel.Overload("query_map_string",
+		[]*cel.Type{cel.StringType},
+		cel.MapType(cel.StringType, cel.StringType),
+		cel.UnaryBinding(func(query ref.Val) ref.Val {
+			values, err := url.ParseQuery(fmt.Sprintf("%v", query.Value()))
+			if err != nil {
+				return types.NewErr("invalid query: %v", err)
+			}
+			return types.DefaultTypeAdapter.NativeToValue(lo.MapValues(values, func(v []string, _ string) string { return v[0] }))
+		}),
+	))
+	builder.PushPolicyBinding(celvalidator.AuthPolicyKind, celvalidator.AuthPolicyName, cel.AnyType)
+	builder.PushPolicyBinding(celvalidator.RateLimitPolicyKind, celvalidator.RateLimitName, cel.AnyType)
+	builder.PushPolicyBinding(celvalidator.TokenRateLimitPolicyKind, celvalidator.RateLimitName, cel.AnyType)
+	return builder.Build()
+}
+
+// hostnameMatches tells whether a hostname of an action set, possibly a wildcard, matches the host of a request
+func hostnameMatches(hostname, host string) bool {
+	host, _, _ = strings.Cut(host, ":")
+	if hostname == "*" || hostname == host {
+		return true
+	}
+	if suffix, wildcard := strings.CutPrefix(hostname, "*"); wildcard {
+		return strings.HasSuffix(host, suffix)
+	}
+	return false
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// ServeHTTP handles simulation requests: POST a SimulationRequest as JSON to get a SimulationResult
+func (s *PolicySimulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mutex.RLock()
+	synced := s.synced
+	s.mutex.RUnlock()
+	if !synced {
+		http.Error(w, "policies not reconciled yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	var request SimulationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("invalid simulation request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if request.Host == "" || request.Path == "" {
+		http.Error(w, "invalid simulation request: host and path are required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.Simulate(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// NewPolicySimulationServer returns the server of the policy simulation endpoint, on a dedicated port.
+// It is served over TLS, with the certificate in POLICY_SIMULATION_CERT_DIR or a self-signed one, and the
+// authentication and authorization of the requests are delegated to the Kubernetes API: the bearer token of the
+// request is reviewed, and the authenticated user must be allowed to perform the verb of the request on the path as a
+// non-resource URL, e.g.:
+//
+//	rules:
+//	- nonResourceURLs: ["/simulate"]
+//	  verbs: ["post"]
+//
+// The reviews are cached, and the metrics of the operator are served at /metrics with the same protection.
+func NewPolicySimulationServer(config *rest.Config, httpClient *http.Client, simulator http.Handler) (metricsserver.Server, error) {
+	port, err := env.GetInt("POLICY_SIMULATION_PORT", defaultPolicySimulationPort)
+	if err != nil {
+		return nil, fmt.Errorf("POLICY_SIMULATION_PORT env value could not be parsed as int: %w", err)
+	}
+	return metricsserver.NewServer(metricsserver.Options{
+		BindAddress:    fmt.Sprintf(":%d", port),
+		SecureServing:  true,
+		CertDir:        env.GetString("POLICY_SIMULATION_CERT_DIR", ""),
+		FilterProvider: filters.WithAuthenticationAndAuthorization,
+		ExtraHandlers:  map[string]http.Handler{PolicySimulationPath: simulator},
+	}, config, httpClient)
+}
@@ -0,0 +1,207 @@
+//go:build unit
+
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+
+	"github.com/kuadrant/kuadrant-operator/internal/wasm"
+)
+
+func TestPolicySimulatorSimulate(t *testing.T) {
+	gateway := k8stypes.NamespacedName{Namespace: "gateway-system", Name: "my-gateway"}
+
+	limitData := func(identifier string) []wasm.DataType {
+		return []wasm.DataType{{Value: &wasm.Expression{ExpressionItem: wasm.ExpressionItem{Key: identifier, Value: "1"}}}}
+	}
+
+	simulator := NewPolicySimulator()
+	simulator.synced = true
+	simulator.actionSets = []simulatedActionSet{
+		{
+			gateway: gateway,
+			actionSet: wasm.ActionSet{
+				Name:        "toys-get",
+				SourceRoute: "HTTPRoute/my-ns/toys",
+				RouteRuleConditions: wasm.RouteRuleConditions{
+					Hostnames:  []string{"*.toystore.com"},
+					Predicates: []string{"request.method == 'GET'", "request.url_path.startsWith('/toys')"},
+				},
+				Actions: []wasm.Action{
+					{
+						ServiceName:          wasm.AuthServiceName,
+						Scope:                "my-authconfig",
+						SourcePolicyLocators: []string{"kuadrant.io/v1/authpolicy:my-ns/toys-auth"},
+					},
+					{
+						ServiceName:          wasm.RateLimitServiceName,
+						Scope:                "my-ns/toys",
+						SourcePolicyLocators: []string{"kuadrant.io/v1/ratelimitpolicy:gateway-system/gw-rlp"},
+						ConditionalData: []wasm.ConditionalData{
+							{Data: limitData("limit.global__1234abcd")},
+						},
+					},
+					{
+						ServiceName:          wasm.RateLimitServiceName,
+						Scope:                "my-ns/toys",
+						SourcePolicyLocators: []string{"kuadrant.io/v1/ratelimitpolicy:my-ns/toys-rlp"},
+						ConditionalData: []wasm.ConditionalData{
+							{
+								Predicates: []string{`auth.identity.group == "free"`},
+								Data:       limitData("limit.free__abcd1234"),
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			gateway: gateway,
+			actionSet: wasm.ActionSet{
+				Name:        "toys-catch-all",
+				SourceRoute: "HTTPRoute/my-ns/toys",
+				RouteRuleConditions: wasm.RouteRuleConditions{
+					Hostnames: []string{"*.toystore.com"},
+				},
+				Actions: []wasm.Action{
+					{
+						ServiceName:          wasm.AuthServiceName,
+						Scope:                "my-other-authconfig",
+						SourcePolicyLocators: []string{"kuadrant.io/v1/authpolicy:gateway-system/gw-auth"},
+					},
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		request  SimulationRequest
+		expected *SimulationResult
+	}{
+		{
+			name:     "no matching hostname",
+			request:  SimulationRequest{Host: "api.other.com", Path: "/toys"},
+			expected: &SimulationResult{Gateways: []GatewaySimulationResult{}},
+		},
+		{
+			name:    "first matching action set wins",
+			request: SimulationRequest{Host: "api.toystore.com", Path: "/toys?page=2", Auth: map[string]any{"identity": map[string]any{"group": "free"}}},
+			expected: &SimulationResult{Gateways: []GatewaySimulationResult{
+				{
+					Gateway:     "gateway-system/my-gateway",
+					ActionSet:   "toys-get",
+					Route:       "HTTPRoute/my-ns/toys",
+					AuthConfigs: []SimulatedAuthConfig{{Name: "my-authconfig", SourcePolicies: []string{"kuadrant.io/v1/authpolicy:my-ns/toys-auth"}}},
+					Limits: []SimulatedLimit{
+						{Namespace: "my-ns/toys", Identifier: "limit.global__1234abcd", Service: wasm.RateLimitServiceName, SourcePolicies: []string{"kuadrant.io/v1/ratelimitpolicy:gateway-system/gw-rlp"}},
+						{Namespace: "my-ns/toys", Identifier: "limit.free__abcd1234", Service: wasm.RateLimitServiceName, SourcePolicies: []string{"kuadrant.io/v1/ratelimitpolicy:my-ns/toys-rlp"}},
+					},
+				},
+			}},
+		},
+		{
+			name:    "limit not hit",
+			request: SimulationRequest{Host: "api.toystore.com", Path: "/toys", Auth: map[string]any{"identity": map[string]any{"group": "gold"}}},
+			expected: &SimulationResult{Gateways: []GatewaySimulationResult{
+				{
+					Gateway:     "gateway-system/my-gateway",
+					ActionSet:   "toys-get",
+					Route:       "HTTPRoute/my-ns/toys",
+					AuthConfigs: []SimulatedAuthConfig{{Name: "my-authconfig", SourcePolicies: []string{"kuadrant.io/v1/authpolicy:my-ns/toys-auth"}}},
+					Limits: []SimulatedLimit{
+						{Namespace: "my-ns/toys", Identifier: "limit.global__1234abcd", Service: wasm.RateLimitServiceName, SourcePolicies: []string{"kuadrant.io/v1/ratelimitpolicy:gateway-system/gw-rlp"}},
+					},
+				},
+			}},
+		},
+		{
+			name:    "predicates that cannot be evaluated are reported",
+			request: SimulationRequest{Host: "api.toystore.com", Path: "/toys"},
+			expected: &SimulationResult{Gateways: []GatewaySimulationResult{
+				{
+					Gateway:     "gateway-system/my-gateway",
+					ActionSet:   "toys-get",
+					Route:       "HTTPRoute/my-ns/toys",
+					AuthConfigs: []SimulatedAuthConfig{{Name: "my-authconfig", SourcePolicies: []string{"kuadrant.io/v1/authpolicy:my-ns/toys-auth"}}},
+					Limits: []SimulatedLimit{
+						{Namespace: "my-ns/toys", Identifier: "limit.global__1234abcd", Service: wasm.RateLimitServiceName, SourcePolicies: []string{"kuadrant.io/v1/ratelimitpolicy:gateway-system/gw-rlp"}},
+						{Namespace: "my-ns/toys", Identifier: "limit.free__abcd1234", Service: wasm.RateLimitServiceName, SourcePolicies: []string{"kuadrant.io/v1/ratelimitpolicy:my-ns/toys-rlp"}, Error: `failed to evaluate predicate "auth.identity.group == \"free\"": no such attribute(s): auth`},
+					},
+				},
+			}},
+		},
+		{
+			name:    "fall through to the next action set",
+			request: SimulationRequest{Host: "api.toystore.com", Path: "/toys", Method: "DELETE"},
+			expected: &SimulationResult{Gateways: []GatewaySimulationResult{
+				{
+					Gateway:     "gateway-system/my-gateway",
+					ActionSet:   "toys-catch-all",
+					Route:       "HTTPRoute/my-ns/toys",
+					AuthConfigs: []SimulatedAuthConfig{{Name: "my-other-authconfig", SourcePolicies: []string{"kuadrant.io/v1/authpolicy:gateway-system/gw-auth"}}},
+				},
+			}},
+		},
+		{
+			name:     "other gateway",
+			request:  SimulationRequest{Gateway: "gateway-system/other-gateway", Host: "api.toystore.com", Path: "/toys"},
+			expected: &SimulationResult{Gateways: []GatewaySimulationResult{}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := simulator.Simulate(tc.request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, result); diff != "" {
+				t.Errorf("unexpected simulation result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHostnameMatches(t *testing.T) {
+	testCases := []struct {
+		hostname string
+		host     string
+		expected bool
+	}{
+		{hostname: "api.toystore.com", host: "api.toystore.com", expected: true},
+		{hostname: "api.toystore.com", host: "api.toystore.com:8080", expected: true},
+		{hostname: "*.toystore.com", host: "api.toystore.com", expected: true},
+		{hostname: "*.toystore.com", host: "toystore.com", expected: false},
+		{hostname: "*", host: "anything.com", expected: true},
+		{hostname: "api.toystore.com", host: "other.toystore.com", expected: false},
+	}
+
+	for _, tc := range testCases {
+		if actual := hostnameMatches(tc.hostname, tc.host); actual != tc.expected {
+			t.Errorf("hostnameMatches(%q, %q) = %v, expected %v", tc.hostname, tc.host, actual, tc.expected)
+		}
+	}
+}
+
+func TestNewPolicySimulationServer(t *testing.T) {
+	simulator := NewPolicySimulator()
+
+	server, err := NewPolicySimulationServer(&rest.Config{}, http.DefaultClient, simulator)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if server == nil {
+		t.Fatal("expected a server")
+	}
+
+	t.Setenv("POLICY_SIMULATION_PORT", "not-a-port")
+	if _, err := NewPolicySimulationServer(&rest.Config{}, http.DefaultClient, simulator); err == nil {
+		t.Error("expected an error for an invalid port")
+	}
+}
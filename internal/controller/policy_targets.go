@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"slices"
+
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
+	"github.com/kuadrant/kuadrant-operator/internal/kuadrant"
+)
+
+type multiTargetPolicy interface {
+	machinery.Policy
+	kuadrant.Policy
+}
+
+// policyTargetNotFoundError returns the error reported when the object referred by a target reference of a policy does not exist
+func policyTargetNotFoundError(policyKind string, ref machinery.PolicyTargetReference) error {
+	var res schema.GroupResource
+	switch ref.GroupVersionKind().Kind {
+	case machinery.GatewayGroupKind.Kind:
+		res = controller.GatewaysResource.GroupResource()
+	case machinery.HTTPRouteGroupKind.Kind:
+		res = controller.HTTPRoutesResource.GroupResource()
+	case machinery.GRPCRouteGroupKind.Kind:
+		res = controller.GRPCRoutesResource.GroupResource()
+	}
+	return kuadrant.NewErrPolicyTargetNotFound(policyKind, ref, apierrors.NewNotFound(res, ref.GetName()))
+}
+
+// policyTargetStatuses reports the Accepted condition of a policy for each of its targets, telling whether the target
+// exists and the policy is attached to it. The conditions in the current statuses are reused to keep their transition times.
+func policyTargetStatuses(policy multiTargetPolicy, topology *machinery.Topology, current []kuadrantv1.PolicyTargetStatus) []kuadrantv1.PolicyTargetStatus {
+	attachedTargets := lo.SliceToMap(topology.Targetables().Children(policy), func(t machinery.Targetable) (string, struct{}) {
+		return t.GetLocator(), struct{}{}
+	})
+
+	return lo.FilterMap(policy.GetTargetRefs(), func(ref machinery.PolicyTargetReference, _ int) (kuadrantv1.PolicyTargetStatus, bool) {
+		targetRef, ok := ref.(machinery.LocalPolicyTargetReferenceWithSectionName)
+		if !ok {
+			return kuadrantv1.PolicyTargetStatus{}, false
+		}
+
+		targetStatus := kuadrantv1.PolicyTargetStatus{TargetRef: targetRef.LocalPolicyTargetReferenceWithSectionName}
+		if currentTargetStatus, found := lo.Find(current, func(s kuadrantv1.PolicyTargetStatus) bool {
+			return equality.Semantic.DeepEqual(s.TargetRef, targetStatus.TargetRef)
+		}); found {
+			targetStatus.Conditions = slices.Clone(currentTargetStatus.Conditions)
+		}
+
+		var err error
+		if _, attached := attachedTargets[ref.GetLocator()]; !attached {
+			err = policyTargetNotFoundError(policy.Kind(), ref)
+		}
+		meta.SetStatusCondition(&targetStatus.Conditions, *kuadrant.AcceptedCondition(policy, err))
+
+		return targetStatus, true
+	})
+}
+
+// isPolicyTargetNotFound tells whether none of the targets of a policy exists
+func isPolicyTargetNotFound(policy machinery.Policy, topology *machinery.Topology) bool {
+	return len(policy.GetTargetRefs()) > 0 && len(topology.Targetables().Children(policy)) == 0
+}
+
+// targetRefsOverlap tells whether two policies share at least one target
+func targetRefsOverlap(a, b machinery.Policy) bool {
+	locators := lo.Map(b.GetTargetRefs(), func(ref machinery.PolicyTargetReference, _ int) string { return ref.GetLocator() })
+	return lo.SomeBy(a.GetTargetRefs(), func(ref machinery.PolicyTargetReference) bool {
+		return lo.Contains(locators, ref.GetLocator())
+	})
+}
+
+// namespaceLabelsFunc returns a function that looks up the labels of a namespace in the topology by its name
+func namespaceLabelsFunc(topology *machinery.Topology) func(string) map[string]string {
+	namespaces := lo.SliceToMap(topology.Objects().Items(func(o machinery.Object) bool {
+		return o.GroupVersionKind().GroupKind() == NamespaceGroupKind
+	}), func(o machinery.Object) (string, map[string]string) {
+		var namespaceLabels map[string]string
+		if namespace, ok := o.(*controller.RuntimeObject); ok {
+			namespaceLabels = namespace.GetLabels()
+		}
+		return o.GetName(), namespaceLabels
+	})
+	return func(name string) map[string]string {
+		return namespaces[name]
+	}
+}
+
+// policySelectsPathFunc restricts a policy predicate to the policies whose route and namespace selectors select a path
+func policySelectsPathFunc(predicate func(machinery.Policy) bool, path []machinery.Targetable, namespaceLabels func(string) map[string]string) func(machinery.Policy) bool {
+	return func(policy machinery.Policy) bool {
+		return predicate(policy) && kuadrantv1.PolicySelectsPath(policy, path, namespaceLabels)
+	}
+}
@@ -0,0 +1,163 @@
+//go:build unit
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
+)
+
+func gatewayTargetRef(name string) gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName {
+	return gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName{
+		LocalPolicyTargetReference: gatewayapiv1alpha2.LocalPolicyTargetReference{
+			Group: gatewayapiv1.GroupName,
+			Kind:  "Gateway",
+			Name:  gatewayapiv1.ObjectName(name),
+		},
+	}
+}
+
+func TestPolicyTargetStatuses(t *testing.T) {
+	gatewayClass := &gatewayapiv1.GatewayClass{
+		TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.SchemeGroupVersion.String(), Kind: "GatewayClass"},
+		ObjectMeta: metav1.ObjectMeta{Name: "my-gateway-class"},
+	}
+	gateway := &gatewayapiv1.Gateway{
+		TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.SchemeGroupVersion.String(), Kind: "Gateway"},
+		ObjectMeta: metav1.ObjectMeta{Name: "my-gateway", Namespace: "my-ns"},
+		Spec:       gatewayapiv1.GatewaySpec{GatewayClassName: "my-gateway-class"},
+	}
+	policy := &kuadrantv1.DNSPolicy{
+		TypeMeta:   metav1.TypeMeta{APIVersion: kuadrantv1.GroupVersion.String(), Kind: kuadrantv1.DNSPolicyGroupKind.Kind},
+		ObjectMeta: metav1.ObjectMeta{Name: "my-dns-policy", Namespace: "my-ns"},
+		Spec: kuadrantv1.DNSPolicySpec{
+			TargetRefs: []gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName{gatewayTargetRef("my-gateway"), gatewayTargetRef("missing-gateway")},
+		},
+	}
+
+	topology, err := machinery.NewGatewayAPITopology(
+		machinery.WithGatewayClasses(gatewayClass),
+		machinery.WithGateways(gateway),
+		machinery.WithGatewayAPITopologyPolicies(policy),
+	)
+	if err != nil {
+		t.Fatalf("failed to create topology: %v", err)
+	}
+
+	if isPolicyTargetNotFound(policy, topology) {
+		t.Error("expected the policy to be attached to at least one target")
+	}
+
+	statuses := policyTargetStatuses(policy, topology, nil)
+	if len(statuses) != 2 {
+		t.Fatalf("expected 2 target statuses, got %d", len(statuses))
+	}
+	if statuses[0].TargetRef.Name != "my-gateway" || !meta.IsStatusConditionTrue(statuses[0].Conditions, string(gatewayapiv1alpha2.PolicyConditionAccepted)) {
+		t.Errorf("expected the policy to be accepted for my-gateway, got %+v", statuses[0])
+	}
+	condition := meta.FindStatusCondition(statuses[1].Conditions, string(gatewayapiv1alpha2.PolicyConditionAccepted))
+	if statuses[1].TargetRef.Name != "missing-gateway" || condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != string(gatewayapiv1alpha2.PolicyReasonTargetNotFound) {
+		t.Errorf("expected the policy not to be accepted for missing-gateway, got %+v", statuses[1])
+	}
+
+	// the transition time of conditions that did not change is kept
+	statuses[0].Conditions[0].LastTransitionTime = metav1.Unix(0, 0)
+	if updated := policyTargetStatuses(policy, topology, statuses); !updated[0].Conditions[0].LastTransitionTime.Equal(&statuses[0].Conditions[0].LastTransitionTime) {
+		t.Error("expected the transition time of an unchanged condition to be kept")
+	}
+
+	policy.Spec.TargetRefs = []gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName{gatewayTargetRef("missing-gateway")}
+	topology, err = machinery.NewGatewayAPITopology(
+		machinery.WithGatewayClasses(gatewayClass),
+		machinery.WithGateways(gateway),
+		machinery.WithGatewayAPITopologyPolicies(policy),
+	)
+	if err != nil {
+		t.Fatalf("failed to create topology: %v", err)
+	}
+	if !isPolicyTargetNotFound(policy, topology) {
+		t.Error("expected none of the targets of the policy to be found")
+	}
+}
+
+func TestTargetRefsOverlap(t *testing.T) {
+	policy := func(name string, targetRefs ...gatewayapiv1alpha2.LocalPolicyTargetReferenceWithSectionName) *kuadrantv1.TLSPolicy {
+		return &kuadrantv1.TLSPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "my-ns"},
+			Spec:       kuadrantv1.TLSPolicySpec{TargetRefs: targetRefs},
+		}
+	}
+
+	a := policy("a", gatewayTargetRef("gw-1"), gatewayTargetRef("gw-2"))
+	b := policy("b", gatewayTargetRef("gw-2"))
+	c := policy("c", gatewayTargetRef("gw-3"))
+	d := &kuadrantv1.TLSPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "d", Namespace: "my-ns"},
+		Spec:       kuadrantv1.TLSPolicySpec{TargetRef: gatewayTargetRef("gw-1")},
+	}
+
+	if !targetRefsOverlap(a, b) {
+		t.Error("expected policies sharing a target to overlap")
+	}
+	if targetRefsOverlap(a, c) {
+		t.Error("expected policies with disjoint targets not to overlap")
+	}
+	if !targetRefsOverlap(d, a) {
+		t.Error("expected a policy with a single targetRef to overlap with a policy listing the same target")
+	}
+}
+
+func TestPolicySelectsPathFunc(t *testing.T) {
+	namespace := &corev1.Namespace{
+		TypeMeta:   metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Namespace"},
+		ObjectMeta: metav1.ObjectMeta{Name: "my-ns", Labels: map[string]string{"env": "prod"}},
+	}
+	topology, err := machinery.NewGatewayAPITopology(
+		machinery.WithGatewayAPITopologyObjects(&controller.RuntimeObject{Object: namespace}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create topology: %v", err)
+	}
+
+	namespaceLabels := namespaceLabelsFunc(topology)
+	if labels := namespaceLabels("my-ns"); labels["env"] != "prod" {
+		t.Errorf("expected the labels of the namespace, got %v", labels)
+	}
+	if labels := namespaceLabels("other-ns"); labels != nil {
+		t.Errorf("expected no labels for an unknown namespace, got %v", labels)
+	}
+
+	path := []machinery.Targetable{
+		&machinery.HTTPRoute{HTTPRoute: &gatewayapiv1.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "my-route", Namespace: "my-ns"}}},
+	}
+	policy := func(env string) *kuadrantv1.AuthPolicy {
+		return &kuadrantv1.AuthPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-policy", Namespace: "my-ns"},
+			Spec: kuadrantv1.AuthPolicySpec{
+				Overrides: &kuadrantv1.MergeableAuthPolicySpec{
+					PathSelectors: kuadrantv1.PathSelectors{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": env}}},
+				},
+			},
+		}
+	}
+	accepted := func(machinery.Policy) bool { return true }
+
+	if !policySelectsPathFunc(accepted, path, namespaceLabels)(policy("prod")) {
+		t.Error("expected the policy to select the path")
+	}
+	if policySelectsPathFunc(accepted, path, namespaceLabels)(policy("staging")) {
+		t.Error("expected the policy not to select the path")
+	}
+	if policySelectsPathFunc(func(machinery.Policy) bool { return false }, path, namespaceLabels)(policy("prod")) {
+		t.Error("expected the predicate to still apply")
+	}
+}
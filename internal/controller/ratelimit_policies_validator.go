@@ -8,8 +8,6 @@ import (
 	"github.com/kuadrant/policy-machinery/machinery"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/utils/ptr"
 
 	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
@@ -65,20 +63,14 @@ func (r *RateLimitPolicyValidator) Validate(ctx context.Context, _ []controller.
 			err = missingDepErr
 			span.RecordError(err)
 			span.SetStatus(codes.Error, "missing dependency")
-		} else if len(policy.GetTargetRefs()) > 0 && len(topology.Targetables().Children(policy)) == 0 {
-			ref := policy.GetTargetRefs()[0]
-			var res schema.GroupResource
-			switch ref.GroupVersionKind().Kind {
-			case machinery.GatewayGroupKind.Kind:
-				res = controller.GatewaysResource.GroupResource()
-			case machinery.HTTPRouteGroupKind.Kind:
-				res = controller.HTTPRoutesResource.GroupResource()
-			case machinery.GRPCRouteGroupKind.Kind:
-				res = controller.GRPCRoutesResource.GroupResource()
-			}
-			err = kuadrant.NewErrPolicyTargetNotFound(kuadrantv1.RateLimitPolicyGroupKind.Kind, ref, apierrors.NewNotFound(res, ref.GetName()))
+		} else if isPolicyTargetNotFound(policy, topology) {
+			err = policyTargetNotFoundError(kuadrantv1.RateLimitPolicyGroupKind.Kind, policy.GetTargetRefs()[0])
 			span.RecordError(err)
 			span.SetStatus(codes.Error, "target not found")
+		} else if validateErr := p.Validate(); validateErr != nil {
+			err = kuadrant.NewErrInvalid(kuadrantv1.RateLimitPolicyGroupKind.Kind, validateErr)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "validation failed")
 		} else {
 			span.AddEvent("policy validated successfully")
 			span.SetStatus(codes.Ok, "")
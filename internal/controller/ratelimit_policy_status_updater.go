@@ -8,7 +8,6 @@ import (
 	"strings"
 	"sync"
 
-	envoygatewayv1alpha1 "github.com/envoyproxy/gateway/api/v1alpha1"
 	"github.com/go-logr/logr"
 	limitadorv1alpha1 "github.com/kuadrant/limitador-operator/api/v1alpha1"
 	"github.com/kuadrant/policy-machinery/controller"
@@ -21,9 +20,6 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/utils/ptr"
-	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
-	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 
 	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
 	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
@@ -33,11 +29,11 @@ import (
 	kuadrantistio "github.com/kuadrant/kuadrant-operator/internal/istio"
 	"github.com/kuadrant/kuadrant-operator/internal/kuadrant"
 	kuadrantpolicymachinery "github.com/kuadrant/kuadrant-operator/internal/policymachinery"
-	"github.com/kuadrant/kuadrant-operator/internal/wasm"
 )
 
 type RateLimitPolicyStatusUpdater struct {
-	client *dynamic.DynamicClient
+	client           *dynamic.DynamicClient
+	gatewayProviders []GatewayProvider
 }
 
 // RateLimitPolicyStatusUpdater subscribe to events with potential impact on the status of RateLimitPolicy resources
@@ -50,11 +46,13 @@ func (r *RateLimitPolicyStatusUpdater) Subscription() controller.Subscription {
 			{Kind: &machinery.GatewayGroupKind},
 			{Kind: &machinery.HTTPRouteGroupKind},
 			{Kind: &machinery.GRPCRouteGroupKind},
+			{Kind: &NamespaceGroupKind},
 			{Kind: &kuadrantv1.RateLimitPolicyGroupKind},
 			{Kind: &kuadrantv1beta1.LimitadorGroupKind},
 			{Kind: &kuadrantistio.EnvoyFilterGroupKind},
 			{Kind: &kuadrantenvoygateway.EnvoyPatchPolicyGroupKind},
 			{Kind: &kuadrantenvoygateway.EnvoyExtensionPolicyGroupKind},
+			{Kind: &ConfigMapGroupKind},
 		},
 	}
 }
@@ -98,13 +96,17 @@ func (r *RateLimitPolicyStatusUpdater) UpdateStatus(ctx context.Context, _ []con
 
 		accepted, err := policyAcceptedFunc(policy)
 		meta.SetStatusCondition(&newStatus.Conditions, *kuadrant.AcceptedCondition(policy, err))
+		if len(policy.Spec.TargetRefs) > 0 {
+			newStatus.Targets = policyTargetStatuses(policy, topology, policy.Status.Targets)
+		}
 
 		// do not set enforced condition if Accepted condition is false
 		if !accepted {
 			meta.RemoveStatusCondition(&newStatus.Conditions, string(kuadrant.PolicyConditionEnforced))
 		} else {
-			enforcedCond := r.enforcedCondition(policy, topology, state, logger)
+			enforcedCond, shadowed := r.enforcedCondition(policy, topology, state, logger)
 			meta.SetStatusCondition(&newStatus.Conditions, *enforcedCond)
+			newStatus.ShadowedRules = shadowed
 		}
 
 		equalStatus := equality.Semantic.DeepEqual(newStatus, policy.Status)
@@ -152,16 +154,16 @@ func (r *RateLimitPolicyStatusUpdater) UpdateStatus(ctx context.Context, _ []con
 	return nil
 }
 
-func (r *RateLimitPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.RateLimitPolicy, topology *machinery.Topology, state *sync.Map, logger logr.Logger) *metav1.Condition {
+func (r *RateLimitPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.RateLimitPolicy, topology *machinery.Topology, state *sync.Map, logger logr.Logger) (*metav1.Condition, []kuadrantv1.ShadowedRule) {
 	kObj := GetKuadrantFromTopology(topology, state)
 	if kObj == nil {
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrSystemResource("kuadrant"), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrSystemResource("kuadrant"), false), nil
 	}
 	policyKind := kuadrantv1.RateLimitPolicyGroupKind.Kind
 
 	effectivePolicies, ok := state.Load(StateEffectiveRateLimitPolicies)
 	if !ok {
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrUnknown(policyKind, ErrMissingStateEffectiveRateLimitPolicies), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrUnknown(policyKind, ErrMissingStateEffectiveRateLimitPolicies), false), nil
 	}
 
 	type affectedGateway struct {
@@ -172,6 +174,7 @@ func (r *RateLimitPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.Rate
 	// check the state of the rules of the policy in the effective policies
 	policyRuleKeys := lo.Keys(policy.Rules())
 	overridingPolicies := map[string][]string{}      // policyRuleKey → locators of policies overriding the policy rule
+	shadowedPaths := map[string][]string{}           // policyRuleKey → IDs of the paths where the policy rule is overridden
 	affectedGateways := map[string]affectedGateway{} // Gateway locator → {GatewayClass, Gateway}
 
 	var rateLimitCelValidationErrors []error
@@ -184,8 +187,11 @@ func (r *RateLimitPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.Rate
 		rateLimitIssuesByPathID, ratelimitIssuesFound = celIssuesCollection.GetByPolicyKind(policyKind)
 	}
 
+	namespaceLabels := namespaceLabelsFunc(topology)
 	for _, effectivePolicy := range effectivePolicies.(EffectiveRateLimitPolicies) {
-		if len(kuadrantv1.PoliciesInPath(effectivePolicy.Path, func(p machinery.Policy) bool { return p.GetLocator() == policy.GetLocator() })) == 0 {
+		if len(kuadrantv1.PoliciesInPath(effectivePolicy.Path, func(p machinery.Policy) bool {
+			return p.GetLocator() == policy.GetLocator() && kuadrantv1.PolicySelectsPath(p, effectivePolicy.Path, namespaceLabels)
+		})) == 0 {
 			continue
 		}
 		if ratelimitIssuesFound {
@@ -237,6 +243,7 @@ func (r *RateLimitPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.Rate
 					// to the effective policy are the ones that overrode this policy
 					overridingPolicies[policyRuleKey] = append(overridingPolicies[policyRuleKey], effectivePolicy.SourcePolicies...)
 				}
+				shadowedPaths[policyRuleKey] = append(shadowedPaths[policyRuleKey], kuadrantv1.PathID(effectivePolicy.Path))
 				continue
 			}
 			// policy rule is in the effective policy, track the Gateway affected by the policy
@@ -249,14 +256,14 @@ func (r *RateLimitPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.Rate
 
 	if len(affectedGateways) == 0 { // no rules of the policy found in the effective policies
 		if len(overridingPolicies) == 0 { // no rules of the policy have been overridden by any other policy
-			return kuadrant.EnforcedCondition(policy, kuadrant.NewErrNoRoutes(policyKind), false)
+			return kuadrant.EnforcedCondition(policy, kuadrant.NewErrNoRoutes(policyKind), false), shadowedRules(overridingPolicies, shadowedPaths)
 		}
 		// all rules of the policy have been overridden by at least one other policy
 		overridingPoliciesKeys := lo.FilterMap(lo.Uniq(lo.Flatten(lo.Values(overridingPolicies))), func(policyLocator string, _ int) (k8stypes.NamespacedName, bool) {
 			policyKey, err := kuadrantpolicymachinery.NamespacedNameFromLocator(policyLocator)
 			return policyKey, err == nil
 		})
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrOverridden(policyKind, overridingPoliciesKeys), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrOverridden(policyKind, overridingPoliciesKeys), false), shadowedRules(overridingPolicies, shadowedPaths)
 	}
 
 	var componentsToSync []string
@@ -267,7 +274,7 @@ func (r *RateLimitPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.Rate
 	} else {
 		limitador := GetLimitadorFromTopology(topology, state)
 		if limitador == nil {
-			return kuadrant.EnforcedCondition(policy, kuadrant.NewErrSystemResource("limitador"), false)
+			return kuadrant.EnforcedCondition(policy, kuadrant.NewErrSystemResource("limitador"), false), shadowedRules(overridingPolicies, shadowedPaths)
 		}
 		if !meta.IsStatusConditionTrue(limitador.Status.Conditions, limitadorv1alpha1.StatusConditionReady) {
 			componentsToSync = append(componentsToSync, kuadrantv1beta1.LimitadorGroupKind.Kind)
@@ -276,45 +283,32 @@ func (r *RateLimitPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1.Rate
 
 	// check the status of the gateways' configuration resources
 	for _, g := range affectedGateways {
-		controllerName := g.gatewayClass.Spec.ControllerName
-		switch defaultGatewayControllerName(controllerName) {
-		case defaultIstioGatewayControllerName:
-			// EnvoyFilter (rate limit clusters)
-			istioRateLimitClustersModifiedGateways, _ := state.Load(StateIstioRateLimitClustersModified)
-			componentsToSync = append(componentsToSync, gatewayComponentsToSyncWithName(g.gateway, kuadrantistio.EnvoyFilterGroupKind, RateLimitClusterName(g.gateway.GetName()), istioRateLimitClustersModifiedGateways, topology, func(_ machinery.Object) bool {
-				// return meta.IsStatusConditionTrue(lo.Map(obj.(*controller.RuntimeObject).Object.(*istioclientgonetworkingv1alpha3.EnvoyFilter).Status.Conditions, kuadrantistio.ConditionToProperConditionFunc), "Ready")
-				return true // Istio won't ever populate the status stanza of EnvoyFilter resources, so we cannot expect to find a given a condition there
-			})...)
-			// EnvoyFilter (wasm plugin)
-			istioExtensionsModifiedGateways, _ := state.Load(StateIstioExtensionsModified)
-			componentsToSync = append(componentsToSync, gatewayComponentsToSyncWithName(g.gateway, kuadrantistio.EnvoyFilterGroupKind, wasm.ExtensionName(g.gateway.GetName()), istioExtensionsModifiedGateways, topology, func(_ machinery.Object) bool {
-				// return meta.IsStatusConditionTrue(lo.Map(obj.(*controller.RuntimeObject).Object.(*istioclientgonetworkingv1alpha3.EnvoyFilter).Status.Conditions, kuadrantistio.ConditionToProperConditionFunc), "Ready")
-				return true // Istio won't ever populate the status stanza of EnvoyFilter resources, so we cannot expect to find a given a condition there
-			})...)
-		case defaultEnvoyGatewayGatewayControllerName:
-			gatewayAncestor := gatewayapiv1.ParentReference{Name: gatewayapiv1.ObjectName(g.gateway.GetName()), Namespace: ptr.To(gatewayapiv1.Namespace(g.gateway.GetNamespace()))}
-			// EnvoyPatchPolicy
-			envoyGatewayRateLimitClustersModifiedGateways, _ := state.Load(StateEnvoyGatewayRateLimitClustersModified)
-			componentsToSync = append(componentsToSync, gatewayComponentsToSync(g.gateway, kuadrantenvoygateway.EnvoyPatchPolicyGroupKind, envoyGatewayRateLimitClustersModifiedGateways, topology, func(obj machinery.Object) bool {
-				return meta.IsStatusConditionTrue(kuadrantgatewayapi.PolicyStatusConditionsFromAncestor(obj.(*controller.RuntimeObject).Object.(*envoygatewayv1alpha1.EnvoyPatchPolicy).Status, controllerName, gatewayAncestor, gatewayapiv1.Namespace(obj.GetNamespace())), string(envoygatewayv1alpha1.PolicyConditionProgrammed))
-			})...)
-			// EnvoyExtensionPolicy
-			envoyGatewayExtensionsModifiedGateways, _ := state.Load(StateEnvoyGatewayExtensionsModified)
-			componentsToSync = append(componentsToSync, gatewayComponentsToSync(g.gateway, kuadrantenvoygateway.EnvoyExtensionPolicyGroupKind, envoyGatewayExtensionsModifiedGateways, topology, func(obj machinery.Object) bool {
-				return meta.IsStatusConditionTrue(kuadrantgatewayapi.PolicyStatusConditionsFromAncestor(obj.(*controller.RuntimeObject).Object.(*envoygatewayv1alpha1.EnvoyExtensionPolicy).Status, controllerName, gatewayAncestor, gatewayapiv1.Namespace(obj.GetNamespace())), string(gatewayapiv1alpha2.PolicyConditionAccepted))
-			})...)
-		default:
+		provider, found := gatewayProviderFor(r.gatewayProviders, g.gatewayClass.Spec.ControllerName)
+		if !found {
 			componentsToSync = append(componentsToSync, fmt.Sprintf("%s (%s/%s)", machinery.GatewayGroupKind.Kind, g.gateway.GetNamespace(), g.gateway.GetName()))
+			continue
 		}
+		componentsToSync = append(componentsToSync, provider.ComponentsToSync(kuadrantv1.RateLimitPolicyGroupKind, g.gateway, g.gatewayClass, topology, state)...)
 	}
 
 	if len(rateLimitCelValidationErrors) > 0 {
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrCelValidation(rateLimitCelValidationErrors), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrCelValidation(rateLimitCelValidationErrors), false), shadowedRules(overridingPolicies, shadowedPaths)
 	}
 
 	if len(componentsToSync) > 0 {
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrOutOfSync(policyKind, componentsToSync), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrOutOfSync(policyKind, componentsToSync), false), shadowedRules(overridingPolicies, shadowedPaths)
+	}
+
+	if features := nativeDataPlaneUnsupportedFeatures(state)[policy.GetLocator()]; len(features) > 0 {
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrUnsupportedFeature(policyKind, features), false), shadowedRules(overridingPolicies, shadowedPaths)
+	}
+
+	if policy.InShadowMode() {
+		if err := shadowModeUnsupportedError(policyKind, lo.MapToSlice(affectedGateways, func(_ string, g affectedGateway) *machinery.Gateway { return g.gateway })); err != nil {
+			return kuadrant.EnforcedCondition(policy, err, false), shadowedRules(overridingPolicies, shadowedPaths)
+		}
+		return kuadrant.ShadowCondition(policy), shadowedRules(overridingPolicies, shadowedPaths)
 	}
 
-	return kuadrant.EnforcedCondition(policy, nil, len(overridingPolicies) == 0)
+	return kuadrant.EnforcedCondition(policy, nil, len(overridingPolicies) == 0), shadowedRules(overridingPolicies, shadowedPaths)
 }
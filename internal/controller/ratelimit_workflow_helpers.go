@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"unicode"
 
@@ -21,6 +23,7 @@ import (
 	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
 	kuadrantv1alpha1 "github.com/kuadrant/kuadrant-operator/api/v1alpha1"
 	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
+	celvalidator "github.com/kuadrant/kuadrant-operator/internal/cel"
 	"github.com/kuadrant/kuadrant-operator/internal/kuadrant"
 	kuadrantpolicymachinery "github.com/kuadrant/kuadrant-operator/internal/policymachinery"
 	"github.com/kuadrant/kuadrant-operator/internal/wasm"
@@ -148,6 +151,31 @@ func wasmDataFromLimit(limitIdentifier string, limit *kuadrantv1.Limit) []wasm.D
 		)
 	}
 
+	data = append(data, wasmDataFromRates(limit.Rates)...)
+
+	return data
+}
+
+// wasmDataFromRates returns the descriptor entries required by the calendar periods and hour ranges of the rates
+func wasmDataFromRates(rates []kuadrantv1.Rate) []wasm.DataType {
+	data := make([]wasm.DataType, 0)
+	keys := make(map[string]struct{})
+	for _, rate := range rates {
+		for _, descriptor := range rate.Descriptors() {
+			if _, found := keys[descriptor.Key]; found {
+				continue
+			}
+			keys[descriptor.Key] = struct{}{}
+			data = append(data, wasm.DataType{
+				Value: &wasm.Expression{
+					ExpressionItem: wasm.ExpressionItem{
+						Key:   descriptor.Key,
+						Value: descriptor.Expression,
+					},
+				},
+			})
+		}
+	}
 	return data
 }
 
@@ -215,7 +243,7 @@ func TokenLimitNameToLimitadorIdentifier(trlpKey k8stypes.NamespacedName, unique
 	return identifier
 }
 
-func wasmActionsFromTokenLimit(tokenLimit *kuadrantv1alpha1.TokenLimit, limitIdentifier, scope, sourcePolicyLocator string, topLevelPredicates kuadrantv1.WhenPredicates) []wasm.Action {
+func wasmActionsFromTokenLimit(tokenLimit *kuadrantv1alpha1.TokenLimit, limitIdentifier, scope, sourcePolicyLocator string, topLevelPredicates kuadrantv1.WhenPredicates, pricing *kuadrantv1alpha1.TokenPricing) []wasm.Action {
 	predicates := make([]string, 0, len(topLevelPredicates)+1)
 	for _, pred := range topLevelPredicates {
 		predicates = append(predicates, pred.Predicate)
@@ -249,23 +277,57 @@ func wasmActionsFromTokenLimit(tokenLimit *kuadrantv1alpha1.TokenLimit, limitIde
 		})
 	}
 
+	// add calendar periods and hour ranges if specified
+	commonData = append(commonData, wasmDataFromRates(tokenLimit.Rates)...)
+
 	// Create separate data slices for request and response phases
 	// We need independent copies because each phase has different hits_addend values
 
-	// Request phase - check limit without consuming tokens
+	// Request phase - check limit without consuming tokens, unless an estimate is to be reserved up front
+	requestServiceName := wasm.RateLimitCheckServiceName
+	requestHitsAddend := "0"
+	responseHitsAddend := tokenUsageHitsAddend(tokenLimit)
+	var requestData map[string]string
+	if tokenLimit.Unit == kuadrantv1alpha1.TokenLimitUnitCost {
+		responseHitsAddend = "0"
+		if pricing != nil {
+			model := pricing.ModelExpression()
+			// the request body is gone by the response phase, so a model read from it is captured up front. An expression
+			// that does not parse is captured as well, its syntax error is reported by the validation of the actions
+			if readsRequestBody, err := celvalidator.CallsFunction(model, celvalidator.RequestBodyJSONFunc); err != nil || readsRequestBody {
+				modelKey := pricingModelKey(limitIdentifier)
+				requestData = map[string]string{modelKey: model}
+				model = wasm.RequestDataValue(modelKey)
+			}
+			responseHitsAddend = tokenCostHitsAddend(pricing, model)
+		}
+	}
+	if estimate := tokenLimit.Estimate; estimate != nil {
+		requestHitsAddend = tokenEstimateHitsAddend(estimate)
+		if estimate.Reserve {
+			// the estimate is consumed when the request is admitted, so only the excess usage is reported.
+			// The request body is gone by the response phase, so the reserved amount is captured up front.
+			requestServiceName = wasm.RateLimitServiceName
+			reservedKey := reservedTokensKey(limitIdentifier)
+			requestData = lo.Assign(requestData, map[string]string{reservedKey: requestHitsAddend})
+			reserved := fmt.Sprintf("int(%s)", wasm.RequestDataValue(reservedKey))
+			responseHitsAddend = fmt.Sprintf("(%[1]s) > %[2]s ? (%[1]s) - %[2]s : 0", responseHitsAddend, reserved)
+		}
+	}
+
 	requestPhaseData := make([]wasm.DataType, 0, len(commonData)+1)
 	requestPhaseData = append(requestPhaseData, commonData...)
 	requestPhaseData = append(requestPhaseData, wasm.DataType{
 		Value: &wasm.Expression{
 			ExpressionItem: wasm.ExpressionItem{
 				Key:   "ratelimit.hits_addend",
-				Value: "0",
+				Value: requestHitsAddend,
 			},
 		},
 	})
 
 	requestAction := wasm.Action{
-		ServiceName:          wasm.RateLimitCheckServiceName,
+		ServiceName:          requestServiceName,
 		Scope:                scope,
 		SourcePolicyLocators: []string{sourcePolicyLocator}, // Single policy for individual token limits
 		ConditionalData: []wasm.ConditionalData{
@@ -274,16 +336,17 @@ func wasmActionsFromTokenLimit(tokenLimit *kuadrantv1alpha1.TokenLimit, limitIde
 				Data:       requestPhaseData,
 			},
 		},
+		RequestData: requestData,
 	}
 
-	// Response phase - increment counter with actual token usage
+	// Response phase - increment counter with actual token usage (or its cost)
 	responsePhaseData := make([]wasm.DataType, 0, len(commonData)+1)
 	responsePhaseData = append(responsePhaseData, commonData...)
 	responsePhaseData = append(responsePhaseData, wasm.DataType{
 		Value: &wasm.Expression{
 			ExpressionItem: wasm.ExpressionItem{
 				Key:   "ratelimit.hits_addend",
-				Value: "responseBodyJSON(\"/usage/total_tokens\")",
+				Value: responseHitsAddend,
 			},
 		},
 	})
@@ -303,6 +366,85 @@ func wasmActionsFromTokenLimit(tokenLimit *kuadrantv1alpha1.TokenLimit, limitIde
 	return []wasm.Action{requestAction, responseAction}
 }
 
+// tokenEstimateHitsAddend returns the request phase hits addend of the estimate of a token limit. The data plane
+// resolves the fields missing from the request body to null, e.g. a request without `max_tokens`, in which case the
+// request is estimated at 0 tokens rather than failing the evaluation of the estimate
+func tokenEstimateHitsAddend(estimate *kuadrantv1alpha1.TokenEstimate) string {
+	return fmt.Sprintf("(%[1]s) != null ? (%[1]s) : 0", estimate.Expression)
+}
+
+// reservedTokensKey is the requestData key the amount reserved in the request phase for a token limit is captured under
+func reservedTokensKey(limitIdentifier string) string {
+	return limitIdentifier + ".reserved"
+}
+
+// pricingModelKey is the requestData key the model read from the request of a cost limit is captured under
+// tokenLimitUsesRequestData tells whether a token limit carries values from the request phase to the response phase,
+// i.e. it reserves its estimate or reads the pricing model from the request body
+func tokenLimitUsesRequestData(tokenLimit *kuadrantv1alpha1.TokenLimit, pricing *kuadrantv1alpha1.TokenPricing) bool {
+	return usesRequestData(wasmActionsFromTokenLimit(tokenLimit, "", "", "", nil, pricing))
+}
+
+func usesRequestData(actions []wasm.Action) bool {
+	return lo.SomeBy(actions, func(action wasm.Action) bool { return len(action.RequestData) > 0 })
+}
+
+func pricingModelKey(limitIdentifier string) string {
+	return limitIdentifier + ".model"
+}
+
+// tokenUsageHitsAddend builds the CEL expression that reads the token count of a response from the
+// source configured in the token limit, defaulting to the OpenAI-style `usage` body field of the limit's token class
+func tokenUsageHitsAddend(tokenLimit *kuadrantv1alpha1.TokenLimit) string {
+	return tokenUsageExpression(tokenLimit.TokenUsage, tokenLimit.TokenClass.DefaultJSONPointer())
+}
+
+// tokenUsageExpression builds the CEL expression that reads a token count of a response from the given source,
+// defaulting to the given JSON pointer of the response body
+func tokenUsageExpression(usage *kuadrantv1alpha1.TokenUsage, defaultJSONPointer string) string {
+	switch {
+	case usage != nil && usage.Expression != "":
+		return string(usage.Expression)
+	case usage != nil && usage.Header != "":
+		return fmt.Sprintf("int(response.headers[%q])", strings.ToLower(usage.Header))
+	case usage != nil && usage.JSONPointer != "":
+		return responseBodyTokenUsage(usage.JSONPointer)
+	default:
+		return responseBodyTokenUsage(defaultJSONPointer)
+	}
+}
+
+// responseBodyTokenUsage reads the token count at the given JSON pointer of the response body
+func responseBodyTokenUsage(jsonPointer string) string {
+	return fmt.Sprintf("%s(%q)", celvalidator.ResponseBodyJSONFunc, jsonPointer)
+}
+
+// tokenCostHitsAddend builds the CEL expression that computes the cost of a response from its input and output
+// token counts, priced according to the model, resolved by the given expression, that served the request
+func tokenCostHitsAddend(pricing *kuadrantv1alpha1.TokenPricing, model string) string {
+	inputUsage := tokenUsageExpression(pricing.InputTokenUsage, kuadrantv1alpha1.DefaultInputTokenUsageJSONPointer)
+	outputUsage := tokenUsageExpression(pricing.OutputTokenUsage, kuadrantv1alpha1.DefaultOutputTokenUsageJSONPointer)
+	inputPrice := modelTokenPrice(model, pricing, func(price kuadrantv1alpha1.TokenPrice) int64 { return price.Input })
+	outputPrice := modelTokenPrice(model, pricing, func(price kuadrantv1alpha1.TokenPrice) int64 { return price.Output })
+	return fmt.Sprintf("int(%s) * %s + int(%s) * %s", inputUsage, inputPrice, outputUsage, outputPrice)
+}
+
+// modelTokenPrice builds the CEL expression that looks up the price of a token of the model in the pricing table,
+// falling back to the default price for unlisted models
+func modelTokenPrice(model string, pricing *kuadrantv1alpha1.TokenPricing, price func(kuadrantv1alpha1.TokenPrice) int64) string {
+	modelNames := lo.Keys(pricing.Models)
+	sort.Strings(modelNames)
+	entries := lo.Map(modelNames, func(name string, _ int) string {
+		return fmt.Sprintf("%q: %d", name, price(pricing.Models[name]))
+	})
+	table := fmt.Sprintf("{%s}", strings.Join(entries, ", "))
+	var defaultPrice int64
+	if pricing.Default != nil {
+		defaultPrice = price(*pricing.Default)
+	}
+	return fmt.Sprintf("(%[1]s in %[2]s ? %[2]s[%[1]s] : %[3]d)", model, table, defaultPrice)
+}
+
 func buildWasmActionsForRateLimit(effectivePolicy EffectiveRateLimitPolicy, policyPredicate func(machinery.Policy) bool) []wasm.Action {
 	return buildWasmActionsForAnyRateLimit(
 		effectivePolicy.Path,
@@ -360,8 +502,21 @@ func buildWasmActionsForTokenRateLimit(effectivePolicy EffectiveTokenRateLimitPo
 		scope := limitsNamespace
 		sourcePolicyLocator := source.GetLocator()
 
+		// cost limits are priced according to the pricing of the policy where they are defined
+		var pricing *kuadrantv1alpha1.TokenPricing
+		if sourcePolicy, ok := source.(*kuadrantv1alpha1.TokenRateLimitPolicy); ok {
+			pricing = sourcePolicy.Spec.Proper().Pricing
+		}
+
 		// TokenRateLimitPolicy generates multiple actions per limit (request + response phase)
-		tokenActions := wasmActionsFromTokenLimit(limitSpec, limitIdentifier, scope, sourcePolicyLocator, topLevelWhenPredicates)
+		tokenActions := wasmActionsFromTokenLimit(limitSpec, limitIdentifier, scope, sourcePolicyLocator, topLevelWhenPredicates, pricing)
+		// the response phase would read values the wasm-shim never captured in the request phase
+		if usesRequestData(tokenActions) && !wasm.ShimSupports(wasm.ShimFeatureRequestData) {
+			continue
+		}
+		for i := range tokenActions {
+			tokenActions[i].ReportOnly = kuadrant.IsInShadowMode(source)
+		}
 		allActions = append(allActions, tokenActions...)
 	}
 
@@ -414,6 +569,8 @@ func buildWasmActionsForAnyRateLimit(
 		scope := limitsNamespace
 		sourcePolicyLocator := source.GetLocator()
 
-		return actionFunc(limitSpec, limitIdentifier, scope, sourcePolicyLocator, topLevelWhenPredicates), true
+		action := actionFunc(limitSpec, limitIdentifier, scope, sourcePolicyLocator, topLevelWhenPredicates)
+		action.ReportOnly = kuadrant.IsInShadowMode(source)
+		return action, true
 	})
 }
@@ -50,6 +50,7 @@ func (r *RoutePolicyDiscoverabilityReconciler) Subscription() *controller.Subscr
 			{Kind: &machinery.GatewayGroupKind},
 			{Kind: &machinery.HTTPRouteGroupKind},
 			{Kind: &machinery.GRPCRouteGroupKind},
+			{Kind: &NamespaceGroupKind},
 			{Kind: &kuadrantv1.AuthPolicyGroupKind},
 			{Kind: &kuadrantv1.RateLimitPolicyGroupKind},
 			{Kind: &kuadrantv1alpha1.TokenRateLimitPolicyGroupKind},
@@ -65,6 +66,7 @@ func (r *RoutePolicyDiscoverabilityReconciler) reconcile(ctx context.Context, _
 
 	routes := extractDiscoverableRoutes(topology)
 	policyKinds := policyGroupKinds()
+	namespaceLabels := namespaceLabelsFunc(topology)
 
 	for _, route := range routes {
 		routeStatusParents := deepCopyParents(route.getParents())
@@ -77,7 +79,7 @@ func (r *RoutePolicyDiscoverabilityReconciler) reconcile(ctx context.Context, _
 			})
 
 			policies := kuadrantv1.PoliciesInPath(path, func(policy machinery.Policy) bool {
-				return policy.GroupVersionKind().GroupKind() == *policyKind && IsPolicyAccepted(ctx, policy, s)
+				return policy.GroupVersionKind().GroupKind() == *policyKind && IsPolicyAccepted(ctx, policy, s) && kuadrantv1.PolicySelectsPath(policy, path, namespaceLabels)
 			})
 
 			if len(policies) == 0 {
@@ -8,10 +8,7 @@ import (
 	"sort"
 	"sync"
 
-	istiosecurity "istio.io/client-go/pkg/apis/security/v1"
-
 	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
-	egv1alpha1 "github.com/envoyproxy/gateway/api/v1alpha1"
 	"github.com/go-logr/logr"
 	authorinooperatorv1beta1 "github.com/kuadrant/authorino-operator/api/v1beta1"
 	authorinov1beta3 "github.com/kuadrant/authorino/api/v1beta3"
@@ -25,7 +22,6 @@ import (
 	"github.com/samber/lo"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
-	istioclientnetworkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -42,10 +38,8 @@ import (
 	kuadrantv1alpha1 "github.com/kuadrant/kuadrant-operator/api/v1alpha1"
 	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
 	"github.com/kuadrant/kuadrant-operator/internal/authorino"
-	"github.com/kuadrant/kuadrant-operator/internal/envoygateway"
 	"github.com/kuadrant/kuadrant-operator/internal/extension"
 	kuadrantgatewayapi "github.com/kuadrant/kuadrant-operator/internal/gatewayapi"
-	"github.com/kuadrant/kuadrant-operator/internal/istio"
 	"github.com/kuadrant/kuadrant-operator/internal/kuadrant"
 	"github.com/kuadrant/kuadrant-operator/internal/log"
 	operatormetrics "github.com/kuadrant/kuadrant-operator/internal/metrics"
@@ -60,6 +54,9 @@ var (
 	kuadrantManagedLabelKey = "kuadrant.io/managed"
 
 	ConfigMapGroupKind = schema.GroupKind{Group: corev1.GroupName, Kind: "ConfigMap"}
+	NamespaceGroupKind = schema.GroupKind{Group: corev1.GroupName, Kind: "Namespace"}
+
+	namespacesResource = corev1.SchemeGroupVersion.WithResource("namespaces")
 )
 
 // gateway-api permissions
@@ -76,6 +73,8 @@ var (
 //+kubebuilder:rbac:groups=kuadrant.io,resources=kuadrants,verbs=get;list;watch;update;patch
 //+kubebuilder:rbac:groups=kuadrant.io,resources=kuadrants/finalizers,verbs=update
 //+kubebuilder:rbac:groups=kuadrant.io,resources=kuadrants/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=kuadrant.io,resources=kuadrantextensions,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=kuadrant.io,resources=kuadrantextensions/status,verbs=get;update;patch
 
 // core, apps, coordination.k8s,io permissions
 //+kubebuilder:rbac:groups=core,resources=serviceaccounts;configmaps;services,verbs=get;list;watch;create;update;patch;delete
@@ -84,6 +83,10 @@ var (
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups="",resources=leases,verbs=get;list;watch;create;update;patch;delete
 
+// policy simulation endpoint authentication and authorization
+//+kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
 func NewPolicyMachineryController(manager ctrlruntime.Manager, client *dynamic.DynamicClient, logger logr.Logger, opts ...controller.ControllerOption) (*controller.Controller, error) {
 	// Base options
 	controllerOpts := []controller.ControllerOption{
@@ -133,6 +136,12 @@ func NewPolicyMachineryController(manager ctrlruntime.Manager, client *dynamic.D
 			controller.WithPredicates(&ctrlruntimepredicate.TypedGenerationChangedPredicate[*corev1.ConfigMap]{}),
 			controller.FilterResourcesByLabel[*corev1.ConfigMap](fmt.Sprintf("%s=true", kuadrant.TopologyLabel)),
 		)),
+		controller.WithRunnable("namespace watcher", controller.Watch(
+			&corev1.Namespace{},
+			namespacesResource,
+			metav1.NamespaceAll,
+			controller.WithPredicates(&ctrlruntimepredicate.TypedLabelChangedPredicate[*corev1.Namespace]{}),
+		)),
 		controller.WithRunnable("developer portal deployment watcher", controller.Watch(
 			&appsv1.Deployment{},
 			kuadrantv1beta1.DeploymentsResource,
@@ -162,6 +171,7 @@ func NewPolicyMachineryController(manager ctrlruntime.Manager, client *dynamic.D
 			kuadrantv1beta1.KuadrantGroupKind,
 			ConfigMapGroupKind,
 			kuadrantv1beta1.DeploymentGroupKind,
+			NamespaceGroupKind,
 		),
 		controller.WithObjectLinks(
 			kuadrantv1beta1.LinkKuadrantToGatewayClasses,
@@ -186,12 +196,23 @@ func NewPolicyMachineryController(manager ctrlruntime.Manager, client *dynamic.D
 // on if external dependent CRDs are installed at boot time
 func NewBootOptionsBuilder(manager ctrlruntime.Manager, client *dynamic.DynamicClient, logger logr.Logger) *BootOptionsBuilder {
 	return &BootOptionsBuilder{
-		manager: manager,
-		client:  client,
-		logger:  logger,
+		manager:          manager,
+		client:           client,
+		logger:           logger,
+		gatewayProviders: DefaultGatewayProviders(),
+		// the rollout of the changes of the wasm configuration progresses over time
+		wasmConfigRollout: newWasmConfigRolloutTracker(func(reason string) error {
+			return extension.TriggerKuadrantReconciliation(context.Background(), client, logger, reason)
+		}),
 	}
 }
 
+// RegisterGatewayProvider adds a gateway provider to the ones enabled at boot time if installed.
+// Providers must be registered before the controller options are built.
+func (b *BootOptionsBuilder) RegisterGatewayProvider(provider GatewayProvider) {
+	b.gatewayProviders = append(b.gatewayProviders, provider)
+}
+
 type BootOptionsBuilder struct {
 	logger  logr.Logger
 	manager ctrlruntime.Manager
@@ -199,8 +220,6 @@ type BootOptionsBuilder struct {
 
 	// Internal configurations
 	isGatewayAPIInstalled         bool
-	isEnvoyGatewayInstalled       bool
-	isIstioInstalled              bool
 	isCertManagerInstalled        bool
 	isConsolePluginInstalled      bool
 	isClusterVersionInstalled     bool
@@ -209,6 +228,14 @@ type BootOptionsBuilder struct {
 	isAuthorinoOperatorInstalled  bool
 	isPrometheusOperatorInstalled bool
 	isUsingExtensions             bool
+
+	// Gateway providers registered and those found installed at boot time
+	gatewayProviders          []GatewayProvider
+	installedGatewayProviders []GatewayProvider
+
+	policySimulator   *PolicySimulator
+	extensionManager  *extension.Manager
+	wasmConfigRollout *wasmConfigRolloutTracker
 }
 
 func (b *BootOptionsBuilder) getOptions() ([]controller.ControllerOption, error) {
@@ -223,17 +250,11 @@ func (b *BootOptionsBuilder) getOptions() ([]controller.ControllerOption, error)
 	}
 	opts = append(opts, gwapiOpts...)
 
-	istioOpts, optionErr := b.getIstioOptions()
+	gatewayProviderOpts, optionErr := b.getGatewayProviderOptions()
 	if optionErr != nil {
 		return opts, optionErr
 	}
-	opts = append(opts, istioOpts...)
-
-	envoyGatewayOpts, optionErr := b.getEnvoyGatewayOptions()
-	if optionErr != nil {
-		return opts, optionErr
-	}
-	opts = append(opts, envoyGatewayOpts...)
+	opts = append(opts, gatewayProviderOpts...)
 
 	certManagerOpts, optionErr := b.getCertManagerOptions()
 	if optionErr != nil {
@@ -308,94 +329,58 @@ func (b *BootOptionsBuilder) getGatewayAPIOptions() ([]controller.ControllerOpti
 			controller.GRPCRoutesResource,
 			metav1.NamespaceAll,
 		)),
+		controller.WithRunnable("effectivepolicy watcher", controller.Watch(
+			&kuadrantv1alpha1.EffectivePolicy{},
+			kuadrantv1alpha1.EffectivePoliciesResource,
+			metav1.NamespaceAll,
+			controller.FilterResourcesByLabel[*kuadrantv1alpha1.EffectivePolicy](fmt.Sprintf("%s=true", effectivePolicyObjectLabelKey)),
+		)),
+		controller.WithObjectKinds(
+			kuadrantv1alpha1.EffectivePolicyGroupKind,
+		),
 	)
 
-	return opts, nil
-}
-
-func (b *BootOptionsBuilder) getEnvoyGatewayOptions() ([]controller.ControllerOption, error) {
-	var opts []controller.ControllerOption
-	var err error
-	b.isEnvoyGatewayInstalled, err = envoygateway.IsEnvoyGatewayInstalled(b.manager.GetRESTMapper())
+	b.policySimulator = NewPolicySimulator()
+	simulationServer, err := NewPolicySimulationServer(b.manager.GetConfig(), b.manager.GetHTTPClient(), b.policySimulator)
 	if err != nil {
 		return nil, err
 	}
-
-	operatormetrics.SetDependencyDetected("envoygateway", b.isEnvoyGatewayInstalled)
-
-	if !b.isEnvoyGatewayInstalled {
-		b.logger.Info("envoygateway is not installed, skipping related watches and reconcilers")
-		operatormetrics.SetControllerRegistered("envoygateway_integration", false)
-		return opts, nil
+	if err := b.manager.Add(simulationServer); err != nil {
+		return nil, err
+	}
+	if err := b.manager.Add(shadowDenials); err != nil {
+		return nil, err
 	}
-
-	operatormetrics.SetControllerRegistered("envoygateway_integration", true)
-	opts = append(opts,
-		controller.WithRunnable("envoypatchpolicy watcher", controller.Watch(
-			&egv1alpha1.EnvoyPatchPolicy{},
-			envoygateway.EnvoyPatchPoliciesResource,
-			metav1.NamespaceAll,
-			controller.FilterResourcesByLabel[*egv1alpha1.EnvoyPatchPolicy](fmt.Sprintf("%s=true", kuadrantManagedLabelKey)),
-		)),
-		controller.WithRunnable("envoyextensionpolicy watcher", controller.Watch(
-			&egv1alpha1.EnvoyExtensionPolicy{},
-			envoygateway.EnvoyExtensionPoliciesResource,
-			metav1.NamespaceAll,
-			controller.FilterResourcesByLabel[*egv1alpha1.EnvoyExtensionPolicy](fmt.Sprintf("%s=true", kuadrantManagedLabelKey)),
-		)),
-		controller.WithObjectKinds(
-			envoygateway.EnvoyPatchPolicyGroupKind,
-			envoygateway.EnvoyExtensionPolicyGroupKind,
-		),
-		controller.WithObjectLinks(
-			envoygateway.LinkGatewayToEnvoyPatchPolicy,
-			envoygateway.LinkGatewayToEnvoyExtensionPolicy,
-		),
-	)
 
 	return opts, nil
 }
 
-func (b *BootOptionsBuilder) getIstioOptions() ([]controller.ControllerOption, error) {
+func (b *BootOptionsBuilder) getGatewayProviderOptions() ([]controller.ControllerOption, error) {
 	var opts []controller.ControllerOption
-	var err error
-	b.isIstioInstalled, err = istio.IsIstioInstalled(b.manager.GetRESTMapper())
-	if err != nil {
-		return nil, err
-	}
 
-	operatormetrics.SetDependencyDetected("istio", b.isIstioInstalled)
+	for _, provider := range b.gatewayProviders {
+		installed, err := provider.IsInstalled(b.manager.GetRESTMapper())
+		if err != nil {
+			return nil, err
+		}
 
-	if !b.isIstioInstalled {
-		b.logger.Info("istio is not installed. skipping related watches and reconcilers")
-		operatormetrics.SetControllerRegistered("istio_integration", false)
-		return opts, nil
+		operatormetrics.SetDependencyDetected(provider.Name(), installed)
+
+		if !installed {
+			b.logger.Info("gateway provider is not installed, skipping related watches and reconcilers", "provider", provider.Name())
+			operatormetrics.SetControllerRegistered(fmt.Sprintf("%s_integration", provider.Name()), false)
+			continue
+		}
+
+		operatormetrics.SetControllerRegistered(fmt.Sprintf("%s_integration", provider.Name()), true)
+		b.installedGatewayProviders = append(b.installedGatewayProviders, provider)
+		opts = append(opts, provider.ControllerOptions(b.logger)...)
 	}
 
-	operatormetrics.SetControllerRegistered("istio_integration", true)
-	opts = append(opts,
-		controller.WithRunnable("envoyfilter watcher", controller.Watch(
-			&istioclientnetworkingv1alpha3.EnvoyFilter{},
-			istio.EnvoyFiltersResource,
-			metav1.NamespaceAll,
-			controller.FilterResourcesByLabel[*istioclientnetworkingv1alpha3.EnvoyFilter](fmt.Sprintf("%s=true", kuadrantManagedLabelKey)),
-		)),
-		controller.WithRunnable("peerauthentication watcher", controller.Watch(
-			&istiosecurity.PeerAuthentication{},
-			istio.PeerAuthenticationResource,
-			metav1.NamespaceAll,
-			controller.FilterResourcesByLabel[*istiosecurity.PeerAuthentication](fmt.Sprintf("%s=true", kuadrantManagedLabelKey)),
-		)),
-		controller.WithObjectKinds(
-			istio.EnvoyFilterGroupKind,
-			istio.PeerAuthenticationGroupKind,
-		),
-		controller.WithObjectLinks(
-			istio.LinkGatewayToEnvoyFilter,
-			istio.LinkKuadrantToPeerAuthentication,
-		),
-		controller.WithRunnable("wasm server", wasmServerRunnable(b.logger)),
-	)
+	// the wasm modules served by the wasm server are reloaded from the filesystem, outside of the watched resources
+	wasmModules.SetChangeNotifier(func(reason string) error {
+		return extension.TriggerKuadrantReconciliation(context.Background(), b.client, b.logger, reason)
+	})
 
 	return opts, nil
 }
@@ -637,13 +622,25 @@ func (b *BootOptionsBuilder) getExtensionsOptions() []controller.ControllerOptio
 		return opts
 	}
 	extManager.SetChangeNotifier(extManager.TriggerReconciliation)
+	extManager.SetRESTMapper(b.manager.GetRESTMapper())
+	b.extensionManager = &extManager
 
-	opts = append(opts, controller.WithRunnable(
-		"extension manager",
-		func(*controller.Controller) controller.Runnable {
-			return &extManager
-		},
-	))
+	opts = append(opts,
+		controller.WithRunnable(
+			"extension manager",
+			func(*controller.Controller) controller.Runnable {
+				return &extManager
+			},
+		),
+		controller.WithRunnable("kuadrantextension watcher", controller.Watch(
+			&kuadrantv1alpha1.KuadrantExtension{},
+			kuadrantv1alpha1.KuadrantExtensionsResource,
+			metav1.NamespaceAll,
+		)),
+		controller.WithObjectKinds(
+			kuadrantv1alpha1.KuadrantExtensionGroupKind,
+		),
+	)
 	return opts
 }
 
@@ -663,7 +660,7 @@ func getKuadrantWatcherPredicate() ctrlruntimepredicate.TypedPredicate[*kuadrant
 }
 
 func (b *BootOptionsBuilder) isGatewayProviderInstalled() bool {
-	return b.isIstioInstalled || b.isEnvoyGatewayInstalled
+	return len(b.installedGatewayProviders) > 0
 }
 
 // additionalAttrsFn returns additional []attribute.KeyValue's derived from the reconciliation parameters to be added to
@@ -721,7 +718,7 @@ func (b *BootOptionsBuilder) Reconciler() controller.ReconcileFunc {
 		Tasks: []controller.ReconcileFunc{
 			traceReconcileFunc("workflow.dns", NewDNSWorkflow(b.client, b.manager.GetScheme(), b.isGatewayAPIInstalled, b.isDNSOperatorInstalled).Run),
 			traceReconcileFunc("workflow.tls", NewTLSWorkflow(b.client, b.manager.GetScheme(), b.isGatewayAPIInstalled, b.isCertManagerInstalled).Run),
-			traceReconcileFunc("workflow.data_plane_policies", NewDataPlanePoliciesWorkflow(b.manager, b.client, b.isGatewayAPIInstalled, b.isIstioInstalled, b.isEnvoyGatewayInstalled, b.isLimitadorOperatorInstalled, b.isAuthorinoOperatorInstalled).Run),
+			traceReconcileFunc("workflow.data_plane_policies", NewDataPlanePoliciesWorkflow(b.manager, b.client, b.isGatewayAPIInstalled, b.installedGatewayProviders, b.isLimitadorOperatorInstalled, b.isAuthorinoOperatorInstalled, b.wasmConfigRollout).Run),
 			traceReconcileFunc("workflow.observability", NewObservabilityReconciler(b.client, b.manager, operatorNamespace).Subscription().Reconcile),
 			traceReconcileFunc("workflow.developer_portal", NewDeveloperPortalReconciler(b.manager).Subscription().Reconcile),
 		},
@@ -815,6 +812,8 @@ func (b *BootOptionsBuilder) finalStepsWorkflow() *controller.Workflow {
 		workflow.Tasks = append(workflow.Tasks,
 			traceReconcileFunc("finalize.gateway_policy_discoverability", NewGatewayPolicyDiscoverabilityReconciler(b.client).Subscription().Reconcile),
 			traceReconcileFunc("finalize.route_policy_discoverability", NewRoutePolicyDiscoverabilityReconciler(b.client).Subscription().Reconcile),
+			traceReconcileFunc("finalize.policy_simulator", b.policySimulator.Reconcile),
+			traceReconcileFunc("finalize.effective_policies", NewEffectivePoliciesExportReconciler(b.client).Subscription().Reconcile),
 		)
 	}
 
@@ -822,6 +821,10 @@ func (b *BootOptionsBuilder) finalStepsWorkflow() *controller.Workflow {
 		workflow.Tasks = append(workflow.Tasks, traceReconcileFunc("finalize.extensions", extension.Reconcile))
 	}
 
+	if b.extensionManager != nil {
+		workflow.Tasks = append(workflow.Tasks, traceReconcileFunc("finalize.remote_extensions", b.extensionManager.ReconcileRemoteExtensions))
+	}
+
 	return workflow
 }
 
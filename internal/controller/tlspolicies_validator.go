@@ -3,7 +3,6 @@ package controllers
 import (
 	"context"
 	"errors"
-	"reflect"
 	"sync"
 
 	certmanv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
@@ -117,24 +116,24 @@ func (r *TLSPoliciesValidator) isMissingDependency() error {
 	return nil
 }
 
-// isTargetRefsFound Policies are already linked to their targets. If the target ref length and length of targetables by this policy is not the same,
-// then the policy could not find the target
-// TODO: What should happen if multiple target refs is supported in the future in terms of reporting in log and policy status?
+// isTargetRefsFound Policies are already linked to their targets. If none of the targets of the policy is linked to it,
+// then the policy could not find any target. Targets that could not be found when others were are reported in the status of the policy.
 func (r *TLSPoliciesValidator) isTargetRefsFound(topology *machinery.Topology, p *kuadrantv1.TLSPolicy) error {
-	if len(p.GetTargetRefs()) != len(topology.Targetables().Children(p)) {
-		return kuadrant.NewErrTargetNotFound(kuadrantv1.TLSPolicyGroupKind.Kind, p.Spec.TargetRef.LocalPolicyTargetReference, apierrors.NewNotFound(controller.GatewaysResource.GroupResource(), p.GetName()))
+	if isPolicyTargetNotFound(p, topology) {
+		targetRef := p.GetTargetRefs()[0].(machinery.LocalPolicyTargetReferenceWithSectionName)
+		return kuadrant.NewErrTargetNotFound(kuadrantv1.TLSPolicyGroupKind.Kind, targetRef.LocalPolicyTargetReference, apierrors.NewNotFound(controller.GatewaysResource.GroupResource(), p.GetName()))
 	}
 
 	return nil
 }
 
-// isConflict Validates if there's already an older policy with the same target ref
+// isConflict Validates if there's already an older policy sharing a target ref
 func (r *TLSPoliciesValidator) isConflict(policies []machinery.Policy, p *kuadrantv1.TLSPolicy) error {
 	conflictingP, ok := lo.Find(policies, func(item machinery.Policy) bool {
 		conflictTLSPolicy := item.(*kuadrantv1.TLSPolicy)
 		return p != conflictTLSPolicy && conflictTLSPolicy.DeletionTimestamp == nil &&
 			conflictTLSPolicy.CreationTimestamp.Before(&p.CreationTimestamp) &&
-			reflect.DeepEqual(conflictTLSPolicy.GetTargetRefs(), p.GetTargetRefs())
+			targetRefsOverlap(conflictTLSPolicy, p)
 	})
 
 	if ok {
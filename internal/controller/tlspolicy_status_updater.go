@@ -82,6 +82,9 @@ func (t *TLSPolicyStatusUpdater) UpdateStatus(ctx context.Context, _ []controlle
 
 		_, err := IsTLSPolicyValid(ctx, s, p)
 		meta.SetStatusCondition(&newStatus.Conditions, *kuadrant.AcceptedCondition(p, err))
+		if len(p.Spec.TargetRefs) > 0 {
+			newStatus.Targets = policyTargetStatuses(p, topology, p.Status.Targets)
+		}
 
 		// Do not set enforced condition if Accepted condition is false
 		if meta.IsStatusConditionFalse(newStatus.Conditions, string(gatewayapiv1alpha2.PolicyReasonAccepted)) {
@@ -12,7 +12,6 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/utils/ptr"
 	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 
@@ -70,18 +69,14 @@ func (r *TokenRateLimitPolicyValidator) Validate(ctx context.Context, _ []contro
 			err = missingDependencyErr
 			span.RecordError(err)
 			span.SetStatus(codes.Error, "missing dependency")
-		} else if len(policy.GetTargetRefs()) > 0 && len(topology.Targetables().Children(policy)) == 0 {
-			ref := policy.GetTargetRefs()[0]
-			var res schema.GroupResource
-			switch ref.GroupVersionKind().Kind {
-			case machinery.GatewayGroupKind.Kind:
-				res = controller.GatewaysResource.GroupResource()
-			case machinery.HTTPRouteGroupKind.Kind:
-				res = controller.HTTPRoutesResource.GroupResource()
-			}
-			err = kuadrant.NewErrPolicyTargetNotFound(kuadrantv1alpha1.TokenRateLimitPolicyGroupKind.Kind, ref, apierrors.NewNotFound(res, ref.GetName()))
+		} else if isPolicyTargetNotFound(policy, topology) {
+			err = policyTargetNotFoundError(kuadrantv1alpha1.TokenRateLimitPolicyGroupKind.Kind, policy.GetTargetRefs()[0])
 			span.RecordError(err)
 			span.SetStatus(codes.Error, "target not found")
+		} else if validateErr := p.Validate(); validateErr != nil {
+			err = kuadrant.NewErrInvalid(kuadrantv1alpha1.TokenRateLimitPolicyGroupKind.Kind, validateErr)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "validation failed")
 		} else {
 			span.AddEvent("policy validated successfully")
 			span.SetStatus(codes.Ok, "")
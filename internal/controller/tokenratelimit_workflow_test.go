@@ -3,10 +3,13 @@
 package controllers
 
 import (
+	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/samber/lo"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 
 	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
@@ -376,10 +379,267 @@ func TestWasmActionsFromTokenLimit(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			computedActions := wasmActionsFromTokenLimit(tc.tokenLimit, tc.limitIdentifier, tc.scope, "test/policy/locator", tc.topLevelPredicates)
+			computedActions := wasmActionsFromTokenLimit(tc.tokenLimit, tc.limitIdentifier, tc.scope, "test/policy/locator", tc.topLevelPredicates, nil)
 			if diff := cmp.Diff(tc.expectedActions, computedActions); diff != "" {
 				t.Errorf("unexpected wasm actions (-want +got):\n%s", diff)
 			}
 		})
 	}
 }
+
+func TestTokenUsageHitsAddend(t *testing.T) {
+	testCases := []struct {
+		name       string
+		tokenLimit *kuadrantv1alpha1.TokenLimit
+		expected   string
+	}{
+		{
+			name:       "defaults to total tokens of the response body",
+			tokenLimit: &kuadrantv1alpha1.TokenLimit{},
+			expected:   `responseBodyJSON("/usage/total_tokens")`,
+		},
+		{
+			name:       "input token class",
+			tokenLimit: &kuadrantv1alpha1.TokenLimit{TokenClass: kuadrantv1alpha1.TokenClassInput},
+			expected:   `responseBodyJSON("/usage/prompt_tokens")`,
+		},
+		{
+			name:       "output token class",
+			tokenLimit: &kuadrantv1alpha1.TokenLimit{TokenClass: kuadrantv1alpha1.TokenClassOutput},
+			expected:   `responseBodyJSON("/usage/completion_tokens")`,
+		},
+		{
+			name:       "json pointer",
+			tokenLimit: &kuadrantv1alpha1.TokenLimit{TokenUsage: &kuadrantv1alpha1.TokenUsage{JSONPointer: "/usage/output_tokens"}},
+			expected:   `responseBodyJSON("/usage/output_tokens")`,
+		},
+		{
+			name: "json pointer takes precedence over the token class default",
+			tokenLimit: &kuadrantv1alpha1.TokenLimit{
+				TokenClass: kuadrantv1alpha1.TokenClassOutput,
+				TokenUsage: &kuadrantv1alpha1.TokenUsage{JSONPointer: "/usage/output_tokens"},
+			},
+			expected: `responseBodyJSON("/usage/output_tokens")`,
+		},
+		{
+			name:       "cel expression",
+			tokenLimit: &kuadrantv1alpha1.TokenLimit{TokenUsage: &kuadrantv1alpha1.TokenUsage{Expression: `responseBodyJSON("/usage/input_tokens") + responseBodyJSON("/usage/output_tokens")`}},
+			expected:   `responseBodyJSON("/usage/input_tokens") + responseBodyJSON("/usage/output_tokens")`,
+		},
+		{
+			name:       "response header",
+			tokenLimit: &kuadrantv1alpha1.TokenLimit{TokenUsage: &kuadrantv1alpha1.TokenUsage{Header: "X-Usage-Tokens"}},
+			expected:   `int(response.headers["x-usage-tokens"])`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tokenUsageHitsAddend(tc.tokenLimit); got != tc.expected {
+				t.Errorf("unexpected hits addend, expected(%s), got (%s)", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestWasmActionsFromTokenLimitWithEstimate(t *testing.T) {
+	testCases := []struct {
+		name                       string
+		estimate                   *kuadrantv1alpha1.TokenEstimate
+		expectedRequestService     string
+		expectedRequestHitsAddend  string
+		expectedRequestData        map[string]string
+		expectedResponseHitsAddend string
+	}{
+		{
+			name:                       "no estimate",
+			expectedRequestService:     wasm.RateLimitCheckServiceName,
+			expectedRequestHitsAddend:  "0",
+			expectedResponseHitsAddend: `responseBodyJSON("/usage/total_tokens")`,
+		},
+		{
+			name:                       "estimate checked against the remaining budget",
+			estimate:                   &kuadrantv1alpha1.TokenEstimate{Expression: `requestBodyJSON("/max_tokens")`},
+			expectedRequestService:     wasm.RateLimitCheckServiceName,
+			expectedRequestHitsAddend:  `(requestBodyJSON("/max_tokens")) != null ? (requestBodyJSON("/max_tokens")) : 0`,
+			expectedResponseHitsAddend: `responseBodyJSON("/usage/total_tokens")`,
+		},
+		{
+			name:                       "estimate reserved up front",
+			estimate:                   &kuadrantv1alpha1.TokenEstimate{Expression: `requestBodyJSON("/max_tokens")`, Reserve: true},
+			expectedRequestService:     wasm.RateLimitServiceName,
+			expectedRequestHitsAddend:  `(requestBodyJSON("/max_tokens")) != null ? (requestBodyJSON("/max_tokens")) : 0`,
+			expectedRequestData:        map[string]string{"tokenlimit.myTokenLimit__d681f6c3.reserved": `(requestBodyJSON("/max_tokens")) != null ? (requestBodyJSON("/max_tokens")) : 0`},
+			expectedResponseHitsAddend: `(responseBodyJSON("/usage/total_tokens")) > int(string(filter_state["wasm.kuadrant.tokenlimit.myTokenLimit__d681f6c3.reserved"])) ? (responseBodyJSON("/usage/total_tokens")) - int(string(filter_state["wasm.kuadrant.tokenlimit.myTokenLimit__d681f6c3.reserved"])) : 0`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actions := wasmActionsFromTokenLimit(&kuadrantv1alpha1.TokenLimit{Estimate: tc.estimate}, "tokenlimit.myTokenLimit__d681f6c3", "my-ns/my-route", "test/policy/locator", nil, nil)
+			if len(actions) != 2 {
+				t.Fatalf("expected 2 actions, got %d", len(actions))
+			}
+			if actions[0].ServiceName != tc.expectedRequestService {
+				t.Errorf("unexpected request phase service, expected(%s), got (%s)", tc.expectedRequestService, actions[0].ServiceName)
+			}
+			if got := wasmActionHitsAddend(actions[0]); got != tc.expectedRequestHitsAddend {
+				t.Errorf("unexpected request phase hits addend, expected(%s), got (%s)", tc.expectedRequestHitsAddend, got)
+			}
+			if !reflect.DeepEqual(actions[0].RequestData, tc.expectedRequestData) {
+				t.Errorf("unexpected request data, expected(%v), got (%v)", tc.expectedRequestData, actions[0].RequestData)
+			}
+			if actions[1].ServiceName != wasm.RateLimitReportServiceName {
+				t.Errorf("unexpected response phase service, expected(%s), got (%s)", wasm.RateLimitReportServiceName, actions[1].ServiceName)
+			}
+			if got := wasmActionHitsAddend(actions[1]); got != tc.expectedResponseHitsAddend {
+				t.Errorf("unexpected response phase hits addend, expected(%s), got (%s)", tc.expectedResponseHitsAddend, got)
+			}
+		})
+	}
+}
+
+func TestWasmActionsFromTokenLimitCost(t *testing.T) {
+	tokenLimit := &kuadrantv1alpha1.TokenLimit{Unit: kuadrantv1alpha1.TokenLimitUnitCost}
+	models := map[string]kuadrantv1alpha1.TokenPrice{"gpt-4o": {Input: 2500, Output: 10000}}
+
+	// model read from the response is evaluated in the response phase
+	actions := wasmActionsFromTokenLimit(tokenLimit, "tokenlimit.spend__d681f6c3", "my-ns/my-route", "test/policy/locator", nil, &kuadrantv1alpha1.TokenPricing{Models: models})
+	if actions[0].RequestData != nil {
+		t.Errorf("unexpected request data, got (%v)", actions[0].RequestData)
+	}
+	if got := wasmActionHitsAddend(actions[1]); !strings.Contains(got, `responseBodyJSON("/model")`) {
+		t.Errorf("unexpected response phase hits addend, got (%s)", got)
+	}
+
+	// model read from the request is captured in the request phase
+	actions = wasmActionsFromTokenLimit(tokenLimit, "tokenlimit.spend__d681f6c3", "my-ns/my-route", "test/policy/locator", nil, &kuadrantv1alpha1.TokenPricing{Model: `requestBodyJSON("/model")`, Models: models})
+	expectedRequestData := map[string]string{"tokenlimit.spend__d681f6c3.model": `requestBodyJSON("/model")`}
+	if !reflect.DeepEqual(actions[0].RequestData, expectedRequestData) {
+		t.Errorf("unexpected request data, expected(%v), got (%v)", expectedRequestData, actions[0].RequestData)
+	}
+	got := wasmActionHitsAddend(actions[1])
+	if strings.Contains(got, "requestBodyJSON") || !strings.Contains(got, `string(filter_state["wasm.kuadrant.tokenlimit.spend__d681f6c3.model"])`) {
+		t.Errorf("unexpected response phase hits addend, got (%s)", got)
+	}
+
+	// model read from a request header is evaluated in the response phase, as the request attributes are kept
+	actions = wasmActionsFromTokenLimit(tokenLimit, "tokenlimit.spend__d681f6c3", "my-ns/my-route", "test/policy/locator", nil, &kuadrantv1alpha1.TokenPricing{Model: `request.headers["x-model"]`, Models: models})
+	if actions[0].RequestData != nil {
+		t.Errorf("unexpected request data, got (%v)", actions[0].RequestData)
+	}
+	if got := wasmActionHitsAddend(actions[1]); !strings.Contains(got, `request.headers["x-model"]`) || strings.Contains(got, "filter_state") {
+		t.Errorf("unexpected response phase hits addend, got (%s)", got)
+	}
+}
+
+func TestTokenLimitUsesRequestData(t *testing.T) {
+	models := map[string]kuadrantv1alpha1.TokenPrice{"gpt-4o": {Input: 2500, Output: 10000}}
+	testCases := []struct {
+		name       string
+		tokenLimit *kuadrantv1alpha1.TokenLimit
+		pricing    *kuadrantv1alpha1.TokenPricing
+		expected   bool
+	}{
+		{name: "no estimate", tokenLimit: &kuadrantv1alpha1.TokenLimit{}},
+		{name: "estimate checked", tokenLimit: &kuadrantv1alpha1.TokenLimit{Estimate: &kuadrantv1alpha1.TokenEstimate{Expression: `requestBodyJSON("/max_tokens")`}}},
+		{name: "estimate reserved", tokenLimit: &kuadrantv1alpha1.TokenLimit{Estimate: &kuadrantv1alpha1.TokenEstimate{Expression: `requestBodyJSON("/max_tokens")`, Reserve: true}}, expected: true},
+		{name: "model from the response body", tokenLimit: &kuadrantv1alpha1.TokenLimit{Unit: kuadrantv1alpha1.TokenLimitUnitCost}, pricing: &kuadrantv1alpha1.TokenPricing{Models: models}},
+		{name: "model from the request body", tokenLimit: &kuadrantv1alpha1.TokenLimit{Unit: kuadrantv1alpha1.TokenLimitUnitCost}, pricing: &kuadrantv1alpha1.TokenPricing{Model: `requestBodyJSON("/model")`, Models: models}, expected: true},
+		{name: "model from the request body of a limit not priced", tokenLimit: &kuadrantv1alpha1.TokenLimit{}, pricing: &kuadrantv1alpha1.TokenPricing{Model: `requestBodyJSON("/model")`, Models: models}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tokenLimitUsesRequestData(tc.tokenLimit, tc.pricing); got != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestTokenCostHitsAddend(t *testing.T) {
+	pricing := &kuadrantv1alpha1.TokenPricing{
+		Models: map[string]kuadrantv1alpha1.TokenPrice{
+			"gpt-4o":      {Input: 2500, Output: 10000},
+			"gpt-4o-mini": {Input: 150, Output: 600},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		pricing  *kuadrantv1alpha1.TokenPricing
+		expected string
+	}{
+		{
+			name:     "model from the response body",
+			pricing:  pricing,
+			expected: `int(responseBodyJSON("/usage/prompt_tokens")) * (responseBodyJSON("/model") in {"gpt-4o": 2500, "gpt-4o-mini": 150} ? {"gpt-4o": 2500, "gpt-4o-mini": 150}[responseBodyJSON("/model")] : 0) + int(responseBodyJSON("/usage/completion_tokens")) * (responseBodyJSON("/model") in {"gpt-4o": 10000, "gpt-4o-mini": 600} ? {"gpt-4o": 10000, "gpt-4o-mini": 600}[responseBodyJSON("/model")] : 0)`,
+		},
+		{
+			name: "model from the request body with default price",
+			pricing: &kuadrantv1alpha1.TokenPricing{
+				Model:   `requestBodyJSON("/model")`,
+				Models:  map[string]kuadrantv1alpha1.TokenPrice{"gpt-4o": {Input: 2500, Output: 10000}},
+				Default: &kuadrantv1alpha1.TokenPrice{Input: 5000, Output: 20000},
+			},
+			expected: `int(responseBodyJSON("/usage/prompt_tokens")) * (requestBodyJSON("/model") in {"gpt-4o": 2500} ? {"gpt-4o": 2500}[requestBodyJSON("/model")] : 5000) + int(responseBodyJSON("/usage/completion_tokens")) * (requestBodyJSON("/model") in {"gpt-4o": 10000} ? {"gpt-4o": 10000}[requestBodyJSON("/model")] : 20000)`,
+		},
+		{
+			name: "token usage of a non OpenAI-style response",
+			pricing: &kuadrantv1alpha1.TokenPricing{
+				Models:           map[string]kuadrantv1alpha1.TokenPrice{"claude": {Input: 3000, Output: 15000}},
+				InputTokenUsage:  &kuadrantv1alpha1.TokenUsage{JSONPointer: "/usage/input_tokens"},
+				OutputTokenUsage: &kuadrantv1alpha1.TokenUsage{Header: "X-Output-Tokens"},
+			},
+			expected: `int(responseBodyJSON("/usage/input_tokens")) * (responseBodyJSON("/model") in {"claude": 3000} ? {"claude": 3000}[responseBodyJSON("/model")] : 0) + int(int(response.headers["x-output-tokens"])) * (responseBodyJSON("/model") in {"claude": 15000} ? {"claude": 15000}[responseBodyJSON("/model")] : 0)`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tokenCostHitsAddend(tc.pricing, tc.pricing.ModelExpression()); got != tc.expected {
+				t.Errorf("unexpected hits addend, expected(%s), got (%s)", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestWasmDataFromRates(t *testing.T) {
+	rates := []kuadrantv1.Rate{
+		{Limit: 100, Calendar: kuadrantv1.CalendarPeriodDay, TimeZone: "Europe/Madrid"},
+		{Limit: 10, Window: "1m", TimeZone: "Europe/Madrid", Hours: &kuadrantv1.HourRange{From: 9, To: 18}},
+		{Limit: 20, Window: "1m", TimeZone: "Europe/Madrid", Hours: &kuadrantv1.HourRange{From: 18, To: 9}},
+		{Limit: 5, Window: "1s"},
+	}
+
+	data := wasmDataFromRates(rates)
+	keys := lo.Map(data, func(d wasm.DataType, _ int) string {
+		return d.Value.(*wasm.Expression).ExpressionItem.Key
+	})
+	expected := []string{"calendar.day.Europe/Madrid", "calendar.hour.Europe/Madrid"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("unexpected descriptor keys, expected(%v), got (%v)", expected, keys)
+	}
+
+	actions := wasmActionsFromTokenLimit(&kuadrantv1alpha1.TokenLimit{Rates: rates}, "tokenlimit.myTokenLimit__d681f6c3", "my-ns/my-route", "test/policy/locator", nil, nil)
+	for _, action := range actions {
+		found := lo.ContainsBy(action.ConditionalData[0].Data, func(d wasm.DataType) bool {
+			expr, ok := d.Value.(*wasm.Expression)
+			return ok && expr.ExpressionItem.Key == "calendar.day.Europe/Madrid"
+		})
+		if !found {
+			t.Errorf("expected calendar period descriptor in %s action", action.ServiceName)
+		}
+	}
+}
+
+// wasmActionHitsAddend returns the expression of the hits addend sent by a rate limit action
+func wasmActionHitsAddend(action wasm.Action) string {
+	for _, data := range action.ConditionalData[0].Data {
+		if expr, ok := data.Value.(*wasm.Expression); ok && expr.ExpressionItem.Key == "ratelimit.hits_addend" {
+			return expr.ExpressionItem.Value
+		}
+	}
+	return ""
+}
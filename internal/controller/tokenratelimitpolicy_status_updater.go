@@ -7,7 +7,6 @@ import (
 	"strings"
 	"sync"
 
-	envoygatewayv1alpha1 "github.com/envoyproxy/gateway/api/v1alpha1"
 	limitadorv1alpha1 "github.com/kuadrant/limitador-operator/api/v1alpha1"
 	"github.com/kuadrant/policy-machinery/controller"
 	"github.com/kuadrant/policy-machinery/machinery"
@@ -19,9 +18,6 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/utils/ptr"
-	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
-	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 
 	kuadrantv1 "github.com/kuadrant/kuadrant-operator/api/v1"
 	kuadrantv1alpha1 "github.com/kuadrant/kuadrant-operator/api/v1alpha1"
@@ -36,7 +32,8 @@ import (
 )
 
 type TokenRateLimitPolicyStatusUpdater struct {
-	client *dynamic.DynamicClient
+	client           *dynamic.DynamicClient
+	gatewayProviders []GatewayProvider
 }
 
 // TokenRateLimitPolicyStatusUpdater subscribes to events with potential impact on the status of TokenRateLimitPolicy resources
@@ -53,6 +50,7 @@ func (r *TokenRateLimitPolicyStatusUpdater) Subscription() controller.Subscripti
 			{Kind: &kuadrantistio.EnvoyFilterGroupKind},
 			{Kind: &kuadrantenvoygateway.EnvoyPatchPolicyGroupKind},
 			{Kind: &kuadrantenvoygateway.EnvoyExtensionPolicyGroupKind},
+			{Kind: &ConfigMapGroupKind},
 		},
 	}
 }
@@ -101,8 +99,9 @@ func (r *TokenRateLimitPolicyStatusUpdater) UpdateStatus(ctx context.Context, _
 		if !accepted {
 			meta.RemoveStatusCondition(&newStatus.Conditions, string(kuadrant.PolicyConditionEnforced))
 		} else {
-			enforcedCond := r.enforcedCondition(policy, topology, state)
+			enforcedCond, shadowed := r.enforcedCondition(policy, topology, state)
 			meta.SetStatusCondition(&newStatus.Conditions, *enforcedCond)
+			newStatus.ShadowedRules = shadowed
 		}
 
 		equalStatus := equality.Semantic.DeepEqual(newStatus, policy.Status)
@@ -142,16 +141,16 @@ func (r *TokenRateLimitPolicyStatusUpdater) UpdateStatus(ctx context.Context, _
 	return nil
 }
 
-func (r *TokenRateLimitPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1alpha1.TokenRateLimitPolicy, topology *machinery.Topology, state *sync.Map) *metav1.Condition {
+func (r *TokenRateLimitPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1alpha1.TokenRateLimitPolicy, topology *machinery.Topology, state *sync.Map) (*metav1.Condition, []kuadrantv1.ShadowedRule) {
 	kObj := GetKuadrantFromTopology(topology, state)
 	if kObj == nil {
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrSystemResource("kuadrant"), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrSystemResource("kuadrant"), false), nil
 	}
 	policyKind := kuadrantv1alpha1.TokenRateLimitPolicyGroupKind.Kind
 
 	effectivePolicies, ok := state.Load(StateEffectiveTokenRateLimitPolicies)
 	if !ok {
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrUnknown(policyKind, fmt.Errorf("missing effective token rate limit policies state")), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrUnknown(policyKind, fmt.Errorf("missing effective token rate limit policies state")), false), nil
 	}
 
 	type affectedGateway struct {
@@ -162,6 +161,7 @@ func (r *TokenRateLimitPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1
 	// check the state of the rules of the policy in the effective policies
 	policyRuleKeys := lo.Keys(policy.Rules())
 	overridingPolicies := map[string][]string{}      // policyRuleKey → locators of policies overriding the policy rule
+	shadowedPaths := map[string][]string{}           // policyRuleKey → IDs of the paths where the policy rule is overridden
 	affectedGateways := map[string]affectedGateway{} // Gateway locator → {GatewayClass, Gateway}
 
 	var rateLimitCelValidationErrors []error
@@ -200,6 +200,7 @@ func (r *TokenRateLimitPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1
 					// to the effective policy are the ones that overrode this policy
 					overridingPolicies[policyRuleKey] = append(overridingPolicies[policyRuleKey], effectivePolicy.SourcePolicies...)
 				}
+				shadowedPaths[policyRuleKey] = append(shadowedPaths[policyRuleKey], kuadrantv1.PathID(effectivePolicy.Path))
 				continue
 			}
 			// policy rule is in the effective policy, track the Gateway affected by the policy
@@ -218,32 +219,33 @@ func (r *TokenRateLimitPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1
 			}
 			overriddenPolicyRules = append(overriddenPolicyRules, fmt.Sprintf("%s%s", policyRuleKey, overriddenByMessage))
 		}
+		slices.Sort(overriddenPolicyRules)
 		message = fmt.Sprintf("policy rule(s) overridden: %s", strings.Join(overriddenPolicyRules, ", "))
 	}
 
 	if len(affectedGateways) == 0 { // no rules of the policy found in the effective policies
 		if len(overridingPolicies) == 0 { // no rules of the policy have been overridden by any other policy
-			return kuadrant.EnforcedCondition(policy, kuadrant.NewErrNoRoutes(policyKind), false)
+			return kuadrant.EnforcedCondition(policy, kuadrant.NewErrNoRoutes(policyKind), false), shadowedRules(overridingPolicies, shadowedPaths)
 		}
 		// all rules of the policy have been overridden by at least one other policy
 		overridingPoliciesKeys := lo.FilterMap(lo.Uniq(lo.Flatten(lo.Values(overridingPolicies))), func(policyLocator string, _ int) (k8stypes.NamespacedName, bool) {
 			policyKey, err := kuadrantpolicymachinery.NamespacedNameFromLocator(policyLocator)
 			return policyKey, err == nil
 		})
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrOverridden(policyKind, overridingPoliciesKeys), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrOverridden(policyKind, overridingPoliciesKeys), false), shadowedRules(overridingPolicies, shadowedPaths)
 	}
 
 	// check if any policy rule of the policy has been overridden by a more specific policy
 	if len(overridingPolicies) > 0 {
 		if message != "" {
-			return kuadrant.EnforcedCondition(policy, kuadrant.NewErrUnknown(policyKind, fmt.Errorf("%s", message)), false)
+			return kuadrant.EnforcedCondition(policy, kuadrant.NewErrUnknown(policyKind, fmt.Errorf("%s", message)), false), shadowedRules(overridingPolicies, shadowedPaths)
 		}
 		// all rules of the policy have been overridden by at least one other policy
 		overridingPoliciesKeys := lo.FilterMap(lo.Uniq(lo.Flatten(lo.Values(overridingPolicies))), func(policyLocator string, _ int) (k8stypes.NamespacedName, bool) {
 			policyKey, err := kuadrantpolicymachinery.NamespacedNameFromLocator(policyLocator)
 			return policyKey, err == nil
 		})
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrOverridden(policyKind, overridingPoliciesKeys), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrOverridden(policyKind, overridingPoliciesKeys), false), shadowedRules(overridingPolicies, shadowedPaths)
 	}
 
 	var componentsToSync []string
@@ -254,7 +256,7 @@ func (r *TokenRateLimitPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1
 	} else {
 		limitador := GetLimitadorFromTopology(topology, state)
 		if limitador == nil {
-			return kuadrant.EnforcedCondition(policy, kuadrant.NewErrSystemResource("limitador"), false)
+			return kuadrant.EnforcedCondition(policy, kuadrant.NewErrSystemResource("limitador"), false), shadowedRules(overridingPolicies, shadowedPaths)
 		}
 		if !meta.IsStatusConditionTrue(limitador.Status.Conditions, limitadorv1alpha1.StatusConditionReady) {
 			componentsToSync = append(componentsToSync, kuadrantv1beta1.LimitadorGroupKind.Kind)
@@ -263,45 +265,43 @@ func (r *TokenRateLimitPolicyStatusUpdater) enforcedCondition(policy *kuadrantv1
 
 	// check the status of the gateways' configuration resources
 	for _, g := range affectedGateways {
-		controllerName := g.gatewayClass.Spec.ControllerName
-		switch defaultGatewayControllerName(controllerName) {
-		case defaultIstioGatewayControllerName:
-			// EnvoyFilter (rate limit clusters - shared with RateLimitPolicy)
-			istioRateLimitClustersModifiedGateways, _ := state.Load(StateIstioRateLimitClustersModified)
-			componentsToSync = append(componentsToSync, gatewayComponentsToSyncWithName(g.gateway, kuadrantistio.EnvoyFilterGroupKind, RateLimitClusterName(g.gateway.GetName()), istioRateLimitClustersModifiedGateways, topology, func(_ machinery.Object) bool {
-				return true // Istio won't ever populate the status stanza of EnvoyFilter resources, so we cannot expect to find a given a condition there
-			})...)
-			// EnvoyFilter (wasm plugin)
-			istioExtensionsModifiedGateways, _ := state.Load(StateIstioExtensionsModified)
-			componentsToSync = append(componentsToSync, gatewayComponentsToSyncWithName(g.gateway, kuadrantistio.EnvoyFilterGroupKind, wasm.ExtensionName(g.gateway.GetName()), istioExtensionsModifiedGateways, topology, func(_ machinery.Object) bool {
-				return true // Istio won't ever populate the status stanza of EnvoyFilter resources, so we cannot expect to find a given a condition there
-			})...)
-		case defaultEnvoyGatewayGatewayControllerName:
-			gatewayAncestor := gatewayapiv1.ParentReference{Name: gatewayapiv1.ObjectName(g.gateway.GetName()), Namespace: ptr.To(gatewayapiv1.Namespace(g.gateway.GetNamespace()))}
-			// EnvoyPatchPolicy (shared with RateLimitPolicy)
-			envoyGatewayRateLimitClustersModifiedGateways, _ := state.Load(StateEnvoyGatewayRateLimitClustersModified)
-			componentsToSync = append(componentsToSync, gatewayComponentsToSync(g.gateway, kuadrantenvoygateway.EnvoyPatchPolicyGroupKind, envoyGatewayRateLimitClustersModifiedGateways, topology, func(obj machinery.Object) bool {
-				return meta.IsStatusConditionTrue(kuadrantgatewayapi.PolicyStatusConditionsFromAncestor(obj.(*controller.RuntimeObject).Object.(*envoygatewayv1alpha1.EnvoyPatchPolicy).Status, controllerName, gatewayAncestor, gatewayapiv1.Namespace(obj.GetNamespace())), string(envoygatewayv1alpha1.PolicyConditionProgrammed))
-			})...)
-			// EnvoyExtensionPolicy
-			envoyGatewayExtensionsModifiedGateways, _ := state.Load(StateEnvoyGatewayExtensionsModified)
-			componentsToSync = append(componentsToSync, gatewayComponentsToSync(g.gateway, kuadrantenvoygateway.EnvoyExtensionPolicyGroupKind, envoyGatewayExtensionsModifiedGateways, topology, func(obj machinery.Object) bool {
-				return meta.IsStatusConditionTrue(kuadrantgatewayapi.PolicyStatusConditionsFromAncestor(obj.(*controller.RuntimeObject).Object.(*envoygatewayv1alpha1.EnvoyExtensionPolicy).Status, controllerName, gatewayAncestor, gatewayapiv1.Namespace(obj.GetNamespace())), string(gatewayapiv1alpha2.PolicyConditionAccepted))
-			})...)
-		default:
+		provider, found := gatewayProviderFor(r.gatewayProviders, g.gatewayClass.Spec.ControllerName)
+		if !found {
 			componentsToSync = append(componentsToSync, fmt.Sprintf("%s (%s/%s)", machinery.GatewayGroupKind.Kind, g.gateway.GetNamespace(), g.gateway.GetName()))
+			continue
 		}
+		componentsToSync = append(componentsToSync, provider.ComponentsToSync(kuadrantv1alpha1.TokenRateLimitPolicyGroupKind, g.gateway, g.gatewayClass, topology, state)...)
 	}
 
 	if len(rateLimitCelValidationErrors) > 0 {
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrCelValidation(rateLimitCelValidationErrors), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrCelValidation(rateLimitCelValidationErrors), false), shadowedRules(overridingPolicies, shadowedPaths)
 	}
 
 	if len(componentsToSync) > 0 {
-		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrOutOfSync(policyKind, componentsToSync), false)
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrOutOfSync(policyKind, componentsToSync), false), shadowedRules(overridingPolicies, shadowedPaths)
+	}
+
+	if features := nativeDataPlaneUnsupportedFeatures(state)[policy.GetLocator()]; len(features) > 0 {
+		return kuadrant.EnforcedCondition(policy, kuadrant.NewErrUnsupportedFeature(policyKind, features), false), shadowedRules(overridingPolicies, shadowedPaths)
+	}
+
+	gateways := lo.MapToSlice(affectedGateways, func(_ string, g affectedGateway) *machinery.Gateway { return g.gateway })
+	if lo.SomeBy(lo.Values(policy.Spec.Proper().Limits), func(limit kuadrantv1alpha1.TokenLimit) bool {
+		return tokenLimitUsesRequestData(&limit, policy.Spec.Proper().Pricing)
+	}) {
+		if err := shimFeatureUnsupportedError(policyKind, "reserved token estimates or a pricing model read from the request body", wasm.ShimFeatureRequestData, gateways); err != nil {
+			return kuadrant.EnforcedCondition(policy, err, false), shadowedRules(overridingPolicies, shadowedPaths)
+		}
+	}
+
+	if policy.InShadowMode() {
+		if err := shadowModeUnsupportedError(policyKind, gateways); err != nil {
+			return kuadrant.EnforcedCondition(policy, err, false), shadowedRules(overridingPolicies, shadowedPaths)
+		}
+		return kuadrant.ShadowCondition(policy), shadowedRules(overridingPolicies, shadowedPaths)
 	}
 
-	return kuadrant.EnforcedCondition(policy, nil, len(overridingPolicies) == 0)
+	return kuadrant.EnforcedCondition(policy, nil, len(overridingPolicies) == 0), shadowedRules(overridingPolicies, shadowedPaths)
 }
 
 func NewTokenRateLimitPolicyStatusUpdater(client *dynamic.DynamicClient) *TokenRateLimitPolicyStatusUpdater {
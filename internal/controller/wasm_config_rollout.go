@@ -0,0 +1,548 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
+	"github.com/kuadrant/kuadrant-operator/internal/wasm"
+)
+
+const (
+	// wasmConfigRolloutCheckInterval is how often the canary gateways are checked during the bake time, including how
+	// often the error metrics of the canary gateways are queried
+	wasmConfigRolloutCheckInterval = 30 * time.Second
+
+	wasmConfigRolloutAnalysisTimeout = 5 * time.Second
+
+	StateWasmConfigRollout = "WasmConfigRollout"
+)
+
+// WasmConfigRolloutReconciler plans the rollout of the changes of the wasm configuration to the gateways.
+// It runs after the effective policies are computed and before the extension reconcilers of the gateway providers,
+// which then only apply the changes to the gateways the rollout admits.
+type WasmConfigRolloutReconciler struct {
+	gatewayControllerNames []gatewayapiv1.GatewayController
+	analyze                wasmConfigRolloutAnalyzer
+	rollout                *wasmConfigRolloutTracker
+}
+
+func NewWasmConfigRolloutReconciler(gatewayProviders []GatewayProvider, rollout *wasmConfigRolloutTracker) *WasmConfigRolloutReconciler {
+	return &WasmConfigRolloutReconciler{
+		gatewayControllerNames: lo.FlatMap(gatewayProviders, func(provider GatewayProvider, _ int) []gatewayapiv1.GatewayController {
+			return provider.GatewayControllerNames()
+		}),
+		analyze: queryPrometheusAnalysis(&http.Client{Timeout: wasmConfigRolloutAnalysisTimeout}),
+		rollout: rollout,
+	}
+}
+
+// Run stores the rollout in the state, for the reconcilers of the gateways and of the shared resources, and plans it
+// on the events of the subscription
+func (r *WasmConfigRolloutReconciler) Run(ctx context.Context, events []controller.ResourceEvent, topology *machinery.Topology, err error, state *sync.Map) error {
+	state.Store(StateWasmConfigRollout, r.rollout)
+	return r.Subscription().Reconcile(ctx, events, topology, err, state)
+}
+
+func (r *WasmConfigRolloutReconciler) Subscription() controller.Subscription {
+	return controller.Subscription{
+		ReconcileFunc: r.Reconcile,
+		Events:        dataPlaneEffectivePoliciesEventMatchers,
+	}
+}
+
+func (r *WasmConfigRolloutReconciler) Reconcile(ctx context.Context, _ []controller.ResourceEvent, topology *machinery.Topology, _ error, state *sync.Map) error {
+	logger := controller.LoggerFromContext(ctx).WithName("WasmConfigRolloutReconciler").WithValues("context", ctx)
+
+	kObj := GetKuadrantFromTopology(topology, state)
+	if kObj == nil || !kObj.Spec.WasmConfigRollout.IsEnabled() {
+		r.rollout.Disable()
+		return nil
+	}
+
+	wasmConfigs, err := buildWasmConfigs(ctx, topology, state, r.gatewayControllerNames)
+	if err != nil {
+		if errors.Is(err, ErrMissingStateEffectiveAuthPolicies) {
+			logger.V(1).Info(err.Error())
+			return nil
+		}
+		return err
+	}
+
+	gateways := lo.Map(topology.Targetables().Items(func(o machinery.Object) bool {
+		return o.GroupVersionKind().GroupKind() == machinery.GatewayGroupKind
+	}), func(g machinery.Targetable, _ int) *machinery.Gateway {
+		return g.(*machinery.Gateway)
+	})
+
+	canaries, err := r.canaryGateways(topology, gateways, kObj.Spec.WasmConfigRollout.CanaryGatewaySelector)
+	if err != nil {
+		logger.Error(err, "invalid canary gateway selector, rolling out to all the gateways at once")
+	}
+
+	r.rollout.Plan(ctx, logger, kObj, wasmConfigs, gateways, canaries, r.analyze, time.Now())
+
+	return nil
+}
+
+// canaryGateways returns the gateways in wasm data plane mode, managed by the gateway controllers of the reconciler,
+// selected by the canary gateway selector
+func (r *WasmConfigRolloutReconciler) canaryGateways(topology *machinery.Topology, gateways []*machinery.Gateway, selector *metav1.LabelSelector) ([]*machinery.Gateway, error) {
+	if selector == nil {
+		return nil, nil
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	if s.Empty() {
+		return nil, nil
+	}
+	return lo.Filter(gateways, func(gateway *machinery.Gateway, _ int) bool {
+		if isNativeDataPlaneGateway(gateway) || !s.Matches(labels.Set(gateway.GetLabels())) {
+			return false
+		}
+		return lo.ContainsBy(topology.Targetables().Parents(gateway), func(parent machinery.Targetable) bool {
+			gatewayClass, ok := parent.(*machinery.GatewayClass)
+			return ok && lo.Contains(r.gatewayControllerNames, gatewayClass.Spec.ControllerName)
+		})
+	}), nil
+}
+
+// wasmConfigRolloutAnalyzer returns the value of the error metrics of a canary gateway
+type wasmConfigRolloutAnalyzer func(ctx context.Context, analysis *kuadrantv1beta1.WasmConfigRolloutAnalysis, gateway *machinery.Gateway) (float64, error)
+
+// wasmConfigRolloutAnalysisResult is the outcome of the last query of the error metrics of the canary gateways
+type wasmConfigRolloutAnalysisResult struct {
+	time          time.Time
+	unhealthy     []string
+	failedQueries []string
+}
+
+// wasmConfigRolloutTracker holds the state of the rollout of the last change of the wasm configuration.
+//
+// A change is identified by the revision of the wasm configuration of all the gateways. While a change is
+// progressing, only the canary gateways get it. Once promoted, all the gateways get it and it becomes the stable
+// configuration the canary gateways are rolled back to when a later change fails. Gateways created after the last
+// promotion have no stable configuration to keep, so they get the change right away.
+//
+// The AuthConfigs and the limits in Limitador are shared by all the gateways. While a change is progressing, only the
+// ones that do not exist yet are created, so the gateways that keep the stable configuration are not affected (see
+// HoldsSharedChanges).
+//
+// A nil tracker is a disabled rollout.
+type wasmConfigRolloutTracker struct {
+	sync.RWMutex
+
+	status        *kuadrantv1beta1.WasmConfigRolloutStatus
+	initialized   bool
+	canaries      map[string]struct{}
+	configs       map[string]wasm.Config
+	stableConfigs map[string]wasm.Config
+	// stableGateways are the gateways that existed when the stable configuration was set, or when the operator
+	// started if it is unknown
+	stableGateways map[string]struct{}
+	analysis       *wasmConfigRolloutAnalysisResult
+
+	timer          *time.Timer
+	changeNotifier func(reason string) error
+}
+
+func newWasmConfigRolloutTracker(changeNotifier func(reason string) error) *wasmConfigRolloutTracker {
+	return &wasmConfigRolloutTracker{changeNotifier: changeNotifier}
+}
+
+// wasmConfigRolloutFromState returns the rollout stored in the state by the WasmConfigRolloutReconciler, if any
+func wasmConfigRolloutFromState(state *sync.Map) *wasmConfigRolloutTracker {
+	rollout, ok := state.Load(StateWasmConfigRollout)
+	if !ok {
+		return nil
+	}
+	return rollout.(*wasmConfigRolloutTracker)
+}
+
+// SetChangeNotifier sets the function called when the rollout has to be reconciled again, either because its phase
+// changed or to check the canary gateways
+func (t *wasmConfigRolloutTracker) SetChangeNotifier(notifier func(reason string) error) {
+	t.Lock()
+	defer t.Unlock()
+	t.changeNotifier = notifier
+}
+
+// Disable lets all the gateways get the changes at once
+func (t *wasmConfigRolloutTracker) Disable() {
+	t.Lock()
+	defer t.Unlock()
+	t.status = nil
+	t.initialized = false
+	t.canaries = nil
+	t.configs = nil
+	t.stableConfigs = nil
+	t.stableGateways = nil
+	t.analysis = nil
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+// Status returns the status of the rollout, and whether the rollout has been planned since the operator started
+func (t *wasmConfigRolloutTracker) Status() (*kuadrantv1beta1.WasmConfigRolloutStatus, bool) {
+	if t == nil {
+		return nil, false
+	}
+	t.RLock()
+	defer t.RUnlock()
+	return t.status.DeepCopy(), t.initialized
+}
+
+// HoldsSharedChanges tells whether the changes of the resources shared by all the gateways, i.e. the AuthConfigs and
+// the limits in Limitador, are held back because the last change of the wasm configuration is progressing.
+// While held back, only the resources that do not exist yet can be created; existing ones must not be modified nor
+// deleted. Once the rollout ends, promoted or not, the shared resources get the changes.
+func (t *wasmConfigRolloutTracker) HoldsSharedChanges() bool {
+	if t == nil {
+		return false
+	}
+	t.RLock()
+	defer t.RUnlock()
+	return t.status != nil && t.status.Phase == kuadrantv1beta1.WasmConfigRolloutProgressing
+}
+
+// ConfigForGateway returns the wasm configuration a gateway gets, given its desired configuration, and whether the
+// gateway can be reconciled at all. Gateways that are not admitted keep their current configuration.
+// Gateways without a stable configuration, i.e. created after the last promotion, are always admitted with their
+// desired configuration, so their policies are enforced during the rollout.
+func (t *wasmConfigRolloutTracker) ConfigForGateway(gatewayLocator string, desired wasm.Config) (wasm.Config, bool) {
+	if t == nil {
+		return desired, true
+	}
+	t.RLock()
+	defer t.RUnlock()
+
+	if t.status == nil || t.status.Phase == kuadrantv1beta1.WasmConfigRolloutPromoted {
+		return desired, true
+	}
+	if _, isCanary := t.canaries[gatewayLocator]; !isCanary {
+		if _, isStable := t.stableGateways[gatewayLocator]; !isStable {
+			return desired, true
+		}
+		return wasm.Config{}, false
+	}
+	if t.status.Phase == kuadrantv1beta1.WasmConfigRolloutProgressing || t.status.Phase == kuadrantv1beta1.WasmConfigRolloutFailed {
+		return desired, true
+	}
+	// rolled back, keeping the configuration the canary gateways were rolled back to before a restart if the stable
+	// configuration is unknown
+	if t.stableConfigs == nil {
+		return wasm.Config{}, false
+	}
+	return t.stableConfigs[gatewayLocator], true
+}
+
+// Plan starts a new rollout when the wasm configuration changes, and promotes or rolls back the progressing one
+// depending on the state of the canary gateways.
+// The error metrics of the canary gateways are queried at most once per check interval, without holding the lock of
+// the tracker.
+func (t *wasmConfigRolloutTracker) Plan(ctx context.Context, logger logr.Logger, kObj *kuadrantv1beta1.Kuadrant, configs map[string]wasm.Config, gateways, canaries []*machinery.Gateway, analyze wasmConfigRolloutAnalyzer, now time.Time) {
+	spec := kObj.Spec.WasmConfigRollout
+	revision := wasmConfigRevision(configs)
+	gatewayLocators := lo.SliceToMap(gateways, func(gateway *machinery.Gateway) (string, struct{}) { return gateway.GetLocator(), struct{}{} })
+
+	t.Lock()
+	previousPhase := lo.FromPtr(t.status).Phase
+
+	// resume the rollout reported in the status of the kuadrant CR after a restart
+	if !t.initialized {
+		t.initialized = true
+		// the configuration of the existing gateways is unknown, so they keep it until the change is promoted
+		t.stableGateways = gatewayLocators
+		if status := kObj.Status.WasmConfigRollout; status != nil && status.Revision == revision {
+			t.status = status.DeepCopy()
+			t.canaries = lo.SliceToMap(status.CanaryGateways, func(locator string) (string, struct{}) { return locator, struct{}{} })
+			t.configs = configs
+			if status.Phase == kuadrantv1beta1.WasmConfigRolloutPromoted {
+				t.stableConfigs = configs
+			}
+			previousPhase = status.Phase
+		}
+	}
+
+	if t.status == nil || t.status.Revision != revision {
+		if t.status != nil {
+			logger.Info("wasm configuration changed", "previous revision", t.status.Revision, "revision", revision)
+		}
+		t.start(revision, configs, canaries, now)
+	}
+
+	canaryGateways := t.canaryGateways(gateways)
+	queryDue := t.status.Phase == kuadrantv1beta1.WasmConfigRolloutProgressing && spec.Analysis != nil && analyze != nil && t.analysisDue(spec.GetBakeTime(), now)
+	t.Unlock()
+
+	var analysis *wasmConfigRolloutAnalysisResult
+	if queryDue {
+		analysis = queryCanaryGateways(ctx, logger, spec.Analysis, canaryGateways, analyze, now)
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	// superseded by a later change while querying the error metrics
+	if t.status == nil || t.status.Revision != revision {
+		return
+	}
+
+	if analysis != nil {
+		t.analysis = analysis
+	}
+
+	if t.status.Phase == kuadrantv1beta1.WasmConfigRolloutProgressing {
+		t.evaluate(spec, canaryGateways, now)
+	}
+
+	if t.status.Phase == kuadrantv1beta1.WasmConfigRolloutPromoted {
+		t.stableGateways = gatewayLocators
+	}
+
+	if t.status.Phase != previousPhase {
+		logger.Info("wasm configuration rollout", "revision", revision, "phase", t.status.Phase, "message", t.status.Message)
+		t.schedule(0, "wasm configuration rollout "+strings.ToLower(string(t.status.Phase)))
+	}
+}
+
+func (t *wasmConfigRolloutTracker) start(revision string, configs map[string]wasm.Config, canaries []*machinery.Gateway, now time.Time) {
+	canaryLocators := lo.Map(canaries, func(gateway *machinery.Gateway, _ int) string { return gateway.GetLocator() })
+	slices.Sort(canaryLocators)
+
+	t.configs = configs
+	t.canaries = lo.SliceToMap(canaryLocators, func(locator string) (string, struct{}) { return locator, struct{}{} })
+	t.analysis = nil
+	t.status = &kuadrantv1beta1.WasmConfigRolloutStatus{
+		Revision:       revision,
+		Phase:          kuadrantv1beta1.WasmConfigRolloutProgressing,
+		CanaryGateways: canaryLocators,
+		StartTime:      metav1.NewTime(now),
+		Message:        "Applying the change to the canary gateways",
+	}
+
+	if len(canaryLocators) == 0 {
+		t.promote("No canary gateway selected, the change is applied to all the gateways")
+	}
+}
+
+// canaryGateways returns the canary gateways of the rollout among the given gateways
+func (t *wasmConfigRolloutTracker) canaryGateways(gateways []*machinery.Gateway) []*machinery.Gateway {
+	return lo.Filter(gateways, func(gateway *machinery.Gateway, _ int) bool {
+		_, isCanary := t.canaries[gateway.GetLocator()]
+		return isCanary
+	})
+}
+
+// analysisDue tells whether the error metrics of the canary gateways have to be queried, i.e. they have not been
+// queried within the check interval, or not since the end of the bake time
+func (t *wasmConfigRolloutTracker) analysisDue(bakeTime time.Duration, now time.Time) bool {
+	if t.analysis == nil {
+		return true
+	}
+	bakeEnd := t.status.StartTime.Add(bakeTime)
+	return now.Sub(t.analysis.time) >= wasmConfigRolloutCheckInterval || (!now.Before(bakeEnd) && t.analysis.time.Before(bakeEnd))
+}
+
+// queryCanaryGateways queries the error metrics of the canary gateways
+func queryCanaryGateways(ctx context.Context, logger logr.Logger, analysis *kuadrantv1beta1.WasmConfigRolloutAnalysis, canaries []*machinery.Gateway, analyze wasmConfigRolloutAnalyzer, now time.Time) *wasmConfigRolloutAnalysisResult {
+	result := &wasmConfigRolloutAnalysisResult{time: now}
+
+	maxValue, err := strconv.ParseFloat(analysis.MaxValue, 64)
+	if err != nil {
+		logger.Error(err, "invalid max value of the wasm configuration rollout analysis", "maxValue", analysis.MaxValue)
+		result.failedQueries = lo.Map(canaries, func(gateway *machinery.Gateway, _ int) string { return gateway.GetLocator() })
+		return result
+	}
+
+	for _, gateway := range canaries {
+		value, err := analyze(ctx, analysis, gateway)
+		if err != nil {
+			logger.Error(err, "failed to query the error metrics of a canary gateway", "gateway", gateway.GetLocator())
+			result.failedQueries = append(result.failedQueries, gateway.GetLocator())
+			continue
+		}
+		if value > maxValue {
+			result.unhealthy = append(result.unhealthy, fmt.Sprintf("%s (%g)", gateway.GetLocator(), value))
+		}
+	}
+	return result
+}
+
+// evaluate checks the readiness and the last error metrics of the canary gateways, rolling back the change as soon as
+// the error metrics of a canary gateway are too high, and promoting it after the bake time if all the canary gateways
+// are ready and healthy
+func (t *wasmConfigRolloutTracker) evaluate(spec *kuadrantv1beta1.WasmConfigRollout, canaries []*machinery.Gateway, now time.Time) {
+	bakeTime := spec.GetBakeTime()
+	remaining := bakeTime - now.Sub(t.status.StartTime.Time)
+
+	var unready []string
+	for _, gateway := range canaries {
+		if !meta.IsStatusConditionTrue(gateway.Status.Conditions, string(gatewayapiv1.GatewayConditionProgrammed)) {
+			unready = append(unready, gateway.GetLocator())
+		}
+	}
+
+	// canary gateways deleted during the rollout
+	for locator := range t.canaries {
+		if !lo.ContainsBy(canaries, func(gateway *machinery.Gateway) bool { return gateway.GetLocator() == locator }) {
+			unready = append(unready, locator)
+		}
+	}
+	slices.Sort(unready)
+
+	var unhealthy, failedQueries []string
+	if spec.Analysis != nil && t.analysis != nil {
+		unhealthy, failedQueries = t.analysis.unhealthy, t.analysis.failedQueries
+	}
+
+	switch {
+	case len(unhealthy) > 0:
+		t.rollback(fmt.Sprintf("Error metrics of the canary gateways above %s: %s", spec.Analysis.MaxValue, strings.Join(unhealthy, ", ")))
+	case remaining > 0:
+		t.status.Message = fmt.Sprintf("Applying the change to the canary gateways until %s", t.status.StartTime.Add(bakeTime).UTC().Format(time.RFC3339))
+		t.schedule(min(remaining, wasmConfigRolloutCheckInterval), "wasm configuration rollout check")
+	case len(unready) > 0:
+		t.rollback(fmt.Sprintf("Canary gateways not programmed after %s: %s", bakeTime, strings.Join(unready, ", ")))
+	case len(failedQueries) > 0:
+		t.rollback(fmt.Sprintf("Failed to query the error metrics of the canary gateways: %s", strings.Join(failedQueries, ", ")))
+	default:
+		t.promote(fmt.Sprintf("Canary gateways ready and healthy for %s, the change is applied to all the gateways", bakeTime))
+	}
+}
+
+func (t *wasmConfigRolloutTracker) promote(message string) {
+	t.status.Phase = kuadrantv1beta1.WasmConfigRolloutPromoted
+	t.status.Message = message
+	t.stableConfigs = t.configs
+}
+
+// rollback reverts the change on the canary gateways, or fails the rollout if the stable configuration is unknown
+// since the operator restarted
+func (t *wasmConfigRolloutTracker) rollback(message string) {
+	if t.stableConfigs == nil {
+		t.status.Phase = kuadrantv1beta1.WasmConfigRolloutFailed
+		t.status.Message = message + ". The previous configuration is unknown since the operator restarted, the canary gateways keep the change"
+		return
+	}
+	t.status.Phase = kuadrantv1beta1.WasmConfigRolloutRolledBack
+	t.status.Message = message + ". The canary gateways are rolled back to the previous configuration"
+}
+
+// schedule triggers a reconciliation after a given delay, replacing any reconciliation previously scheduled
+func (t *wasmConfigRolloutTracker) schedule(delay time.Duration, reason string) {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	notifier := t.changeNotifier
+	if notifier == nil {
+		return
+	}
+	t.timer = time.AfterFunc(delay, func() {
+		_ = notifier(reason)
+	})
+}
+
+// wasmConfigRevision identifies the wasm configuration of all the gateways
+func wasmConfigRevision(configs map[string]wasm.Config) string {
+	// maps are marshalled with sorted keys
+	data, _ := json.Marshal(configs)
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:16]
+}
+
+// queryPrometheusAnalysis returns an analyzer that evaluates the query of the analysis against the Prometheus HTTP API,
+// returning the highest value of the result
+func queryPrometheusAnalysis(client *http.Client) wasmConfigRolloutAnalyzer {
+	return func(ctx context.Context, analysis *kuadrantv1beta1.WasmConfigRolloutAnalysis, gateway *machinery.Gateway) (float64, error) {
+		query := strings.NewReplacer("${gateway_namespace}", gateway.GetNamespace(), "${gateway_name}", gateway.GetName()).Replace(analysis.Query)
+		queryURL := fmt.Sprintf("%s/api/v1/query?query=%s", strings.TrimSuffix(analysis.PrometheusURL, "/"), url.QueryEscape(query))
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, queryURL, nil)
+		if err != nil {
+			return 0, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return 0, fmt.Errorf("unexpected status code %d querying %s", resp.StatusCode, analysis.PrometheusURL)
+		}
+
+		var body struct {
+			Data struct {
+				ResultType string          `json:"resultType"`
+				Result     json.RawMessage `json:"result"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return 0, err
+		}
+		return prometheusQueryResultValue(body.Data.ResultType, body.Data.Result)
+	}
+}
+
+// prometheusQueryResultValue returns the value of a scalar result or the highest value of a vector result.
+// An empty vector is 0, i.e. no errors.
+func prometheusQueryResultValue(resultType string, result json.RawMessage) (float64, error) {
+	parse := func(sample []any) (float64, error) {
+		if len(sample) != 2 {
+			return 0, fmt.Errorf("unexpected sample %v", sample)
+		}
+		value, ok := sample[1].(string)
+		if !ok {
+			return 0, fmt.Errorf("unexpected sample value %v", sample[1])
+		}
+		return strconv.ParseFloat(value, 64)
+	}
+
+	switch resultType {
+	case "scalar":
+		var sample []any
+		if err := json.Unmarshal(result, &sample); err != nil {
+			return 0, err
+		}
+		return parse(sample)
+	case "vector":
+		var samples []struct {
+			Value []any `json:"value"`
+		}
+		if err := json.Unmarshal(result, &samples); err != nil {
+			return 0, err
+		}
+		var highest float64
+		for _, sample := range samples {
+			value, err := parse(sample.Value)
+			if err != nil {
+				return 0, err
+			}
+			highest = max(highest, value)
+		}
+		return highest, nil
+	default:
+		return 0, fmt.Errorf("unsupported result type %q", resultType)
+	}
+}
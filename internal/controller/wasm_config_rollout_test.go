@@ -0,0 +1,335 @@
+//go:build unit
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
+	"github.com/kuadrant/kuadrant-operator/internal/wasm"
+)
+
+func TestWasmConfigRolloutTracker(t *testing.T) {
+	gateway := func(name string, programmed bool) *machinery.Gateway {
+		status := metav1.ConditionFalse
+		if programmed {
+			status = metav1.ConditionTrue
+		}
+		return &machinery.Gateway{Gateway: &gatewayapiv1.Gateway{
+			TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.GroupVersion.String(), Kind: "Gateway"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "gateway-ns"},
+			Status: gatewayapiv1.GatewayStatus{Conditions: []metav1.Condition{
+				{Type: string(gatewayapiv1.GatewayConditionProgrammed), Status: status},
+			}},
+		}}
+	}
+	canary := gateway("canary", true)
+	prod := gateway("prod", true)
+	gateways := []*machinery.Gateway{canary, prod}
+
+	configs := func(name string) map[string]wasm.Config {
+		config := wasm.Config{ActionSets: []wasm.ActionSet{{Name: name}}}
+		return map[string]wasm.Config{canary.GetLocator(): config, prod.GetLocator(): config}
+	}
+
+	kObj := &kuadrantv1beta1.Kuadrant{Spec: kuadrantv1beta1.KuadrantSpec{WasmConfigRollout: &kuadrantv1beta1.WasmConfigRollout{
+		Enable:   true,
+		BakeTime: &metav1.Duration{Duration: time.Minute},
+		Analysis: &kuadrantv1beta1.WasmConfigRolloutAnalysis{PrometheusURL: "http://prometheus", Query: "errors", MaxValue: "0.5"},
+	}}}
+
+	errorRate := 0.0
+	queries := 0
+	analyze := func(_ context.Context, _ *kuadrantv1beta1.WasmConfigRolloutAnalysis, _ *machinery.Gateway) (float64, error) {
+		queries++
+		return errorRate, nil
+	}
+
+	tracker := &wasmConfigRolloutTracker{}
+	start := time.Now()
+
+	// first configuration, promoted after the bake time
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs("v1"), gateways, []*machinery.Gateway{canary}, analyze, start)
+	status, _ := tracker.Status()
+	assert.Equal(t, status.Phase, kuadrantv1beta1.WasmConfigRolloutProgressing)
+	assert.Assert(t, tracker.HoldsSharedChanges())
+	assert.Equal(t, queries, 1)
+
+	// the error metrics are queried at most once per check interval
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs("v1"), gateways, []*machinery.Gateway{canary}, analyze, start.Add(time.Second))
+	assert.Equal(t, queries, 1)
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs("v1"), gateways, []*machinery.Gateway{canary}, analyze, start.Add(wasmConfigRolloutCheckInterval))
+	assert.Equal(t, queries, 2)
+	assert.DeepEqual(t, status.CanaryGateways, []string{canary.GetLocator()})
+	_, admitted := tracker.ConfigForGateway(prod.GetLocator(), configs("v1")[prod.GetLocator()])
+	assert.Assert(t, !admitted)
+	config, admitted := tracker.ConfigForGateway(canary.GetLocator(), configs("v1")[canary.GetLocator()])
+	assert.Assert(t, admitted)
+	assert.Equal(t, config.ActionSets[0].Name, "v1")
+
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs("v1"), gateways, []*machinery.Gateway{canary}, analyze, start.Add(time.Minute))
+	status, _ = tracker.Status()
+	assert.Equal(t, status.Phase, kuadrantv1beta1.WasmConfigRolloutPromoted)
+	assert.Assert(t, !tracker.HoldsSharedChanges())
+	config, admitted = tracker.ConfigForGateway(prod.GetLocator(), configs("v1")[prod.GetLocator()])
+	assert.Assert(t, admitted)
+	assert.Equal(t, config.ActionSets[0].Name, "v1")
+
+	// faulty change, rolled back as soon as the error metrics of the canary gateway are too high
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs("v2"), gateways, []*machinery.Gateway{canary}, analyze, start.Add(2*time.Minute))
+	status, _ = tracker.Status()
+	assert.Equal(t, status.Phase, kuadrantv1beta1.WasmConfigRolloutProgressing)
+
+	errorRate = 0.9
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs("v2"), gateways, []*machinery.Gateway{canary}, analyze, start.Add(2*time.Minute+time.Second))
+	status, _ = tracker.Status()
+	assert.Equal(t, status.Phase, kuadrantv1beta1.WasmConfigRolloutProgressing)
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs("v2"), gateways, []*machinery.Gateway{canary}, analyze, start.Add(2*time.Minute+wasmConfigRolloutCheckInterval))
+	status, _ = tracker.Status()
+	assert.Equal(t, status.Phase, kuadrantv1beta1.WasmConfigRolloutRolledBack)
+	assert.Assert(t, !tracker.HoldsSharedChanges())
+	assert.Assert(t, strings.Contains(status.Message, "gateway.gateway.networking.k8s.io:gateway-ns/canary"), status.Message)
+	config, admitted = tracker.ConfigForGateway(canary.GetLocator(), configs("v2")[canary.GetLocator()])
+	assert.Assert(t, admitted)
+	assert.Equal(t, config.ActionSets[0].Name, "v1")
+	_, admitted = tracker.ConfigForGateway(prod.GetLocator(), configs("v2")[prod.GetLocator()])
+	assert.Assert(t, !admitted)
+
+	// canary gateway not programmed at the end of the bake time
+	errorRate = 0
+	unprogrammedCanary := gateway("canary", false)
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs("v3"), []*machinery.Gateway{unprogrammedCanary, prod}, []*machinery.Gateway{unprogrammedCanary}, analyze, start.Add(3*time.Minute))
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs("v3"), []*machinery.Gateway{unprogrammedCanary, prod}, []*machinery.Gateway{unprogrammedCanary}, analyze, start.Add(4*time.Minute))
+	status, _ = tracker.Status()
+	assert.Equal(t, status.Phase, kuadrantv1beta1.WasmConfigRolloutRolledBack)
+	assert.Assert(t, strings.HasPrefix(status.Message, "Canary gateways not programmed"), status.Message)
+
+	// error metrics not available at the end of the bake time
+	failingAnalyze := func(context.Context, *kuadrantv1beta1.WasmConfigRolloutAnalysis, *machinery.Gateway) (float64, error) {
+		return 0, errors.New("connection refused")
+	}
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs("v4"), gateways, []*machinery.Gateway{canary}, failingAnalyze, start.Add(5*time.Minute))
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs("v4"), gateways, []*machinery.Gateway{canary}, failingAnalyze, start.Add(6*time.Minute))
+	status, _ = tracker.Status()
+	assert.Equal(t, status.Phase, kuadrantv1beta1.WasmConfigRolloutRolledBack)
+
+	// disabled
+	tracker.Disable()
+	assert.Assert(t, !tracker.HoldsSharedChanges())
+	config, admitted = tracker.ConfigForGateway(prod.GetLocator(), configs("v4")[prod.GetLocator()])
+	assert.Assert(t, admitted)
+	assert.Equal(t, config.ActionSets[0].Name, "v4")
+}
+
+func TestWasmConfigRolloutTrackerNoCanary(t *testing.T) {
+	kObj := &kuadrantv1beta1.Kuadrant{Spec: kuadrantv1beta1.KuadrantSpec{WasmConfigRollout: &kuadrantv1beta1.WasmConfigRollout{Enable: true}}}
+	tracker := &wasmConfigRolloutTracker{}
+	tracker.Plan(context.Background(), logr.Discard(), kObj, map[string]wasm.Config{"gateway": {}}, nil, nil, nil, time.Now())
+	status, _ := tracker.Status()
+	assert.Equal(t, status.Phase, kuadrantv1beta1.WasmConfigRolloutPromoted)
+	_, admitted := tracker.ConfigForGateway("gateway", wasm.Config{})
+	assert.Assert(t, admitted)
+}
+
+func TestWasmConfigRolloutTrackerResume(t *testing.T) {
+	configs := map[string]wasm.Config{"gateway.gateway.networking.k8s.io:gateway-ns/canary": {}, "gateway.gateway.networking.k8s.io:gateway-ns/prod": {}}
+	kObj := &kuadrantv1beta1.Kuadrant{
+		Spec: kuadrantv1beta1.KuadrantSpec{WasmConfigRollout: &kuadrantv1beta1.WasmConfigRollout{Enable: true}},
+		Status: kuadrantv1beta1.KuadrantStatus{WasmConfigRollout: &kuadrantv1beta1.WasmConfigRolloutStatus{
+			Revision:       wasmConfigRevision(configs),
+			Phase:          kuadrantv1beta1.WasmConfigRolloutRolledBack,
+			CanaryGateways: []string{"gateway.gateway.networking.k8s.io:gateway-ns/canary"},
+			StartTime:      metav1.Now(),
+		}},
+	}
+
+	tracker := &wasmConfigRolloutTracker{}
+	_, initialized := tracker.Status()
+	assert.Assert(t, !initialized)
+
+	gateways := []*machinery.Gateway{
+		{Gateway: &gatewayapiv1.Gateway{TypeMeta: metav1.TypeMeta{APIVersion: gatewayapiv1.GroupVersion.String(), Kind: "Gateway"}, ObjectMeta: metav1.ObjectMeta{Name: "canary", Namespace: "gateway-ns"}}},
+		{Gateway: &gatewayapiv1.Gateway{TypeMeta: metav1.TypeMeta{APIVersion: gatewayapiv1.GroupVersion.String(), Kind: "Gateway"}, ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "gateway-ns"}}},
+	}
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs, gateways, nil, nil, time.Now())
+	status, initialized := tracker.Status()
+	assert.Assert(t, initialized)
+	assert.DeepEqual(t, status, kObj.Status.WasmConfigRollout)
+
+	// the previous configuration is unknown after a restart, so neither gateway is reconciled
+	_, admitted := tracker.ConfigForGateway("gateway.gateway.networking.k8s.io:gateway-ns/canary", wasm.Config{})
+	assert.Assert(t, !admitted)
+	_, admitted = tracker.ConfigForGateway("gateway.gateway.networking.k8s.io:gateway-ns/prod", wasm.Config{})
+	assert.Assert(t, !admitted)
+}
+
+func TestWasmConfigRolloutTrackerFailedAfterRestart(t *testing.T) {
+	canary := &machinery.Gateway{Gateway: &gatewayapiv1.Gateway{
+		TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.GroupVersion.String(), Kind: "Gateway"},
+		ObjectMeta: metav1.ObjectMeta{Name: "canary", Namespace: "gateway-ns"},
+	}}
+	configs := map[string]wasm.Config{canary.GetLocator(): {ActionSets: []wasm.ActionSet{{Name: "v2"}}}}
+	start := time.Now()
+	kObj := &kuadrantv1beta1.Kuadrant{
+		Spec: kuadrantv1beta1.KuadrantSpec{WasmConfigRollout: &kuadrantv1beta1.WasmConfigRollout{
+			Enable:   true,
+			BakeTime: &metav1.Duration{Duration: time.Minute},
+		}},
+		Status: kuadrantv1beta1.KuadrantStatus{WasmConfigRollout: &kuadrantv1beta1.WasmConfigRolloutStatus{
+			Revision:       wasmConfigRevision(configs),
+			Phase:          kuadrantv1beta1.WasmConfigRolloutProgressing,
+			CanaryGateways: []string{canary.GetLocator()},
+			StartTime:      metav1.NewTime(start),
+		}},
+	}
+
+	tracker := &wasmConfigRolloutTracker{}
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs, []*machinery.Gateway{canary}, []*machinery.Gateway{canary}, nil, start.Add(time.Second))
+	assert.Assert(t, tracker.HoldsSharedChanges())
+
+	// the canary gateway is not programmed at the end of the bake time, but the stable configuration is unknown
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs, []*machinery.Gateway{canary}, []*machinery.Gateway{canary}, nil, start.Add(time.Minute))
+	status, _ := tracker.Status()
+	assert.Equal(t, status.Phase, kuadrantv1beta1.WasmConfigRolloutFailed)
+	assert.Assert(t, strings.HasSuffix(status.Message, "the canary gateways keep the change"), status.Message)
+	assert.Assert(t, !tracker.HoldsSharedChanges())
+	config, admitted := tracker.ConfigForGateway(canary.GetLocator(), configs[canary.GetLocator()])
+	assert.Assert(t, admitted)
+	assert.Equal(t, config.ActionSets[0].Name, "v2")
+}
+
+func TestWasmConfigRolloutFromState(t *testing.T) {
+	state := &sync.Map{}
+
+	// without a rollout, the gateways get their configuration at once
+	rollout := wasmConfigRolloutFromState(state)
+	assert.Assert(t, rollout == nil)
+	assert.Assert(t, !rollout.HoldsSharedChanges())
+	config, admitted := rollout.ConfigForGateway("gateway", wasm.Config{ActionSets: []wasm.ActionSet{{Name: "v1"}}})
+	assert.Assert(t, admitted)
+	assert.Equal(t, config.ActionSets[0].Name, "v1")
+	_, initialized := rollout.Status()
+	assert.Assert(t, !initialized)
+
+	tracker := newWasmConfigRolloutTracker(nil)
+	reconciler := NewWasmConfigRolloutReconciler(nil, tracker)
+	assert.NilError(t, reconciler.Run(context.Background(), nil, nil, nil, state))
+	assert.Equal(t, wasmConfigRolloutFromState(state), tracker)
+}
+
+func TestWasmConfigRolloutTrackerNewGateway(t *testing.T) {
+	gateway := func(name string) *machinery.Gateway {
+		return &machinery.Gateway{Gateway: &gatewayapiv1.Gateway{
+			TypeMeta:   metav1.TypeMeta{APIVersion: gatewayapiv1.GroupVersion.String(), Kind: "Gateway"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "gateway-ns"},
+			Status: gatewayapiv1.GatewayStatus{Conditions: []metav1.Condition{
+				{Type: string(gatewayapiv1.GatewayConditionProgrammed), Status: metav1.ConditionTrue},
+			}},
+		}}
+	}
+	canary := gateway("canary")
+	prod := gateway("prod")
+	newGateway := gateway("new")
+
+	configs := func(name string, gateways ...*machinery.Gateway) map[string]wasm.Config {
+		return lo.SliceToMap(gateways, func(gateway *machinery.Gateway) (string, wasm.Config) {
+			return gateway.GetLocator(), wasm.Config{ActionSets: []wasm.ActionSet{{Name: name}}}
+		})
+	}
+
+	kObj := &kuadrantv1beta1.Kuadrant{Spec: kuadrantv1beta1.KuadrantSpec{WasmConfigRollout: &kuadrantv1beta1.WasmConfigRollout{
+		Enable:   true,
+		BakeTime: &metav1.Duration{Duration: time.Minute},
+		Analysis: &kuadrantv1beta1.WasmConfigRolloutAnalysis{PrometheusURL: "http://prometheus", Query: "errors", MaxValue: "0.5"},
+	}}}
+	errorRate := 0.0
+	analyze := func(context.Context, *kuadrantv1beta1.WasmConfigRolloutAnalysis, *machinery.Gateway) (float64, error) {
+		return errorRate, nil
+	}
+
+	tracker := &wasmConfigRolloutTracker{}
+	start := time.Now()
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs("v1", canary, prod), []*machinery.Gateway{canary, prod}, []*machinery.Gateway{canary}, analyze, start)
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs("v1", canary, prod), []*machinery.Gateway{canary, prod}, []*machinery.Gateway{canary}, analyze, start.Add(time.Minute))
+	status, _ := tracker.Status()
+	assert.Equal(t, status.Phase, kuadrantv1beta1.WasmConfigRolloutPromoted)
+
+	// the gateway created after the promotion changes the revision and starts a new rollout, but gets its
+	// configuration right away, as it has no stable configuration to keep
+	gateways := []*machinery.Gateway{canary, prod, newGateway}
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs("v2", gateways...), gateways, []*machinery.Gateway{canary}, analyze, start.Add(2*time.Minute))
+	status, _ = tracker.Status()
+	assert.Equal(t, status.Phase, kuadrantv1beta1.WasmConfigRolloutProgressing)
+	config, admitted := tracker.ConfigForGateway(newGateway.GetLocator(), configs("v2", gateways...)[newGateway.GetLocator()])
+	assert.Assert(t, admitted)
+	assert.Equal(t, config.ActionSets[0].Name, "v2")
+	_, admitted = tracker.ConfigForGateway(prod.GetLocator(), configs("v2", gateways...)[prod.GetLocator()])
+	assert.Assert(t, !admitted)
+
+	// and keeps it after a rollback
+	errorRate = 0.9
+	tracker.Plan(context.Background(), logr.Discard(), kObj, configs("v2", gateways...), gateways, []*machinery.Gateway{canary}, analyze, start.Add(2*time.Minute+wasmConfigRolloutCheckInterval))
+	status, _ = tracker.Status()
+	assert.Equal(t, status.Phase, kuadrantv1beta1.WasmConfigRolloutRolledBack)
+	config, admitted = tracker.ConfigForGateway(newGateway.GetLocator(), configs("v2", gateways...)[newGateway.GetLocator()])
+	assert.Assert(t, admitted)
+	assert.Equal(t, config.ActionSets[0].Name, "v2")
+	_, admitted = tracker.ConfigForGateway(prod.GetLocator(), configs("v2", gateways...)[prod.GetLocator()])
+	assert.Assert(t, !admitted)
+}
+
+func TestWasmConfigRolloutTrackerQueryOutsideLock(t *testing.T) {
+	canary := &machinery.Gateway{Gateway: &gatewayapiv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "canary", Namespace: "gateway-ns"}}}
+	kObj := &kuadrantv1beta1.Kuadrant{Spec: kuadrantv1beta1.KuadrantSpec{WasmConfigRollout: &kuadrantv1beta1.WasmConfigRollout{
+		Enable:   true,
+		Analysis: &kuadrantv1beta1.WasmConfigRolloutAnalysis{PrometheusURL: "http://prometheus", Query: "errors", MaxValue: "0"},
+	}}}
+
+	tracker := &wasmConfigRolloutTracker{}
+	analyze := func(context.Context, *kuadrantv1beta1.WasmConfigRolloutAnalysis, *machinery.Gateway) (float64, error) {
+		// the gateways are reconciled while the error metrics are queried
+		_, admitted := tracker.ConfigForGateway(canary.GetLocator(), wasm.Config{})
+		assert.Assert(t, admitted)
+		return 0, nil
+	}
+	tracker.Plan(context.Background(), logr.Discard(), kObj, map[string]wasm.Config{canary.GetLocator(): {}}, []*machinery.Gateway{canary}, []*machinery.Gateway{canary}, analyze, time.Now())
+	status, _ := tracker.Status()
+	assert.Equal(t, status.Phase, kuadrantv1beta1.WasmConfigRolloutProgressing)
+}
+
+func TestPrometheusQueryResultValue(t *testing.T) {
+	testCases := []struct {
+		name          string
+		resultType    string
+		result        string
+		expectedValue float64
+		expectedError bool
+	}{
+		{name: "scalar", resultType: "scalar", result: `[1700000000.123, "0.25"]`, expectedValue: 0.25},
+		{name: "vector", resultType: "vector", result: `[{"metric":{"a":"1"},"value":[1700000000, "0.1"]},{"metric":{"a":"2"},"value":[1700000000, "0.7"]}]`, expectedValue: 0.7},
+		{name: "empty vector", resultType: "vector", result: `[]`, expectedValue: 0},
+		{name: "matrix", resultType: "matrix", result: `[]`, expectedError: true},
+		{name: "invalid value", resultType: "scalar", result: `[1700000000, 1]`, expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := prometheusQueryResultValue(tc.resultType, json.RawMessage(tc.result))
+			assert.Equal(t, err != nil, tc.expectedError)
+			assert.Equal(t, value, tc.expectedValue)
+		})
+	}
+}
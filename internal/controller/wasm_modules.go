@@ -0,0 +1,402 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/env"
+)
+
+const (
+	// WasmModuleVersionAnnotation pins the version of the wasm module served by the wasm server to a gateway
+	WasmModuleVersionAnnotation = "kuadrant.io/wasm-module-version"
+
+	// DefaultWasmModuleVersion is the version of the module at WASM_SERVER_FILE_PATH
+	DefaultWasmModuleVersion = "default"
+
+	// WasmModuleResolvedConditionType is the type of the condition of the gateways that pin a version of the wasm module,
+	// telling whether the version is available in the wasm server
+	WasmModuleResolvedConditionType = "kuadrant.io/WasmModuleResolved"
+
+	wasmModuleFileExtension          = ".wasm"
+	wasmModuleSignatureFileExtension = ".sig"
+)
+
+var (
+	wasmModulesDir          = env.GetString("WASM_SERVER_MODULES_DIR", "/wasm/modules")
+	wasmModulePublicKeyPath = env.GetString("WASM_SERVER_PUBLIC_KEY_PATH", "")
+
+	wasmModules = newWasmModuleStore(wasmFilePath, wasmModulesDir)
+
+	ErrWasmModuleSignatureNotFound = errors.New("wasm module signature not found")
+	ErrInvalidWasmModuleSignature  = errors.New("invalid wasm module signature")
+	ErrWasmModuleVersionNotFound   = errors.New("wasm module version not found")
+)
+
+// WasmModule is a verified version of the wasm module, addressed by the SHA256 of its content
+type WasmModule struct {
+	Version string
+	Path    string
+	SHA256  string
+	ModTime time.Time
+	Content []byte
+	// Signature is the content of the signature file the module was verified with, if any
+	Signature []byte
+}
+
+// wasmModuleStore holds the versions of the wasm module served by the wasm server.
+//
+// The default version is read from a single file and other versions from the '<version>.wasm' files of a directory.
+// When a public key is set, the modules are only loaded if the '<version>.wasm.sig' file next to them holds a valid
+// signature of their content, raw or base64 encoded. A version that fails to load, e.g. whose file becomes unreadable or
+// whose signature is replaced by an invalid one, keeps being served with its last verified module, if any, and the
+// failure is recorded until the version loads again.
+type wasmModuleStore struct {
+	sync.RWMutex
+
+	defaultFilePath string
+	modulesDir      string
+	publicKey       crypto.PublicKey
+
+	modules        map[string]WasmModule
+	failures       map[string]error
+	changeNotifier func(reason string) error
+}
+
+func newWasmModuleStore(defaultFilePath, modulesDir string) *wasmModuleStore {
+	return &wasmModuleStore{
+		defaultFilePath: defaultFilePath,
+		modulesDir:      modulesDir,
+		modules:         map[string]WasmModule{},
+		failures:        map[string]error{},
+	}
+}
+
+// SetPublicKey sets the public key the signatures of the modules are verified against
+func (s *wasmModuleStore) SetPublicKey(publicKey crypto.PublicKey) {
+	s.Lock()
+	defer s.Unlock()
+	s.publicKey = publicKey
+}
+
+// SetChangeNotifier sets the function called when the modules change after a reload
+func (s *wasmModuleStore) SetChangeNotifier(notifier func(reason string) error) {
+	s.Lock()
+	defer s.Unlock()
+	s.changeNotifier = notifier
+}
+
+// Load (re)loads the modules from the filesystem, only reading the files modified since the last load.
+// When a public key is set, a module whose signature file changed is verified again, even if the module did not.
+// It returns whether the set of modules, or the versions that failed to load, changed.
+func (s *wasmModuleStore) Load(logger logr.Logger) (bool, error) {
+	s.RLock()
+	previous := s.modules
+	previousFailures := s.failures
+	publicKey := s.publicKey
+	s.RUnlock()
+
+	files := map[string]string{}
+	if _, err := os.Stat(s.defaultFilePath); err == nil {
+		files[DefaultWasmModuleVersion] = s.defaultFilePath
+	}
+	if s.modulesDir != "" {
+		entries, err := os.ReadDir(s.modulesDir)
+		if err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), wasmModuleFileExtension) {
+				continue
+			}
+			version := strings.TrimSuffix(entry.Name(), wasmModuleFileExtension)
+			if version == DefaultWasmModuleVersion {
+				logger.Info("ignoring wasm module with reserved version", "path", filepath.Join(s.modulesDir, entry.Name()))
+				continue
+			}
+			files[version] = filepath.Join(s.modulesDir, entry.Name())
+		}
+	}
+
+	modules := make(map[string]WasmModule, len(files))
+	failures := map[string]error{}
+	fail := func(version, path string, err error) {
+		failures[version] = err
+		// the last verified module of the version is served until the version loads again
+		module, served := previous[version]
+		if served {
+			modules[version] = module
+		}
+		if previousErr, failed := previousFailures[version]; !failed || previousErr.Error() != err.Error() {
+			logger.Error(err, "failed to load wasm module", "version", version, "path", path, "servingLastVerified", served)
+		}
+	}
+	for version, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			fail(version, path, err)
+			continue
+		}
+		var signature []byte
+		if publicKey != nil {
+			if signature, err = os.ReadFile(path + wasmModuleSignatureFileExtension); err != nil {
+				if os.IsNotExist(err) {
+					err = ErrWasmModuleSignatureNotFound
+				}
+				fail(version, path, err)
+				continue
+			}
+		}
+		// unchanged since the last load
+		if module, ok := previous[version]; ok && module.Path == path && module.ModTime.Equal(info.ModTime()) && int64(len(module.Content)) == info.Size() && bytes.Equal(module.Signature, signature) {
+			modules[version] = module
+			continue
+		}
+		module, err := loadWasmModule(version, path, info.ModTime(), publicKey, signature)
+		if err != nil {
+			fail(version, path, err)
+			continue
+		}
+		modules[version] = module
+	}
+
+	changed := !maps.EqualFunc(previous, modules, func(a, b WasmModule) bool { return a.SHA256 == b.SHA256 }) ||
+		!maps.EqualFunc(previousFailures, failures, func(a, b error) bool { return a.Error() == b.Error() })
+
+	s.Lock()
+	s.modules = modules
+	s.failures = failures
+	s.Unlock()
+
+	return changed, nil
+}
+
+// Watch reloads the modules periodically until the stop channel is closed, notifying the changes
+func (s *wasmModuleStore) Watch(logger logr.Logger, interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			changed, err := s.Load(logger)
+			if err != nil {
+				logger.Error(err, "failed to reload wasm modules")
+				continue
+			}
+			if !changed {
+				continue
+			}
+			logger.Info("wasm modules changed", "modules", s.Versions())
+			s.RLock()
+			notifier := s.changeNotifier
+			s.RUnlock()
+			if notifier != nil {
+				if err := notifier("wasm modules changed"); err != nil {
+					logger.Error(err, "failed to trigger reconciliation after wasm modules changed")
+				}
+			}
+		}
+	}
+}
+
+// Module returns the module of a given version
+func (s *wasmModuleStore) Module(version string) (WasmModule, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	module, ok := s.modules[version]
+	return module, ok
+}
+
+// Failure returns the error the last load of a given version failed with, if any
+func (s *wasmModuleStore) Failure(version string) error {
+	s.RLock()
+	defer s.RUnlock()
+	return s.failures[version]
+}
+
+// ModuleBySHA256 returns the module whose content has a given SHA256
+func (s *wasmModuleStore) ModuleBySHA256(sha string) (WasmModule, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	for _, module := range s.modules {
+		if module.SHA256 == sha {
+			return module, true
+		}
+	}
+	return WasmModule{}, false
+}
+
+// Versions returns the SHA256 of the modules by version
+func (s *wasmModuleStore) Versions() map[string]string {
+	s.RLock()
+	defer s.RUnlock()
+	versions := make(map[string]string, len(s.modules))
+	for version, module := range s.modules {
+		versions[version] = module.SHA256
+	}
+	return versions
+}
+
+// ModuleForGateway returns the module pinned to a gateway, or the default one if the gateway does not pin a version.
+// It fails if the pinned version is not available, rather than falling back to the default version.
+func (s *wasmModuleStore) ModuleForGateway(gateway *machinery.Gateway) (WasmModule, bool, error) {
+	if version := gateway.GetAnnotations()[WasmModuleVersionAnnotation]; version != "" {
+		if module, ok := s.Module(version); ok {
+			return module, true, nil
+		}
+		return WasmModule{}, false, fmt.Errorf("%w: %s (available: %s)", ErrWasmModuleVersionNotFound, version, strings.Join(slices.Sorted(maps.Keys(s.Versions())), ", "))
+	}
+	module, ok := s.Module(DefaultWasmModuleVersion)
+	return module, ok, nil
+}
+
+// wasmModuleForGateway returns the content-addressed URL in the wasm server and the SHA256 of the module pinned to a
+// gateway, falling back to the URL of the default module if the modules have not been loaded.
+// It fails if the version pinned to the gateway is not available.
+func wasmModuleForGateway(gateway *machinery.Gateway, wasmServerHost string, wasmServerPort int) (string, string, error) {
+	module, ok, err := wasmModules.ModuleForGateway(gateway)
+	if err != nil {
+		return "", "", err
+	}
+	if !ok {
+		return fmt.Sprintf("http://%s:%d/plugin.wasm", wasmServerHost, wasmServerPort), "", nil
+	}
+	return fmt.Sprintf("http://%s:%d/sha256/%s%s", wasmServerHost, wasmServerPort, module.SHA256, wasmModuleFileExtension), module.SHA256, nil
+}
+
+// gatewayUsesWasmServer tells whether a gateway fetches the wasm module from the wasm server, i.e. it is handled by the
+// istio or the envoy_xds_file gateway providers and not in native data plane mode
+func gatewayUsesWasmServer(gateway *machinery.Gateway, gatewayClass *machinery.GatewayClass) bool {
+	controllerName := gatewayClass.Spec.ControllerName
+	return (lo.Contains(istioGatewayControllerNames, controllerName) || lo.Contains(envoyXDSFileGatewayControllerNames, controllerName)) && !isNativeDataPlaneGateway(gateway)
+}
+
+// wasmModuleResolvedCondition returns the condition telling whether the version of the wasm module pinned to a gateway
+// is available, and whether it failed to load, e.g. its signature became invalid. It returns nil if the gateway does not
+// pin a version and the default version did not fail to load.
+func wasmModuleResolvedCondition(gateway *machinery.Gateway) *metav1.Condition {
+	version := gateway.GetAnnotations()[WasmModuleVersionAnnotation]
+	failure := wasmModules.Failure(lo.Ternary(version == "", DefaultWasmModuleVersion, version))
+	if version == "" && failure == nil {
+		return nil
+	}
+	module, ok, err := wasmModules.ModuleForGateway(gateway)
+	if err == nil && !ok {
+		err = fmt.Errorf("%w: %s", ErrWasmModuleVersionNotFound, DefaultWasmModuleVersion)
+	}
+	if err != nil {
+		message := err.Error()
+		if failure != nil {
+			message = fmt.Sprintf("%s: failed to load: %v", message, failure)
+		}
+		return &metav1.Condition{
+			Type:    WasmModuleResolvedConditionType,
+			Status:  metav1.ConditionFalse,
+			Reason:  "VersionNotFound",
+			Message: message,
+		}
+	}
+	if failure != nil {
+		return &metav1.Condition{
+			Type:    WasmModuleResolvedConditionType,
+			Status:  metav1.ConditionTrue,
+			Reason:  "LastVerifiedVersion",
+			Message: fmt.Sprintf("wasm module version %s failed to load: %v; its last verified module (sha256 %s) is served to the gateway", module.Version, failure, module.SHA256),
+		}
+	}
+	return &metav1.Condition{
+		Type:    WasmModuleResolvedConditionType,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Resolved",
+		Message: fmt.Sprintf("wasm module version %s is served to the gateway", version),
+	}
+}
+
+// wasmModuleComponentsToSync returns the wasm module pinned to a gateway as a component to sync while its version is
+// not available
+func wasmModuleComponentsToSync(gateway *machinery.Gateway) []string {
+	if _, _, err := wasmModules.ModuleForGateway(gateway); err != nil {
+		return []string{fmt.Sprintf("wasm module %s (%s/%s)", gateway.GetAnnotations()[WasmModuleVersionAnnotation], gateway.GetNamespace(), gateway.GetName())}
+	}
+	return nil
+}
+
+func loadWasmModule(version, path string, modTime time.Time, publicKey crypto.PublicKey, signature []byte) (WasmModule, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return WasmModule{}, err
+	}
+	if publicKey != nil {
+		if err := verifyWasmModuleSignature(publicKey, content, signature); err != nil {
+			return WasmModule{}, err
+		}
+	}
+	return WasmModule{
+		Version:   version,
+		Path:      path,
+		SHA256:    fmt.Sprintf("%x", sha256.Sum256(content)),
+		ModTime:   modTime,
+		Content:   content,
+		Signature: signature,
+	}, nil
+}
+
+// verifyWasmModuleSignature verifies a signature of the content of a module, as created by e.g.
+// 'cosign sign-blob --key' (ECDSA) or 'openssl pkeyutl -sign' (Ed25519). RSA signatures are PKCS #1 v1.5 with SHA256.
+func verifyWasmModuleSignature(publicKey crypto.PublicKey, content, signature []byte) error {
+	if decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature))); err == nil {
+		signature = decoded
+	}
+	digest := sha256.Sum256(content)
+
+	var valid bool
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, content, signature)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	if !valid {
+		return ErrInvalidWasmModuleSignature
+	}
+	return nil
+}
+
+// loadWasmModulePublicKey reads a PEM encoded PKIX public key
+func loadWasmModulePublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
@@ -0,0 +1,251 @@
+//go:build unit
+
+package controllers
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestWasmModuleStore(t *testing.T) {
+	dir := t.TempDir()
+	defaultFilePath := filepath.Join(dir, "plugin.wasm")
+	modulesDir := filepath.Join(dir, "modules")
+	assert.NilError(t, os.Mkdir(modulesDir, 0o755))
+	assert.NilError(t, os.WriteFile(defaultFilePath, []byte("default"), 0o600))
+	assert.NilError(t, os.WriteFile(filepath.Join(modulesDir, "v2.wasm"), []byte("v2"), 0o600))
+	assert.NilError(t, os.WriteFile(filepath.Join(modulesDir, "README.md"), []byte("not a module"), 0o600))
+
+	store := newWasmModuleStore(defaultFilePath, modulesDir)
+
+	changed, err := store.Load(logr.Discard())
+	assert.NilError(t, err)
+	assert.Assert(t, changed)
+	assert.DeepEqual(t, store.Versions(), map[string]string{
+		DefaultWasmModuleVersion: fmt.Sprintf("%x", sha256.Sum256([]byte("default"))),
+		"v2":                     fmt.Sprintf("%x", sha256.Sum256([]byte("v2"))),
+	})
+
+	module, ok := store.ModuleBySHA256(fmt.Sprintf("%x", sha256.Sum256([]byte("v2"))))
+	assert.Assert(t, ok)
+	assert.Equal(t, module.Version, "v2")
+	assert.Equal(t, string(module.Content), "v2")
+
+	// no changes
+	changed, err = store.Load(logr.Discard())
+	assert.NilError(t, err)
+	assert.Assert(t, !changed)
+
+	// hot reload
+	assert.NilError(t, os.WriteFile(filepath.Join(modulesDir, "v2.wasm"), []byte("v2-fixed"), 0o600))
+	assert.NilError(t, os.Chtimes(filepath.Join(modulesDir, "v2.wasm"), time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+	changed, err = store.Load(logr.Discard())
+	assert.NilError(t, err)
+	assert.Assert(t, changed)
+	module, _ = store.Module("v2")
+	assert.Equal(t, module.SHA256, fmt.Sprintf("%x", sha256.Sum256([]byte("v2-fixed"))))
+
+	// pinned versions
+	gateway := func(version string) *machinery.Gateway {
+		return &machinery.Gateway{Gateway: &gatewayapiv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "my-gateway", Annotations: map[string]string{WasmModuleVersionAnnotation: version}}}}
+	}
+	module, ok, err = store.ModuleForGateway(gateway("v2"))
+	assert.NilError(t, err)
+	assert.Assert(t, ok)
+	assert.Equal(t, module.Version, "v2")
+	_, ok, err = store.ModuleForGateway(gateway("v3"))
+	assert.Assert(t, errors.Is(err, ErrWasmModuleVersionNotFound))
+	assert.Assert(t, !ok)
+	module, ok, err = store.ModuleForGateway(gateway(""))
+	assert.NilError(t, err)
+	assert.Assert(t, ok)
+	assert.Equal(t, module.Version, DefaultWasmModuleVersion)
+
+	// removed module
+	assert.NilError(t, os.Remove(filepath.Join(modulesDir, "v2.wasm")))
+	changed, err = store.Load(logr.Discard())
+	assert.NilError(t, err)
+	assert.Assert(t, changed)
+	_, ok = store.Module("v2")
+	assert.Assert(t, !ok)
+}
+
+func TestWasmModuleStoreSignatures(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+
+	dir := t.TempDir()
+	defaultFilePath := filepath.Join(dir, "plugin.wasm")
+	modulesDir := filepath.Join(dir, "modules")
+	assert.NilError(t, os.Mkdir(modulesDir, 0o755))
+	assert.NilError(t, os.WriteFile(defaultFilePath, []byte("default"), 0o600))
+	assert.NilError(t, os.WriteFile(defaultFilePath+".sig", ed25519.Sign(privateKey, []byte("default")), 0o600))
+	assert.NilError(t, os.WriteFile(filepath.Join(modulesDir, "signed.wasm"), []byte("signed"), 0o600))
+	assert.NilError(t, os.WriteFile(filepath.Join(modulesDir, "signed.wasm.sig"), []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte("signed")))+"\n"), 0o600))
+	assert.NilError(t, os.WriteFile(filepath.Join(modulesDir, "tampered.wasm"), []byte("tampered"), 0o600))
+	assert.NilError(t, os.WriteFile(filepath.Join(modulesDir, "tampered.wasm.sig"), ed25519.Sign(privateKey, []byte("original")), 0o600))
+	assert.NilError(t, os.WriteFile(filepath.Join(modulesDir, "unsigned.wasm"), []byte("unsigned"), 0o600))
+
+	store := newWasmModuleStore(defaultFilePath, modulesDir)
+	store.SetPublicKey(publicKey)
+
+	_, err = store.Load(logr.Discard())
+	assert.NilError(t, err)
+	_, ok := store.Module(DefaultWasmModuleVersion)
+	assert.Assert(t, ok)
+	_, ok = store.Module("signed")
+	assert.Assert(t, ok)
+	_, ok = store.Module("tampered")
+	assert.Assert(t, !ok)
+	_, ok = store.Module("unsigned")
+	assert.Assert(t, !ok)
+
+	assert.Assert(t, errors.Is(store.Failure("tampered"), ErrInvalidWasmModuleSignature))
+	assert.Assert(t, errors.Is(store.Failure("unsigned"), ErrWasmModuleSignatureNotFound))
+	assert.NilError(t, store.Failure("signed"))
+
+	// a signature replaced next to an unchanged module is verified again, and the last verified module is kept
+	signed, _ := store.Module("signed")
+	assert.NilError(t, os.WriteFile(filepath.Join(modulesDir, "signed.wasm.sig"), ed25519.Sign(privateKey, []byte("other")), 0o600))
+	changed, err := store.Load(logr.Discard())
+	assert.NilError(t, err)
+	assert.Assert(t, changed)
+	module, ok := store.Module("signed")
+	assert.Assert(t, ok)
+	assert.Equal(t, module.SHA256, signed.SHA256)
+	assert.Assert(t, errors.Is(store.Failure("signed"), ErrInvalidWasmModuleSignature))
+
+	// unchanged failures are not reported as changes
+	changed, err = store.Load(logr.Discard())
+	assert.NilError(t, err)
+	assert.Assert(t, !changed)
+
+	// the last verified module is also kept when the module is replaced by one that does not match the signature
+	assert.NilError(t, os.WriteFile(filepath.Join(modulesDir, "signed.wasm"), []byte("replaced"), 0o600))
+	_, err = store.Load(logr.Discard())
+	assert.NilError(t, err)
+	module, ok = store.Module("signed")
+	assert.Assert(t, ok)
+	assert.Equal(t, module.SHA256, signed.SHA256)
+
+	// the version loads again once its signature is valid
+	assert.NilError(t, os.WriteFile(filepath.Join(modulesDir, "signed.wasm.sig"), ed25519.Sign(privateKey, []byte("replaced")), 0o600))
+	changed, err = store.Load(logr.Discard())
+	assert.NilError(t, err)
+	assert.Assert(t, changed)
+	module, ok = store.Module("signed")
+	assert.Assert(t, ok)
+	assert.Equal(t, string(module.Content), "replaced")
+	assert.NilError(t, store.Failure("signed"))
+
+	assert.NilError(t, os.WriteFile(filepath.Join(modulesDir, "tampered.wasm.sig"), ed25519.Sign(privateKey, []byte("tampered")), 0o600))
+	changed, err = store.Load(logr.Discard())
+	assert.NilError(t, err)
+	assert.Assert(t, changed)
+	_, ok = store.Module("tampered")
+	assert.Assert(t, ok)
+}
+
+func TestVerifyWasmModuleSignatureECDSA(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	content := []byte("module")
+	digest := sha256.Sum256(content)
+	signature, err := ecdsa.SignASN1(rand.Reader, privateKey, digest[:])
+	assert.NilError(t, err)
+
+	// the public key is read from a PEM file, as exported by 'cosign generate-key-pair'
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	assert.NilError(t, err)
+	publicKeyPath := filepath.Join(t.TempDir(), "cosign.pub")
+	assert.NilError(t, os.WriteFile(publicKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER}), 0o600))
+	publicKey, err := loadWasmModulePublicKey(publicKeyPath)
+	assert.NilError(t, err)
+
+	assert.NilError(t, verifyWasmModuleSignature(publicKey, content, []byte(base64.StdEncoding.EncodeToString(signature))))
+	assert.Equal(t, verifyWasmModuleSignature(publicKey, []byte("other"), signature), ErrInvalidWasmModuleSignature)
+}
+
+func TestWasmModuleResolvedCondition(t *testing.T) {
+	dir := t.TempDir()
+	defaultFilePath := filepath.Join(dir, "plugin.wasm")
+	assert.NilError(t, os.WriteFile(defaultFilePath, []byte("default"), 0o600))
+
+	defer func(store *wasmModuleStore) { wasmModules = store }(wasmModules)
+	wasmModules = newWasmModuleStore(defaultFilePath, "")
+	_, err := wasmModules.Load(logr.Discard())
+	assert.NilError(t, err)
+
+	gateway := func(version string) *machinery.Gateway {
+		return &machinery.Gateway{Gateway: &gatewayapiv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "my-gateway", Namespace: "gateway-system", Annotations: map[string]string{WasmModuleVersionAnnotation: version}}}}
+	}
+
+	assert.Assert(t, wasmModuleResolvedCondition(gateway("")) == nil)
+	assert.Equal(t, len(wasmModuleComponentsToSync(gateway(""))), 0)
+
+	condition := wasmModuleResolvedCondition(gateway(DefaultWasmModuleVersion))
+	assert.Equal(t, condition.Status, metav1.ConditionTrue)
+	assert.Equal(t, len(wasmModuleComponentsToSync(gateway(DefaultWasmModuleVersion))), 0)
+
+	condition = wasmModuleResolvedCondition(gateway("v3"))
+	assert.Equal(t, condition.Status, metav1.ConditionFalse)
+	assert.Equal(t, condition.Reason, "VersionNotFound")
+	assert.Equal(t, condition.Message, "wasm module version not found: v3 (available: default)")
+	assert.DeepEqual(t, wasmModuleComponentsToSync(gateway("v3")), []string{"wasm module v3 (gateway-system/my-gateway)"})
+}
+
+func TestWasmModuleResolvedConditionLastVerifiedVersion(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+
+	dir := t.TempDir()
+	defaultFilePath := filepath.Join(dir, "plugin.wasm")
+	assert.NilError(t, os.WriteFile(defaultFilePath, []byte("default"), 0o600))
+	assert.NilError(t, os.WriteFile(defaultFilePath+".sig", ed25519.Sign(privateKey, []byte("default")), 0o600))
+
+	defer func(store *wasmModuleStore) { wasmModules = store }(wasmModules)
+	wasmModules = newWasmModuleStore(defaultFilePath, "")
+	wasmModules.SetPublicKey(publicKey)
+	_, err = wasmModules.Load(logr.Discard())
+	assert.NilError(t, err)
+	loaded, ok := wasmModules.Module(DefaultWasmModuleVersion)
+	assert.Assert(t, ok)
+
+	gateway := &machinery.Gateway{Gateway: &gatewayapiv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "my-gateway", Namespace: "gateway-system"}}}
+	assert.Assert(t, wasmModuleResolvedCondition(gateway) == nil)
+
+	// the signature of the loaded module is replaced by an invalid one
+	assert.NilError(t, os.WriteFile(defaultFilePath+".sig", ed25519.Sign(privateKey, []byte("other")), 0o600))
+	_, err = wasmModules.Load(logr.Discard())
+	assert.NilError(t, err)
+
+	// the last verified module is still served, and the failure is surfaced
+	wasmURL, wasmSHA, err := wasmModuleForGateway(gateway, "wasm-server", 8082)
+	assert.NilError(t, err)
+	assert.Equal(t, wasmSHA, loaded.SHA256)
+	assert.Equal(t, wasmURL, fmt.Sprintf("http://wasm-server:8082/sha256/%s.wasm", loaded.SHA256))
+	condition := wasmModuleResolvedCondition(gateway)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, metav1.ConditionTrue)
+	assert.Equal(t, condition.Reason, "LastVerifiedVersion")
+	assert.Equal(t, condition.Message, fmt.Sprintf("wasm module version default failed to load: invalid wasm module signature; its last verified module (sha256 %s) is served to the gateway", loaded.SHA256))
+	assert.Equal(t, len(wasmModuleComponentsToSync(gateway)), 0)
+}
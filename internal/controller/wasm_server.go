@@ -1,13 +1,14 @@
 package controllers
 
 import (
+	"bytes"
 	"context"
-	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -18,34 +19,22 @@ import (
 )
 
 const (
-	defaultWasmServerPort = 8082
-	WasmServerClusterName = "kuadrant-operator-wasm"
+	defaultWasmServerPort           = 8082
+	defaultWasmServerReloadInterval = 30 * time.Second
+	WasmServerClusterName           = "kuadrant-operator-wasm"
 )
 
 var wasmFilePath = env.GetString("WASM_SERVER_FILE_PATH", "/wasm/plugin.wasm")
 
-var WasmFileSHA256 string
-
-func computeFileSHA256(path string) (string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
-	h := sha256.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", err
-	}
-	return fmt.Sprintf("%x", h.Sum(nil)), nil
-}
-
 type WasmServer struct {
-	server *http.Server
-	logger logr.Logger
+	server  *http.Server
+	modules *wasmModuleStore
+	synced  atomic.Bool
+	logger  logr.Logger
 }
 
 func NewWasmServer(logger logr.Logger) *WasmServer {
-	return &WasmServer{logger: logger.WithName("WasmServer")}
+	return &WasmServer{modules: wasmModules, logger: logger.WithName("WasmServer")}
 }
 
 func (s *WasmServer) Run(stopCh <-chan struct{}) {
@@ -55,22 +44,40 @@ func (s *WasmServer) Run(stopCh <-chan struct{}) {
 		port = defaultWasmServerPort
 	}
 
-	if _, err := os.Stat(wasmFilePath); err != nil {
-		s.logger.Error(err, "wasm file not found, server will not start", "path", wasmFilePath)
-		return
+	reloadInterval, err := time.ParseDuration(env.GetString("WASM_SERVER_RELOAD_INTERVAL", defaultWasmServerReloadInterval.String()))
+	if err != nil || reloadInterval <= 0 {
+		s.logger.Error(err, "invalid WASM_SERVER_RELOAD_INTERVAL, using default", "default", defaultWasmServerReloadInterval)
+		reloadInterval = defaultWasmServerReloadInterval
 	}
 
-	sha, err := computeFileSHA256(wasmFilePath)
-	if err != nil {
-		s.logger.Error(err, "failed to compute wasm file SHA256", "path", wasmFilePath)
+	if !s.loadModules() {
 		return
 	}
-	WasmFileSHA256 = sha
+
+	go s.modules.Watch(s.logger, reloadInterval, stopCh)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /plugin.wasm", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/wasm")
-		http.ServeFile(w, r, wasmFilePath)
+		module, ok := s.modules.Module(DefaultWasmModuleVersion)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		serveWasmModule(w, r, module)
+	})
+	// content-addressed modules, so the proxies never cache a version as another
+	mux.HandleFunc("GET /sha256/{file}", func(w http.ResponseWriter, r *http.Request) {
+		sha, found := strings.CutSuffix(r.PathValue("file"), wasmModuleFileExtension)
+		module, ok := s.modules.ModuleBySHA256(sha)
+		if !found || !ok {
+			http.NotFound(w, r)
+			return
+		}
+		serveWasmModule(w, r, module)
+	})
+	mux.HandleFunc("GET /modules", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.modules.Versions())
 	})
 
 	h2s := &http2.Server{}
@@ -81,7 +88,7 @@ func (s *WasmServer) Run(stopCh <-chan struct{}) {
 	}
 
 	go func() {
-		s.logger.Info("starting wasm server (h2c)", "port", port, "file", wasmFilePath, "sha256", WasmFileSHA256)
+		s.logger.Info("starting wasm server (h2c)", "port", port, "modules", s.modules.Versions())
 		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			s.logger.Error(err, "wasm server failed")
 		}
@@ -97,10 +104,42 @@ func (s *WasmServer) Run(stopCh <-chan struct{}) {
 	}
 }
 
-func (s *WasmServer) HasSynced() bool {
+// loadModules loads the modules for the first time, returning whether the server can start
+func (s *WasmServer) loadModules() bool {
+	defer s.synced.Store(true)
+
+	if wasmModulePublicKeyPath != "" {
+		publicKey, err := loadWasmModulePublicKey(wasmModulePublicKeyPath)
+		if err != nil {
+			s.logger.Error(err, "failed to load the public key of the wasm modules, server will not start", "path", wasmModulePublicKeyPath)
+			return false
+		}
+		s.modules.SetPublicKey(publicKey)
+	}
+
+	if _, err := s.modules.Load(s.logger); err != nil {
+		s.logger.Error(err, "failed to load wasm modules, server will not start", "path", wasmFilePath, "dir", wasmModulesDir)
+		return false
+	}
+
+	if len(s.modules.Versions()) == 0 {
+		s.logger.Error(errors.New("no wasm module found"), "server will not start", "path", wasmFilePath, "dir", wasmModulesDir)
+		return false
+	}
+
 	return true
 }
 
+// HasSynced is true once the modules have been loaded, so the extension reconcilers only run with the modules known
+func (s *WasmServer) HasSynced() bool {
+	return s.synced.Load()
+}
+
+func serveWasmModule(w http.ResponseWriter, r *http.Request, module WasmModule) {
+	w.Header().Set("Content-Type", "application/wasm")
+	http.ServeContent(w, r, module.Version+wasmModuleFileExtension, module.ModTime, bytes.NewReader(module.Content))
+}
+
 func wasmServerRunnable(logger logr.Logger) controller.RunnableBuilder {
 	return func(*controller.Controller) controller.Runnable {
 		return NewWasmServer(logger)
@@ -0,0 +1,134 @@
+package envoy
+
+const (
+	ExtAuthzFilterName  = "envoy.filters.http.ext_authz"
+	RateLimitFilterName = "envoy.filters.http.ratelimit"
+	RBACFilterName      = "envoy.filters.http.rbac"
+
+	ExtAuthzTypeURL          = "type.googleapis.com/envoy.extensions.filters.http.ext_authz.v3.ExtAuthz"
+	ExtAuthzPerRouteTypeURL  = "type.googleapis.com/envoy.extensions.filters.http.ext_authz.v3.ExtAuthzPerRoute"
+	RateLimitTypeURL         = "type.googleapis.com/envoy.extensions.filters.http.ratelimit.v3.RateLimit"
+	RateLimitPerRouteTypeURL = "type.googleapis.com/envoy.extensions.filters.http.ratelimit.v3.RateLimitPerRoute"
+	RBACTypeURL              = "type.googleapis.com/envoy.extensions.filters.http.rbac.v3.RBAC"
+	RBACPerRouteTypeURL      = "type.googleapis.com/envoy.extensions.filters.http.rbac.v3.RBACPerRoute"
+	FilterConfigTypeURL      = "type.googleapis.com/envoy.config.route.v3.FilterConfig"
+
+	// NativeRateLimitDomain is the domain of the ratelimit filter, always overridden by the domain set per route
+	NativeRateLimitDomain = "kuadrant"
+)
+
+// BuildExtAuthzFilter builds the ext_authz HTTP filter calling the auth service over gRPC.
+// The filter is disabled by default and only enabled in the routes with a per-route config
+func BuildExtAuthzFilter(clusterName, timeout string, failureModeAllow bool) map[string]any {
+	return map[string]any{
+		"name":     ExtAuthzFilterName,
+		"disabled": true,
+		"typed_config": map[string]any{
+			"@type": ExtAuthzTypeURL,
+			"grpc_service": map[string]any{
+				"envoy_grpc": map[string]any{
+					"cluster_name": clusterName,
+				},
+				"timeout": timeout,
+			},
+			"transport_api_version": "V3",
+			"failure_mode_allow":    failureModeAllow,
+		},
+	}
+}
+
+// BuildRateLimitFilter builds the ratelimit HTTP filter calling the rate limit service over gRPC.
+// The filter is disabled by default and only enabled in the routes with a per-route config
+func BuildRateLimitFilter(clusterName, timeout string, failureModeDeny bool) map[string]any {
+	return map[string]any{
+		"name":     RateLimitFilterName,
+		"disabled": true,
+		"typed_config": map[string]any{
+			"@type":  RateLimitTypeURL,
+			"domain": NativeRateLimitDomain,
+			"rate_limit_service": map[string]any{
+				"grpc_service": map[string]any{
+					"envoy_grpc": map[string]any{
+						"cluster_name": clusterName,
+					},
+					"timeout": timeout,
+				},
+				"transport_api_version": "V3",
+			},
+			"timeout":           timeout,
+			"failure_mode_deny": failureModeDeny,
+		},
+	}
+}
+
+// BuildRBACFilter builds the RBAC HTTP filter used to deny the requests of the routes whose auth cannot be enforced.
+// The filter is disabled by default and only enabled in the routes with a per-route config
+func BuildRBACFilter() map[string]any {
+	return map[string]any{
+		"name":     RBACFilterName,
+		"disabled": true,
+		"typed_config": map[string]any{
+			"@type": RBACTypeURL,
+		},
+	}
+}
+
+// BuildDenyAllPerRouteConfig builds the per-route config that enables the RBAC filter in a route, denying every request
+func BuildDenyAllPerRouteConfig() map[string]any {
+	return buildFilterConfig(map[string]any{
+		"@type": RBACPerRouteTypeURL,
+		"rbac": map[string]any{
+			"rules": map[string]any{
+				"action": "DENY",
+				"policies": map[string]any{
+					"kuadrant-deny-all": map[string]any{
+						"permissions": []any{map[string]any{"any": true}},
+						"principals":  []any{map[string]any{"any": true}},
+					},
+				},
+			},
+		},
+	})
+}
+
+// BuildExtAuthzPerRouteConfig builds the per-route config that enables the ext_authz filter in a route, sending the
+// name of the auth config as the host context extension
+func BuildExtAuthzPerRouteConfig(authConfigName string) map[string]any {
+	return buildFilterConfig(map[string]any{
+		"@type": ExtAuthzPerRouteTypeURL,
+		"check_settings": map[string]any{
+			"context_extensions": map[string]any{
+				"host": authConfigName,
+			},
+		},
+	})
+}
+
+// BuildRateLimitPerRouteConfig builds the per-route config that enables the ratelimit filter in a route.
+// The filter sends a single descriptor to the given domain, with an entry per descriptor key set to "1"
+func BuildRateLimitPerRouteConfig(domain string, descriptorKeys []string) map[string]any {
+	actions := make([]any, 0, len(descriptorKeys))
+	for _, key := range descriptorKeys {
+		actions = append(actions, map[string]any{
+			"generic_key": map[string]any{
+				"descriptor_key":   key,
+				"descriptor_value": "1",
+			},
+		})
+	}
+	return buildFilterConfig(map[string]any{
+		"@type":  RateLimitPerRouteTypeURL,
+		"domain": domain,
+		"rate_limits": []any{
+			map[string]any{"actions": actions},
+		},
+	})
+}
+
+// buildFilterConfig wraps a per-route config so it enables the filter disabled in the HTTP connection manager
+func buildFilterConfig(config map[string]any) map[string]any {
+	return map[string]any{
+		"@type":  FilterConfigTypeURL,
+		"config": config,
+	}
+}
@@ -0,0 +1,103 @@
+package envoy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	WasmFilterName = "envoy.filters.http.wasm"
+
+	WasmTypeURL                 = "type.googleapis.com/envoy.extensions.filters.http.wasm.v3.Wasm"
+	ClusterTypeURL              = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+	TypedExtensionConfigTypeURL = "type.googleapis.com/envoy.config.core.v3.TypedExtensionConfig"
+)
+
+// BuildWasmFilterConfig builds the Envoy wasm filter configuration
+func BuildWasmFilterConfig(wasmURL, imagePullSecret, imageSHA, clusterName string, pluginConfig *structpb.Struct) (map[string]any, error) {
+	config := map[string]any{
+		"name":    "kuadrant-wasm-shim",
+		"root_id": "kuadrant_wasm_shim",
+		"vm_config": map[string]any{
+			"runtime": "envoy.wasm.runtime.v8",
+			"code": map[string]any{
+				"remote": map[string]any{
+					"http_uri": map[string]any{
+						"uri":     wasmURL,
+						"timeout": "10s",
+						"cluster": clusterName,
+					},
+					"sha256": imageSHA,
+				},
+			},
+			"allow_precompiled": true,
+		},
+		"allow_on_headers_stop_iteration": true,
+	}
+
+	if pluginConfig != nil {
+		configJSON, err := pluginConfig.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal plugin config: %w", err)
+		}
+		config["configuration"] = map[string]any{
+			"@type": "type.googleapis.com/google.protobuf.StringValue",
+			"value": string(configJSON),
+		}
+	}
+
+	// Add image pull secret if provided
+	if imagePullSecret != "" {
+		if vmConfig, ok := config["vm_config"].(map[string]any); ok {
+			if code, ok := vmConfig["code"].(map[string]any); ok {
+				if remote, ok := code["remote"].(map[string]any); ok {
+					remote["image_pull_secret"] = imagePullSecret
+				}
+			}
+		}
+	}
+
+	return map[string]any{
+		"config": config,
+	}, nil
+}
+
+// BuildWasmExtensionConfig wraps a wasm filter configuration into a TypedExtensionConfig, as served to Envoy
+// via the extension config discovery service (ECDS)
+func BuildWasmExtensionConfig(name string, wasmFilterConfig map[string]any) map[string]any {
+	typedConfig := maps.Clone(wasmFilterConfig)
+	typedConfig["@type"] = WasmTypeURL
+	return map[string]any{
+		"name":         name,
+		"typed_config": typedConfig,
+	}
+}
+
+// BuildDiscoveryResponse builds the JSON encoded xDS DiscoveryResponse of a given type of resources, to be read by
+// Envoy from the filesystem (path_config_source). The version of the response is derived from the content of the
+// resources, so Envoy only applies the response when the resources change.
+func BuildDiscoveryResponse(typeURL string, resources []map[string]any) ([]byte, error) {
+	typedResources := lo.Map(resources, func(resource map[string]any, _ int) map[string]any {
+		typedResource := maps.Clone(resource)
+		typedResource["@type"] = typeURL
+		return typedResource
+	})
+
+	resourcesJSON, err := json.Marshal(typedResources)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(resourcesJSON)
+
+	return json.Marshal(map[string]any{
+		"version_info": hex.EncodeToString(hash[:8]),
+		"type_url":     typeURL,
+		"resources":    json.RawMessage(resourcesJSON),
+	})
+}
@@ -2,6 +2,7 @@ package envoygateway
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect"
 
 	envoygatewayv1alpha1 "github.com/envoyproxy/gateway/api/v1alpha1"
@@ -151,6 +152,51 @@ func BuildEnvoyPatchPolicyClusterPatch(name, host string, port int, mtls bool, c
 	}, nil
 }
 
+// BuildEnvoyPatchPolicyHTTPFiltersPatch returns the patches that add HTTP filters to the HTTP connection managers of
+// a listener, at the beginning of the filter chain and in the given order.
+func BuildEnvoyPatchPolicyHTTPFiltersPatch(listenerName string, filters []map[string]any) ([]envoygatewayv1alpha1.EnvoyJSONPatchConfig, error) {
+	patches := make([]envoygatewayv1alpha1.EnvoyJSONPatchConfig, 0, len(filters))
+	// every filter is inserted first, so the last one added runs first
+	for i := len(filters) - 1; i >= 0; i-- {
+		patchRaw, _ := json.Marshal(filters[i])
+		patch := &apiextensionsv1.JSON{}
+		if err := patch.UnmarshalJSON(patchRaw); err != nil {
+			return nil, err
+		}
+		patches = append(patches, envoygatewayv1alpha1.EnvoyJSONPatchConfig{
+			Type: envoygatewayv1alpha1.ListenerEnvoyResourceType,
+			Name: listenerName,
+			Operation: envoygatewayv1alpha1.JSONPatchOperation{
+				Op:       envoygatewayv1alpha1.JSONPatchOperationType("add"),
+				JSONPath: ptr.To("..http_filters"),
+				Path:     ptr.To("/0"),
+				Value:    patch,
+			},
+		})
+	}
+	return patches, nil
+}
+
+// BuildEnvoyPatchPolicyRoutePatch returns the patch that sets the typed_per_filter_config of the routes of a route
+// configuration whose names match a given regular expression.
+func BuildEnvoyPatchPolicyRoutePatch(routeConfigurationName, routeNamePattern string, perFilterConfig map[string]any) (envoygatewayv1alpha1.EnvoyJSONPatchConfig, error) {
+	patchRaw, _ := json.Marshal(perFilterConfig)
+	patch := &apiextensionsv1.JSON{}
+	if err := patch.UnmarshalJSON(patchRaw); err != nil {
+		return envoygatewayv1alpha1.EnvoyJSONPatchConfig{}, err
+	}
+	return envoygatewayv1alpha1.EnvoyJSONPatchConfig{
+		Type: envoygatewayv1alpha1.RouteConfigurationEnvoyResourceType,
+		Name: routeConfigurationName,
+		Operation: envoygatewayv1alpha1.JSONPatchOperation{
+			Op:       envoygatewayv1alpha1.JSONPatchOperationType("add"),
+			JSONPath: ptr.To(fmt.Sprintf("..routes[?match(@.name, '%s')]", routeNamePattern)),
+			Path:     ptr.To("/typed_per_filter_config"),
+			Value:    patch,
+		},
+	}, nil
+}
+
 func EqualEnvoyPatchPolicies(a, b *envoygatewayv1alpha1.EnvoyPatchPolicy) bool {
 	if a.Spec.Type != b.Spec.Type || a.Spec.Priority != b.Spec.Priority || !reflect.DeepEqual(a.Spec.TargetRef, b.Spec.TargetRef) {
 		return false
@@ -28,12 +28,15 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/go-logr/logr"
 	"github.com/google/cel-go/cel"
 	celtypes "github.com/google/cel-go/common/types"
 	authorinov1beta3 "github.com/kuadrant/authorino/api/v1beta3"
+	"github.com/samber/lo"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	grpcstatus "google.golang.org/grpc/status"
@@ -41,7 +44,9 @@ import (
 	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/utils/env"
 
@@ -56,13 +61,25 @@ var ErrNoExtensionsFound = errors.New("no extensions found")
 type ChangeNotifier func(reason string) error
 
 type Manager struct {
-	extensions       []Extension
-	service          extpb.ExtensionServiceServer
-	dag              *nilGuardedPointer[StateAwareDAG]
-	logger           logr.Logger
-	sync             io.Writer
-	client           dynamic.Interface
-	descriptorServer *grpc.Server
+	extensions        []Extension
+	service           extpb.ExtensionServiceServer
+	dag               *nilGuardedPointer[StateAwareDAG]
+	logger            logr.Logger
+	sync              io.Writer
+	client            dynamic.Interface
+	restMapper        meta.RESTMapper
+	descriptorServer  *grpc.Server
+	remoteServer      *grpc.Server
+	remoteExtensions  *remoteExtensions
+	location          string
+	supervisorOptions SupervisorOptions
+	swapTimeout       time.Duration
+	changeNotifier    ChangeNotifier
+	extensionsMu      sync.Mutex
+	versions          map[string]extensionVersion
+	generation        int
+	watcher           *fsnotify.Watcher
+	watcherDone       chan struct{}
 }
 
 type Extension interface {
@@ -71,21 +88,30 @@ type Extension interface {
 	Name() string
 }
 
+// NewManager returns a manager of the extensions found in the given directory. The directory is watched once the
+// manager is started, so it can be empty, but it must exist unless remote extensions are enabled.
 func NewManager(location string, logger logr.Logger, sync io.Writer, client dynamic.Interface) (Manager, error) {
-	names := discoverExtensions(logger, location)
-	if len(names) == 0 {
-		return Manager{}, ErrNoExtensionsFound
+	if _, err := os.Stat(location); err != nil {
+		logger.Info("Extensions directory cannot be read", "directory", location, "error", err.Error())
+		if remoteExtensionsTLSDir() == "" {
+			return Manager{}, ErrNoExtensionsFound
+		}
 	}
+	names := discoverExtensions(logger, location)
 
 	var extensions []Extension
 	var err error
+	versions := map[string]extensionVersion{}
 
 	service := newExtensionService(BlockingDAG, logger)
 	logger = logger.WithName("extension")
 
+	supervisorOptions := DefaultSupervisorOptions(logger)
+
 	for _, name := range names {
 		if oopExtension, e := NewOOPExtension(name, location, service, logger, sync); e == nil {
-			extensions = append(extensions, &oopExtension)
+			extensions = append(extensions, NewSupervisor(&oopExtension, supervisorOptions, logger))
+			versions[name], _ = getExtensionVersion(location, name)
 		} else {
 			if err == nil {
 				err = fmt.Errorf("%s: %w", name, e)
@@ -96,12 +122,17 @@ func NewManager(location string, logger logr.Logger, sync io.Writer, client dyna
 	}
 
 	return Manager{
-		extensions: extensions,
-		service:    service,
-		dag:        BlockingDAG,
-		logger:     logger,
-		sync:       sync,
-		client:     client,
+		extensions:        extensions,
+		service:           service,
+		dag:               BlockingDAG,
+		logger:            logger,
+		sync:              sync,
+		client:            client,
+		location:          location,
+		supervisorOptions: supervisorOptions,
+		swapTimeout:       defaultExtensionSwapTimeout,
+		versions:          versions,
+		remoteExtensions:  newRemoteExtensions(),
 	}, err
 }
 
@@ -113,6 +144,16 @@ func (m *Manager) Start() error {
 		err = fmt.Errorf("descriptor server: %w", e)
 	}
 
+	if e := m.startRemoteServer(); e != nil {
+		m.logger.Error(e, "failed to start remote extensions server")
+		if err == nil {
+			err = fmt.Errorf("remote extensions server: %w", e)
+		} else {
+			err = fmt.Errorf("%w; remote extensions server: %w", err, e)
+		}
+	}
+
+	m.extensionsMu.Lock()
 	for _, extension := range m.extensions {
 		if e := extension.Start(); e != nil {
 			if err == nil {
@@ -122,6 +163,13 @@ func (m *Manager) Start() error {
 			}
 		}
 	}
+	m.extensionsMu.Unlock()
+
+	if _, e := os.Stat(m.location); e != nil {
+		m.logger.Info("extensions directory cannot be read, only remote extensions are served", "directory", m.location)
+	} else if e := m.watchExtensions(); e != nil {
+		m.logger.Error(e, "failed to watch the extensions directory, extensions will not be reloaded", "directory", m.location)
+	}
 
 	return err
 }
@@ -129,8 +177,12 @@ func (m *Manager) Start() error {
 func (m *Manager) Stop() error {
 	var err error
 
+	m.stopWatchingExtensions()
 	m.stopDescriptorServer()
+	m.stopRemoteServer()
 
+	m.extensionsMu.Lock()
+	defer m.extensionsMu.Unlock()
 	for _, extension := range m.extensions {
 		if e := extension.Stop(); e != nil {
 			if err == nil {
@@ -207,14 +259,36 @@ func (m *Manager) SetChangeNotifier(notifier ChangeNotifier) {
 	if service, ok := m.service.(*extensionService); ok {
 		service.changeNotifier = notifier
 	}
+	m.extensionsMu.Lock()
+	defer m.extensionsMu.Unlock()
+	m.changeNotifier = notifier
+	for _, extension := range m.extensions {
+		if supervisor, ok := extension.(*Supervisor); ok {
+			supervisor.SetChangeNotifier(notifier)
+		}
+	}
+}
+
+// SetRESTMapper sets the mapper used to find the resources of the policies handled by the extensions, e.g. to release
+// their finalizers once an extension is removed
+func (m *Manager) SetRESTMapper(mapper meta.RESTMapper) {
+	m.extensionsMu.Lock()
+	defer m.extensionsMu.Unlock()
+	m.restMapper = mapper
 }
 
 func (m *Manager) TriggerReconciliation(reason string) error {
-	logger := m.logger.WithName("TriggerReconciliation")
+	return TriggerKuadrantReconciliation(context.TODO(), m.client, m.logger, reason)
+}
+
+// TriggerKuadrantReconciliation triggers a reconciliation of the state of the world by annotating a Kuadrant resource
+// with the time and reason of the trigger
+func TriggerKuadrantReconciliation(ctx context.Context, client dynamic.Interface, logger logr.Logger, reason string) error {
+	logger = logger.WithName("TriggerReconciliation")
 	logger.V(1).Info("triggering reconciliation", "reason", reason)
 
-	kuadrantResource := m.client.Resource(kuadrantv1beta1.KuadrantsResource)
-	kuadrantList, err := kuadrantResource.List(context.TODO(), metav1.ListOptions{})
+	kuadrantResource := client.Resource(kuadrantv1beta1.KuadrantsResource)
+	kuadrantList, err := kuadrantResource.List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to list Kuadrant resources: %w", err)
 	}
@@ -236,7 +310,7 @@ func (m *Manager) TriggerReconciliation(reason string) error {
 		kuadrant.SetAnnotations(annotations)
 
 		_, err := kuadrantResource.Namespace(kuadrant.GetNamespace()).Update(
-			context.TODO(),
+			ctx,
 			&kuadrant,
 			metav1.UpdateOptions{},
 		)
@@ -319,6 +393,11 @@ type extensionService struct {
 	reflectionFetcher ReflectionFetcher
 	changeNotifier    ChangeNotifier
 	logger            logr.Logger
+
+	// handledPolicyKinds are the kinds of the policies each extension made calls for, by name of the extension
+	handledPolicyKinds   map[string]map[schema.GroupKind]struct{}
+	handledPolicyKindsMu sync.Mutex
+
 	extpb.UnimplementedExtensionServiceServer
 	extpb.UnimplementedDescriptorServiceServer
 }
@@ -392,14 +471,66 @@ func newExtensionService(dag *nilGuardedPointer[StateAwareDAG], logger logr.Logg
 	return service
 }
 
+// recordHandledPolicyKind records the kind of a policy the extension the call comes from handles, so that the data
+// registered for the policies of the kind, and their finalizers, can be released once the extension is removed
+func (s *extensionService) recordHandledPolicyKind(ctx context.Context, metadata *extpb.Metadata) {
+	name := extensionNameFromContext(ctx)
+	if name == "" || metadata.GetKind() == "" {
+		return
+	}
+	s.handledPolicyKindsMu.Lock()
+	defer s.handledPolicyKindsMu.Unlock()
+	if s.handledPolicyKinds == nil {
+		s.handledPolicyKinds = map[string]map[schema.GroupKind]struct{}{}
+	}
+	if s.handledPolicyKinds[name] == nil {
+		s.handledPolicyKinds[name] = map[schema.GroupKind]struct{}{}
+	}
+	s.handledPolicyKinds[name][schema.GroupKind{Group: metadata.GetGroup(), Kind: metadata.GetKind()}] = struct{}{}
+}
+
+// releaseExtension clears the data registered for the policies of the kinds handled by an extension and by no other
+// extension, returning the kinds
+func (s *extensionService) releaseExtension(name string) []schema.GroupKind {
+	s.handledPolicyKindsMu.Lock()
+	kinds := lo.Filter(lo.Keys(s.handledPolicyKinds[name]), func(kind schema.GroupKind, _ int) bool {
+		return !lo.SomeBy(lo.Entries(s.handledPolicyKinds), func(e lo.Entry[string, map[schema.GroupKind]struct{}]) bool {
+			_, handled := e.Value[kind]
+			return e.Key != name && handled
+		})
+	})
+	delete(s.handledPolicyKinds, name)
+	s.handledPolicyKindsMu.Unlock()
+
+	for _, kind := range kinds {
+		for _, policy := range s.registeredData.GetPoliciesOfKind(kind.Kind) {
+			clearedMutators, clearedSubscriptions, clearedUpstreams := s.registeredData.ClearPolicyData(policy)
+			s.logger.V(1).Info("cleared data of policy handled by removed extension", "extension", name,
+				"policy", fmt.Sprintf("%s/%s", policy.Namespace, policy.Name), "kind", policy.Kind,
+				"mutators", clearedMutators, "subscriptions", clearedSubscriptions, "upstreams", clearedUpstreams)
+		}
+	}
+	return kinds
+}
+
 func (s *extensionService) Subscribe(request *extpb.SubscribeRequest, stream grpc.ServerStreamingServer[extpb.SubscribeResponse]) error {
 	if request.PolicyKind == "" {
 		return fmt.Errorf("policy_kind is required for subscription")
 	}
+	if err := checkPolicyKind(stream.Context(), request.PolicyKind); err != nil {
+		return err
+	}
 
-	channel := BlockingDAG.newUpdateChannel()
+	channel, closeChannel := BlockingDAG.newClosableUpdateChannel()
+	defer closeChannel()
 	for {
-		dag := <-channel
+		var dag StateAwareDAG
+		select {
+		case <-stream.Context().Done():
+			// the extension went away or is being replaced, so the stream is no longer read
+			return stream.Context().Err()
+		case dag = <-channel:
+		}
 		opts := []cel.EnvOption{
 			kuadrant.CelExt(&dag),
 		}
@@ -410,12 +541,14 @@ func (s *extensionService) Subscribe(request *extpb.SubscribeRequest, stream grp
 				if prg, err := env.Program(sub.CAst); err == nil {
 					if newVal, _, err := prg.Eval(sub.Input); err == nil {
 						if equalResult := celtypes.Equal(newVal, sub.Val); !celtypes.IsBool(equalResult) || equalResult != celtypes.True {
-							s.registeredData.UpdateSubscriptionValue(key.Policy, key.Expression, newVal)
+							// only recorded once sent, so the change is not lost if the stream of an extension that is being
+							// replaced fails
 							if err := stream.Send(&extpb.SubscribeResponse{Event: &extpb.Event{
 								Metadata: sub.Input["self"].(*extpb.Policy).Metadata,
 							}}); err != nil {
 								return err
 							}
+							s.registeredData.UpdateSubscriptionValue(key.Policy, key.Expression, newVal)
 						}
 					}
 				}
@@ -424,7 +557,12 @@ func (s *extensionService) Subscribe(request *extpb.SubscribeRequest, stream grp
 	}
 }
 
-func (s *extensionService) Resolve(_ context.Context, request *extpb.ResolveRequest) (*extpb.ResolveResponse, error) {
+func (s *extensionService) Resolve(ctx context.Context, request *extpb.ResolveRequest) (*extpb.ResolveResponse, error) {
+	if err := checkPolicyKind(ctx, request.GetPolicy().GetMetadata().GetKind()); err != nil {
+		return nil, err
+	}
+	s.recordHandledPolicyKind(ctx, request.GetPolicy().GetMetadata())
+
 	dag, success := s.dag.getWaitWithTimeout(1 * time.Minute)
 	if !success {
 		return nil, fmt.Errorf("unable to get to a dag in time")
@@ -445,6 +583,9 @@ func (s *extensionService) Resolve(_ context.Context, request *extpb.ResolveRequ
 	if issues.Err() != nil {
 		return nil, issues.Err()
 	}
+	if err := checkCELExpression(ctx, cAst); err != nil {
+		return nil, err
+	}
 	prg, err := env.Program(cAst)
 	if err != nil {
 		return nil, err
@@ -483,7 +624,7 @@ func (s *extensionService) Resolve(_ context.Context, request *extpb.ResolveRequ
 	}, nil
 }
 
-func (s *extensionService) RegisterMutator(_ context.Context, request *extpb.RegisterMutatorRequest) (*emptypb.Empty, error) {
+func (s *extensionService) RegisterMutator(ctx context.Context, request *extpb.RegisterMutatorRequest) (*emptypb.Empty, error) {
 	// we should probably parse / check the cel expression here
 	if request == nil {
 		return nil, errors.New("request cannot be nil")
@@ -503,6 +644,13 @@ func (s *extensionService) RegisterMutator(_ context.Context, request *extpb.Reg
 	if len(request.Policy.TargetRefs) == 0 {
 		return nil, errors.New("policy must have target references")
 	}
+	if err := checkPolicyKind(ctx, request.Policy.Metadata.Kind); err != nil {
+		return nil, err
+	}
+	s.recordHandledPolicyKind(ctx, request.Policy.Metadata)
+	if err := checkDomain(ctx, request.Domain); err != nil {
+		return nil, err
+	}
 
 	policyID := ResourceID{
 		Kind:      request.Policy.Metadata.Kind,
@@ -534,7 +682,7 @@ func (s *extensionService) RegisterMutator(_ context.Context, request *extpb.Reg
 	return &emptypb.Empty{}, nil
 }
 
-func (s *extensionService) ClearPolicy(_ context.Context, request *extpb.ClearPolicyRequest) (*extpb.ClearPolicyResponse, error) {
+func (s *extensionService) ClearPolicy(ctx context.Context, request *extpb.ClearPolicyRequest) (*extpb.ClearPolicyResponse, error) {
 	if request == nil {
 		return nil, errors.New("request cannot be nil")
 	}
@@ -547,6 +695,10 @@ func (s *extensionService) ClearPolicy(_ context.Context, request *extpb.ClearPo
 	if request.Policy.Metadata.Kind == "" || request.Policy.Metadata.Namespace == "" || request.Policy.Metadata.Name == "" {
 		return nil, errors.New("policy kind, namespace, and name must be specified")
 	}
+	if err := checkPolicyKind(ctx, request.Policy.Metadata.Kind); err != nil {
+		return nil, err
+	}
+	s.recordHandledPolicyKind(ctx, request.Policy.Metadata)
 
 	policyID := ResourceID{
 		Kind:      request.Policy.Metadata.Kind,
@@ -623,6 +775,13 @@ func (s *extensionService) RegisterActionMethod(ctx context.Context, request *ex
 	if host == "" {
 		return nil, fmt.Errorf("url must contain a host: %q", request.Url)
 	}
+	if err := checkPolicyKind(ctx, request.Policy.Metadata.Kind); err != nil {
+		return nil, err
+	}
+	s.recordHandledPolicyKind(ctx, request.Policy.Metadata)
+	if err := checkUpstreamHost(ctx, host); err != nil {
+		return nil, err
+	}
 	var port int
 	if portStr := parsed.Port(); portStr != "" {
 		var err error
@@ -746,7 +905,7 @@ func validateCELExpression(expr string) error {
 
 var varNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 
-func (s *extensionService) PipelineCommit(_ context.Context, request *extpb.PipelineCommitRequest) (*emptypb.Empty, error) {
+func (s *extensionService) PipelineCommit(ctx context.Context, request *extpb.PipelineCommitRequest) (*emptypb.Empty, error) {
 	if request == nil {
 		return nil, errors.New("request cannot be nil")
 	}
@@ -755,6 +914,13 @@ func (s *extensionService) PipelineCommit(_ context.Context, request *extpb.Pipe
 	if err != nil {
 		return nil, err
 	}
+	if err := checkPolicyKind(ctx, policyID.Kind); err != nil {
+		return nil, err
+	}
+	s.recordHandledPolicyKind(ctx, request.Policy.Metadata)
+	if err := checkActionTypes(ctx, request.Actions); err != nil {
+		return nil, err
+	}
 
 	entries, err := s.validateActions(policyID, request.Actions)
 	if err != nil {
@@ -793,6 +959,10 @@ var actionEntryValidators = map[extpb.ActionType]actionEntryValidator{
 	extpb.ActionType_ACTION_TYPE_DENY:        validateDenyEntry,
 	extpb.ActionType_ACTION_TYPE_FAIL:        validateFailEntry,
 	extpb.ActionType_ACTION_TYPE_ADD_HEADERS: validateAddHeadersEntry,
+
+	extpb.ActionType_ACTION_TYPE_REPLACE_BODY:    validateReplaceBodyEntry,
+	extpb.ActionType_ACTION_TYPE_REMOVE_HEADERS:  validateRemoveHeadersEntry,
+	extpb.ActionType_ACTION_TYPE_REPLACE_HEADERS: validateReplaceHeadersEntry,
 }
 
 func validateGRPCMethodEntry(action *extpb.ActionEntry, index int, entry *PipelineActionEntry, vctx *actionValidationCtx) error {
@@ -844,6 +1014,56 @@ func validateAddHeadersEntry(action *extpb.ActionEntry, index int, entry *Pipeli
 	return nil
 }
 
+func validateReplaceBodyEntry(action *extpb.ActionEntry, index int, entry *PipelineActionEntry, _ *actionValidationCtx) error {
+	if action.Body == "" {
+		return fmt.Errorf("actions[%d]: body must be specified for replace_body actions", index)
+	}
+	if err := validateCELExpression(action.Body); err != nil {
+		return fmt.Errorf("actions[%d].body: %w", index, err)
+	}
+	if err := validateShimFeature(wasm.ShimFeatureBodyAction, index); err != nil {
+		return err
+	}
+	entry.Body = action.Body
+	return nil
+}
+
+func validateRemoveHeadersEntry(action *extpb.ActionEntry, index int, entry *PipelineActionEntry, _ *actionValidationCtx) error {
+	if action.HeadersToRemove == "" {
+		return fmt.Errorf("actions[%d]: headers_to_remove must be specified for remove_headers actions", index)
+	}
+	if err := validateCELExpression(action.HeadersToRemove); err != nil {
+		return fmt.Errorf("actions[%d].headers_to_remove: %w", index, err)
+	}
+	if err := validateShimFeature(wasm.ShimFeatureRemoveHeadersAction, index); err != nil {
+		return err
+	}
+	entry.HeadersToRemove = action.HeadersToRemove
+	return nil
+}
+
+func validateReplaceHeadersEntry(action *extpb.ActionEntry, index int, entry *PipelineActionEntry, _ *actionValidationCtx) error {
+	if action.HeadersToReplace == "" {
+		return fmt.Errorf("actions[%d]: headers_to_replace must be specified for replace_headers actions", index)
+	}
+	if err := validateCELExpression(action.HeadersToReplace); err != nil {
+		return fmt.Errorf("actions[%d].headers_to_replace: %w", index, err)
+	}
+	if err := validateShimFeature(wasm.ShimFeatureReplaceHeadersAction, index); err != nil {
+		return err
+	}
+	entry.HeadersToReplace = action.HeadersToReplace
+	return nil
+}
+
+// validateShimFeature rejects the actions whose typed action the wasm-shim is not declared to implement
+func validateShimFeature(feature wasm.ShimFeature, index int) error {
+	if !wasm.ShimSupports(feature) {
+		return fmt.Errorf("actions[%d]: %s typed actions are not declared as implemented by the wasm-shim in WASM_SHIM_FEATURES", index, feature)
+	}
+	return nil
+}
+
 func (s *extensionService) validateActions(policyID ResourceID, actions []*extpb.ActionEntry) ([]PipelineActionEntry, error) {
 	entries := make([]PipelineActionEntry, 0, len(actions))
 	vctx := actionValidationCtx{
@@ -952,6 +1172,15 @@ func collectCELExpressions(action *extpb.ActionEntry) []string {
 	if action.HeadersToAdd != "" {
 		exprs = append(exprs, action.HeadersToAdd)
 	}
+	if action.Body != "" {
+		exprs = append(exprs, action.Body)
+	}
+	if action.HeadersToRemove != "" {
+		exprs = append(exprs, action.HeadersToRemove)
+	}
+	if action.HeadersToReplace != "" {
+		exprs = append(exprs, action.HeadersToReplace)
+	}
 	return exprs
 }
 
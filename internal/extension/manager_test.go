@@ -52,6 +52,27 @@ func newTestExtensionService() *extensionService {
 	}
 }
 
+// testManifest declares the capabilities used by the calls of the tests
+var testManifest = &Manifest{
+	PolicyKinds:   []string{"DemoPolicy"},
+	Domains:       []string{"DOMAIN_AUTH", "DOMAIN_REQUEST"},
+	UpstreamHosts: []string{"svc", "my-service", "auth.kuadrant-system.svc.cluster.local"},
+	ActionTypes: []string{
+		"ACTION_TYPE_GRPC_METHOD",
+		"ACTION_TYPE_DENY",
+		"ACTION_TYPE_FAIL",
+		"ACTION_TYPE_ADD_HEADERS",
+		"ACTION_TYPE_REPLACE_BODY",
+		"ACTION_TYPE_REMOVE_HEADERS",
+		"ACTION_TYPE_REPLACE_HEADERS",
+	},
+}
+
+// testContext returns the context of a call of an extension with the test manifest
+func testContext() context.Context {
+	return withManifest(context.Background(), testManifest)
+}
+
 func testPolicy(kind, namespace, name string, targetRefs ...*extpb.TargetRef) *extpb.Policy {
 	return &extpb.Policy{
 		Metadata: &extpb.Metadata{
@@ -85,7 +106,7 @@ func validRequest() *extpb.RegisterActionMethodRequest {
 
 func TestRegisterActionMethod_NilRequest(t *testing.T) {
 	svc := newTestExtensionService()
-	_, err := svc.RegisterActionMethod(context.Background(), nil)
+	_, err := svc.RegisterActionMethod(testContext(), nil)
 	if err == nil {
 		t.Fatal("Expected error for nil request")
 	}
@@ -93,7 +114,7 @@ func TestRegisterActionMethod_NilRequest(t *testing.T) {
 
 func TestRegisterActionMethod_NilPolicy(t *testing.T) {
 	svc := newTestExtensionService()
-	_, err := svc.RegisterActionMethod(context.Background(), &extpb.RegisterActionMethodRequest{})
+	_, err := svc.RegisterActionMethod(testContext(), &extpb.RegisterActionMethodRequest{})
 	if err == nil {
 		t.Fatal("Expected error for nil policy")
 	}
@@ -101,7 +122,7 @@ func TestRegisterActionMethod_NilPolicy(t *testing.T) {
 
 func TestRegisterActionMethod_NilMetadata(t *testing.T) {
 	svc := newTestExtensionService()
-	_, err := svc.RegisterActionMethod(context.Background(), &extpb.RegisterActionMethodRequest{
+	_, err := svc.RegisterActionMethod(testContext(), &extpb.RegisterActionMethodRequest{
 		Policy: &extpb.Policy{},
 	})
 	if err == nil {
@@ -111,7 +132,7 @@ func TestRegisterActionMethod_NilMetadata(t *testing.T) {
 
 func TestRegisterActionMethod_MissingPolicyFields(t *testing.T) {
 	svc := newTestExtensionService()
-	_, err := svc.RegisterActionMethod(context.Background(), &extpb.RegisterActionMethodRequest{
+	_, err := svc.RegisterActionMethod(testContext(), &extpb.RegisterActionMethodRequest{
 		Policy: testPolicy("", "ns", "name"),
 		Url:    "grpc://svc:8081",
 	})
@@ -122,7 +143,7 @@ func TestRegisterActionMethod_MissingPolicyFields(t *testing.T) {
 
 func TestRegisterActionMethod_MissingURL(t *testing.T) {
 	svc := newTestExtensionService()
-	_, err := svc.RegisterActionMethod(context.Background(), &extpb.RegisterActionMethodRequest{
+	_, err := svc.RegisterActionMethod(testContext(), &extpb.RegisterActionMethodRequest{
 		Policy: testPolicy("DemoPolicy", "default", "demo",
 			testTargetRef("gateway.networking.k8s.io", "HTTPRoute", "my-route", "default")),
 		Name: "assess-threat",
@@ -137,7 +158,7 @@ func TestRegisterActionMethod_InvalidScheme(t *testing.T) {
 	req := validRequest()
 	req.Url = "http://svc:8081"
 
-	_, err := svc.RegisterActionMethod(context.Background(), req)
+	_, err := svc.RegisterActionMethod(testContext(), req)
 	if err == nil {
 		t.Fatal("Expected error for non-grpc scheme")
 	}
@@ -151,7 +172,7 @@ func TestRegisterActionMethod_NoTargetRefs(t *testing.T) {
 	req := validRequest()
 	req.Policy.TargetRefs = nil
 
-	_, err := svc.RegisterActionMethod(context.Background(), req)
+	_, err := svc.RegisterActionMethod(testContext(), req)
 	if err == nil {
 		t.Fatal("Expected error for no target refs")
 	}
@@ -165,7 +186,7 @@ func TestRegisterActionMethod_NilTargetRefElement(t *testing.T) {
 	req := validRequest()
 	req.Policy.TargetRefs = []*extpb.TargetRef{nil}
 
-	_, err := svc.RegisterActionMethod(context.Background(), req)
+	_, err := svc.RegisterActionMethod(testContext(), req)
 	if err == nil {
 		t.Fatal("Expected error for nil target ref element")
 	}
@@ -179,7 +200,7 @@ func TestRegisterActionMethod_MissingService(t *testing.T) {
 	req := validRequest()
 	req.Service = ""
 
-	_, err := svc.RegisterActionMethod(context.Background(), req)
+	_, err := svc.RegisterActionMethod(testContext(), req)
 	if err == nil {
 		t.Fatal("Expected error for missing service")
 	}
@@ -193,7 +214,7 @@ func TestRegisterActionMethod_MissingMethod(t *testing.T) {
 	req := validRequest()
 	req.Method = ""
 
-	_, err := svc.RegisterActionMethod(context.Background(), req)
+	_, err := svc.RegisterActionMethod(testContext(), req)
 	if err == nil {
 		t.Fatal("Expected error for missing method")
 	}
@@ -205,7 +226,7 @@ func TestRegisterActionMethod_MissingMethod(t *testing.T) {
 func TestRegisterActionMethod_Success(t *testing.T) {
 	svc := newTestExtensionService()
 
-	_, err := svc.RegisterActionMethod(context.Background(), validRequest())
+	_, err := svc.RegisterActionMethod(testContext(), validRequest())
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -265,7 +286,7 @@ func TestRegisterActionMethod_ClusterNameGeneration(t *testing.T) {
 				Method:  "ExampleMethod",
 			}
 
-			_, err := svc.RegisterActionMethod(context.Background(), req)
+			_, err := svc.RegisterActionMethod(testContext(), req)
 			if err != nil {
 				t.Fatalf("Expected no error, got %v", err)
 			}
@@ -297,7 +318,7 @@ func TestRegisterActionMethod_ChangeNotifier(t *testing.T) {
 		return nil
 	}
 
-	_, err := svc.RegisterActionMethod(context.Background(), validRequest())
+	_, err := svc.RegisterActionMethod(testContext(), validRequest())
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -319,7 +340,7 @@ func TestRegisterActionMethod_InvalidMethod(t *testing.T) {
 		Method:  "NonExistentMethod",
 	}
 
-	_, err := svc.RegisterActionMethod(context.Background(), req)
+	_, err := svc.RegisterActionMethod(testContext(), req)
 	if err == nil {
 		t.Fatal("Expected error for non-existent method")
 	}
@@ -354,12 +375,12 @@ func TestClearPolicy_ProtoCacheCleanup(t *testing.T) {
 		Method:  "AnotherMethod",
 	}
 
-	_, err := svc.RegisterActionMethod(context.Background(), policy1Req)
+	_, err := svc.RegisterActionMethod(testContext(), policy1Req)
 	if err != nil {
 		t.Fatalf("Failed to register policy1: %v", err)
 	}
 
-	_, err = svc.RegisterActionMethod(context.Background(), policy2Req)
+	_, err = svc.RegisterActionMethod(testContext(), policy2Req)
 	if err != nil {
 		t.Fatalf("Failed to register policy2: %v", err)
 	}
@@ -376,7 +397,7 @@ func TestClearPolicy_ProtoCacheCleanup(t *testing.T) {
 	}
 
 	// Clear policy1
-	_, err = svc.ClearPolicy(context.Background(), &extpb.ClearPolicyRequest{
+	_, err = svc.ClearPolicy(testContext(), &extpb.ClearPolicyRequest{
 		Policy: policy1Req.Policy,
 	})
 	if err != nil {
@@ -390,7 +411,7 @@ func TestClearPolicy_ProtoCacheCleanup(t *testing.T) {
 	}
 
 	// Clear policy2
-	_, err = svc.ClearPolicy(context.Background(), &extpb.ClearPolicyRequest{
+	_, err = svc.ClearPolicy(testContext(), &extpb.ClearPolicyRequest{
 		Policy: policy2Req.Policy,
 	})
 	if err != nil {
@@ -436,7 +457,7 @@ func TestGetServiceDescriptors_Success(t *testing.T) {
 		},
 	}
 
-	resp, err := svc.GetServiceDescriptors(context.Background(), req)
+	resp, err := svc.GetServiceDescriptors(testContext(), req)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -474,7 +495,7 @@ func TestGetServiceDescriptors_NotFound(t *testing.T) {
 		},
 	}
 
-	_, err := svc.GetServiceDescriptors(context.Background(), req)
+	_, err := svc.GetServiceDescriptors(testContext(), req)
 	if err == nil {
 		t.Fatal("Expected error for missing descriptor")
 	}
@@ -483,7 +504,7 @@ func TestGetServiceDescriptors_NotFound(t *testing.T) {
 func TestGetServiceDescriptors_NilRequest(t *testing.T) {
 	svc := newTestExtensionService()
 
-	_, err := svc.GetServiceDescriptors(context.Background(), nil)
+	_, err := svc.GetServiceDescriptors(testContext(), nil)
 	if err == nil {
 		t.Fatal("Expected error for nil request")
 	}
@@ -498,7 +519,7 @@ func TestGetServiceDescriptors_MissingClusterName(t *testing.T) {
 		},
 	}
 
-	_, err := svc.GetServiceDescriptors(context.Background(), req)
+	_, err := svc.GetServiceDescriptors(testContext(), req)
 	if err == nil {
 		t.Fatal("Expected error for missing cluster_name")
 	}
@@ -513,7 +534,7 @@ func TestGetServiceDescriptors_MissingService(t *testing.T) {
 		},
 	}
 
-	_, err := svc.GetServiceDescriptors(context.Background(), req)
+	_, err := svc.GetServiceDescriptors(testContext(), req)
 	if err == nil {
 		t.Fatal("Expected error for missing service")
 	}
@@ -540,12 +561,12 @@ func TestRegisterActionMethod_MultipleMethodsSamePolicy(t *testing.T) {
 		Method:  "AnotherMethod",
 	}
 
-	_, err := svc.RegisterActionMethod(context.Background(), method1Req)
+	_, err := svc.RegisterActionMethod(testContext(), method1Req)
 	if err != nil {
 		t.Fatalf("Failed to register first method: %v", err)
 	}
 
-	_, err = svc.RegisterActionMethod(context.Background(), method2Req)
+	_, err = svc.RegisterActionMethod(testContext(), method2Req)
 	if err != nil {
 		t.Fatalf("Failed to register second method: %v", err)
 	}
@@ -603,7 +624,7 @@ func TestRegisterActionMethod_ReregistrationIdempotent(t *testing.T) {
 	req := validRequest()
 
 	// First registration
-	_, err := svc.RegisterActionMethod(context.Background(), req)
+	_, err := svc.RegisterActionMethod(testContext(), req)
 	if err != nil {
 		t.Fatalf("First registration failed: %v", err)
 	}
@@ -613,7 +634,7 @@ func TestRegisterActionMethod_ReregistrationIdempotent(t *testing.T) {
 	}
 
 	// Re-register the same method
-	_, err = svc.RegisterActionMethod(context.Background(), req)
+	_, err = svc.RegisterActionMethod(testContext(), req)
 	if err != nil {
 		t.Fatalf("Re-registration failed: %v", err)
 	}
@@ -662,7 +683,7 @@ func TestRegisterActionMethod_PartialFailure(t *testing.T) {
 		Method:  "ExampleMethod",
 	}
 
-	_, err := svc.RegisterActionMethod(context.Background(), validReq)
+	_, err := svc.RegisterActionMethod(testContext(), validReq)
 	if err != nil {
 		t.Fatalf("First registration should succeed, got error: %v", err)
 	}
@@ -681,7 +702,7 @@ func TestRegisterActionMethod_PartialFailure(t *testing.T) {
 		Method:  "NonExistentMethod",
 	}
 
-	_, err = svc.RegisterActionMethod(context.Background(), invalidReq)
+	_, err = svc.RegisterActionMethod(testContext(), invalidReq)
 	if err == nil {
 		t.Fatal("Second registration should fail for non-existent method")
 	}
@@ -723,7 +744,7 @@ func TestRegisterActionMethod_MissingName(t *testing.T) {
 	req := validRequest()
 	req.Name = ""
 
-	_, err := svc.RegisterActionMethod(context.Background(), req)
+	_, err := svc.RegisterActionMethod(testContext(), req)
 	if err == nil {
 		t.Fatal("Expected error for missing name")
 	}
@@ -737,7 +758,7 @@ func TestRegisterActionMethod_WhitespaceOnlyName(t *testing.T) {
 	req := validRequest()
 	req.Name = "   "
 
-	_, err := svc.RegisterActionMethod(context.Background(), req)
+	_, err := svc.RegisterActionMethod(testContext(), req)
 	if err == nil {
 		t.Fatal("Expected error for whitespace-only name")
 	}
@@ -759,7 +780,7 @@ func TestRegisterActionMethod_DuplicateNameSamePolicy(t *testing.T) {
 		Method:  "ExampleMethod",
 	}
 
-	_, err := svc.RegisterActionMethod(context.Background(), req1)
+	_, err := svc.RegisterActionMethod(testContext(), req1)
 	if err != nil {
 		t.Fatalf("First registration should succeed: %v", err)
 	}
@@ -774,7 +795,7 @@ func TestRegisterActionMethod_DuplicateNameSamePolicy(t *testing.T) {
 		Method:  "AnotherMethod",
 	}
 
-	_, err = svc.RegisterActionMethod(context.Background(), req2)
+	_, err = svc.RegisterActionMethod(testContext(), req2)
 	if err == nil {
 		t.Fatal("Expected error for duplicate name within same policy")
 	}
@@ -808,12 +829,12 @@ func TestRegisterActionMethod_SameNameDifferentPolicies(t *testing.T) {
 		Method:  "ExampleMethod",
 	}
 
-	_, err := svc.RegisterActionMethod(context.Background(), req1)
+	_, err := svc.RegisterActionMethod(testContext(), req1)
 	if err != nil {
 		t.Fatalf("First policy registration should succeed: %v", err)
 	}
 
-	_, err = svc.RegisterActionMethod(context.Background(), req2)
+	_, err = svc.RegisterActionMethod(testContext(), req2)
 	if err != nil {
 		t.Fatalf("Second policy with same name should succeed: %v", err)
 	}
@@ -825,7 +846,7 @@ func TestRegisterActionMethod_MessageTemplatePassthrough(t *testing.T) {
 	req := validRequest()
 	req.MessageTemplate = `ThreatRequest { uri: request.path, method: request.method }`
 
-	_, err := svc.RegisterActionMethod(context.Background(), req)
+	_, err := svc.RegisterActionMethod(testContext(), req)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -852,7 +873,7 @@ func TestRegisterActionMethod_EmptyMessageTemplate(t *testing.T) {
 	req := validRequest()
 	// MessageTemplate is optional, empty is allowed
 
-	_, err := svc.RegisterActionMethod(context.Background(), req)
+	_, err := svc.RegisterActionMethod(testContext(), req)
 	if err != nil {
 		t.Fatalf("Expected no error with empty MessageTemplate, got %v", err)
 	}
@@ -885,7 +906,7 @@ func registerTestActionMethod(t *testing.T, svc *extensionService, policyName, m
 		Service: "example.v1.ExampleService",
 		Method:  "ExampleMethod",
 	}
-	_, err := svc.RegisterActionMethod(context.Background(), req)
+	_, err := svc.RegisterActionMethod(testContext(), req)
 	if err != nil {
 		t.Fatalf("Failed to register action method %q: %v", methodName, err)
 	}
@@ -898,7 +919,7 @@ func testPipelinePolicy() *extpb.Policy {
 
 func TestPipelineCommit_NilRequest(t *testing.T) {
 	svc := newTestExtensionService()
-	_, err := svc.PipelineCommit(context.Background(), nil)
+	_, err := svc.PipelineCommit(testContext(), nil)
 	if err == nil {
 		t.Fatal("Expected error for nil request")
 	}
@@ -906,7 +927,7 @@ func TestPipelineCommit_NilRequest(t *testing.T) {
 
 func TestPipelineCommit_NilPolicy(t *testing.T) {
 	svc := newTestExtensionService()
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{})
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{})
 	if err == nil {
 		t.Fatal("Expected error for nil policy")
 	}
@@ -914,7 +935,7 @@ func TestPipelineCommit_NilPolicy(t *testing.T) {
 
 func TestPipelineCommit_EmptyBothPhases(t *testing.T) {
 	svc := newTestExtensionService()
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 	})
 	if err != nil {
@@ -934,7 +955,7 @@ func TestPipelineCommit_BothPhases(t *testing.T) {
 	svc := newTestExtensionService()
 	registerTestActionMethod(t, svc, "demo", "assess-threat")
 
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 		Actions: []*extpb.ActionEntry{
 			{ActionType: extpb.ActionType_ACTION_TYPE_GRPC_METHOD, Phase: "request", Method: "assess-threat", Predicate: "true", Var: "threatResponse"},
@@ -983,7 +1004,7 @@ func TestPipelineCommit_BothPhases(t *testing.T) {
 func TestPipelineCommit_InvalidPhase_RejectsAll(t *testing.T) {
 	svc := newTestExtensionService()
 
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 		Actions: []*extpb.ActionEntry{
 			{ActionType: extpb.ActionType_ACTION_TYPE_DENY, Phase: "request", WithStatus: 403},
@@ -1002,7 +1023,7 @@ func TestPipelineCommit_InvalidPhase_RejectsAll(t *testing.T) {
 
 func TestPipelineCommit_NilActionEntry(t *testing.T) {
 	svc := newTestExtensionService()
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 		Actions: []*extpb.ActionEntry{
 			nil,
@@ -1018,7 +1039,7 @@ func TestPipelineCommit_NilActionEntry(t *testing.T) {
 
 func TestPipelineCommit_InvalidActionType(t *testing.T) {
 	svc := newTestExtensionService()
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 		Actions: []*extpb.ActionEntry{
 			{ActionType: extpb.ActionType_ACTION_TYPE_UNSPECIFIED, Phase: "request"},
@@ -1034,7 +1055,7 @@ func TestPipelineCommit_InvalidActionType(t *testing.T) {
 
 func TestPipelineCommit_InvalidPredicate(t *testing.T) {
 	svc := newTestExtensionService()
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 		Actions: []*extpb.ActionEntry{
 			{ActionType: extpb.ActionType_ACTION_TYPE_DENY, Phase: "request", WithStatus: 403, Predicate: "!!!invalid cel"},
@@ -1050,7 +1071,7 @@ func TestPipelineCommit_InvalidPredicate(t *testing.T) {
 
 func TestPipelineCommit_GRPCMethod_UnregisteredMethod(t *testing.T) {
 	svc := newTestExtensionService()
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 		Actions: []*extpb.ActionEntry{
 			{ActionType: extpb.ActionType_ACTION_TYPE_GRPC_METHOD, Phase: "request", Method: "nonexistent"},
@@ -1066,7 +1087,7 @@ func TestPipelineCommit_GRPCMethod_UnregisteredMethod(t *testing.T) {
 
 func TestPipelineCommit_GRPCMethod_MissingMethod(t *testing.T) {
 	svc := newTestExtensionService()
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 		Actions: []*extpb.ActionEntry{
 			{ActionType: extpb.ActionType_ACTION_TYPE_GRPC_METHOD, Phase: "request"},
@@ -1083,7 +1104,7 @@ func TestPipelineCommit_GRPCMethod_MissingMethod(t *testing.T) {
 func TestPipelineCommit_GRPCMethod_InvalidVarName(t *testing.T) {
 	svc := newTestExtensionService()
 	registerTestActionMethod(t, svc, "demo", "assess-threat")
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 		Actions: []*extpb.ActionEntry{
 			{ActionType: extpb.ActionType_ACTION_TYPE_GRPC_METHOD, Phase: "request", Method: "assess-threat", Var: "invalid var!"},
@@ -1108,7 +1129,7 @@ func TestPipelineCommit_Deny_InvalidStatusCode(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+			_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 				Policy: testPipelinePolicy(),
 				Actions: []*extpb.ActionEntry{
 					{ActionType: extpb.ActionType_ACTION_TYPE_DENY, Phase: "request", WithStatus: tt.withStatus},
@@ -1123,7 +1144,7 @@ func TestPipelineCommit_Deny_InvalidStatusCode(t *testing.T) {
 
 func TestPipelineCommit_AddHeaders_MissingHeadersToAdd(t *testing.T) {
 	svc := newTestExtensionService()
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 		Actions: []*extpb.ActionEntry{
 			{ActionType: extpb.ActionType_ACTION_TYPE_ADD_HEADERS, Phase: "response"},
@@ -1139,7 +1160,7 @@ func TestPipelineCommit_AddHeaders_MissingHeadersToAdd(t *testing.T) {
 
 func TestPipelineCommit_AddHeaders_InvalidCEL(t *testing.T) {
 	svc := newTestExtensionService()
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 		Actions: []*extpb.ActionEntry{
 			{ActionType: extpb.ActionType_ACTION_TYPE_ADD_HEADERS, Phase: "response", HeadersToAdd: "!!!invalid cel"},
@@ -1153,6 +1174,133 @@ func TestPipelineCommit_AddHeaders_InvalidCEL(t *testing.T) {
 	}
 }
 
+func TestPipelineCommit_TransformationActions_MissingExpression(t *testing.T) {
+	tests := []struct {
+		name       string
+		actionType extpb.ActionType
+		wantErr    string
+	}{
+		{"replace_body", extpb.ActionType_ACTION_TYPE_REPLACE_BODY, "body must be specified"},
+		{"remove_headers", extpb.ActionType_ACTION_TYPE_REMOVE_HEADERS, "headers_to_remove must be specified"},
+		{"replace_headers", extpb.ActionType_ACTION_TYPE_REPLACE_HEADERS, "headers_to_replace must be specified"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestExtensionService()
+			_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
+				Policy: testPipelinePolicy(),
+				Actions: []*extpb.ActionEntry{
+					{ActionType: tt.actionType, Phase: "response"},
+				},
+			})
+			if err == nil {
+				t.Fatalf("Expected error for missing expression of %s", tt.name)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected %q error, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPipelineCommit_TransformationActions_InvalidCEL(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  *extpb.ActionEntry
+		wantErr string
+	}{
+		{"replace_body", &extpb.ActionEntry{ActionType: extpb.ActionType_ACTION_TYPE_REPLACE_BODY, Phase: "response", Body: "!!!invalid cel"}, "body"},
+		{"remove_headers", &extpb.ActionEntry{ActionType: extpb.ActionType_ACTION_TYPE_REMOVE_HEADERS, Phase: "response", HeadersToRemove: "!!!invalid cel"}, "headers_to_remove"},
+		{"replace_headers", &extpb.ActionEntry{ActionType: extpb.ActionType_ACTION_TYPE_REPLACE_HEADERS, Phase: "response", HeadersToReplace: "!!!invalid cel"}, "headers_to_replace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestExtensionService()
+			_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
+				Policy:  testPipelinePolicy(),
+				Actions: []*extpb.ActionEntry{tt.action},
+			})
+			if err == nil {
+				t.Fatalf("Expected error for invalid CEL in %s", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected %s error, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPipelineCommit_TransformationActions_UnsupportedShimFeature(t *testing.T) {
+	t.Setenv("WASM_SHIM_FEATURES", "body")
+
+	tests := []struct {
+		name    string
+		action  *extpb.ActionEntry
+		wantErr string
+	}{
+		{"remove_headers", &extpb.ActionEntry{ActionType: extpb.ActionType_ACTION_TYPE_REMOVE_HEADERS, Phase: "request", HeadersToRemove: `["x-internal"]`}, "removeHeaders typed actions are not declared"},
+		{"replace_headers", &extpb.ActionEntry{ActionType: extpb.ActionType_ACTION_TYPE_REPLACE_HEADERS, Phase: "response", HeadersToReplace: `{"cache-control": "no-store"}`}, "replaceHeaders typed actions are not declared"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestExtensionService()
+			_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
+				Policy: testPipelinePolicy(),
+				Actions: []*extpb.ActionEntry{
+					{ActionType: extpb.ActionType_ACTION_TYPE_REPLACE_BODY, Phase: "response", Body: `"redacted"`},
+					tt.action,
+				},
+			})
+			if err == nil {
+				t.Fatalf("Expected error for %s without the wasm-shim feature", tt.name)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected %q error, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPipelineCommit_TransformationActions(t *testing.T) {
+	t.Setenv("WASM_SHIM_FEATURES", "body,removeHeaders,replaceHeaders")
+
+	svc := newTestExtensionService()
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
+		Policy: testPipelinePolicy(),
+		Actions: []*extpb.ActionEntry{
+			{ActionType: extpb.ActionType_ACTION_TYPE_REMOVE_HEADERS, Phase: "request", HeadersToRemove: `["x-internal"]`},
+			{ActionType: extpb.ActionType_ACTION_TYPE_REPLACE_HEADERS, Phase: "response", HeadersToReplace: `{"cache-control": "no-store"}`},
+			{ActionType: extpb.ActionType_ACTION_TYPE_REPLACE_BODY, Phase: "response", Body: `"redacted"`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	policyID := ResourceID{Kind: "DemoPolicy", Namespace: "default", Name: "demo"}
+	reqActions := svc.registeredData.GetPipelineActions(policyID, PipelinePhaseRequest)
+	if len(reqActions) != 1 {
+		t.Fatalf("Expected 1 request action, got %d", len(reqActions))
+	}
+	if reqActions[0].HeadersToRemove != `["x-internal"]` {
+		t.Errorf("Expected headers_to_remove, got %q", reqActions[0].HeadersToRemove)
+	}
+
+	respActions := svc.registeredData.GetPipelineActions(policyID, PipelinePhaseResponse)
+	if len(respActions) != 2 {
+		t.Fatalf("Expected 2 response actions, got %d", len(respActions))
+	}
+	if respActions[0].HeadersToReplace != `{"cache-control": "no-store"}` {
+		t.Errorf("Expected headers_to_replace, got %q", respActions[0].HeadersToReplace)
+	}
+	if respActions[1].Body != `"redacted"` {
+		t.Errorf("Expected body, got %q", respActions[1].Body)
+	}
+}
+
 func testFDSWithMessages() *descriptorpb.FileDescriptorSet {
 	return &descriptorpb.FileDescriptorSet{
 		File: []*descriptorpb.FileDescriptorProto{
@@ -1208,7 +1356,7 @@ func registerTestActionMethodWithFDS(t *testing.T, svc *extensionService, policy
 		Service: "example.v1.ExampleService",
 		Method:  "ExampleMethod",
 	}
-	_, err := svc.RegisterActionMethod(context.Background(), req)
+	_, err := svc.RegisterActionMethod(testContext(), req)
 	if err != nil {
 		t.Fatalf("Failed to register action method %q: %v", methodName, err)
 	}
@@ -1218,7 +1366,7 @@ func TestPipelineCommit_CrossAction_ValidVarFieldAccess(t *testing.T) {
 	svc := newTestExtensionService()
 	registerTestActionMethodWithFDS(t, svc, "demo", "assess-threat")
 
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 		Actions: []*extpb.ActionEntry{
 			{ActionType: extpb.ActionType_ACTION_TYPE_GRPC_METHOD, Phase: "request", Method: "assess-threat", Var: "threatResponse"},
@@ -1234,7 +1382,7 @@ func TestPipelineCommit_CrossAction_InvalidVarFieldAccess(t *testing.T) {
 	svc := newTestExtensionService()
 	registerTestActionMethodWithFDS(t, svc, "demo", "assess-threat")
 
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 		Actions: []*extpb.ActionEntry{
 			{ActionType: extpb.ActionType_ACTION_TYPE_GRPC_METHOD, Phase: "request", Method: "assess-threat", Var: "threatResponse"},
@@ -1254,7 +1402,7 @@ func TestPipelineCommit_AtomicReplacement(t *testing.T) {
 	registerTestActionMethod(t, svc, "demo", "assess-threat")
 
 	// First commit
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 		Actions: []*extpb.ActionEntry{
 			{ActionType: extpb.ActionType_ACTION_TYPE_DENY, Phase: "request", WithStatus: 403},
@@ -1265,7 +1413,7 @@ func TestPipelineCommit_AtomicReplacement(t *testing.T) {
 	}
 
 	// Second commit replaces, not appends
-	_, err = svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err = svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 		Actions: []*extpb.ActionEntry{
 			{ActionType: extpb.ActionType_ACTION_TYPE_DENY, Phase: "request", WithStatus: 401},
@@ -1294,7 +1442,7 @@ func TestPipelineCommit_ChangeNotifier(t *testing.T) {
 		return nil
 	}
 
-	_, err := svc.PipelineCommit(context.Background(), &extpb.PipelineCommitRequest{
+	_, err := svc.PipelineCommit(testContext(), &extpb.PipelineCommitRequest{
 		Policy: testPipelinePolicy(),
 		Actions: []*extpb.ActionEntry{
 			{ActionType: extpb.ActionType_ACTION_TYPE_DENY, Phase: "request", WithStatus: 403},
@@ -0,0 +1,233 @@
+/*
+Copyright 2025 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package extension
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"sigs.k8s.io/yaml"
+
+	extpb "github.com/kuadrant/kuadrant-operator/pkg/extension/grpc/v1"
+)
+
+// ManifestFileName is the name of the manifest file in the directory of an extension
+const ManifestFileName = "manifest.yaml"
+
+// Manifest declares the capabilities of an extension. The operator rejects the calls of the extension outside of them,
+// and does not start extensions without a manifest.
+type Manifest struct {
+	// PolicyKinds are the kinds of the policies the extension manages, e.g. PlanPolicy
+	PolicyKinds []string `json:"policyKinds"`
+	// Domains are the domains the extension can register mutators for, e.g. DOMAIN_AUTH
+	Domains []string `json:"domains,omitempty"`
+	// UpstreamHosts are the hosts of the action methods the extension can register. A leading `*.` matches any
+	// subdomain.
+	UpstreamHosts []string `json:"upstreamHosts,omitempty"`
+	// ActionTypes are the types of the pipeline actions the extension can commit, e.g. ACTION_TYPE_DENY.
+	// ACTION_TYPE_GRPC_METHOD is also required to register action methods.
+	ActionTypes []string `json:"actionTypes,omitempty"`
+}
+
+// ErrMissingManifest is returned when loading the manifest of an extension that has none
+var ErrMissingManifest = fmt.Errorf("missing %s", ManifestFileName)
+
+// LoadManifest reads the manifest of the extension in the given directory
+func LoadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrMissingManifest
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	if err := yaml.UnmarshalStrict(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ManifestFileName, err)
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ManifestFileName, err)
+	}
+	return manifest, nil
+}
+
+func (m *Manifest) Validate() error {
+	if len(m.PolicyKinds) == 0 {
+		return errors.New("policyKinds must not be empty")
+	}
+	for _, domain := range m.Domains {
+		if value, ok := extpb.Domain_value[domain]; !ok || value == int32(extpb.Domain_DOMAIN_UNSPECIFIED) {
+			return fmt.Errorf("unknown domain %q", domain)
+		}
+	}
+	for _, actionType := range m.ActionTypes {
+		if value, ok := extpb.ActionType_value[actionType]; !ok || value == int32(extpb.ActionType_ACTION_TYPE_UNSPECIFIED) {
+			return fmt.Errorf("unknown action type %q", actionType)
+		}
+	}
+	for _, host := range m.UpstreamHosts {
+		if host == "" || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+			return fmt.Errorf("invalid upstream host %q", host)
+		}
+	}
+	return nil
+}
+
+func (m *Manifest) allowsPolicyKind(kind string) bool {
+	return slices.Contains(m.PolicyKinds, kind)
+}
+
+func (m *Manifest) allowsDomain(domain extpb.Domain) bool {
+	return slices.Contains(m.Domains, domain.String())
+}
+
+func (m *Manifest) allowsActionType(actionType extpb.ActionType) bool {
+	return slices.Contains(m.ActionTypes, actionType.String())
+}
+
+func (m *Manifest) allowsUpstreamHost(host string) bool {
+	host = strings.ToLower(host)
+	return slices.ContainsFunc(m.UpstreamHosts, func(allowed string) bool {
+		if suffix, found := strings.CutPrefix(allowed, "*"); found {
+			return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+		}
+		return host == allowed
+	})
+}
+
+type manifestContextKey struct{}
+
+// withManifest returns a context carrying the manifest of the extension the call comes from
+func withManifest(ctx context.Context, manifest *Manifest) context.Context {
+	if manifest == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, manifestContextKey{}, manifest)
+}
+
+// manifestFromContext returns the manifest of the extension the call comes from. The calls of extensions without a
+// manifest are rejected.
+func manifestFromContext(ctx context.Context) (*Manifest, error) {
+	manifest, _ := ctx.Value(manifestContextKey{}).(*Manifest)
+	if manifest == nil {
+		return nil, grpcstatus.Error(codes.PermissionDenied, "extension has no manifest")
+	}
+	return manifest, nil
+}
+
+func permissionDenied(format string, args ...any) error {
+	return grpcstatus.Errorf(codes.PermissionDenied, "extension manifest does not allow "+format, args...)
+}
+
+// checkPolicyKind rejects calls for policies of kinds the extension does not declare in its manifest
+func checkPolicyKind(ctx context.Context, kind string) error {
+	manifest, err := manifestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !manifest.allowsPolicyKind(kind) {
+		return permissionDenied("policy kind %q", kind)
+	}
+	return nil
+}
+
+// checkDomain rejects mutators for domains the extension does not declare in its manifest
+func checkDomain(ctx context.Context, domain extpb.Domain) error {
+	manifest, err := manifestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !manifest.allowsDomain(domain) {
+		return permissionDenied("domain %s", domain)
+	}
+	return nil
+}
+
+// celPolicyTypeName is the type of self in the expressions of the extensions
+const celPolicyTypeName = "kuadrant.v1.Policy"
+
+// celOverloadDomains are the domains of the policies read by the functions of the Kuadrant CEL library, by overload
+var celOverloadDomains = map[string]extpb.Domain{
+	"authpolicies_for_policy": extpb.Domain_DOMAIN_AUTH,
+}
+
+// checkCELExpression restricts the expressions of an extension to the kinds and domains it declares in its manifest:
+// policies can only be queried from self, whose kind is checked, and not from policies built in the expression, and
+// functions reading the policies of a domain, e.g. self.findAuthPolicies() for DOMAIN_AUTH, require the domain
+func checkCELExpression(ctx context.Context, checked *cel.Ast) error {
+	manifest, err := manifestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	var policyLiteral bool
+	celast.PreOrderVisit(checked.NativeRep().Expr(), celast.NewExprVisitor(func(e celast.Expr) {
+		if e.Kind() == celast.StructKind && e.AsStruct().TypeName() == celPolicyTypeName {
+			policyLiteral = true
+		}
+	}))
+	if policyLiteral {
+		return permissionDenied("%s literals in expressions", celPolicyTypeName)
+	}
+	for _, reference := range checked.NativeRep().ReferenceMap() {
+		for _, overload := range reference.OverloadIDs {
+			if domain, ok := celOverloadDomains[overload]; ok && !manifest.allowsDomain(domain) {
+				return permissionDenied("domain %s in expressions", domain)
+			}
+		}
+	}
+	return nil
+}
+
+// checkActionTypes rejects pipeline actions of types the extension does not declare in its manifest
+func checkActionTypes(ctx context.Context, actions []*extpb.ActionEntry) error {
+	manifest, err := manifestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	for _, action := range actions {
+		// unspecified action types are rejected by the validation of the actions
+		if action != nil && action.ActionType != extpb.ActionType_ACTION_TYPE_UNSPECIFIED && !manifest.allowsActionType(action.ActionType) {
+			return permissionDenied("action type %s", action.ActionType)
+		}
+	}
+	return nil
+}
+
+// checkUpstreamHost rejects action methods on hosts the extension does not declare in its manifest
+func checkUpstreamHost(ctx context.Context, host string) error {
+	manifest, err := manifestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !manifest.allowsActionType(extpb.ActionType_ACTION_TYPE_GRPC_METHOD) {
+		return permissionDenied("action type %s", extpb.ActionType_ACTION_TYPE_GRPC_METHOD)
+	}
+	if !manifest.allowsUpstreamHost(host) {
+		return permissionDenied("upstream host %q", host)
+	}
+	return nil
+}
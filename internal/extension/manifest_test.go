@@ -0,0 +1,260 @@
+//go:build unit
+
+package extension
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kuadrant/policy-machinery/machinery"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+
+	extpb "github.com/kuadrant/kuadrant-operator/pkg/extension/grpc/v1"
+)
+
+func TestLoadManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  string
+		want     *Manifest
+	}{
+		{
+			name:    "no manifest",
+			wantErr: "missing manifest.yaml",
+		},
+		{
+			name: "valid manifest",
+			manifest: `policyKinds: [DemoPolicy]
+domains: [DOMAIN_AUTH]
+upstreamHosts: ["svc", "*.svc.cluster.local"]
+actionTypes: [ACTION_TYPE_GRPC_METHOD, ACTION_TYPE_DENY]
+`,
+			want: &Manifest{
+				PolicyKinds:   []string{"DemoPolicy"},
+				Domains:       []string{"DOMAIN_AUTH"},
+				UpstreamHosts: []string{"svc", "*.svc.cluster.local"},
+				ActionTypes:   []string{"ACTION_TYPE_GRPC_METHOD", "ACTION_TYPE_DENY"},
+			},
+		},
+		{
+			name:     "missing policy kinds",
+			manifest: `domains: [DOMAIN_AUTH]`,
+			wantErr:  "policyKinds must not be empty",
+		},
+		{
+			name:     "unknown domain",
+			manifest: "policyKinds: [DemoPolicy]\ndomains: [DOMAIN_UNSPECIFIED]",
+			wantErr:  `unknown domain "DOMAIN_UNSPECIFIED"`,
+		},
+		{
+			name:     "unknown action type",
+			manifest: "policyKinds: [DemoPolicy]\nactionTypes: [ACTION_TYPE_REDIRECT]",
+			wantErr:  `unknown action type "ACTION_TYPE_REDIRECT"`,
+		},
+		{
+			name:     "invalid upstream host",
+			manifest: "policyKinds: [DemoPolicy]\nupstreamHosts: [\"svc.*\"]",
+			wantErr:  `invalid upstream host "svc.*"`,
+		},
+		{
+			name:     "unknown field",
+			manifest: "policyKinds: [DemoPolicy]\nkinds: [DemoPolicy]",
+			wantErr:  `unknown field "kinds"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.manifest != "" {
+				if err := os.WriteFile(filepath.Join(dir, ManifestFileName), []byte(tt.manifest), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			manifest, err := LoadManifest(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(manifest, tt.want) {
+				t.Errorf("Expected manifest %v, got %v", tt.want, manifest)
+			}
+		})
+	}
+}
+
+func TestManifestAllowsUpstreamHost(t *testing.T) {
+	manifest := &Manifest{UpstreamHosts: []string{"svc", "*.svc.cluster.local"}}
+
+	for host, want := range map[string]bool{
+		"svc":                       true,
+		"SVC":                       true,
+		"other":                     false,
+		"my.svc.cluster.local":      true,
+		"a.b.svc.cluster.local":     true,
+		".svc.cluster.local":        false,
+		"svc.cluster.local":         false,
+		"evilsvc.cluster.local":     false,
+		"my.svc.cluster.local.evil": false,
+	} {
+		if got := manifest.allowsUpstreamHost(host); got != want {
+			t.Errorf("allowsUpstreamHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func assertPermissionDenied(t *testing.T, err error, message string) {
+	t.Helper()
+	if grpcstatus.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Expected PermissionDenied, got %v", err)
+	}
+	if !strings.Contains(err.Error(), message) {
+		t.Errorf("Expected error containing %q, got %v", message, err)
+	}
+}
+
+func TestManifestEnforcement(t *testing.T) {
+	manifest := &Manifest{
+		PolicyKinds:   []string{"DemoPolicy"},
+		Domains:       []string{"DOMAIN_REQUEST"},
+		UpstreamHosts: []string{"svc"},
+		ActionTypes:   []string{"ACTION_TYPE_GRPC_METHOD", "ACTION_TYPE_DENY"},
+	}
+	ctx := withManifest(context.Background(), manifest)
+	targetRef := testTargetRef("gateway.networking.k8s.io", "HTTPRoute", "my-route", "default")
+
+	t.Run("RegisterMutator", func(t *testing.T) {
+		svc := newTestExtensionService()
+
+		_, err := svc.RegisterMutator(ctx, &extpb.RegisterMutatorRequest{
+			Policy: testPolicy("OtherPolicy", "default", "demo", targetRef),
+			Domain: extpb.Domain_DOMAIN_REQUEST,
+		})
+		assertPermissionDenied(t, err, `policy kind "OtherPolicy"`)
+
+		_, err = svc.RegisterMutator(ctx, &extpb.RegisterMutatorRequest{
+			Policy: testPolicy("DemoPolicy", "default", "demo", targetRef),
+			Domain: extpb.Domain_DOMAIN_AUTH,
+		})
+		assertPermissionDenied(t, err, "domain DOMAIN_AUTH")
+
+		if _, err := svc.RegisterMutator(ctx, &extpb.RegisterMutatorRequest{
+			Policy: testPolicy("DemoPolicy", "default", "demo", targetRef),
+			Domain: extpb.Domain_DOMAIN_REQUEST,
+		}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("ClearPolicy", func(t *testing.T) {
+		svc := newTestExtensionService()
+
+		_, err := svc.ClearPolicy(ctx, &extpb.ClearPolicyRequest{Policy: testPolicy("OtherPolicy", "default", "demo")})
+		assertPermissionDenied(t, err, `policy kind "OtherPolicy"`)
+
+		if _, err := svc.ClearPolicy(ctx, &extpb.ClearPolicyRequest{Policy: testPolicy("DemoPolicy", "default", "demo")}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("Resolve", func(t *testing.T) {
+		svc := newTestExtensionService()
+
+		_, err := svc.Resolve(ctx, &extpb.ResolveRequest{Policy: testPolicy("OtherPolicy", "default", "demo"), Expression: "self"})
+		assertPermissionDenied(t, err, `policy kind "OtherPolicy"`)
+
+		_, err = svc.Resolve(ctx, &extpb.ResolveRequest{Expression: "self"})
+		assertPermissionDenied(t, err, `policy kind ""`)
+	})
+
+	t.Run("Resolve expressions", func(t *testing.T) {
+		topology, err := machinery.NewTopology()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dag := newNilGuardedPointer[StateAwareDAG]()
+		dag.set(StateAwareDAG{topology: topology})
+		svc := newTestExtensionService()
+		svc.dag = dag
+		policy := testPolicy("DemoPolicy", "default", "demo", targetRef)
+
+		_, err = svc.Resolve(ctx, &extpb.ResolveRequest{Policy: policy, Expression: "self.findAuthPolicies()"})
+		assertPermissionDenied(t, err, "domain DOMAIN_AUTH in expressions")
+
+		_, err = svc.Resolve(ctx, &extpb.ResolveRequest{Policy: policy, Expression: `kuadrant.v1.Policy{metadata: kuadrant.v1.Metadata{kind: "AuthPolicy"}, targetRefs: self.targetRefs}.findGateways()`})
+		assertPermissionDenied(t, err, "kuadrant.v1.Policy literals in expressions")
+
+		if _, err := svc.Resolve(ctx, &extpb.ResolveRequest{Policy: policy, Expression: "self.findGateways()"}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		authCtx := withManifest(context.Background(), &Manifest{PolicyKinds: []string{"DemoPolicy"}, Domains: []string{"DOMAIN_AUTH"}})
+		if _, err := svc.Resolve(authCtx, &extpb.ResolveRequest{Policy: policy, Expression: "self.findAuthPolicies()"}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("RegisterActionMethod", func(t *testing.T) {
+		svc := newTestExtensionService()
+
+		request := validRequest()
+		request.Url = "grpc://other:8081"
+		_, err := svc.RegisterActionMethod(ctx, request)
+		assertPermissionDenied(t, err, `upstream host "other"`)
+
+		noGRPCMethod := withManifest(context.Background(), &Manifest{PolicyKinds: []string{"DemoPolicy"}, UpstreamHosts: []string{"svc"}})
+		_, err = svc.RegisterActionMethod(noGRPCMethod, validRequest())
+		assertPermissionDenied(t, err, "action type ACTION_TYPE_GRPC_METHOD")
+
+		if _, err := svc.RegisterActionMethod(ctx, validRequest()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("PipelineCommit", func(t *testing.T) {
+		svc := newTestExtensionService()
+
+		_, err := svc.PipelineCommit(ctx, &extpb.PipelineCommitRequest{
+			Policy: testPipelinePolicy(),
+			Actions: []*extpb.ActionEntry{
+				{ActionType: extpb.ActionType_ACTION_TYPE_DENY, Phase: "request", WithStatus: 403},
+				{ActionType: extpb.ActionType_ACTION_TYPE_FAIL, Phase: "response", LogMessage: "internal error"},
+			},
+		})
+		assertPermissionDenied(t, err, "action type ACTION_TYPE_FAIL")
+
+		if _, err := svc.PipelineCommit(ctx, &extpb.PipelineCommitRequest{
+			Policy: testPipelinePolicy(),
+			Actions: []*extpb.ActionEntry{
+				{ActionType: extpb.ActionType_ACTION_TYPE_DENY, Phase: "request", WithStatus: 403},
+			},
+		}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("no manifest denies everything", func(t *testing.T) {
+		svc := newTestExtensionService()
+
+		_, err := svc.RegisterMutator(context.Background(), &extpb.RegisterMutatorRequest{
+			Policy: testPolicy("DemoPolicy", "default", "demo", targetRef),
+			Domain: extpb.Domain_DOMAIN_REQUEST,
+		})
+		assertPermissionDenied(t, err, "extension has no manifest")
+
+		_, err = svc.Resolve(context.Background(), &extpb.ResolveRequest{Policy: testPolicy("DemoPolicy", "default", "demo"), Expression: "self"})
+		assertPermissionDenied(t, err, "extension has no manifest")
+	})
+}
@@ -18,6 +18,8 @@ package extension
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -25,6 +27,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -40,6 +43,7 @@ type OOPExtension struct {
 	name         string
 	executable   string
 	socket       string
+	manifest     *Manifest
 	cmd          *exec.Cmd
 	server       *grpc.Server
 	service      extpb.ExtensionServiceServer
@@ -48,6 +52,12 @@ type OOPExtension struct {
 	serverMu     sync.Mutex
 	monitorWg    sync.WaitGroup
 	completionWg sync.WaitGroup
+	exited       chan error
+	lastPing     atomic.Int64
+	ready        chan struct{}
+	readyOnce    *sync.Once
+	drainCtx     context.Context
+	drain        context.CancelFunc
 }
 
 func NewOOPExtension(name string, location string, service extpb.ExtensionServiceServer, logger logr.Logger, sync io.Writer) (OOPExtension, error) {
@@ -61,16 +71,31 @@ func NewOOPExtension(name string, location string, service extpb.ExtensionServic
 		}
 	}
 
+	var manifest *Manifest
+	if err == nil {
+		manifest, err = LoadManifest(filepath.Join(location, name))
+	}
+
 	return OOPExtension{
 		name:       name,
-		socket:     fmt.Sprintf("/tmp/kuadrant/%s/%s", name, defaultUnixSocket),
+		socket:     extensionSocket(name, 0),
 		executable: executable,
+		manifest:   manifest,
 		service:    service,
 		logger:     logger.WithName(name),
 		sync:       sync,
 	}, err
 }
 
+// extensionSocket returns the path of the unix socket of an extension. Each generation of an extension replaced
+// while running gets its own socket, so that both can be served during the swap.
+func extensionSocket(name string, generation int) string {
+	if generation == 0 {
+		return fmt.Sprintf("/tmp/kuadrant/%s/%s", name, defaultUnixSocket)
+	}
+	return fmt.Sprintf("/tmp/kuadrant/%s/.grpc-%d.sock", name, generation)
+}
+
 func (p *OOPExtension) Name() string {
 	return p.name
 }
@@ -104,14 +129,19 @@ func (p *OOPExtension) Start() error {
 	}
 	p.logger.Info("started")
 
+	exited := make(chan error, 1)
 	p.completionWg.Go(func() {
 		// We must wait for stderr to be fully read before calling cmd.Wait()
 		p.monitorWg.Wait()
 
-		if e := cmd.Wait(); e != nil {
+		e := cmd.Wait()
+		if e != nil {
 			p.logger.Error(e, fmt.Sprintf("Extension %q finished with an error", p.name))
 		}
+		exited <- e
+		close(exited)
 	})
+	p.exited = exited
 
 	// only set this, if we successfully started it all
 	p.cmd = cmd
@@ -126,18 +156,59 @@ func (p *OOPExtension) WaitForCompletion() {
 	p.completionWg.Wait()
 }
 
+// Exited returns a channel receiving the result of the process once it exits, or nil if it was never started
+func (p *OOPExtension) Exited() <-chan error {
+	return p.exited
+}
+
+// LastPing returns the time of the last Ping call of the extension, or the zero time if it never pinged
+func (p *OOPExtension) LastPing() time.Time {
+	if nanos := p.lastPing.Load(); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return time.Time{}
+}
+
+// Ready returns a channel closed once the extension made its first call to the operator since it was started
+func (p *OOPExtension) Ready() <-chan struct{} {
+	return p.ready
+}
+
+// Drain closes the streams opened by the extension, e.g. its subscriptions, so that it stops receiving events before
+// being stopped
+func (p *OOPExtension) Drain() {
+	p.serverMu.Lock()
+	defer p.serverMu.Unlock()
+	if p.drain != nil {
+		p.drain()
+	}
+}
+
+// Kill kills the process of the extension, e.g. when it stopped responding
+func (p *OOPExtension) Kill() error {
+	if p.cmd == nil {
+		return nil
+	}
+	return p.cmd.Process.Kill()
+}
+
 func (p *OOPExtension) Stop() error {
 	p.logger.Info("stopping...")
 	var err error
 
 	// Did we ever successfully started?
 	if p.cmd != nil {
-		if err = p.cmd.Process.Signal(syscall.SIGTERM); err == nil {
+		cmd := p.cmd
+		if err = cmd.Process.Signal(syscall.SIGTERM); err == nil {
 			timer := time.AfterFunc(2*time.Second, func() {
-				_ = p.cmd.Process.Kill() // we know this can fail, as this is racy. All that really matters is the `Wait()` below
+				_ = cmd.Process.Kill() // we know this can fail, as this is racy. All that really matters is the `Wait()` below
 			})
 
+			p.completionWg.Wait()
 			timer.Stop()
+		} else if errors.Is(err, os.ErrProcessDone) {
+			// the process already exited, e.g. it crashed and is being restarted
+			err = nil
 		}
 
 		// let stderr monitoring finish
@@ -177,9 +248,12 @@ func (p *OOPExtension) startServer() error {
 			return err
 		}
 
-		server := grpc.NewServer()
+		server := grpc.NewServer(grpc.UnaryInterceptor(p.recordCall), grpc.StreamInterceptor(p.recordStream))
 		extpb.RegisterExtensionServiceServer(server, p.service)
 		p.server = server
+		p.ready = make(chan struct{})
+		p.readyOnce = &sync.Once{}
+		p.drainCtx, p.drain = context.WithCancel(context.Background())
 
 		go func() {
 			if err := server.Serve(ln); err != nil {
@@ -191,10 +265,57 @@ func (p *OOPExtension) startServer() error {
 	return nil
 }
 
+// recordCall records the time of the Ping calls of the extension, used to check its liveness, and that the extension
+// is ready. The manifest of the extension is passed on to the service, which enforces it.
+func (p *OOPExtension) recordCall(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if info.FullMethod == extpb.ExtensionService_Ping_FullMethodName {
+		p.lastPing.Store(time.Now().UnixNano())
+	}
+	p.readyOnce.Do(func() { close(p.ready) })
+	return handler(withExtensionName(withManifest(ctx, p.manifest), p.name), req)
+}
+
+// recordStream records that the extension is ready, and ties the streams it opens to Drain
+func (p *OOPExtension) recordStream(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	p.readyOnce.Do(func() { close(p.ready) })
+
+	ctx, cancel := context.WithCancel(withExtensionName(withManifest(stream.Context(), p.manifest), p.name))
+	defer cancel()
+	stop := context.AfterFunc(p.drainCtx, cancel)
+	defer stop()
+	return handler(srv, &drainableStream{ServerStream: stream, ctx: ctx})
+}
+
+type extensionNameContextKey struct{}
+
+// withExtensionName returns a context carrying the name of the extension the call comes from
+func withExtensionName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, extensionNameContextKey{}, name)
+}
+
+// extensionNameFromContext returns the name of the extension the call comes from, or an empty string if unknown
+func extensionNameFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(extensionNameContextKey{}).(string)
+	return name
+}
+
+// drainableStream is a server stream whose context is also cancelled when the extension is drained
+type drainableStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *drainableStream) Context() context.Context {
+	return s.ctx
+}
+
 func (p *OOPExtension) stopServer() error {
 	p.serverMu.Lock()
 	server := p.server
 	p.server = nil
+	if p.drain != nil {
+		p.drain()
+	}
 	p.serverMu.Unlock()
 
 	if server != nil {
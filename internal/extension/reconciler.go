@@ -3,6 +3,7 @@ package extension
 import (
 	"context"
 	"reflect"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -21,7 +22,13 @@ type nilGuardedPointer[T any] struct {
 	ptr     atomic.Pointer[T]
 	mu      sync.Mutex
 	cond    *sync.Cond
-	updates []chan T
+	updates []updateChannel[T]
+}
+
+// updateChannel receives the values set on a nilGuardedPointer until it is closed
+type updateChannel[T any] struct {
+	channel chan T
+	closed  chan struct{}
 }
 
 // newNilGuardedPointer creates a new nilGuardedPointer.
@@ -41,19 +48,36 @@ func (ngp *nilGuardedPointer[T]) set(x T) {
 	ngp.cond.Broadcast()
 
 	if previous != nil && ngp.updates != nil {
-		for _, update := range ngp.updates {
-			update <- x
-		}
+		ngp.updates = slices.DeleteFunc(ngp.updates, func(update updateChannel[T]) bool {
+			select {
+			case update.channel <- x:
+				return false
+			case <-update.closed:
+				return true
+			}
+		})
 	}
 }
 
 func (ngp *nilGuardedPointer[T]) newUpdateChannel() chan T {
+	channel, _ := ngp.newClosableUpdateChannel()
+	return channel
+}
+
+// newClosableUpdateChannel returns a channel receiving the values set on the pointer, and a function to call once the
+// channel is no longer read, so that set does not block on it
+func (ngp *nilGuardedPointer[T]) newClosableUpdateChannel() (chan T, func()) {
 	ngp.mu.Lock()
 	defer ngp.mu.Unlock()
 
-	channel := make(chan T)
-	ngp.updates = append(ngp.updates, channel)
-	return channel
+	update := updateChannel[T]{channel: make(chan T), closed: make(chan struct{})}
+	ngp.updates = append(ngp.updates, update)
+
+	// the channel is removed by the next set, as set holds the lock while sending
+	var once sync.Once
+	return update.channel, func() {
+		once.Do(func() { close(update.closed) })
+	}
 }
 
 // get returns the current value of the pointer without blocking.
@@ -629,6 +629,39 @@ func TestNilGuardedPointer(t *testing.T) {
 		}
 	})
 
+	t.Run("closed update channels do not block set", func(t *testing.T) {
+		ptr := newNilGuardedPointer[string]()
+		ptr.set("first")
+
+		channel, closeChannel := ptr.newClosableUpdateChannel()
+		other := ptr.newUpdateChannel()
+
+		done := make(chan struct{})
+		go func() {
+			ptr.set("second")
+			ptr.set("third")
+			close(done)
+		}()
+
+		if val := <-channel; val != "second" {
+			t.Errorf("Expected 'second' on the closable update channel, got '%s'", val)
+		}
+		closeChannel()
+
+		for _, expected := range []string{"second", "third"} {
+			if val := <-other; val != expected {
+				t.Errorf("Expected '%s' on the other update channel, got '%s'", expected, val)
+			}
+		}
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("Timed out waiting for set to return")
+		}
+		closeChannel()
+	})
+
 	t.Run("get without waiting", func(t *testing.T) {
 		ptr := newNilGuardedPointer[string]()
 
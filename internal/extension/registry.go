@@ -338,6 +338,12 @@ type PipelineActionEntry struct {
 	WithBody     string // response body string (deny)
 	HeadersToAdd string // CEL expression for headers (add_headers)
 	LogMessage   string // error message to log (fail)
+	// CEL expression for the new body (replace_body)
+	Body string
+	// CEL expression for the names of the headers to remove (remove_headers)
+	HeadersToRemove string
+	// CEL expression for headers overwriting the existing ones (replace_headers)
+	HeadersToReplace string
 }
 
 // pipelineKey identifies a set of actions for a specific policy and phase.
@@ -804,6 +810,39 @@ func (r *RegisteredDataStore) GetPoliciesWithPipelineActions() []ResourceID {
 	return result
 }
 
+// GetPoliciesOfKind returns the IDs of the policies of a kind that have any registered data
+func (r *RegisteredDataStore) GetPoliciesOfKind(kind string) []ResourceID {
+	r.dataMutex.RLock()
+	r.subsMutex.RLock()
+	r.upstreamsMutex.RLock()
+	r.pipelineMutex.RLock()
+	defer r.dataMutex.RUnlock()
+	defer r.subsMutex.RUnlock()
+	defer r.upstreamsMutex.RUnlock()
+	defer r.pipelineMutex.RUnlock()
+
+	seen := make(map[ResourceID]bool)
+	for key := range r.dataProviders {
+		seen[key.Policy] = true
+	}
+	for key := range r.subscriptions {
+		seen[key.Policy] = true
+	}
+	for key := range r.registeredUpstreams {
+		seen[key.Policy] = true
+	}
+	for key := range r.pipelineActions {
+		seen[key.Policy] = true
+	}
+	result := make([]ResourceID, 0, len(seen))
+	for id := range seen {
+		if id.Kind == kind {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
 func (r *RegisteredDataStore) ClearPolicyData(policy ResourceID) (clearedMutators int, clearedSubscriptions int, clearedUpstreams int) {
 	r.dataMutex.Lock()
 	r.subsMutex.Lock()
@@ -953,7 +992,11 @@ func (m *RegisteredDataMutator[TResource]) mutateAuthConfig(authConfig *authorin
 
 // mutateWasmConfig handles WasmConfig-specific mutations
 func (m *RegisteredDataMutator[TResource]) mutateWasmConfig(wasmConfig *wasm.Config, targetRefs []machinery.PolicyTargetReference) error {
-	requestData := make(map[string]string)
+	// keep the request data of the config, e.g. token reservations captured in the request phase
+	requestData := make(map[string]string, len(wasmConfig.RequestData))
+	for key, value := range wasmConfig.RequestData {
+		requestData[key] = value
+	}
 
 	for _, targetRef := range targetRefs {
 		providerEntries := m.store.GetAllForTargetRef(targetRef.GetLocator(), extpb.Domain_DOMAIN_REQUEST)
@@ -1151,9 +1194,21 @@ func entryMatchesVar(entry PipelineActionEntry, pattern *regexp.Regexp) bool {
 	if entry.LogMessage != "" && pattern.MatchString(entry.LogMessage) {
 		return true
 	}
+	if entry.Body != "" && pattern.MatchString(entry.Body) {
+		return true
+	}
+	if entry.HeadersToRemove != "" && pattern.MatchString(entry.HeadersToRemove) {
+		return true
+	}
+	if entry.HeadersToReplace != "" && pattern.MatchString(entry.HeadersToReplace) {
+		return true
+	}
 	return false
 }
 
+// entryToTypedAction builds the typed action of a pipeline action entry. Typed actions are named after what they act
+// upon, e.g. `headers` adds headers and `body` replaces the body. The actions whose type is a feature of the wasm-shim
+// are only accepted if the feature is declared (see validateShimFeature)
 func entryToTypedAction(entry PipelineActionEntry, sources []string, phase string) wasm.TypedAction {
 	ta := wasm.TypedAction{
 		Predicate:            predicateOrTrue(entry.Predicate),
@@ -1174,6 +1229,24 @@ func entryToTypedAction(entry PipelineActionEntry, sources []string, phase strin
 		ta.Type = "fail"
 		ta.Terminal = true
 		ta.LogMessage = entry.LogMessage
+	case extpb.ActionType_ACTION_TYPE_REPLACE_BODY:
+		ta.Type = "body"
+		ta.Body = entry.Body
+		if phase == string(PipelinePhaseResponse) {
+			ta.Target = "response"
+		}
+	case extpb.ActionType_ACTION_TYPE_REMOVE_HEADERS:
+		ta.Type = "removeHeaders"
+		ta.Headers = entry.HeadersToRemove
+		if phase == string(PipelinePhaseResponse) {
+			ta.Target = "response"
+		}
+	case extpb.ActionType_ACTION_TYPE_REPLACE_HEADERS:
+		ta.Type = "replaceHeaders"
+		ta.Headers = entry.HeadersToReplace
+		if phase == string(PipelinePhaseResponse) {
+			ta.Target = "response"
+		}
 	}
 	return ta
 }
@@ -2162,6 +2162,92 @@ func TestMutateWasmConfig_TranslatesPipelineActions(t *testing.T) {
 	}
 }
 
+func TestMutateWasmConfig_TranslatesTransformationActions(t *testing.T) {
+	store := NewRegisteredDataStore()
+	mockTargetRef := createMockGatewayTargetRef()
+	targetRef := TargetRef{Group: "gateway.networking.k8s.io", Kind: "Gateway", Name: mockTargetRef.GetName(), Namespace: mockTargetRef.GetNamespace()}
+	policyID := testResourceID("RedactPolicy", "default", "my-redact")
+
+	store.SetUpstream(
+		RegisteredUpstreamKey{Policy: policyID, Name: "redact", URL: "grpc://svc:8081", Service: "redact.Service", Method: "Redact"},
+		RegisteredUpstreamEntry{ClusterName: "ext-svc-8081", Host: "svc", Port: 8081, TargetRef: targetRef, FailureMode: "deny", Timeout: "100ms", Service: "redact.Service", Method: "Redact", MessageTemplate: "redact.v1.Request{}"},
+		testFileDescriptorSet(),
+	)
+
+	// Request phase: remove_headers (root)
+	store.AppendPipelineActions(policyID, PipelinePhaseRequest, []PipelineActionEntry{
+		{ActionType: extpb.ActionType_ACTION_TYPE_REMOVE_HEADERS, HeadersToRemove: `["x-internal"]`},
+	})
+	// Response phase: replace_headers (root), grpc with var, replace_body referencing var (onReply)
+	store.AppendPipelineActions(policyID, PipelinePhaseResponse, []PipelineActionEntry{
+		{ActionType: extpb.ActionType_ACTION_TYPE_REPLACE_HEADERS, HeadersToReplace: `{"cache-control": "no-store"}`},
+		{ActionType: extpb.ActionType_ACTION_TYPE_GRPC_METHOD, Method: "redact", Var: "redactResponse"},
+		{ActionType: extpb.ActionType_ACTION_TYPE_REPLACE_BODY, Predicate: "redactResponse.redacted", Body: "redactResponse.body"},
+	})
+
+	mutator := NewRegisteredDataMutator[*wasm.Config](store)
+	wasmConfig := &wasm.Config{
+		Services: make(map[string]wasm.Service),
+		ActionSets: []wasm.ActionSet{
+			{Name: "test-action-set", Actions: []wasm.Action{}},
+		},
+	}
+
+	err := mutator.Mutate(wasmConfig, []machinery.PolicyTargetReference{mockTargetRef})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	typed := wasmConfig.ActionSets[0].TypedActions
+	// Root-level: remove_headers, replace_headers, grpc (with onReply)
+	if len(typed) != 3 {
+		t.Fatalf("Expected 3 root-level TypedActions, got %d", len(typed))
+	}
+
+	// typed[0]: request remove_headers
+	if typed[0].Type != "removeHeaders" {
+		t.Errorf("typed[0]: expected type 'removeHeaders', got %q", typed[0].Type)
+	}
+	if typed[0].Headers != `["x-internal"]` {
+		t.Errorf("typed[0]: expected headers, got %q", typed[0].Headers)
+	}
+	if typed[0].Target != "" {
+		t.Errorf("typed[0]: expected no target, got %q", typed[0].Target)
+	}
+
+	// typed[1]: response replace_headers
+	if typed[1].Type != "replaceHeaders" {
+		t.Errorf("typed[1]: expected type 'replaceHeaders', got %q", typed[1].Type)
+	}
+	if typed[1].Headers != `{"cache-control": "no-store"}` {
+		t.Errorf("typed[1]: expected headers, got %q", typed[1].Headers)
+	}
+	if typed[1].Target != "response" {
+		t.Errorf("typed[1]: expected target 'response', got %q", typed[1].Target)
+	}
+
+	// typed[2]: grpc with the body replacement driven by its result
+	grpc := typed[2]
+	if grpc.Type != "grpc" {
+		t.Fatalf("typed[2]: expected type 'grpc', got %q", grpc.Type)
+	}
+	if len(grpc.OnReply) != 1 {
+		t.Fatalf("Expected 1 onReply action, got %d", len(grpc.OnReply))
+	}
+	if grpc.OnReply[0].Type != "body" {
+		t.Errorf("onReply[0]: expected type 'body', got %q", grpc.OnReply[0].Type)
+	}
+	if grpc.OnReply[0].Body != "redactResponse.body" {
+		t.Errorf("onReply[0]: expected body, got %q", grpc.OnReply[0].Body)
+	}
+	if grpc.OnReply[0].Target != "response" {
+		t.Errorf("onReply[0]: expected target 'response', got %q", grpc.OnReply[0].Target)
+	}
+	if grpc.OnReply[0].Terminal {
+		t.Error("onReply[0]: expected NOT terminal")
+	}
+}
+
 func TestMutateWasmConfig_NoPipelineActionsNoChange(t *testing.T) {
 	store := NewRegisteredDataStore()
 	mockTargetRef := createMockGatewayTargetRef()
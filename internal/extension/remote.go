@@ -0,0 +1,402 @@
+/*
+Copyright 2025 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package extension
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"github.com/samber/lo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	grpcstatus "google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/env"
+
+	kuadrantv1alpha1 "github.com/kuadrant/kuadrant-operator/api/v1alpha1"
+	extpb "github.com/kuadrant/kuadrant-operator/pkg/extension/grpc/v1"
+)
+
+const (
+	// defaultRemoteExtensionsPort is the port remote extensions connect to, unless overridden by
+	// EXTENSIONS_REMOTE_SERVICE_PORT
+	defaultRemoteExtensionsPort = 50052
+
+	// the files of the certificate of the operator, and of the CA the client certificates of the remote extensions are
+	// verified with, in the EXTENSIONS_REMOTE_TLS_DIR directory. This is the layout of a kubernetes.io/tls secret.
+	remoteExtensionsCertFile = "tls.crt"
+	remoteExtensionsKeyFile  = "tls.key"
+	remoteExtensionsCAFile   = "ca.crt"
+)
+
+// kuadrantExtensionsNamespace returns the namespace of the KuadrantExtensions registering remote extensions, i.e. the
+// namespace of the operator, as registering an extension grants it access to the policies and the data plane of the
+// whole cluster
+func kuadrantExtensionsNamespace() string {
+	return env.GetString("OPERATOR_NAMESPACE", "kuadrant-system")
+}
+
+// remoteExtensionsTLSDir returns the directory of the certificates of the remote extensions server, or an empty string
+// if remote extensions are disabled
+func remoteExtensionsTLSDir() string {
+	return env.GetString("EXTENSIONS_REMOTE_TLS_DIR", "")
+}
+
+// RemoteExtension is an extension running outside of the operator, e.g. in its own Deployment, registered by a
+// KuadrantExtension
+type RemoteExtension struct {
+	// Name is the namespaced name of the KuadrantExtension
+	Name string
+	// Identity is the identity of the client certificate of the extension
+	Identity string
+	// Manifest declares the capabilities of the extension
+	Manifest *Manifest
+}
+
+type remoteExtension struct {
+	RemoteExtension
+	drainCtx context.Context
+	drain    context.CancelFunc
+}
+
+// remoteExtensions are the remote extensions allowed to call the operator, by identity
+type remoteExtensions struct {
+	mu         sync.RWMutex
+	byIdentity map[string]*remoteExtension
+}
+
+func newRemoteExtensions() *remoteExtensions {
+	return &remoteExtensions{byIdentity: map[string]*remoteExtension{}}
+}
+
+// set replaces the registered remote extensions, returning the names of the extensions removed. The streams of the
+// extensions removed, or whose capabilities changed, are closed, so that they stop receiving events, or subscribe again
+// with their new capabilities.
+func (r *remoteExtensions) set(extensions []RemoteExtension) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byIdentity := make(map[string]*remoteExtension, len(extensions))
+	for _, extension := range extensions {
+		if current, ok := r.byIdentity[extension.Identity]; ok && current.Name == extension.Name && reflect.DeepEqual(current.Manifest, extension.Manifest) {
+			byIdentity[extension.Identity] = current
+			continue
+		}
+		drainCtx, drain := context.WithCancel(context.Background())
+		byIdentity[extension.Identity] = &remoteExtension{RemoteExtension: extension, drainCtx: drainCtx, drain: drain}
+	}
+
+	names := lo.SliceToMap(extensions, func(extension RemoteExtension) (string, struct{}) {
+		return extension.Name, struct{}{}
+	})
+	var removed []string
+	for identity, current := range r.byIdentity {
+		if byIdentity[identity] != current {
+			current.drain()
+		}
+		if _, ok := names[current.Name]; !ok {
+			removed = append(removed, current.Name)
+		}
+	}
+	r.byIdentity = byIdentity
+	slices.Sort(removed)
+	return removed
+}
+
+// drainAll closes the streams of all the remote extensions
+func (r *remoteExtensions) drainAll() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, extension := range r.byIdentity {
+		extension.drain()
+	}
+}
+
+// authenticate returns the remote extension the call comes from, identified by the verified client certificate of the
+// connection
+func (r *remoteExtensions) authenticate(ctx context.Context) (*remoteExtension, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, grpcstatus.Error(codes.Unauthenticated, "unknown peer")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, grpcstatus.Error(codes.Unauthenticated, "no verified client certificate")
+	}
+
+	identities := certificateIdentities(tlsInfo.State.VerifiedChains[0][0])
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, identity := range identities {
+		if extension, ok := r.byIdentity[identity]; ok {
+			return extension, nil
+		}
+	}
+	return nil, grpcstatus.Errorf(codes.PermissionDenied, "no KuadrantExtension registered for identities %q", identities)
+}
+
+// certificateIdentities returns the identities a client certificate can be registered with: its URI and DNS subject
+// alternative names, and its common name
+func certificateIdentities(cert *x509.Certificate) []string {
+	identities := make([]string, 0, len(cert.URIs)+len(cert.DNSNames)+1)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	identities = append(identities, cert.DNSNames...)
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+	return identities
+}
+
+// authenticateCall rejects the calls of unknown remote extensions, and passes on the name and the manifest of the
+// extension to the service, which enforces the latter
+func (r *remoteExtensions) authenticateCall(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	extension, err := r.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(withExtensionName(withManifest(ctx, extension.Manifest), extension.Name), req)
+}
+
+// authenticateStream rejects the streams of unknown remote extensions, and closes the streams of an extension once it
+// is removed or its capabilities change
+func (r *remoteExtensions) authenticateStream(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	extension, err := r.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(withExtensionName(withManifest(stream.Context(), extension.Manifest), extension.Name))
+	defer cancel()
+	stop := context.AfterFunc(extension.drainCtx, cancel)
+	defer stop()
+	return handler(srv, &drainableStream{ServerStream: stream, ctx: ctx})
+}
+
+// remoteExtensionsTLSConfig returns the TLS configuration of the remote extensions server, which requires the client
+// certificates to be signed by the CA of the given directory. The certificates are read on each handshake, so that
+// rotated ones are used without restarting the operator.
+func remoteExtensionsTLSConfig(dir string) (*tls.Config, error) {
+	load := func() (*tls.Config, error) {
+		cert, err := tls.LoadX509KeyPair(filepath.Join(dir, remoteExtensionsCertFile), filepath.Join(dir, remoteExtensionsKeyFile))
+		if err != nil {
+			return nil, err
+		}
+		caFile := filepath.Join(dir, remoteExtensionsCAFile)
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("%s: no certificate found", caFile)
+		}
+		return &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    clientCAs,
+			MinVersion:   tls.VersionTLS13,
+			NextProtos:   []string{"h2"},
+		}, nil
+	}
+
+	if _, err := load(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return load()
+		},
+	}, nil
+}
+
+// startRemoteServer serves the extension service to the remote extensions over TCP with mutual TLS, if enabled
+func (m *Manager) startRemoteServer() error {
+	tlsDir := remoteExtensionsTLSDir()
+	if tlsDir == "" {
+		return nil
+	}
+
+	port, portErr := env.GetInt("EXTENSIONS_REMOTE_SERVICE_PORT", defaultRemoteExtensionsPort)
+	if portErr != nil {
+		m.logger.Error(portErr, "invalid EXTENSIONS_REMOTE_SERVICE_PORT, using default", "default", defaultRemoteExtensionsPort)
+		port = defaultRemoteExtensionsPort
+	}
+
+	tlsConfig, err := remoteExtensionsTLSConfig(tlsDir)
+	if err != nil {
+		return fmt.Errorf("failed to load certificates from %s: %w", tlsDir, err)
+	}
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %d: %w", port, err)
+	}
+
+	server := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(tlsConfig)),
+		grpc.UnaryInterceptor(m.remoteExtensions.authenticateCall),
+		grpc.StreamInterceptor(m.remoteExtensions.authenticateStream),
+	)
+	extpb.RegisterExtensionServiceServer(server, m.service)
+	m.remoteServer = server
+
+	go func() {
+		m.logger.Info("starting remote extensions service", "port", port)
+		if err := server.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			m.logger.Error(err, "remote extensions server failed")
+		}
+	}()
+
+	return nil
+}
+
+func (m *Manager) stopRemoteServer() {
+	if m.remoteServer == nil {
+		return
+	}
+
+	m.logger.Info("stopping remote extensions service")
+	// the streams of the remote extensions, e.g. their subscriptions, never end on their own
+	m.remoteExtensions.drainAll()
+	m.remoteServer.GracefulStop()
+	m.remoteServer = nil
+}
+
+// ReconcileRemoteExtensions registers the remote extensions declared by the KuadrantExtensions of the namespace of the
+// operator, and reports in the status of each KuadrantExtension whether it was accepted. When several
+// KuadrantExtensions declare the same identity, the oldest one is accepted. The data registered by the remote
+// extensions whose KuadrantExtension was deleted is released.
+func (m *Manager) ReconcileRemoteExtensions(ctx context.Context, _ []controller.ResourceEvent, topology *machinery.Topology, _ error, _ *sync.Map) error {
+	kuadrantExtensions := lo.FilterMap(topology.Objects().Items(func(o machinery.Object) bool {
+		return o.GroupVersionKind().GroupKind() == kuadrantv1alpha1.KuadrantExtensionGroupKind
+	}), func(o machinery.Object, _ int) (*kuadrantv1alpha1.KuadrantExtension, bool) {
+		kuadrantExtension, ok := o.(*controller.RuntimeObject).Object.(*kuadrantv1alpha1.KuadrantExtension)
+		return kuadrantExtension, ok
+	})
+	slices.SortFunc(kuadrantExtensions, func(a, b *kuadrantv1alpha1.KuadrantExtension) int {
+		if c := a.CreationTimestamp.Compare(b.CreationTimestamp.Time); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Namespace+"/"+a.Name, b.Namespace+"/"+b.Name)
+	})
+
+	namespace := kuadrantExtensionsNamespace()
+	var extensions []RemoteExtension
+	owners := map[string]string{}
+	for _, kuadrantExtension := range kuadrantExtensions {
+		name := kuadrantExtension.Namespace + "/" + kuadrantExtension.Name
+		cond := metav1.Condition{
+			Type:    kuadrantv1alpha1.KuadrantExtensionConditionAccepted,
+			Status:  metav1.ConditionTrue,
+			Reason:  kuadrantv1alpha1.KuadrantExtensionConditionAccepted,
+			Message: "KuadrantExtension has been accepted",
+		}
+
+		manifest := remoteExtensionManifest(kuadrantExtension)
+		if kuadrantExtension.Namespace != namespace {
+			cond.Status = metav1.ConditionFalse
+			cond.Reason = kuadrantv1alpha1.KuadrantExtensionNotAllowedReason
+			cond.Message = fmt.Sprintf("KuadrantExtensions are only accepted in namespace %s", namespace)
+		} else if err := manifest.Validate(); err != nil {
+			cond.Status = metav1.ConditionFalse
+			cond.Reason = kuadrantv1alpha1.KuadrantExtensionInvalidReason
+			cond.Message = err.Error()
+		} else if owner, taken := owners[kuadrantExtension.Spec.Identity]; taken {
+			cond.Status = metav1.ConditionFalse
+			cond.Reason = kuadrantv1alpha1.KuadrantExtensionConflictedReason
+			cond.Message = fmt.Sprintf("identity %q is already registered by %s", kuadrantExtension.Spec.Identity, owner)
+		} else {
+			owners[kuadrantExtension.Spec.Identity] = name
+			extensions = append(extensions, RemoteExtension{Name: name, Identity: kuadrantExtension.Spec.Identity, Manifest: manifest})
+		}
+
+		if err := m.updateKuadrantExtensionStatus(ctx, kuadrantExtension, cond); err != nil {
+			m.logger.Error(err, "failed to update KuadrantExtension status", "name", name)
+		}
+	}
+
+	var releasedKinds []schema.GroupKind
+	removed := m.remoteExtensions.set(extensions)
+	for _, name := range removed {
+		m.logger.Info("remote extension removed, releasing its policies", "name", name)
+		releasedKinds = append(releasedKinds, m.releaseExtension(name)...)
+	}
+	m.releasePolicies(ctx, releasedKinds)
+
+	if len(removed) > 0 && m.changeNotifier != nil {
+		if err := m.changeNotifier(fmt.Sprintf("remote extensions %s removed", strings.Join(removed, ", "))); err != nil {
+			m.logger.Error(err, "failed to trigger reconciliation after remote extensions were removed")
+		}
+	}
+	return nil
+}
+
+// remoteExtensionManifest returns the manifest of the capabilities declared by a KuadrantExtension
+func remoteExtensionManifest(kuadrantExtension *kuadrantv1alpha1.KuadrantExtension) *Manifest {
+	return &Manifest{
+		PolicyKinds:   kuadrantExtension.Spec.PolicyKinds,
+		Domains:       kuadrantExtension.Spec.Domains,
+		UpstreamHosts: kuadrantExtension.Spec.UpstreamHosts,
+		ActionTypes:   kuadrantExtension.Spec.ActionTypes,
+	}
+}
+
+func (m *Manager) updateKuadrantExtensionStatus(ctx context.Context, kuadrantExtension *kuadrantv1alpha1.KuadrantExtension, cond metav1.Condition) error {
+	newStatus := &kuadrantv1alpha1.KuadrantExtensionStatus{
+		Conditions:         slices.Clone(kuadrantExtension.Status.Conditions),
+		ObservedGeneration: kuadrantExtension.Generation,
+	}
+	meta.SetStatusCondition(&newStatus.Conditions, cond)
+	if equality.Semantic.DeepEqual(*newStatus, kuadrantExtension.Status) {
+		return nil
+	}
+
+	kuadrantExtension = kuadrantExtension.DeepCopy()
+	kuadrantExtension.Status = *newStatus
+	obj, err := controller.Destruct(kuadrantExtension)
+	if err != nil {
+		return err
+	}
+	_, err = m.client.Resource(kuadrantv1alpha1.KuadrantExtensionsResource).Namespace(kuadrantExtension.Namespace).UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+	if err != nil && strings.Contains(err.Error(), "StorageError: invalid object") {
+		// the KuadrantExtension was removed in the meantime
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,351 @@
+//go:build unit
+
+package extension
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kuadrant/policy-machinery/controller"
+	"github.com/kuadrant/policy-machinery/machinery"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	grpcstatus "google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	kuadrantv1alpha1 "github.com/kuadrant/kuadrant-operator/api/v1alpha1"
+	extpb "github.com/kuadrant/kuadrant-operator/pkg/extension/grpc/v1"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate signed by the CA, and its key, to the tls.crt and tls.key files of dir, along with the CA
+func (ca *testCA) issue(t *testing.T, dir string, commonName string, uris ...string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.URIs = append(template.URIs, u)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		remoteExtensionsCertFile: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		remoteExtensionsKeyFile:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+		remoteExtensionsCAFile:   ca.pem,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+func dialRemoteExtensions(t *testing.T, port int, dir string) extpb.ExtensionServiceClient {
+	t.Helper()
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, remoteExtensionsCertFile), filepath.Join(dir, remoteExtensionsKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootCAs := x509.NewCertPool()
+	ca, err := os.ReadFile(filepath.Join(dir, remoteExtensionsCAFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootCAs.AppendCertsFromPEM(ca)
+
+	conn, err := grpc.NewClient(fmt.Sprintf("127.0.0.1:%d", port), grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      rootCAs,
+		MinVersion:   tls.VersionTLS13,
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return extpb.NewExtensionServiceClient(conn)
+}
+
+func TestRemoteExtensions(t *testing.T) {
+	ca := newTestCA(t)
+	serverDir, clientDir, unknownDir := t.TempDir(), t.TempDir(), t.TempDir()
+	ca.issue(t, serverDir, "kuadrant-operator")
+	ca.issue(t, clientDir, "plan-policy", "spiffe://cluster.local/ns/extensions/sa/plan-policy")
+	ca.issue(t, unknownDir, "unknown")
+
+	port := freePort(t)
+	t.Setenv("EXTENSIONS_REMOTE_TLS_DIR", serverDir)
+	t.Setenv("EXTENSIONS_REMOTE_SERVICE_PORT", fmt.Sprint(port))
+
+	manager := &Manager{
+		service:          newExtensionService(nil, logr.Discard()),
+		logger:           logr.Discard(),
+		remoteExtensions: newRemoteExtensions(),
+	}
+	manager.remoteExtensions.set([]RemoteExtension{{
+		Name:     "kuadrant-system/plan-policy",
+		Identity: "spiffe://cluster.local/ns/extensions/sa/plan-policy",
+		Manifest: &Manifest{PolicyKinds: []string{"PlanPolicy"}, Domains: []string{"DOMAIN_AUTH"}},
+	}})
+	if err := manager.startRemoteServer(); err != nil {
+		t.Fatal(err)
+	}
+	defer manager.stopRemoteServer()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("registered extension", func(t *testing.T) {
+		client := dialRemoteExtensions(t, port, clientDir)
+		if _, err := client.Ping(ctx, &extpb.PingRequest{}); err != nil {
+			t.Fatalf("Ping() error = %v", err)
+		}
+
+		stream, err := client.Subscribe(ctx, &extpb.SubscribeRequest{PolicyKind: "OIDCPolicy"})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		if code := grpcstatus.Code(err); code != codes.PermissionDenied {
+			t.Fatalf("Subscribe() of an undeclared policy kind code = %v, want %v", code, codes.PermissionDenied)
+		}
+
+		// the policy kinds handled by the extension are recorded, so that they are released once it is removed
+		policy := &extpb.Policy{
+			Metadata:   &extpb.Metadata{Group: "extensions.kuadrant.io", Kind: "PlanPolicy", Namespace: "default", Name: "plan"},
+			TargetRefs: []*extpb.TargetRef{{Group: "gateway.networking.k8s.io", Kind: "HTTPRoute", Namespace: "default", Name: "toystore"}},
+		}
+		if _, err := client.RegisterMutator(ctx, &extpb.RegisterMutatorRequest{Policy: policy, Domain: extpb.Domain_DOMAIN_AUTH, Binding: "plan", Expression: "self"}); err != nil {
+			t.Fatalf("RegisterMutator() error = %v", err)
+		}
+		service := manager.service.(*extensionService)
+		service.handledPolicyKindsMu.Lock()
+		_, handled := service.handledPolicyKinds["kuadrant-system/plan-policy"][schema.GroupKind{Group: "extensions.kuadrant.io", Kind: "PlanPolicy"}]
+		service.handledPolicyKindsMu.Unlock()
+		if !handled {
+			t.Error("policy kind handled by the remote extension was not recorded")
+		}
+	})
+
+	t.Run("unknown extension", func(t *testing.T) {
+		client := dialRemoteExtensions(t, port, unknownDir)
+		_, err := client.Ping(ctx, &extpb.PingRequest{})
+		if code := grpcstatus.Code(err); code != codes.PermissionDenied {
+			t.Fatalf("Ping() code = %v, want %v", code, codes.PermissionDenied)
+		}
+	})
+
+	t.Run("removed extension", func(t *testing.T) {
+		manager.remoteExtensions.set(nil)
+		client := dialRemoteExtensions(t, port, clientDir)
+		_, err := client.Ping(ctx, &extpb.PingRequest{})
+		if code := grpcstatus.Code(err); code != codes.PermissionDenied {
+			t.Fatalf("Ping() code = %v, want %v", code, codes.PermissionDenied)
+		}
+	})
+}
+
+func TestRemoteExtensionsSet(t *testing.T) {
+	extensions := newRemoteExtensions()
+	plan := RemoteExtension{Name: "extensions/plan", Identity: "plan", Manifest: &Manifest{PolicyKinds: []string{"PlanPolicy"}}}
+	oidc := RemoteExtension{Name: "extensions/oidc", Identity: "oidc", Manifest: &Manifest{PolicyKinds: []string{"OIDCPolicy"}}}
+	extensions.set([]RemoteExtension{plan, oidc})
+	planExtension, oidcExtension := extensions.byIdentity["plan"], extensions.byIdentity["oidc"]
+
+	plan.Manifest = &Manifest{PolicyKinds: []string{"PlanPolicy"}}
+	oidc.Manifest = &Manifest{PolicyKinds: []string{"OIDCPolicy", "PlanPolicy"}}
+	extensions.set([]RemoteExtension{plan, oidc})
+
+	if extensions.byIdentity["plan"] != planExtension || planExtension.drainCtx.Err() != nil {
+		t.Error("unchanged extension was replaced")
+	}
+	if extensions.byIdentity["oidc"] == oidcExtension || oidcExtension.drainCtx.Err() == nil {
+		t.Error("changed extension was not replaced and drained")
+	}
+
+	if removed := extensions.set([]RemoteExtension{plan}); !reflect.DeepEqual(removed, []string{"extensions/oidc"}) {
+		t.Errorf("removed extensions = %v, want [extensions/oidc]", removed)
+	}
+
+	extensions.set(nil)
+	if len(extensions.byIdentity) != 0 || planExtension.drainCtx.Err() == nil {
+		t.Error("removed extension was not drained")
+	}
+}
+
+func TestReconcileRemoteExtensions(t *testing.T) {
+	newKuadrantExtension := func(namespace, name string, created time.Time, spec kuadrantv1alpha1.KuadrantExtensionSpec) *kuadrantv1alpha1.KuadrantExtension {
+		return &kuadrantv1alpha1.KuadrantExtension{
+			TypeMeta:   metav1.TypeMeta{APIVersion: kuadrantv1alpha1.GroupVersion.String(), Kind: kuadrantv1alpha1.KuadrantExtensionGroupKind.Kind},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, CreationTimestamp: metav1.NewTime(created)},
+			Spec:       spec,
+		}
+	}
+	now := time.Now()
+	kuadrantExtensions := []*kuadrantv1alpha1.KuadrantExtension{
+		newKuadrantExtension("kuadrant-system", "plan", now, kuadrantv1alpha1.KuadrantExtensionSpec{Identity: "plan", PolicyKinds: []string{"PlanPolicy"}}),
+		newKuadrantExtension("kuadrant-system", "plan-copy", now.Add(time.Minute), kuadrantv1alpha1.KuadrantExtensionSpec{Identity: "plan", PolicyKinds: []string{"PlanPolicy"}}),
+		newKuadrantExtension("kuadrant-system", "invalid", now, kuadrantv1alpha1.KuadrantExtensionSpec{Identity: "invalid", PolicyKinds: []string{"DemoPolicy"}, Domains: []string{"DOMAIN_DNS"}}),
+		newKuadrantExtension("extensions", "elsewhere", now, kuadrantv1alpha1.KuadrantExtensionSpec{Identity: "elsewhere", PolicyKinds: []string{"OIDCPolicy"}}),
+	}
+
+	scheme := runtime.NewScheme()
+	if err := kuadrantv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	objects := make([]runtime.Object, 0, len(kuadrantExtensions))
+	topologyObjects := make([]*controller.RuntimeObject, 0, len(kuadrantExtensions))
+	for _, kuadrantExtension := range kuadrantExtensions {
+		objects = append(objects, kuadrantExtension.DeepCopy())
+		topologyObjects = append(topologyObjects, &controller.RuntimeObject{Object: kuadrantExtension})
+	}
+	client := dynamicfake.NewSimpleDynamicClient(scheme, objects...)
+	topology, err := machinery.NewTopology(machinery.WithObjects(topologyObjects...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager := &Manager{client: client, logger: logr.Discard(), service: newExtensionService(nil, logr.Discard()), remoteExtensions: newRemoteExtensions()}
+	if err := manager.ReconcileRemoteExtensions(context.Background(), nil, topology, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manager.remoteExtensions.byIdentity) != 1 || manager.remoteExtensions.byIdentity["plan"].Name != "kuadrant-system/plan" {
+		t.Errorf("registered remote extensions = %v, want only kuadrant-system/plan", manager.remoteExtensions.byIdentity)
+	}
+
+	wantReasons := map[string]string{
+		"kuadrant-system/plan":      kuadrantv1alpha1.KuadrantExtensionConditionAccepted,
+		"kuadrant-system/plan-copy": kuadrantv1alpha1.KuadrantExtensionConflictedReason,
+		"kuadrant-system/invalid":   kuadrantv1alpha1.KuadrantExtensionInvalidReason,
+		"extensions/elsewhere":      kuadrantv1alpha1.KuadrantExtensionNotAllowedReason,
+	}
+	for namespacedName, wantReason := range wantReasons {
+		namespace, name, _ := strings.Cut(namespacedName, "/")
+		obj, err := client.Resource(kuadrantv1alpha1.KuadrantExtensionsResource).Namespace(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		kuadrantExtension := &kuadrantv1alpha1.KuadrantExtension{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, kuadrantExtension); err != nil {
+			t.Fatal(err)
+		}
+		cond := meta.FindStatusCondition(kuadrantExtension.Status.Conditions, kuadrantv1alpha1.KuadrantExtensionConditionAccepted)
+		if cond == nil || cond.Reason != wantReason {
+			t.Errorf("%s: Accepted condition = %v, want reason %s", namespacedName, cond, wantReason)
+		}
+	}
+
+	// the data registered by the extension is released once its KuadrantExtension is deleted
+	service := manager.service.(*extensionService)
+	ctx := withExtensionName(withManifest(context.Background(), &Manifest{PolicyKinds: []string{"PlanPolicy"}, Domains: []string{"DOMAIN_AUTH"}}), "kuadrant-system/plan")
+	policy := &extpb.Policy{
+		Metadata:   &extpb.Metadata{Group: "extensions.kuadrant.io", Kind: "PlanPolicy", Namespace: "default", Name: "plan"},
+		TargetRefs: []*extpb.TargetRef{{Group: "gateway.networking.k8s.io", Kind: "HTTPRoute", Namespace: "default", Name: "toystore"}},
+	}
+	if _, err := service.RegisterMutator(ctx, &extpb.RegisterMutatorRequest{Policy: policy, Domain: extpb.Domain_DOMAIN_AUTH, Binding: "plan", Expression: "self"}); err != nil {
+		t.Fatal(err)
+	}
+	var reasons []string
+	manager.changeNotifier = func(reason string) error {
+		reasons = append(reasons, reason)
+		return nil
+	}
+	emptyTopology, err := machinery.NewTopology()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.ReconcileRemoteExtensions(context.Background(), nil, emptyTopology, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if policies := service.registeredData.GetPoliciesOfKind("PlanPolicy"); len(policies) != 0 {
+		t.Errorf("policies of the removed remote extension = %v, want none", policies)
+	}
+	if len(reasons) != 1 {
+		t.Errorf("reconciliations triggered = %v, want 1", reasons)
+	}
+}
@@ -0,0 +1,386 @@
+/*
+Copyright 2025 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package extension
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/utils/env"
+
+	operatormetrics "github.com/kuadrant/kuadrant-operator/internal/metrics"
+)
+
+type ExtensionState string
+
+const (
+	ExtensionStateRunning      ExtensionState = "Running"
+	ExtensionStateRestarting   ExtensionState = "Restarting"
+	ExtensionStateCrashLooping ExtensionState = "CrashLooping"
+	ExtensionStateStopped      ExtensionState = "Stopped"
+)
+
+// ExtensionHealth is the health of an extension, as observed by its supervisor
+type ExtensionHealth struct {
+	Name      string
+	State     ExtensionState
+	Restarts  int
+	LastError string
+}
+
+func (h ExtensionHealth) Healthy() bool {
+	return h.State == ExtensionStateRunning
+}
+
+var (
+	extensionsHealth   = map[string]ExtensionHealth{}
+	extensionsHealthMu sync.RWMutex
+)
+
+// Health returns the health of the supervised extensions, sorted by name
+func Health() []ExtensionHealth {
+	extensionsHealthMu.RLock()
+	defer extensionsHealthMu.RUnlock()
+	return slices.SortedFunc(maps.Values(extensionsHealth), func(a, b ExtensionHealth) int {
+		if a.Name < b.Name {
+			return -1
+		}
+		if a.Name > b.Name {
+			return 1
+		}
+		return 0
+	})
+}
+
+// SupervisorOptions configures how a supervisor restarts an extension
+type SupervisorOptions struct {
+	// InitialBackoff is the delay before the first restart after a crash, doubled after each consecutive crash
+	InitialBackoff time.Duration
+	// MaxBackoff is the longest delay between restarts
+	MaxBackoff time.Duration
+	// StableAfter is how long an extension must run for its next crash not to be considered consecutive
+	StableAfter time.Duration
+	// CrashLoopThreshold is the number of crashes within CrashLoopWindow for an extension to be crash looping
+	CrashLoopThreshold int
+	CrashLoopWindow    time.Duration
+	// LivenessTimeout is how long an extension can go without calling Ping before it is restarted. Only extensions
+	// that called Ping at least once since they started are checked. Zero disables the check.
+	LivenessTimeout time.Duration
+	// CheckInterval is how often the liveness of the extension is checked
+	CheckInterval time.Duration
+}
+
+const defaultExtensionLivenessTimeout = 30 * time.Second
+
+// DefaultSupervisorOptions returns the default options, with the liveness timeout read from EXTENSIONS_LIVENESS_TIMEOUT
+func DefaultSupervisorOptions(logger logr.Logger) SupervisorOptions {
+	livenessTimeout, err := time.ParseDuration(env.GetString("EXTENSIONS_LIVENESS_TIMEOUT", defaultExtensionLivenessTimeout.String()))
+	if err != nil || livenessTimeout < 0 {
+		logger.Error(err, "invalid EXTENSIONS_LIVENESS_TIMEOUT, using default", "default", defaultExtensionLivenessTimeout)
+		livenessTimeout = defaultExtensionLivenessTimeout
+	}
+	return SupervisorOptions{
+		InitialBackoff:     time.Second,
+		MaxBackoff:         5 * time.Minute,
+		StableAfter:        time.Minute,
+		CrashLoopThreshold: 5,
+		CrashLoopWindow:    10 * time.Minute,
+		LivenessTimeout:    livenessTimeout,
+		CheckInterval:      5 * time.Second,
+	}
+}
+
+// supervisedExtension is an extension running in a process that can exit, and be killed and restarted
+type supervisedExtension interface {
+	Extension
+	Exited() <-chan error
+	LastPing() time.Time
+	Kill() error
+	Ready() <-chan struct{}
+	Drain()
+}
+
+// Supervisor runs an extension, restarting it with an exponential backoff whenever its process exits or stops
+// calling Ping. The health of the extension is reported by Health and by the operator health metrics.
+type Supervisor struct {
+	name      string
+	extension supervisedExtension
+	options   SupervisorOptions
+	logger    logr.Logger
+
+	mu             sync.Mutex
+	changeNotifier ChangeNotifier
+	stopCh         chan struct{}
+	doneCh         chan struct{}
+}
+
+func NewSupervisor(extension supervisedExtension, options SupervisorOptions, logger logr.Logger) *Supervisor {
+	return &Supervisor{
+		name:      extension.Name(),
+		extension: extension,
+		options:   options,
+		logger:    logger.WithName(extension.Name()).WithName("supervisor"),
+	}
+}
+
+func (s *Supervisor) Name() string {
+	return s.name
+}
+
+// SetChangeNotifier sets the function called when the state of the extension changes
+func (s *Supervisor) SetChangeNotifier(notifier ChangeNotifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changeNotifier = notifier
+}
+
+// Start starts the extension and supervises it until Stop is called. An extension that fails to start is restarted
+// like one that crashed, so the error is only logged.
+func (s *Supervisor) Start() error {
+	err := s.extension.Start()
+	health := ExtensionHealth{Name: s.Name(), State: ExtensionStateRunning}
+	if err != nil {
+		s.logger.Error(err, "failed to start extension")
+		health.State = ExtensionStateRestarting
+		health.LastError = err.Error()
+	}
+	s.setHealth(health)
+
+	s.resume(health, err)
+	return nil
+}
+
+func (s *Supervisor) Stop() error {
+	s.pause()
+
+	err := s.extension.Stop()
+	health := s.health()
+	health.State = ExtensionStateStopped
+	s.setHealth(health)
+	return err
+}
+
+// Remove stops the extension and forgets about its health, e.g. once it was removed from the extensions directory
+func (s *Supervisor) Remove() error {
+	err := s.Stop()
+
+	extensionsHealthMu.Lock()
+	delete(extensionsHealth, s.Name())
+	extensionsHealthMu.Unlock()
+	operatormetrics.DeleteExtensionMetrics(s.Name())
+	return err
+}
+
+// Swap replaces the supervised extension with next, e.g. after its binary was upgraded, without a gap: next is started
+// alongside the current extension, which is only drained and stopped once next made its first call to the operator.
+// If next fails to start, exits or is not ready within the timeout, it is stopped and the current extension is kept.
+// The data registered by the extension is keyed by policy, so next takes it over as is.
+func (s *Supervisor) Swap(next supervisedExtension, timeout time.Duration) error {
+	s.pause()
+	health := s.health()
+
+	if err := next.Start(); err != nil {
+		s.abortSwap(next, health)
+		return fmt.Errorf("failed to start the new extension: %w", err)
+	}
+
+	select {
+	case <-next.Ready():
+	case err := <-next.Exited():
+		s.abortSwap(next, health)
+		return fmt.Errorf("the new extension exited before being ready: %w", err)
+	case <-time.After(timeout):
+		s.abortSwap(next, health)
+		return fmt.Errorf("the new extension was not ready within %s", timeout)
+	}
+
+	s.extension.Drain()
+	if err := s.extension.Stop(); err != nil {
+		s.logger.Error(err, "failed to stop the replaced extension")
+	}
+	s.extension = next
+	s.logger.Info("extension replaced")
+
+	health = ExtensionHealth{Name: s.Name(), State: ExtensionStateRunning}
+	s.setHealth(health)
+	s.resume(health, nil)
+	return nil
+}
+
+// abortSwap stops an extension that failed to replace the supervised one, and resumes supervising the latter
+func (s *Supervisor) abortSwap(next supervisedExtension, health ExtensionHealth) {
+	if err := next.Stop(); err != nil {
+		s.logger.Error(err, "failed to stop the new extension")
+	}
+	s.resume(health, nil)
+}
+
+// resume starts supervising the extension
+func (s *Supervisor) resume(health ExtensionHealth, startErr error) {
+	stopCh, doneCh := make(chan struct{}), make(chan struct{})
+	s.mu.Lock()
+	s.stopCh, s.doneCh = stopCh, doneCh
+	s.mu.Unlock()
+
+	go s.supervise(health, startErr, stopCh, doneCh)
+}
+
+// pause stops supervising the extension, leaving it running
+func (s *Supervisor) pause() {
+	s.mu.Lock()
+	stopCh, doneCh := s.stopCh, s.doneCh
+	s.stopCh = nil
+	s.mu.Unlock()
+
+	if stopCh != nil {
+		close(stopCh)
+		<-doneCh
+	}
+}
+
+func (s *Supervisor) supervise(health ExtensionHealth, startErr error, stopCh <-chan struct{}, doneCh chan<- struct{}) {
+	defer close(doneCh)
+
+	ticker := time.NewTicker(s.options.CheckInterval)
+	defer ticker.Stop()
+
+	startedAt := time.Now()
+	running := startErr == nil
+	unresponsive := false
+	consecutiveCrashes := 0
+	var crashes []time.Time
+
+	for {
+		if running {
+			select {
+			case <-stopCh:
+				return
+			case err := <-s.extension.Exited():
+				switch {
+				case unresponsive:
+					unresponsive = false
+				case err != nil:
+					health.LastError = err.Error()
+				default:
+					health.LastError = "exited"
+				}
+			case now := <-ticker.C:
+				if lastPing := s.extension.LastPing(); !unresponsive && s.options.LivenessTimeout > 0 && lastPing.After(startedAt) && now.Sub(lastPing) > s.options.LivenessTimeout {
+					s.logger.Info("extension stopped calling Ping, killing it", "lastPing", lastPing, "timeout", s.options.LivenessTimeout)
+					health.LastError = fmt.Sprintf("no Ping received for %s", s.options.LivenessTimeout)
+					unresponsive = true
+					if err := s.extension.Kill(); err != nil {
+						s.logger.Error(err, "failed to kill unresponsive extension")
+					}
+					// restarted once the process exits
+					continue
+				}
+				// a crash looping extension recovers once it has been running for long enough
+				if health.State == ExtensionStateCrashLooping && now.Sub(startedAt) > s.options.StableAfter {
+					health.State = ExtensionStateRunning
+					s.setHealth(health)
+				}
+				continue
+			}
+		}
+
+		now := time.Now()
+		if now.Sub(startedAt) > s.options.StableAfter {
+			consecutiveCrashes = 0
+		}
+		consecutiveCrashes++
+		crashes = append(slices.DeleteFunc(crashes, func(t time.Time) bool {
+			return now.Sub(t) > s.options.CrashLoopWindow
+		}), now)
+
+		delay := s.backoff(consecutiveCrashes)
+		health.State = ExtensionStateRestarting
+		if len(crashes) >= s.options.CrashLoopThreshold {
+			health.State = ExtensionStateCrashLooping
+			delay = s.options.MaxBackoff
+		}
+		s.logger.Info("extension is not running, restarting", "state", health.State, "delay", delay, "error", health.LastError, "restarts", health.Restarts)
+		s.setHealth(health)
+
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(delay):
+		}
+
+		// release the socket and gRPC server of the previous process
+		if err := s.extension.Stop(); err != nil {
+			s.logger.Error(err, "failed to clean up extension before restarting it")
+		}
+
+		health.Restarts++
+		operatormetrics.SetExtensionRestarts(s.Name(), health.Restarts)
+		startedAt = time.Now()
+		if err := s.extension.Start(); err != nil {
+			s.logger.Error(err, "failed to restart extension")
+			health.LastError = err.Error()
+			running = false
+			continue
+		}
+		running = true
+		if health.State != ExtensionStateCrashLooping {
+			health.State = ExtensionStateRunning
+		}
+		s.setHealth(health)
+	}
+}
+
+// backoff returns the delay before restarting an extension after a number of consecutive crashes
+func (s *Supervisor) backoff(consecutiveCrashes int) time.Duration {
+	delay := s.options.InitialBackoff
+	for i := 1; i < consecutiveCrashes && delay < s.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.options.MaxBackoff)
+}
+
+func (s *Supervisor) health() ExtensionHealth {
+	extensionsHealthMu.RLock()
+	defer extensionsHealthMu.RUnlock()
+	return extensionsHealth[s.Name()]
+}
+
+// setHealth records the health of the extension, notifying when its state changes
+func (s *Supervisor) setHealth(health ExtensionHealth) {
+	extensionsHealthMu.Lock()
+	previous, found := extensionsHealth[health.Name]
+	extensionsHealth[health.Name] = health
+	extensionsHealthMu.Unlock()
+
+	operatormetrics.SetExtensionHealthy(health.Name, health.Healthy())
+
+	if !found || previous.State == health.State || health.State == ExtensionStateStopped {
+		return
+	}
+	s.mu.Lock()
+	notifier := s.changeNotifier
+	s.mu.Unlock()
+	if notifier != nil {
+		if err := notifier(fmt.Sprintf("extension %s %s", health.Name, health.State)); err != nil {
+			s.logger.Error(err, "failed to trigger reconciliation after extension health changed")
+		}
+	}
+}
@@ -0,0 +1,258 @@
+/*
+Copyright 2025 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package extension
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gotest.tools/assert"
+)
+
+type fakeProcessExtension struct {
+	name     string
+	notReady bool
+
+	mu       sync.Mutex
+	starts   int
+	stops    int
+	drained  bool
+	exited   chan error
+	ready    chan struct{}
+	lastPing time.Time
+}
+
+func (f *fakeProcessExtension) Name() string { return f.name }
+
+func (f *fakeProcessExtension) Start() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.starts++
+	f.exited = make(chan error, 1)
+	f.ready = make(chan struct{})
+	if !f.notReady {
+		close(f.ready)
+	}
+	return nil
+}
+
+func (f *fakeProcessExtension) Stop() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stops++
+	return nil
+}
+
+func (f *fakeProcessExtension) Ready() <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ready
+}
+
+func (f *fakeProcessExtension) Drain() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.drained = true
+}
+
+func (f *fakeProcessExtension) Exited() <-chan error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.exited
+}
+
+func (f *fakeProcessExtension) LastPing() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastPing
+}
+
+func (f *fakeProcessExtension) Kill() error {
+	f.crash(errors.New("signal: killed"))
+	return nil
+}
+
+func (f *fakeProcessExtension) crash(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.exited <- err
+}
+
+func (f *fakeProcessExtension) ping() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastPing = time.Now()
+}
+
+func (f *fakeProcessExtension) startCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.starts
+}
+
+func (f *fakeProcessExtension) stopCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stops
+}
+
+func (f *fakeProcessExtension) isDrained() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.drained
+}
+
+func extensionHealth(name string) ExtensionHealth {
+	for _, health := range Health() {
+		if health.Name == name {
+			return health
+		}
+	}
+	return ExtensionHealth{}
+}
+
+func testSupervisorOptions() SupervisorOptions {
+	return SupervisorOptions{
+		InitialBackoff:     10 * time.Millisecond,
+		MaxBackoff:         50 * time.Millisecond,
+		StableAfter:        time.Hour,
+		CrashLoopThreshold: 3,
+		CrashLoopWindow:    time.Hour,
+		LivenessTimeout:    50 * time.Millisecond,
+		CheckInterval:      10 * time.Millisecond,
+	}
+}
+
+func TestSupervisorRestartsCrashedExtension(t *testing.T) {
+	ext := &fakeProcessExtension{name: "test-restart"}
+	var notifications []string
+	var notificationsMu sync.Mutex
+	supervisor := NewSupervisor(ext, testSupervisorOptions(), logr.Discard())
+	supervisor.SetChangeNotifier(func(reason string) error {
+		notificationsMu.Lock()
+		defer notificationsMu.Unlock()
+		notifications = append(notifications, reason)
+		return nil
+	})
+
+	assert.NilError(t, supervisor.Start())
+	assert.Assert(t, extensionHealth("test-restart").Healthy())
+
+	ext.crash(errors.New("exit status 1"))
+	assert.Assert(t, waitFor(func() bool { return ext.startCount() == 2 }), "extension not restarted")
+	assert.Assert(t, waitFor(func() bool { return extensionHealth("test-restart").Healthy() }), "extension not running")
+	health := extensionHealth("test-restart")
+	assert.Equal(t, health.Restarts, 1)
+	assert.Equal(t, health.LastError, "exit status 1")
+
+	// crash loop after 3 crashes within the window
+	ext.crash(errors.New("exit status 1"))
+	assert.Assert(t, waitFor(func() bool { return ext.startCount() == 3 }), "extension not restarted")
+	ext.crash(errors.New("exit status 2"))
+	assert.Assert(t, waitFor(func() bool { return extensionHealth("test-restart").State == ExtensionStateCrashLooping }), "extension not crash looping")
+	assert.Assert(t, !extensionHealth("test-restart").Healthy())
+	assert.Assert(t, waitFor(func() bool { return ext.startCount() == 4 }), "crash looping extension not restarted")
+
+	assert.NilError(t, supervisor.Stop())
+	assert.Equal(t, extensionHealth("test-restart").State, ExtensionStateStopped)
+
+	notificationsMu.Lock()
+	defer notificationsMu.Unlock()
+	assert.Assert(t, len(notifications) > 0)
+}
+
+func TestSupervisorRestartsUnresponsiveExtension(t *testing.T) {
+	ext := &fakeProcessExtension{name: "test-liveness"}
+	supervisor := NewSupervisor(ext, testSupervisorOptions(), logr.Discard())
+	assert.NilError(t, supervisor.Start())
+	defer supervisor.Stop()
+
+	// extensions that never pinged are not checked
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, ext.startCount(), 1)
+
+	ext.ping()
+	assert.Assert(t, waitFor(func() bool { return ext.startCount() == 2 }), "unresponsive extension not restarted")
+	assert.Equal(t, extensionHealth("test-liveness").LastError, "no Ping received for 50ms")
+}
+
+func TestSupervisorSwap(t *testing.T) {
+	current := &fakeProcessExtension{name: "test-swap"}
+	supervisor := NewSupervisor(current, testSupervisorOptions(), logr.Discard())
+	assert.NilError(t, supervisor.Start())
+	defer supervisor.Stop()
+
+	next := &fakeProcessExtension{name: "test-swap"}
+	assert.NilError(t, supervisor.Swap(next, time.Second))
+	assert.Assert(t, current.isDrained())
+	assert.Equal(t, current.stopCount(), 1)
+	assert.Equal(t, next.startCount(), 1)
+	assert.Assert(t, extensionHealth("test-swap").Healthy())
+
+	// the new extension is supervised
+	next.crash(errors.New("exit status 1"))
+	assert.Assert(t, waitFor(func() bool { return next.startCount() == 2 }), "new extension not restarted")
+	assert.Equal(t, current.startCount(), 1)
+}
+
+func TestSupervisorSwapNotReady(t *testing.T) {
+	current := &fakeProcessExtension{name: "test-swap-not-ready"}
+	supervisor := NewSupervisor(current, testSupervisorOptions(), logr.Discard())
+	assert.NilError(t, supervisor.Start())
+	defer supervisor.Stop()
+
+	next := &fakeProcessExtension{name: "test-swap-not-ready", notReady: true}
+	assert.ErrorContains(t, supervisor.Swap(next, 50*time.Millisecond), "not ready within 50ms")
+	assert.Equal(t, next.stopCount(), 1)
+	assert.Assert(t, !current.isDrained())
+	assert.Equal(t, current.stopCount(), 0)
+
+	// the running extension is still supervised
+	current.crash(errors.New("exit status 1"))
+	assert.Assert(t, waitFor(func() bool { return current.startCount() == 2 }), "running extension not restarted")
+}
+
+func TestSupervisorRemove(t *testing.T) {
+	supervisor := NewSupervisor(&fakeProcessExtension{name: "test-remove"}, testSupervisorOptions(), logr.Discard())
+	assert.NilError(t, supervisor.Start())
+	assert.Equal(t, extensionHealth("test-remove").State, ExtensionStateRunning)
+
+	assert.NilError(t, supervisor.Remove())
+	assert.Equal(t, extensionHealth("test-remove").Name, "")
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	supervisor := NewSupervisor(&fakeProcessExtension{name: "test-backoff"}, SupervisorOptions{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}, logr.Discard())
+	assert.Equal(t, supervisor.backoff(1), time.Second)
+	assert.Equal(t, supervisor.backoff(2), 2*time.Second)
+	assert.Equal(t, supervisor.backoff(4), 8*time.Second)
+	assert.Equal(t, supervisor.backoff(5), 10*time.Second)
+	assert.Equal(t, supervisor.backoff(100), 10*time.Second)
+}
+
+func waitFor(condition func() bool) bool {
+	for range 200 {
+		if condition() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
@@ -0,0 +1,324 @@
+/*
+Copyright 2025 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package extension
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	extcontroller "github.com/kuadrant/kuadrant-operator/pkg/extension/controller"
+)
+
+const (
+	// defaultExtensionSwapTimeout is how long an upgraded extension has to make its first call to the operator before
+	// the upgrade is abandoned and the running extension kept
+	defaultExtensionSwapTimeout = time.Minute
+
+	// extensionsReloadDelay is how long the extensions directory must be left untouched before reloading the
+	// extensions, so that binaries being copied are not started half written
+	extensionsReloadDelay = 2 * time.Second
+
+	// extensionPoliciesReleaseTimeout bounds the removal of the finalizers of the policies of the removed extensions
+	extensionPoliciesReleaseTimeout = time.Minute
+)
+
+// extensionVersion identifies a version of the executable and manifest of an extension
+type extensionVersion struct {
+	size            int64
+	modTime         time.Time
+	manifestModTime time.Time
+}
+
+func getExtensionVersion(location, name string) (extensionVersion, error) {
+	stat, err := os.Stat(filepath.Join(location, name, name))
+	if err != nil {
+		return extensionVersion{}, err
+	}
+	version := extensionVersion{size: stat.Size(), modTime: stat.ModTime()}
+	if stat, err := os.Stat(filepath.Join(location, name, ManifestFileName)); err == nil {
+		version.manifestModTime = stat.ModTime()
+	}
+	return version, nil
+}
+
+// watchExtensions watches the extensions directory, and the directory of each extension, reloading the extensions
+// whenever they change
+func (m *Manager) watchExtensions() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(m.location); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	entries, err := os.ReadDir(m.location)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			m.watchExtensionDir(watcher, filepath.Join(m.location, entry.Name()))
+		}
+	}
+
+	m.watcher = watcher
+	m.watcherDone = make(chan struct{})
+	go m.watch(watcher, m.watcherDone)
+
+	m.logger.Info("watching extensions directory", "directory", m.location)
+	return nil
+}
+
+func (m *Manager) watchExtensionDir(watcher *fsnotify.Watcher, dir string) {
+	if err := watcher.Add(dir); err != nil {
+		m.logger.Error(err, "failed to watch extension directory", "directory", dir)
+	}
+}
+
+func (m *Manager) watch(watcher *fsnotify.Watcher, done chan<- struct{}) {
+	defer close(done)
+
+	location := filepath.Clean(m.location)
+	var reload <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Create) && filepath.Dir(event.Name) == location {
+				if stat, err := os.Stat(event.Name); err == nil && stat.IsDir() {
+					m.watchExtensionDir(watcher, event.Name)
+				}
+			}
+			reload = time.After(extensionsReloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			m.logger.Error(err, "error watching extensions directory")
+		case <-reload:
+			reload = nil
+			m.reloadExtensions()
+		}
+	}
+}
+
+func (m *Manager) stopWatchingExtensions() {
+	if m.watcher == nil {
+		return
+	}
+	if err := m.watcher.Close(); err != nil {
+		m.logger.Error(err, "failed to stop watching extensions directory")
+	}
+	<-m.watcherDone
+	m.watcher = nil
+}
+
+// reloadExtensions starts the extensions added to the extensions directory, gracefully stops the ones removed from
+// it, and replaces the ones whose executable or manifest changed with a blue/green swap.
+// The finalizers of the policies released by the removed extensions are removed once the extensions are reloaded,
+// without holding the lock of the extensions.
+func (m *Manager) reloadExtensions() {
+	names := discoverExtensions(m.logger, m.location)
+
+	m.extensionsMu.Lock()
+	releasedKinds := m.syncExtensions(names)
+	m.extensionsMu.Unlock()
+
+	m.releasePolicies(context.Background(), releasedKinds)
+}
+
+// syncExtensions reconciles the managed extensions with the extensions found in the extensions directory, returning
+// the kinds of the policies no longer handled by any extension. The lock of the extensions must be held.
+func (m *Manager) syncExtensions(names []string) []schema.GroupKind {
+	var changes []string
+	var releasedKinds []schema.GroupKind
+
+	m.extensions = slices.DeleteFunc(m.extensions, func(extension Extension) bool {
+		if slices.Contains(names, extension.Name()) {
+			return false
+		}
+		m.logger.Info("extension removed, stopping it", "name", extension.Name())
+		stop := extension.Stop
+		if supervisor, ok := extension.(*Supervisor); ok {
+			stop = supervisor.Remove
+		}
+		if err := stop(); err != nil {
+			m.logger.Error(err, "failed to stop removed extension", "name", extension.Name())
+		}
+		releasedKinds = append(releasedKinds, m.releaseExtension(extension.Name())...)
+		delete(m.versions, extension.Name())
+		changes = append(changes, fmt.Sprintf("%s removed", extension.Name()))
+		return true
+	})
+
+	for _, name := range names {
+		version, err := getExtensionVersion(m.location, name)
+		if err != nil {
+			m.logger.Error(err, "failed to read extension executable", "name", name)
+			continue
+		}
+
+		index := slices.IndexFunc(m.extensions, func(extension Extension) bool { return extension.Name() == name })
+		if index < 0 {
+			if m.addExtension(name) {
+				m.versions[name] = version
+				changes = append(changes, fmt.Sprintf("%s added", name))
+			}
+			continue
+		}
+
+		if m.versions[name] == version {
+			continue
+		}
+		supervisor, ok := m.extensions[index].(*Supervisor)
+		if !ok {
+			continue
+		}
+		if m.upgradeExtension(supervisor) {
+			m.versions[name] = version
+		}
+	}
+
+	if len(changes) > 0 && m.changeNotifier != nil {
+		if err := m.changeNotifier(fmt.Sprintf("extensions %s", strings.Join(changes, ", "))); err != nil {
+			m.logger.Error(err, "failed to trigger reconciliation after extensions changed")
+		}
+	}
+
+	return releasedKinds
+}
+
+// releaseExtension clears the data registered for the policies handled by a removed extension, i.e. its mutators,
+// subscriptions, upstreams and pipeline actions, returning the kinds of the policies that no other extension handles.
+// The finalizer the extensions added to the policies of these kinds must then be removed (see releasePolicies), as no
+// extension is around to clean them up.
+func (m *Manager) releaseExtension(name string) []schema.GroupKind {
+	service, ok := m.service.(*extensionService)
+	if !ok {
+		return nil
+	}
+	return service.releaseExtension(name)
+}
+
+// releasePolicies removes the finalizer of the extensions from the policies of the given kinds
+func (m *Manager) releasePolicies(ctx context.Context, kinds []schema.GroupKind) {
+	if len(kinds) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, extensionPoliciesReleaseTimeout)
+	defer cancel()
+	for _, kind := range kinds {
+		if err := m.removePolicyFinalizers(ctx, kind); err != nil {
+			m.logger.Error(err, "failed to release the policies of removed extension", "kind", kind.String())
+		}
+	}
+}
+
+// removePolicyFinalizers removes the finalizer of the extensions from the policies of a kind
+func (m *Manager) removePolicyFinalizers(ctx context.Context, kind schema.GroupKind) error {
+	if m.client == nil || m.restMapper == nil {
+		return nil
+	}
+	mapping, err := m.restMapper.RESTMapping(kind)
+	if err != nil {
+		return err
+	}
+	resource := m.client.Resource(mapping.Resource)
+	policies, err := resource.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		if !controllerutil.ContainsFinalizer(policy, extcontroller.ExtensionFinalizer) {
+			continue
+		}
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			if !controllerutil.RemoveFinalizer(policy, extcontroller.ExtensionFinalizer) {
+				return nil
+			}
+			_, updateErr := resource.Namespace(policy.GetNamespace()).Update(ctx, policy, metav1.UpdateOptions{})
+			if apierrors.IsConflict(updateErr) {
+				// retried with the latest version of the policy
+				latest, getErr := resource.Namespace(policy.GetNamespace()).Get(ctx, policy.GetName(), metav1.GetOptions{})
+				if getErr != nil {
+					return getErr
+				}
+				policy = latest
+			}
+			return updateErr
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) addExtension(name string) bool {
+	oopExtension, err := NewOOPExtension(name, m.location, m.service, m.logger, m.sync)
+	if err != nil {
+		m.logger.Error(err, "failed to add extension", "name", name)
+		return false
+	}
+	m.logger.Info("extension added, starting it", "name", name)
+	supervisor := NewSupervisor(&oopExtension, m.supervisorOptions, m.logger)
+	supervisor.SetChangeNotifier(m.changeNotifier)
+	// failures to start are retried by the supervisor
+	_ = supervisor.Start()
+	m.extensions = append(m.extensions, supervisor)
+	return true
+}
+
+// upgradeExtension replaces a running extension with a process of its new executable or manifest, serving the latter
+// on its own socket while both run
+func (m *Manager) upgradeExtension(supervisor *Supervisor) bool {
+	name := supervisor.Name()
+	oopExtension, err := NewOOPExtension(name, m.location, m.service, m.logger, m.sync)
+	if err != nil {
+		m.logger.Error(err, "failed to upgrade extension", "name", name)
+		return false
+	}
+	m.generation++
+	oopExtension.socket = extensionSocket(name, m.generation)
+
+	m.logger.Info("extension executable changed, replacing it", "name", name)
+	if err := supervisor.Swap(&oopExtension, m.swapTimeout); err != nil {
+		m.logger.Error(err, "failed to upgrade extension, keeping the running one", "name", name)
+		return false
+	}
+	return true
+}
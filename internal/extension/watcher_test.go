@@ -0,0 +1,240 @@
+/*
+Copyright 2025 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package extension
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	extcontroller "github.com/kuadrant/kuadrant-operator/pkg/extension/controller"
+	extpb "github.com/kuadrant/kuadrant-operator/pkg/extension/grpc/v1"
+)
+
+// writeExtension writes an executable that never calls the operator, so it can be started but never becomes ready,
+// and its manifest
+func writeExtension(t *testing.T, location, name string) {
+	t.Helper()
+	assert.NilError(t, os.MkdirAll(filepath.Join(location, name), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(location, name, name), []byte("#!/bin/sh\nexec sleep 30\n"), 0755)) // #nosec G306
+	assert.NilError(t, os.WriteFile(filepath.Join(location, name, ManifestFileName), []byte("policyKinds: [PlanPolicy]\ndomains: [DOMAIN_AUTH]\nactionTypes: [ACTION_TYPE_DENY]\n"), 0600))
+}
+
+func newTestManager(location string) *Manager {
+	return &Manager{
+		service:           newExtensionService(nil, logr.Discard()),
+		logger:            logr.Discard(),
+		sync:              io.Discard,
+		location:          location,
+		supervisorOptions: testSupervisorOptions(),
+		swapTimeout:       100 * time.Millisecond,
+		versions:          map[string]extensionVersion{},
+	}
+}
+
+func managedExtensions(m *Manager) []string {
+	m.extensionsMu.Lock()
+	defer m.extensionsMu.Unlock()
+	names := make([]string, 0, len(m.extensions))
+	for _, extension := range m.extensions {
+		names = append(names, extension.Name())
+	}
+	return names
+}
+
+func TestManagerReloadExtensions(t *testing.T) {
+	location := t.TempDir()
+	manager := newTestManager(location)
+	var reasons []string
+	var reasonsMu sync.Mutex
+	manager.SetChangeNotifier(func(reason string) error {
+		reasonsMu.Lock()
+		defer reasonsMu.Unlock()
+		reasons = append(reasons, reason)
+		return nil
+	})
+	defer manager.Stop()
+
+	writeExtension(t, location, "test-reload-added")
+	manager.reloadExtensions()
+	assert.DeepEqual(t, managedExtensions(manager), []string{"test-reload-added"})
+	assert.Assert(t, extensionHealth("test-reload-added").Healthy())
+
+	// an upgrade that never becomes ready keeps the running extension
+	supervisor := manager.extensions[0].(*Supervisor)
+	running := supervisor.extension
+	time.Sleep(10 * time.Millisecond)
+	writeExtension(t, location, "test-reload-added")
+	assert.NilError(t, os.Chtimes(filepath.Join(location, "test-reload-added", "test-reload-added"), time.Now(), time.Now().Add(time.Minute)))
+	manager.reloadExtensions()
+	assert.Equal(t, supervisor.extension, running)
+	assert.Assert(t, extensionHealth("test-reload-added").Healthy())
+
+	assert.NilError(t, os.RemoveAll(filepath.Join(location, "test-reload-added")))
+	manager.reloadExtensions()
+	assert.DeepEqual(t, managedExtensions(manager), []string{})
+	assert.Equal(t, extensionHealth("test-reload-added").Name, "")
+
+	reasonsMu.Lock()
+	defer reasonsMu.Unlock()
+	assert.DeepEqual(t, reasons, []string{"extensions test-reload-added added", "extensions test-reload-added removed"})
+}
+
+func TestManagerReloadExtensionsReleasesRemovedExtension(t *testing.T) {
+	location := t.TempDir()
+	manager := newTestManager(location)
+	defer manager.Stop()
+
+	planPolicyKind := schema.GroupKind{Group: "extensions.kuadrant.io", Kind: "PlanPolicy"}
+	planPoliciesResource := schema.GroupVersionResource{Group: planPolicyKind.Group, Version: "v1alpha1", Resource: "planpolicies"}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{planPoliciesResource.GroupVersion()})
+	mapper.Add(planPolicyKind.WithVersion("v1alpha1"), meta.RESTScopeNamespace)
+	planPolicy := &unstructured.Unstructured{}
+	planPolicy.SetGroupVersionKind(planPolicyKind.WithVersion("v1alpha1"))
+	planPolicy.SetNamespace("default")
+	planPolicy.SetName("plan")
+	planPolicy.SetFinalizers([]string{extcontroller.ExtensionFinalizer})
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{planPoliciesResource: "PlanPolicyList"}, planPolicy)
+	manager.client = client
+	manager.SetRESTMapper(mapper)
+
+	writeExtension(t, location, "test-release")
+	manager.reloadExtensions()
+	assert.DeepEqual(t, managedExtensions(manager), []string{"test-release"})
+
+	// the extension registers a mutator and pipeline actions for the policy
+	service := manager.service.(*extensionService)
+	manifest := &Manifest{PolicyKinds: []string{"PlanPolicy"}, Domains: []string{"DOMAIN_AUTH"}, ActionTypes: []string{"ACTION_TYPE_DENY"}}
+	ctx := withExtensionName(withManifest(context.Background(), manifest), "test-release")
+	policy := &extpb.Policy{
+		Metadata:   &extpb.Metadata{Group: planPolicyKind.Group, Kind: planPolicyKind.Kind, Namespace: "default", Name: "plan"},
+		TargetRefs: []*extpb.TargetRef{{Group: "gateway.networking.k8s.io", Kind: "HTTPRoute", Namespace: "default", Name: "toystore"}},
+	}
+	_, err := service.RegisterMutator(ctx, &extpb.RegisterMutatorRequest{Policy: policy, Domain: extpb.Domain_DOMAIN_AUTH, Binding: "plan", Expression: "self"})
+	assert.NilError(t, err)
+	_, err = service.PipelineCommit(ctx, &extpb.PipelineCommitRequest{Policy: policy, Actions: []*extpb.ActionEntry{
+		{ActionType: extpb.ActionType_ACTION_TYPE_DENY, Phase: "request", WithStatus: 403},
+	}})
+	assert.NilError(t, err)
+	planPolicyID := ResourceID{Kind: planPolicyKind.Kind, Namespace: "default", Name: "plan"}
+	assert.DeepEqual(t, service.registeredData.GetPoliciesOfKind(planPolicyKind.Kind), []ResourceID{planPolicyID})
+
+	assert.NilError(t, os.RemoveAll(filepath.Join(location, "test-release")))
+	manager.reloadExtensions()
+	assert.DeepEqual(t, managedExtensions(manager), []string{})
+
+	// the data registered for the policy is cleared and its finalizer released
+	assert.DeepEqual(t, service.registeredData.GetPoliciesOfKind(planPolicyKind.Kind), []ResourceID{})
+	assert.Equal(t, len(service.registeredData.GetPipelineActions(planPolicyID, PipelinePhaseRequest)), 0)
+	released, err := client.Resource(planPoliciesResource).Namespace("default").Get(context.Background(), "plan", metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(released.GetFinalizers()), 0)
+}
+
+func TestManagerReloadExtensionsKeepsPoliciesHandledByOtherExtensions(t *testing.T) {
+	location := t.TempDir()
+	manager := newTestManager(location)
+	defer manager.Stop()
+
+	planPolicyKind := schema.GroupKind{Group: "extensions.kuadrant.io", Kind: "PlanPolicy"}
+	planPoliciesResource := schema.GroupVersionResource{Group: planPolicyKind.Group, Version: "v1alpha1", Resource: "planpolicies"}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{planPoliciesResource.GroupVersion()})
+	mapper.Add(planPolicyKind.WithVersion("v1alpha1"), meta.RESTScopeNamespace)
+	planPolicy := &unstructured.Unstructured{}
+	planPolicy.SetGroupVersionKind(planPolicyKind.WithVersion("v1alpha1"))
+	planPolicy.SetNamespace("default")
+	planPolicy.SetName("plan")
+	planPolicy.SetFinalizers([]string{extcontroller.ExtensionFinalizer})
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{planPoliciesResource: "PlanPolicyList"}, planPolicy)
+	manager.client = client
+	manager.SetRESTMapper(mapper)
+
+	writeExtension(t, location, "test-release")
+	writeExtension(t, location, "test-remaining")
+	manager.reloadExtensions()
+	assert.DeepEqual(t, managedExtensions(manager), []string{"test-release", "test-remaining"})
+
+	// both extensions register a mutator for the policy
+	service := manager.service.(*extensionService)
+	manifest := &Manifest{PolicyKinds: []string{"PlanPolicy"}, Domains: []string{"DOMAIN_AUTH"}}
+	policy := &extpb.Policy{
+		Metadata:   &extpb.Metadata{Group: planPolicyKind.Group, Kind: planPolicyKind.Kind, Namespace: "default", Name: "plan"},
+		TargetRefs: []*extpb.TargetRef{{Group: "gateway.networking.k8s.io", Kind: "HTTPRoute", Namespace: "default", Name: "toystore"}},
+	}
+	for _, name := range []string{"test-release", "test-remaining"} {
+		ctx := withExtensionName(withManifest(context.Background(), manifest), name)
+		_, err := service.RegisterMutator(ctx, &extpb.RegisterMutatorRequest{Policy: policy, Domain: extpb.Domain_DOMAIN_AUTH, Binding: name, Expression: "self"})
+		assert.NilError(t, err)
+	}
+
+	assert.NilError(t, os.RemoveAll(filepath.Join(location, "test-release")))
+	manager.reloadExtensions()
+	assert.DeepEqual(t, managedExtensions(manager), []string{"test-remaining"})
+
+	// the policy is still handled by the remaining extension
+	planPolicyID := ResourceID{Kind: planPolicyKind.Kind, Namespace: "default", Name: "plan"}
+	assert.DeepEqual(t, service.registeredData.GetPoliciesOfKind(planPolicyKind.Kind), []ResourceID{planPolicyID})
+	kept, err := client.Resource(planPoliciesResource).Namespace("default").Get(context.Background(), "plan", metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, kept.GetFinalizers(), []string{extcontroller.ExtensionFinalizer})
+}
+
+func TestManagerReloadExtensionsWithoutManifest(t *testing.T) {
+	location := t.TempDir()
+	manager := newTestManager(location)
+	defer manager.Stop()
+
+	writeExtension(t, location, "test-no-manifest")
+	assert.NilError(t, os.Remove(filepath.Join(location, "test-no-manifest", ManifestFileName)))
+	manager.reloadExtensions()
+	assert.DeepEqual(t, managedExtensions(manager), []string{})
+}
+
+func TestManagerWatchExtensions(t *testing.T) {
+	location := t.TempDir()
+	manager := newTestManager(location)
+	assert.NilError(t, manager.watchExtensions())
+	defer manager.Stop()
+
+	writeExtension(t, location, "test-watch")
+	assert.Assert(t, waitForDuration(func() bool { return len(managedExtensions(manager)) == 1 }, 2*extensionsReloadDelay), "added extension not started")
+
+	assert.NilError(t, os.RemoveAll(filepath.Join(location, "test-watch")))
+	assert.Assert(t, waitForDuration(func() bool { return len(managedExtensions(manager)) == 0 }, 2*extensionsReloadDelay), "removed extension not stopped")
+}
+
+func waitForDuration(condition func() bool, timeout time.Duration) bool {
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+	return false
+}
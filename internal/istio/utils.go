@@ -2,7 +2,6 @@ package istio
 
 import (
 	"encoding/json"
-	"fmt"
 
 	"github.com/kuadrant/policy-machinery/controller"
 	"github.com/kuadrant/policy-machinery/machinery"
@@ -20,6 +19,7 @@ import (
 	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
 
 	kuadrantv1beta1 "github.com/kuadrant/kuadrant-operator/api/v1beta1"
+	kuadrantenvoy "github.com/kuadrant/kuadrant-operator/internal/envoy"
 	"github.com/kuadrant/kuadrant-operator/internal/utils"
 )
 
@@ -66,16 +66,16 @@ func BuildEnvoyFilterClusterPatch(host string, port int, mtls bool, clusterPatch
 
 // BuildEnvoyFilterWasmPatch returns an envoy config patch that adds a wasm HTTP filter to the gateway.
 func BuildEnvoyFilterWasmPatch(wasmURL, imagePullSecret, imageSHA, clusterName string, pluginConfig *structpb.Struct) ([]*istioapinetworkingv1alpha3.EnvoyFilter_EnvoyConfigObjectPatch, error) {
-	wasmFilterConfig, err := buildWasmFilterConfig(wasmURL, imagePullSecret, imageSHA, clusterName, pluginConfig)
+	wasmFilterConfig, err := kuadrantenvoy.BuildWasmFilterConfig(wasmURL, imagePullSecret, imageSHA, clusterName, pluginConfig)
 	if err != nil {
 		return nil, err
 	}
 
 	patchValue := map[string]any{
-		"name": "envoy.filters.http.wasm",
+		"name": kuadrantenvoy.WasmFilterName,
 		"typed_config": map[string]any{
 			"@type":    "type.googleapis.com/udpa.type.v1.TypedStruct",
-			"type_url": "type.googleapis.com/envoy.extensions.filters.http.wasm.v3.Wasm",
+			"type_url": kuadrantenvoy.WasmTypeURL,
 			"value":    wasmFilterConfig,
 		},
 	}
@@ -112,52 +112,72 @@ func BuildEnvoyFilterWasmPatch(wasmURL, imagePullSecret, imageSHA, clusterName s
 	}, nil
 }
 
-// buildWasmFilterConfig builds the Envoy wasm filter configuration
-func buildWasmFilterConfig(wasmURL, imagePullSecret, imageSHA, clusterName string, pluginConfig *structpb.Struct) (map[string]any, error) {
-	config := map[string]any{
-		"name":    "kuadrant-wasm-shim",
-		"root_id": "kuadrant_wasm_shim",
-		"vm_config": map[string]any{
-			"runtime": "envoy.wasm.runtime.v8",
-			"code": map[string]any{
-				"remote": map[string]any{
-					"http_uri": map[string]any{
-						"uri":     wasmURL,
-						"timeout": "10s",
-						"cluster": clusterName,
+// BuildEnvoyFilterHTTPFiltersPatch returns envoy config patches that add HTTP filters to the gateway, right before
+// the router and in the given order.
+func BuildEnvoyFilterHTTPFiltersPatch(filters []map[string]any) ([]*istioapinetworkingv1alpha3.EnvoyFilter_EnvoyConfigObjectPatch, error) {
+	patches := make([]*istioapinetworkingv1alpha3.EnvoyFilter_EnvoyConfigObjectPatch, 0, len(filters))
+	for _, filter := range filters {
+		patchRaw, _ := json.Marshal(map[string]any{
+			"operation": "INSERT_BEFORE",
+			"value":     filter,
+		})
+		patch := &istioapinetworkingv1alpha3.EnvoyFilter_Patch{}
+		if err := patch.UnmarshalJSON(patchRaw); err != nil {
+			return nil, err
+		}
+		patches = append(patches, &istioapinetworkingv1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
+			ApplyTo: istioapinetworkingv1alpha3.EnvoyFilter_HTTP_FILTER,
+			Match: &istioapinetworkingv1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+				Context: istioapinetworkingv1alpha3.EnvoyFilter_GATEWAY,
+				ObjectTypes: &istioapinetworkingv1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
+					Listener: &istioapinetworkingv1alpha3.EnvoyFilter_ListenerMatch{
+						FilterChain: &istioapinetworkingv1alpha3.EnvoyFilter_ListenerMatch_FilterChainMatch{
+							Filter: &istioapinetworkingv1alpha3.EnvoyFilter_ListenerMatch_FilterMatch{
+								Name: "envoy.filters.network.http_connection_manager",
+								SubFilter: &istioapinetworkingv1alpha3.EnvoyFilter_ListenerMatch_SubFilterMatch{
+									Name: "envoy.filters.http.router",
+								},
+							},
+						},
 					},
-					"sha256": imageSHA,
 				},
 			},
-			"allow_precompiled": true,
-		},
-		"allow_on_headers_stop_iteration": true,
-	}
-
-	if pluginConfig != nil {
-		configJSON, err := pluginConfig.MarshalJSON()
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal plugin config: %w", err)
-		}
-		config["configuration"] = map[string]any{
-			"@type": "type.googleapis.com/google.protobuf.StringValue",
-			"value": string(configJSON),
-		}
+			Patch: patch,
+		})
 	}
+	return patches, nil
+}
 
-	// Add image pull secret if provided
-	if imagePullSecret != "" {
-		if vmConfig, ok := config["vm_config"].(map[string]any); ok {
-			if code, ok := vmConfig["code"].(map[string]any); ok {
-				if remote, ok := code["remote"].(map[string]any); ok {
-					remote["image_pull_secret"] = imagePullSecret
-				}
-			}
-		}
+// BuildEnvoyFilterRoutePatch returns an envoy config patch that merges the given typed_per_filter_config into the
+// routes of the gateway with a given name, in the route configurations of a given port.
+func BuildEnvoyFilterRoutePatch(portNumber uint32, routeName string, perFilterConfig map[string]any) (*istioapinetworkingv1alpha3.EnvoyFilter_EnvoyConfigObjectPatch, error) {
+	patchRaw, _ := json.Marshal(map[string]any{
+		"operation": "MERGE",
+		"value": map[string]any{
+			"typed_per_filter_config": perFilterConfig,
+		},
+	})
+	patch := &istioapinetworkingv1alpha3.EnvoyFilter_Patch{}
+	if err := patch.UnmarshalJSON(patchRaw); err != nil {
+		return nil, err
 	}
 
-	return map[string]any{
-		"config": config,
+	return &istioapinetworkingv1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
+		ApplyTo: istioapinetworkingv1alpha3.EnvoyFilter_HTTP_ROUTE,
+		Match: &istioapinetworkingv1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+			Context: istioapinetworkingv1alpha3.EnvoyFilter_GATEWAY,
+			ObjectTypes: &istioapinetworkingv1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_RouteConfiguration{
+				RouteConfiguration: &istioapinetworkingv1alpha3.EnvoyFilter_RouteConfigurationMatch{
+					PortNumber: portNumber,
+					Vhost: &istioapinetworkingv1alpha3.EnvoyFilter_RouteConfigurationMatch_VirtualHostMatch{
+						Route: &istioapinetworkingv1alpha3.EnvoyFilter_RouteConfigurationMatch_RouteMatch{
+							Name: routeName,
+						},
+					},
+				},
+			},
+		},
+		Patch: patch,
 	}, nil
 }
 
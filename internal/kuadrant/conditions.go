@@ -8,6 +8,7 @@ import (
 	"sort"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 )
 
@@ -20,6 +21,8 @@ const (
 	PolicyReasonMissingDependency    gatewayapiv1alpha2.PolicyConditionReason = "MissingDependency"
 	PolicyReasonMissingResource      gatewayapiv1alpha2.PolicyConditionReason = "MissingResource"
 	PolicyReasonInvalidCelExpression gatewayapiv1alpha2.PolicyConditionReason = "InvalidCelExpression"
+	PolicyReasonShadow               gatewayapiv1alpha2.PolicyConditionReason = "Shadow"
+	PolicyReasonUnsupportedFeature   gatewayapiv1alpha2.PolicyConditionReason = "UnsupportedFeature"
 )
 
 // ConditionMarshal marshals the set of conditions as a JSON array, sorted by condition type.
@@ -80,3 +83,25 @@ func EnforcedCondition(policy Policy, err PolicyError, fully bool) *metav1.Condi
 
 	return cond
 }
+
+// ShadowCondition returns the enforced condition of a kuadrant policy in shadow mode, i.e. whose decisions
+// are evaluated and reported by the data plane but never enforced
+func ShadowCondition(policy Policy) *metav1.Condition {
+	return &metav1.Condition{
+		Type:    string(PolicyConditionEnforced),
+		Status:  metav1.ConditionFalse,
+		Reason:  string(PolicyReasonShadow),
+		Message: fmt.Sprintf("%s is in shadow mode: its decisions are reported but not enforced", policy.Kind()),
+	}
+}
+
+// ShadowEnforcedCondition returns the enforced condition of a kuadrant policy in shadow mode whose rules are
+// nonetheless enforced, because the data plane merges them with the rules of other policies not in shadow mode
+func ShadowEnforcedCondition(policy Policy, enforcingPolicies []k8stypes.NamespacedName) *metav1.Condition {
+	return &metav1.Condition{
+		Type:    string(PolicyConditionEnforced),
+		Status:  metav1.ConditionTrue,
+		Reason:  string(PolicyReasonEnforced),
+		Message: fmt.Sprintf("%s is in shadow mode, but its rules are enforced where merged with policies not in shadow mode: %s", policy.Kind(), enforcingPolicies),
+	}
+}
@@ -12,6 +12,7 @@ import (
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	gatewayapiv1 "sigs.k8s.io/gateway-api/apis/v1"
 	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
@@ -284,3 +285,27 @@ func TestEnforcedCondition(t *testing.T) {
 		})
 	}
 }
+
+func TestShadowCondition(t *testing.T) {
+	want := &metav1.Condition{
+		Type:    string(PolicyConditionEnforced),
+		Status:  metav1.ConditionFalse,
+		Reason:  string(PolicyReasonShadow),
+		Message: "FakePolicy is in shadow mode: its decisions are reported but not enforced",
+	}
+	if got := ShadowCondition(&FakePolicy{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("ShadowCondition() = %v, want %v", got, want)
+	}
+}
+
+func TestShadowEnforcedCondition(t *testing.T) {
+	want := &metav1.Condition{
+		Type:    string(PolicyConditionEnforced),
+		Status:  metav1.ConditionTrue,
+		Reason:  string(PolicyReasonEnforced),
+		Message: "FakePolicy is in shadow mode, but its rules are enforced where merged with policies not in shadow mode: [ns1/policy1]",
+	}
+	if got := ShadowEnforcedCondition(&FakePolicy{}, []k8stypes.NamespacedName{{Namespace: "ns1", Name: "policy1"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("ShadowEnforcedCondition() = %v, want %v", got, want)
+	}
+}
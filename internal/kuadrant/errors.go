@@ -274,3 +274,49 @@ func (e ErrCelValidation) Error() string {
 func (e ErrCelValidation) Reason() gatewayapiv1alpha2.PolicyConditionReason {
 	return PolicyReasonInvalidCelExpression
 }
+
+var _ PolicyError = ErrUnsupportedFeature{}
+
+type ErrUnsupportedFeature struct {
+	Kind     string
+	Features []string
+}
+
+func (e ErrUnsupportedFeature) Error() string {
+	return fmt.Sprintf("%s uses features not supported by the native data plane: %s", e.Kind, strings.Join(e.Features, ", "))
+}
+
+func (e ErrUnsupportedFeature) Reason() gatewayapiv1alpha2.PolicyConditionReason {
+	return PolicyReasonUnsupportedFeature
+}
+
+func NewErrUnsupportedFeature(kind string, features []string) ErrUnsupportedFeature {
+	return ErrUnsupportedFeature{
+		Kind:     kind,
+		Features: features,
+	}
+}
+
+var _ PolicyError = ErrUnsupportedShimFeature{}
+
+type ErrUnsupportedShimFeature struct {
+	Kind    string
+	Usage   string
+	Feature string
+}
+
+func (e ErrUnsupportedShimFeature) Error() string {
+	return fmt.Sprintf("%s uses %s, which requires the %s feature of the wasm-shim, not declared in WASM_SHIM_FEATURES", e.Kind, e.Usage, e.Feature)
+}
+
+func (e ErrUnsupportedShimFeature) Reason() gatewayapiv1alpha2.PolicyConditionReason {
+	return PolicyReasonUnsupportedFeature
+}
+
+func NewErrUnsupportedShimFeature(kind, usage, feature string) ErrUnsupportedShimFeature {
+	return ErrUnsupportedShimFeature{
+		Kind:    kind,
+		Usage:   usage,
+		Feature: feature,
+	}
+}
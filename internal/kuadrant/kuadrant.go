@@ -1,6 +1,8 @@
 package kuadrant
 
 import (
+	"github.com/kuadrant/policy-machinery/machinery"
+
 	kuadrantgatewayapi "github.com/kuadrant/kuadrant-operator/internal/gatewayapi"
 )
 
@@ -19,3 +21,14 @@ type Policy interface {
 	kuadrantgatewayapi.Policy
 	Kind() string
 }
+
+// ShadowablePolicy is a policy whose decisions can be reported by the data plane without being enforced
+type ShadowablePolicy interface {
+	InShadowMode() bool
+}
+
+// IsInShadowMode tells whether the policy supports shadow mode and is set to it
+func IsInShadowMode(policy machinery.Policy) bool {
+	p, ok := policy.(ShadowablePolicy)
+	return ok && p.InShadowMode()
+}
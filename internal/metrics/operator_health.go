@@ -69,6 +69,23 @@ var (
 			Name: "kuadrant_exists",
 			Help: "Whether a Kuadrant CR exists in the cluster (1=exists, 0=does not exist)",
 		})
+
+	// extensionHealthy tracks whether each out-of-process extension is running and responsive.
+	// An extension restarting after a crash, crash looping or missing its liveness pings is not healthy.
+	extensionHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kuadrant_extension_healthy",
+			Help: "Whether an extension is running and responsive (1=healthy, 0=not healthy)",
+		},
+		[]string{"extension"}) // plan-policy, oidc-policy, etc.
+
+	// extensionRestarts tracks the number of times each extension was restarted by its supervisor since the operator started.
+	extensionRestarts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kuadrant_extension_restarts",
+			Help: "Number of times an extension was restarted since the operator started",
+		},
+		[]string{"extension"})
 )
 
 func init() {
@@ -79,6 +96,8 @@ func init() {
 		kuadrantReady,
 		kuadrantComponentReady,
 		kuadrantExists,
+		extensionHealthy,
+		extensionRestarts,
 	)
 }
 
@@ -133,6 +152,29 @@ func SetKuadrantExists(exists bool) {
 	kuadrantExists.Set(value)
 }
 
+// SetExtensionHealthy records whether an extension is running and responsive.
+// This should be called by the extension supervisor whenever the health of the extension changes.
+func SetExtensionHealthy(extension string, healthy bool) {
+	value := 0.0
+	if healthy {
+		value = 1.0
+	}
+	extensionHealthy.WithLabelValues(extension).Set(value)
+}
+
+// SetExtensionRestarts records the number of times an extension was restarted.
+// This should be called by the extension supervisor after each restart.
+func SetExtensionRestarts(extension string, restarts int) {
+	extensionRestarts.WithLabelValues(extension).Set(float64(restarts))
+}
+
+// DeleteExtensionMetrics removes the metrics of an extension.
+// This should be called when an extension is removed from the extensions directory.
+func DeleteExtensionMetrics(extension string) {
+	extensionHealthy.DeleteLabelValues(extension)
+	extensionRestarts.DeleteLabelValues(extension)
+}
+
 // ResetKuadrantMetrics clears all Kuadrant CR-specific metrics.
 // This should be called when no Kuadrant CR exists to prevent stale metrics
 // from remaining with the last known state.
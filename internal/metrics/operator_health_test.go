@@ -554,3 +554,43 @@ func TestMultipleComponents(t *testing.T) {
 		t.Errorf("expected limitador value 0.0, got %v", *limitadorMetric.Gauge.Value)
 	}
 }
+
+func TestSetExtensionHealth(t *testing.T) {
+	extensionHealthy.Reset()
+	extensionRestarts.Reset()
+
+	SetExtensionHealthy("plan-policy", true)
+	SetExtensionHealthy("oidc-policy", false)
+	SetExtensionRestarts("oidc-policy", 3)
+
+	tests := []struct {
+		name      string
+		gauge     *prometheus.GaugeVec
+		extension string
+		wantValue float64
+	}{
+		{name: "healthy extension", gauge: extensionHealthy, extension: "plan-policy", wantValue: 1.0},
+		{name: "unhealthy extension", gauge: extensionHealthy, extension: "oidc-policy", wantValue: 0.0},
+		{name: "restarts", gauge: extensionRestarts, extension: "oidc-policy", wantValue: 3.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := &dto.Metric{}
+			if err := tt.gauge.WithLabelValues(tt.extension).Write(metric); err != nil {
+				t.Fatalf("failed to write metric: %v", err)
+			}
+			if *metric.Gauge.Value != tt.wantValue {
+				t.Errorf("expected value %v, got %v", tt.wantValue, *metric.Gauge.Value)
+			}
+		})
+	}
+
+	DeleteExtensionMetrics("oidc-policy")
+	metrics := make(chan prometheus.Metric, 10)
+	extensionHealthy.Collect(metrics)
+	close(metrics)
+	if len(metrics) != 1 {
+		t.Errorf("expected the metrics of the deleted extension to be removed, got %d healthy metrics", len(metrics))
+	}
+}
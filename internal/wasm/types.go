@@ -304,6 +304,16 @@ type Action struct {
 	// Serialized to wasm config as "sources" for observability and debugging.
 	// Format: "kind/namespace/name"
 	SourcePolicyLocators []string `json:"sources,omitempty"`
+
+	// ReportOnly makes the data plane evaluate the action and report its decision without ever denying the request.
+	// Set for the actions built from policies in shadow mode, if the wasm-shim supports ShimFeatureReportOnly.
+	// +optional
+	ReportOnly bool `json:"reportOnly,omitempty"`
+
+	// RequestData holds the expressions evaluated in the request phase whose values the action reads in a later
+	// phase, indexed by key. Lifted to the requestData of the config.
+	// +optional
+	RequestData map[string]string `json:"-"`
 }
 
 type ConditionalData struct {
@@ -376,9 +386,11 @@ func (c *ConditionalData) EqualTo(other ConditionalData) bool {
 //   - ServiceName: String comparison
 //   - Predicates: Strict slice equality - order matters
 //   - SourcePolicyLocators: Strict slice equality - order matters
+//   - ReportOnly: Boolean comparison
 func (a *Action) EqualTo(other Action) bool {
 	if a.Scope != other.Scope ||
 		a.ServiceName != other.ServiceName ||
+		a.ReportOnly != other.ReportOnly ||
 		len(a.ConditionalData) != len(other.ConditionalData) {
 		return false
 	}
@@ -484,6 +496,7 @@ type TypedAction struct {
 	DenyWith       string        `json:"denyWith,omitempty"`
 	Target         string        `json:"target,omitempty"`
 	Headers        string        `json:"headers,omitempty"`
+	Body           string        `json:"body,omitempty"`
 	LogMessage     string        `json:"logMessage,omitempty"`
 	// SourcePolicyLocators tracks all policies that contributed to this action.
 	// Format: "kind/namespace/name"
@@ -500,6 +513,7 @@ func (t TypedAction) EqualTo(other TypedAction) bool {
 		t.DenyWith != other.DenyWith ||
 		t.Target != other.Target ||
 		t.Headers != other.Headers ||
+		t.Body != other.Body ||
 		t.LogMessage != other.LogMessage ||
 		!slices.Equal(t.SourcePolicyLocators, other.SourcePolicyLocators) ||
 		len(t.OnReply) != len(other.OnReply) {
@@ -1591,6 +1591,19 @@ func TestAction_EqualTo(t *testing.T) {
 			},
 			expected: false,
 		},
+		{
+			name: "different report only flag",
+			action1: Action{
+				ServiceName: "ratelimit-service",
+				Scope:       "default/other",
+			},
+			action2: Action{
+				ServiceName: "ratelimit-service",
+				Scope:       "default/other",
+				ReportOnly:  true,
+			},
+			expected: false,
+		},
 		{
 			name: "same predicates different order - should NOT be equal (order matters)",
 			action1: Action{
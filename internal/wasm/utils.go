@@ -35,8 +35,50 @@ const (
 	RateLimitReportServiceName = "ratelimit-report-service"
 	AuthServiceName            = "auth-service"
 	TracingServiceName         = "tracing-service"
+
+	// RequestDataFilterStatePrefix is the prefix of the filter state keys the data plane stores the values of the
+	// requestData entries of the config under, once evaluated in the request phase (see ShimFeatureRequestData).
+	RequestDataFilterStatePrefix = "wasm.kuadrant."
+)
+
+// ShimFeature is a configuration of the wasm-shim that not every build of the wasm-shim implements. The operator only
+// configures the features declared in the WASM_SHIM_FEATURES environment variable.
+type ShimFeature string
+
+const (
+	// ShimFeatureReportOnly is the reportOnly field of the actions. A wasm-shim without it ignores the field, thus
+	// enforces the action.
+	ShimFeatureReportOnly ShimFeature = "reportOnly"
+	// ShimFeatureRequestData is the requestData of the config. The wasm-shim evaluates its entries in the request
+	// phase, and stores their values as strings in the filter state, under RequestDataFilterStatePrefix followed by
+	// their key. A wasm-shim without it leaves the filter state entries read by the later phases unset.
+	ShimFeatureRequestData ShimFeature = "requestData"
+	// ShimFeatureBodyAction is the `body` type of typed actions, replacing the body of the request or the response
+	ShimFeatureBodyAction ShimFeature = "body"
+	// ShimFeatureRemoveHeadersAction is the `removeHeaders` type of typed actions
+	ShimFeatureRemoveHeadersAction ShimFeature = "removeHeaders"
+	// ShimFeatureReplaceHeadersAction is the `replaceHeaders` type of typed actions
+	ShimFeatureReplaceHeadersAction ShimFeature = "replaceHeaders"
 )
 
+// ShimSupports tells whether the wasm-shim the gateways are configured with is declared to implement a feature, in the
+// comma-separated list of the WASM_SHIM_FEATURES environment variable. Undeclared features are unsupported.
+func ShimSupports(feature ShimFeature) bool {
+	for _, declared := range strings.Split(env.GetString("WASM_SHIM_FEATURES", ""), ",") {
+		if ShimFeature(strings.TrimSpace(declared)) == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestDataValue returns the CEL expression that reads, in a later phase, the value of a requestData entry
+// evaluated in the request phase. The wasm-shim stores the values as strings, under RequestDataFilterStatePrefix
+// followed by the key of the entry.
+func RequestDataValue(key string) string {
+	return fmt.Sprintf("string(filter_state[%q])", RequestDataFilterStatePrefix+key)
+}
+
 type LogLevel int
 
 const (
@@ -225,7 +267,20 @@ func BuildConfigForActionSet(actionSets []ActionSet, logger *logr.Logger, observ
 		serviceBuilder = NewServiceBuilder(logger)
 	}
 
+	var requestData map[string]string
+	for _, actionSet := range actionSets {
+		for _, action := range actionSet.Actions {
+			for key, value := range action.RequestData {
+				if requestData == nil {
+					requestData = make(map[string]string)
+				}
+				requestData[key] = value
+			}
+		}
+	}
+
 	return Config{
+		RequestData:   requestData,
 		Services:      serviceBuilder.Build(),
 		ActionSets:    actionSets,
 		Observability: observability,
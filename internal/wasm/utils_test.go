@@ -3,8 +3,10 @@
 package wasm
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/go-logr/logr"
@@ -1667,6 +1669,60 @@ func TestBuildConfigForActionSetWithObservability(t *testing.T) {
 	}
 }
 
+func TestBuildConfigForActionSetWithRequestData(t *testing.T) {
+	logger := logr.Discard()
+	actionSets := []ActionSet{
+		{
+			Name: "test-action-set",
+			Actions: []Action{
+				{ServiceName: RateLimitServiceName, Scope: "test-scope", RequestData: map[string]string{"limit-a.reserved": `requestBodyJSON("/max_tokens")`}},
+				{ServiceName: RateLimitReportServiceName, Scope: "test-scope"},
+			},
+		},
+		{
+			Name: "other-action-set",
+			Actions: []Action{
+				{ServiceName: RateLimitServiceName, Scope: "other-scope", RequestData: map[string]string{"limit-b.reserved": "100"}},
+			},
+		},
+	}
+
+	config := BuildConfigForActionSet(actionSets, &logger, nil, nil)
+	assert.DeepEqual(t, config.RequestData, map[string]string{
+		"limit-a.reserved": `requestBodyJSON("/max_tokens")`,
+		"limit-b.reserved": "100",
+	})
+
+	// the wasm-shim reads the entries from the requestData of the config, not from the actions
+	data, err := json.Marshal(config)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(data), `"requestData":{"limit-a.reserved":"requestBodyJSON(\"/max_tokens\")","limit-b.reserved":"100"}`), string(data))
+	assert.Equal(t, strings.Count(string(data), "requestData"), 1)
+
+	config = BuildConfigForActionSet(actionSets[:1], &logger, nil, nil)
+	assert.Equal(t, RequestDataValue("limit-a.reserved"), `string(filter_state["wasm.kuadrant.limit-a.reserved"])`)
+	assert.Equal(t, len(config.RequestData), 1)
+}
+
+func TestShimSupports(t *testing.T) {
+	tests := []struct {
+		features string
+		expected bool
+	}{
+		{features: "", expected: false},
+		{features: "reportOnly", expected: true},
+		{features: "requestData, reportOnly", expected: true},
+		{features: "requestData", expected: false},
+		{features: "reportonly", expected: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.features, func(t *testing.T) {
+			t.Setenv("WASM_SHIM_FEATURES", tc.features)
+			assert.Equal(t, ShimSupports(ShimFeatureReportOnly), tc.expected)
+		})
+	}
+}
+
 func TestGRPCMethodSpecificityEncoding(t *testing.T) {
 	tests := []struct {
 		name                     string
@@ -30,7 +30,7 @@ var (
 
 // Builder constructs an ExtensionController with a fluent API similar to
 // controller-runtime's builder, adding extension specific concerns (gRPC
-// client, event cache, unix socket path).
+// client, event cache, unix socket path or remote address).
 type Builder struct {
 	name       string
 	scheme     *runtime.Scheme
@@ -94,6 +94,23 @@ func (b *Builder) Owns(obj client.Object) *Builder {
 	return b
 }
 
+// newExtensionClient connects to the operator at KUADRANT_EXTENSION_ADDRESS
+// with the certificates of KUADRANT_EXTENSION_TLS_DIR when the extension runs
+// remotely, e.g. in its own Deployment, or otherwise at the unix socket path
+// given as first argument by the operator.
+func (b *Builder) newExtensionClient() (*extensionClient, error) {
+	if address := env.GetString("KUADRANT_EXTENSION_ADDRESS", ""); address != "" {
+		tlsDir := env.GetString("KUADRANT_EXTENSION_TLS_DIR", "/etc/kuadrant-extension/tls")
+		b.logger.Info("connecting to remote extension service", "address", address)
+		return newRemoteExtensionClient(address, tlsDir)
+	}
+
+	if len(os.Args) < 2 {
+		return nil, errors.New("missing socket path argument")
+	}
+	return newExtensionClient(os.Args[1])
+}
+
 // Build validates the configuration, creates the underlying manager, gRPC
 // client and returns a ready to Start ExtensionController.
 func (b *Builder) Build() (*ExtensionController, error) {
@@ -110,13 +127,7 @@ func (b *Builder) Build() (*ExtensionController, error) {
 		return nil, fmt.Errorf("for type must be set")
 	}
 
-	// todo(adam-cattermole): we could rework this to be either unix socket path or host etc and configure appropriately
-	if len(os.Args) < 2 {
-		return nil, errors.New("missing socket path argument")
-	}
-	socketPath := os.Args[1]
-
-	extClient, err := newExtensionClient(socketPath)
+	extClient, err := b.newExtensionClient()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create extension client: %w", err)
 	}
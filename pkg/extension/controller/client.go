@@ -2,12 +2,18 @@ package controller
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"os"
+	"path/filepath"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
@@ -15,8 +21,8 @@ import (
 )
 
 // extensionClient wraps the gRPC client connection to the core extension
-// service over a unix domain socket and exposes a subset of RPCs used by the
-// controller layer.
+// service, over a unix domain socket or over TCP with mutual TLS, and exposes a
+// subset of RPCs used by the controller layer.
 type extensionClient struct {
 	conn   *grpc.ClientConn
 	client extpb.ExtensionServiceClient
@@ -44,7 +50,41 @@ func newExtensionClient(socketPath string) (*extensionClient, error) {
 	}, nil
 }
 
-//lint:ignore U1000
+// newRemoteExtensionClient dials the core extension service at address over
+// TCP with mutual TLS, authenticating with the client certificate of tlsDir
+// (tls.crt and tls.key) and verifying the operator with its CA (ca.crt).
+// The certificates are read on each handshake, so that rotated ones are used.
+func newRemoteExtensionClient(address string, tlsDir string) (*extensionClient, error) {
+	caFile := filepath.Join(tlsDir, "ca.crt")
+	ca, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("%s: no certificate found", caFile)
+	}
+
+	tlsConfig := &tls.Config{
+		RootCAs:    rootCAs,
+		MinVersion: tls.VersionTLS13,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(filepath.Join(tlsDir, "tls.crt"), filepath.Join(tlsDir, "tls.key"))
+			return &cert, err
+		},
+	}
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &extensionClient{
+		conn:   conn,
+		client: extpb.NewExtensionServiceClient(conn),
+	}, nil
+}
+
 func (ec *extensionClient) ping(ctx context.Context) (*extpb.PongResponse, error) {
 	return ec.client.Ping(ctx, &extpb.PingRequest{
 		Out: timestamppb.New(time.Now()),
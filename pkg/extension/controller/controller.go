@@ -35,6 +35,10 @@ const (
 	// cleanup (e.g. mutator/subscription deregistration) can occur prior to
 	// object deletion.
 	ExtensionFinalizer = "kuadrant.io/extensions"
+
+	// heartbeatInterval is how often the extension calls Ping, so that the
+	// operator can restart it if it stops responding.
+	heartbeatInterval = 10 * time.Second
 )
 
 // ExtensionConfig captures the immutable configuration for a controller
@@ -79,6 +83,10 @@ func (ec *ExtensionController) Start(ctx context.Context) error {
 	channelSource := ctrlruntimesrc.Channel(reconcileChan, &ctrlruntimehandler.EnqueueRequestForObject{})
 	watchSources := append(ec.config.WatchSources, channelSource)
 
+	if ec.extensionClient != nil {
+		go ec.heartbeat(ctx)
+	}
+
 	if ec.manager != nil {
 		ctrl, err := ctrlruntimectrl.New(ec.config.Name, ec.manager, ctrlruntimectrl.Options{Reconciler: ec})
 		if err != nil {
@@ -111,6 +119,25 @@ func (ec *ExtensionController) Start(ctx context.Context) error {
 	return nil
 }
 
+// heartbeat calls Ping periodically until the context is done, so the
+// supervisor of the extension in the operator can tell it is alive.
+func (ec *ExtensionController) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, heartbeatInterval)
+			if _, err := ec.extensionClient.ping(pingCtx); err != nil {
+				ec.logger.Error(err, "ping failed")
+			}
+			cancel()
+		}
+	}
+}
+
 // Subscribe opens a long‑lived gRPC stream for events related to the policy
 // kind and enqueues reconcile requests for received events.
 func (ec *ExtensionController) Subscribe(ctx context.Context, reconcileChan chan ctrlruntimeevent.GenericEvent) {
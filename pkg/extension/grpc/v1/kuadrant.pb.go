@@ -78,11 +78,14 @@ func (Domain) EnumDescriptor() ([]byte, []int) {
 type ActionType int32
 
 const (
-	ActionType_ACTION_TYPE_UNSPECIFIED ActionType = 0
-	ActionType_ACTION_TYPE_GRPC_METHOD ActionType = 1
-	ActionType_ACTION_TYPE_DENY        ActionType = 2
-	ActionType_ACTION_TYPE_ADD_HEADERS ActionType = 3
-	ActionType_ACTION_TYPE_FAIL        ActionType = 4
+	ActionType_ACTION_TYPE_UNSPECIFIED     ActionType = 0
+	ActionType_ACTION_TYPE_GRPC_METHOD     ActionType = 1
+	ActionType_ACTION_TYPE_DENY            ActionType = 2
+	ActionType_ACTION_TYPE_ADD_HEADERS     ActionType = 3
+	ActionType_ACTION_TYPE_FAIL            ActionType = 4
+	ActionType_ACTION_TYPE_REPLACE_BODY    ActionType = 5
+	ActionType_ACTION_TYPE_REMOVE_HEADERS  ActionType = 6
+	ActionType_ACTION_TYPE_REPLACE_HEADERS ActionType = 7
 )
 
 // Enum value maps for ActionType.
@@ -93,13 +96,19 @@ var (
 		2: "ACTION_TYPE_DENY",
 		3: "ACTION_TYPE_ADD_HEADERS",
 		4: "ACTION_TYPE_FAIL",
+		5: "ACTION_TYPE_REPLACE_BODY",
+		6: "ACTION_TYPE_REMOVE_HEADERS",
+		7: "ACTION_TYPE_REPLACE_HEADERS",
 	}
 	ActionType_value = map[string]int32{
-		"ACTION_TYPE_UNSPECIFIED": 0,
-		"ACTION_TYPE_GRPC_METHOD": 1,
-		"ACTION_TYPE_DENY":        2,
-		"ACTION_TYPE_ADD_HEADERS": 3,
-		"ACTION_TYPE_FAIL":        4,
+		"ACTION_TYPE_UNSPECIFIED":     0,
+		"ACTION_TYPE_GRPC_METHOD":     1,
+		"ACTION_TYPE_DENY":            2,
+		"ACTION_TYPE_ADD_HEADERS":     3,
+		"ACTION_TYPE_FAIL":            4,
+		"ACTION_TYPE_REPLACE_BODY":    5,
+		"ACTION_TYPE_REMOVE_HEADERS":  6,
+		"ACTION_TYPE_REPLACE_HEADERS": 7,
 	}
 )
 
@@ -716,19 +725,22 @@ func (x *RegisterActionMethodRequest) GetMessageTemplate() string {
 
 // ActionEntry represents a single action in either the request or response phase.
 type ActionEntry struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	ActionType    ActionType             `protobuf:"varint,1,opt,name=action_type,json=actionType,proto3,enum=kuadrant.v1.ActionType" json:"action_type,omitempty"`
-	Predicate     string                 `protobuf:"bytes,2,opt,name=predicate,proto3" json:"predicate,omitempty"`                             // CEL predicate — if false, skip this action
-	Phase         string                 `protobuf:"bytes,3,opt,name=phase,proto3" json:"phase,omitempty"`                                     // "request" or "response"
-	Method        string                 `protobuf:"bytes,4,opt,name=method,proto3" json:"method,omitempty"`                                   // Name of a registered ActionMethod (for grpc_method type)
-	Var           string                 `protobuf:"bytes,5,opt,name=var,proto3" json:"var,omitempty"`                                         // Variable name to store gRPC response (for grpc_method type)
-	WithStatus    int32                  `protobuf:"varint,6,opt,name=with_status,json=withStatus,proto3" json:"with_status,omitempty"`        // HTTP status code (for deny type); 0 means unset
-	WithHeaders   string                 `protobuf:"bytes,9,opt,name=with_headers,json=withHeaders,proto3" json:"with_headers,omitempty"`      // CEL expression — array of [name, value] pairs (for deny type)
-	WithBody      string                 `protobuf:"bytes,10,opt,name=with_body,json=withBody,proto3" json:"with_body,omitempty"`              // Response body string (for deny type)
-	HeadersToAdd  string                 `protobuf:"bytes,7,opt,name=headers_to_add,json=headersToAdd,proto3" json:"headers_to_add,omitempty"` // CEL expression evaluating to a map of headers (for add_headers type)
-	LogMessage    string                 `protobuf:"bytes,8,opt,name=log_message,json=logMessage,proto3" json:"log_message,omitempty"`         // Error message to log (for fail type)
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	ActionType       ActionType             `protobuf:"varint,1,opt,name=action_type,json=actionType,proto3,enum=kuadrant.v1.ActionType" json:"action_type,omitempty"`
+	Predicate        string                 `protobuf:"bytes,2,opt,name=predicate,proto3" json:"predicate,omitempty"`                                          // CEL predicate — if false, skip this action
+	Phase            string                 `protobuf:"bytes,3,opt,name=phase,proto3" json:"phase,omitempty"`                                                  // "request" or "response"
+	Method           string                 `protobuf:"bytes,4,opt,name=method,proto3" json:"method,omitempty"`                                                // Name of a registered ActionMethod (for grpc_method type)
+	Var              string                 `protobuf:"bytes,5,opt,name=var,proto3" json:"var,omitempty"`                                                      // Variable name to store gRPC response (for grpc_method type)
+	WithStatus       int32                  `protobuf:"varint,6,opt,name=with_status,json=withStatus,proto3" json:"with_status,omitempty"`                     // HTTP status code (for deny type); 0 means unset
+	WithHeaders      string                 `protobuf:"bytes,9,opt,name=with_headers,json=withHeaders,proto3" json:"with_headers,omitempty"`                   // CEL expression — array of [name, value] pairs (for deny type)
+	WithBody         string                 `protobuf:"bytes,10,opt,name=with_body,json=withBody,proto3" json:"with_body,omitempty"`                           // Response body string (for deny type)
+	HeadersToAdd     string                 `protobuf:"bytes,7,opt,name=headers_to_add,json=headersToAdd,proto3" json:"headers_to_add,omitempty"`              // CEL expression evaluating to a map of headers (for add_headers type)
+	LogMessage       string                 `protobuf:"bytes,8,opt,name=log_message,json=logMessage,proto3" json:"log_message,omitempty"`                      // Error message to log (for fail type)
+	Body             string                 `protobuf:"bytes,11,opt,name=body,proto3" json:"body,omitempty"`                                                   // CEL expression evaluating to the new body (for replace_body type)
+	HeadersToRemove  string                 `protobuf:"bytes,12,opt,name=headers_to_remove,json=headersToRemove,proto3" json:"headers_to_remove,omitempty"`    // CEL expression evaluating to a list of header names (for remove_headers type)
+	HeadersToReplace string                 `protobuf:"bytes,13,opt,name=headers_to_replace,json=headersToReplace,proto3" json:"headers_to_replace,omitempty"` // CEL expression evaluating to a map of headers (for replace_headers type)
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
 }
 
 func (x *ActionEntry) Reset() {
@@ -831,6 +843,27 @@ func (x *ActionEntry) GetLogMessage() string {
 	return ""
 }
 
+func (x *ActionEntry) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *ActionEntry) GetHeadersToRemove() string {
+	if x != nil {
+		return x.HeadersToRemove
+	}
+	return ""
+}
+
+func (x *ActionEntry) GetHeadersToReplace() string {
+	if x != nil {
+		return x.HeadersToReplace
+	}
+	return ""
+}
+
 // PipelineCommitRequest atomically replaces all pipeline actions for a policy.
 type PipelineCommitRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -928,7 +961,7 @@ const file_v1_kuadrant_proto_rawDesc = "" +
 	"\aservice\x18\x03 \x01(\tR\aservice\x12\x16\n" +
 	"\x06method\x18\x04 \x01(\tR\x06method\x12\x12\n" +
 	"\x04name\x18\x05 \x01(\tR\x04name\x12)\n" +
-	"\x10message_template\x18\x06 \x01(\tR\x0fmessageTemplate\"\xcd\x02\n" +
+	"\x10message_template\x18\x06 \x01(\tR\x0fmessageTemplate\"\xbb\x03\n" +
 	"\vActionEntry\x128\n" +
 	"\vaction_type\x18\x01 \x01(\x0e2\x17.kuadrant.v1.ActionTypeR\n" +
 	"actionType\x12\x1c\n" +
@@ -943,21 +976,27 @@ const file_v1_kuadrant_proto_rawDesc = "" +
 	" \x01(\tR\bwithBody\x12$\n" +
 	"\x0eheaders_to_add\x18\a \x01(\tR\fheadersToAdd\x12\x1f\n" +
 	"\vlog_message\x18\b \x01(\tR\n" +
-	"logMessage\"x\n" +
+	"logMessage\x12\x12\n" +
+	"\x04body\x18\v \x01(\tR\x04body\x12*\n" +
+	"\x11headers_to_remove\x18\f \x01(\tR\x0fheadersToRemove\x12,\n" +
+	"\x12headers_to_replace\x18\r \x01(\tR\x10headersToReplace\"x\n" +
 	"\x15PipelineCommitRequest\x12+\n" +
 	"\x06policy\x18\x01 \x01(\v2\x13.kuadrant.v1.PolicyR\x06policy\x122\n" +
 	"\aactions\x18\x02 \x03(\v2\x18.kuadrant.v1.ActionEntryR\aactions*E\n" +
 	"\x06Domain\x12\x16\n" +
 	"\x12DOMAIN_UNSPECIFIED\x10\x00\x12\x0f\n" +
 	"\vDOMAIN_AUTH\x10\x01\x12\x12\n" +
-	"\x0eDOMAIN_REQUEST\x10\x02*\x8f\x01\n" +
+	"\x0eDOMAIN_REQUEST\x10\x02*\xee\x01\n" +
 	"\n" +
 	"ActionType\x12\x1b\n" +
 	"\x17ACTION_TYPE_UNSPECIFIED\x10\x00\x12\x1b\n" +
 	"\x17ACTION_TYPE_GRPC_METHOD\x10\x01\x12\x14\n" +
 	"\x10ACTION_TYPE_DENY\x10\x02\x12\x1b\n" +
 	"\x17ACTION_TYPE_ADD_HEADERS\x10\x03\x12\x14\n" +
-	"\x10ACTION_TYPE_FAIL\x10\x042\xbb\x04\n" +
+	"\x10ACTION_TYPE_FAIL\x10\x04\x12\x1c\n" +
+	"\x18ACTION_TYPE_REPLACE_BODY\x10\x05\x12\x1e\n" +
+	"\x1aACTION_TYPE_REMOVE_HEADERS\x10\x06\x12\x1f\n" +
+	"\x1bACTION_TYPE_REPLACE_HEADERS\x10\a2\xbb\x04\n" +
 	"\x10ExtensionService\x12=\n" +
 	"\x04Ping\x12\x18.kuadrant.v1.PingRequest\x1a\x19.kuadrant.v1.PongResponse\"\x00\x12N\n" +
 	"\tSubscribe\x12\x1d.kuadrant.v1.SubscribeRequest\x1a\x1e.kuadrant.v1.SubscribeResponse\"\x000\x01\x12F\n" +
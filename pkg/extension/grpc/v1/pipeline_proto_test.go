@@ -33,6 +33,9 @@ func TestActionType_EnumValues(t *testing.T) {
 		{"deny", ActionType_ACTION_TYPE_DENY, "ACTION_TYPE_DENY"},
 		{"add_headers", ActionType_ACTION_TYPE_ADD_HEADERS, "ACTION_TYPE_ADD_HEADERS"},
 		{"fail", ActionType_ACTION_TYPE_FAIL, "ACTION_TYPE_FAIL"},
+		{"replace_body", ActionType_ACTION_TYPE_REPLACE_BODY, "ACTION_TYPE_REPLACE_BODY"},
+		{"remove_headers", ActionType_ACTION_TYPE_REMOVE_HEADERS, "ACTION_TYPE_REMOVE_HEADERS"},
+		{"replace_headers", ActionType_ACTION_TYPE_REPLACE_HEADERS, "ACTION_TYPE_REPLACE_HEADERS"},
 	}
 
 	for _, tt := range tests {
@@ -14,6 +14,10 @@ const (
 	ActionTypeDeny       ActionType = "deny"
 	ActionTypeFail       ActionType = "fail"
 	ActionTypeAddHeaders ActionType = "add_headers"
+
+	ActionTypeReplaceBody    ActionType = "replace_body"
+	ActionTypeRemoveHeaders  ActionType = "remove_headers"
+	ActionTypeReplaceHeaders ActionType = "replace_headers"
 )
 
 // Action is the interface implemented by all pipeline action types.
@@ -136,6 +140,97 @@ func (a AddHeadersAction) PopulateProtobuf(entry *extpb.ActionEntry) {
 	entry.HeadersToAdd = a.HeadersToAdd
 }
 
+// ReplaceBodyAction replaces the body of the request or response depending
+// on the phase in which it is used, when the predicate evaluates to true.
+// The body expression can read the response of a preceding GRPCMethodAction
+// through its variable, e.g. `redacted.body`.
+// Rejected on commit unless the operator declares the `body` feature of the
+// wasm-shim in WASM_SHIM_FEATURES.
+//
+// Phase semantics:
+//   - Request phase: body replaced before the request reaches the backend
+//   - Response phase: body replaced before the response reaches the client
+type ReplaceBodyAction struct {
+	Predicate string // CEL — if true, replace the body
+	Body      string // CEL expression evaluating to the new body
+}
+
+func (a ReplaceBodyAction) actionType() ActionType { return ActionTypeReplaceBody }
+
+func (a ReplaceBodyAction) CelExpressions() []string {
+	var exprs []string
+	if a.Predicate != "" {
+		exprs = append(exprs, a.Predicate)
+	}
+	if a.Body != "" {
+		exprs = append(exprs, a.Body)
+	}
+	return exprs
+}
+
+func (a ReplaceBodyAction) PopulateProtobuf(entry *extpb.ActionEntry) {
+	entry.ActionType = extpb.ActionType_ACTION_TYPE_REPLACE_BODY
+	entry.Predicate = a.Predicate
+	entry.Body = a.Body
+}
+
+// RemoveHeadersAction removes headers from the request or response depending
+// on the phase in which it is used, when the predicate evaluates to true.
+// Rejected on commit unless the operator declares the `removeHeaders` feature
+// of the wasm-shim in WASM_SHIM_FEATURES.
+type RemoveHeadersAction struct {
+	Predicate       string // CEL — if true, remove the headers
+	HeadersToRemove string // CEL expression evaluating to a list of header names
+}
+
+func (a RemoveHeadersAction) actionType() ActionType { return ActionTypeRemoveHeaders }
+
+func (a RemoveHeadersAction) CelExpressions() []string {
+	var exprs []string
+	if a.Predicate != "" {
+		exprs = append(exprs, a.Predicate)
+	}
+	if a.HeadersToRemove != "" {
+		exprs = append(exprs, a.HeadersToRemove)
+	}
+	return exprs
+}
+
+func (a RemoveHeadersAction) PopulateProtobuf(entry *extpb.ActionEntry) {
+	entry.ActionType = extpb.ActionType_ACTION_TYPE_REMOVE_HEADERS
+	entry.Predicate = a.Predicate
+	entry.HeadersToRemove = a.HeadersToRemove
+}
+
+// ReplaceHeadersAction sets headers of the request or response depending on
+// the phase in which it is used, when the predicate evaluates to true.
+// Unlike AddHeadersAction, existing values of the headers are overwritten.
+// Rejected on commit unless the operator declares the `replaceHeaders` feature
+// of the wasm-shim in WASM_SHIM_FEATURES.
+type ReplaceHeadersAction struct {
+	Predicate        string // CEL — if true, replace the headers
+	HeadersToReplace string // CEL expression evaluating to a map of headers
+}
+
+func (a ReplaceHeadersAction) actionType() ActionType { return ActionTypeReplaceHeaders }
+
+func (a ReplaceHeadersAction) CelExpressions() []string {
+	var exprs []string
+	if a.Predicate != "" {
+		exprs = append(exprs, a.Predicate)
+	}
+	if a.HeadersToReplace != "" {
+		exprs = append(exprs, a.HeadersToReplace)
+	}
+	return exprs
+}
+
+func (a ReplaceHeadersAction) PopulateProtobuf(entry *extpb.ActionEntry) {
+	entry.ActionType = extpb.ActionType_ACTION_TYPE_REPLACE_HEADERS
+	entry.Predicate = a.Predicate
+	entry.HeadersToReplace = a.HeadersToReplace
+}
+
 // Pipeline provides a builder for composing ordered actions on HTTP request
 // and response phases. Actions accumulate locally with immediate ordering
 // validation. Commit sends all actions atomically to the operator.
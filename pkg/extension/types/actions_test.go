@@ -20,6 +20,18 @@ func TestAddHeadersAction_ImplementsAction(t *testing.T) {
 	var _ Action = AddHeadersAction{}
 }
 
+func TestReplaceBodyAction_ImplementsAction(t *testing.T) {
+	var _ Action = ReplaceBodyAction{}
+}
+
+func TestRemoveHeadersAction_ImplementsAction(t *testing.T) {
+	var _ Action = RemoveHeadersAction{}
+}
+
+func TestReplaceHeadersAction_ImplementsAction(t *testing.T) {
+	var _ Action = ReplaceHeadersAction{}
+}
+
 func TestGRPCMethodAction_ActionType(t *testing.T) {
 	a := GRPCMethodAction{
 		Predicate: "request.headers['check'] == '1'",
@@ -61,3 +73,33 @@ func TestAddHeadersAction_ActionType(t *testing.T) {
 		t.Errorf("actionType() = %q, want %q", a.actionType(), ActionTypeAddHeaders)
 	}
 }
+
+func TestReplaceBodyAction_ActionType(t *testing.T) {
+	a := ReplaceBodyAction{
+		Predicate: "redactResponse.redacted",
+		Body:      "redactResponse.body",
+	}
+	if a.actionType() != ActionTypeReplaceBody {
+		t.Errorf("actionType() = %q, want %q", a.actionType(), ActionTypeReplaceBody)
+	}
+}
+
+func TestRemoveHeadersAction_ActionType(t *testing.T) {
+	a := RemoveHeadersAction{
+		Predicate:       "true",
+		HeadersToRemove: `["server", "x-powered-by"]`,
+	}
+	if a.actionType() != ActionTypeRemoveHeaders {
+		t.Errorf("actionType() = %q, want %q", a.actionType(), ActionTypeRemoveHeaders)
+	}
+}
+
+func TestReplaceHeadersAction_ActionType(t *testing.T) {
+	a := ReplaceHeadersAction{
+		Predicate:        "true",
+		HeadersToReplace: `{"cache-control": "no-store"}`,
+	}
+	if a.actionType() != ActionTypeReplaceHeaders {
+		t.Errorf("actionType() = %q, want %q", a.actionType(), ActionTypeReplaceHeaders)
+	}
+}